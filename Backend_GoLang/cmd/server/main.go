@@ -1,7 +1,14 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	docs "crud_api_us/docs"
 	"crud_api_us/internal/router"
@@ -12,6 +19,10 @@ import (
 // @description     CRUD người dùng mẫu, sạch và tối giản.
 // @BasePath        /api/v1
 // @schemes         http https
+// @securityDefinitions.apikey BearerAuth
+// @in                          header
+// @name                        Authorization
+// @description                 Nhập dạng: Bearer <JWT>
 // (NÊN bỏ @host để Swagger tự dùng host hiện tại, hoặc để rỗng qua runtime)
 
 func main() {
@@ -32,10 +43,28 @@ func main() {
 	}
 	docs.SwaggerInfo.BasePath = "/api/v1"
 
-	r := router.New()
+	r, jobs, stopKeys := router.New()
+
+	// SIGINT/SIGTERM (Ctrl+C, docker stop, ...) => ngừng nhận request mới, chờ request & job đang chạy xong
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	jobs.Start(ctx)
 
 	// Bind đúng cổng
-	if err := r.Run(":" + port); err != nil {
-		panic(err)
+	srv := &http.Server{Addr: ":" + port, Handler: r}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			panic(err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("shutting down...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("server shutdown: %v", err)
 	}
+	jobs.Stop()
+	stopKeys()
 }
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"crud_api_us/internal/models"
+	"crud_api_us/internal/repository"
+	"crud_api_us/internal/services"
+)
+
+type AuditHandler struct{ svc *services.AuditService }
+
+func NewAuditHandler(repos repository.Repos) *AuditHandler {
+	return &AuditHandler{svc: services.NewAuditService(repos.Audit)}
+}
+
+// ListAuditQuery: query string của GET /admin/audit
+type ListAuditQuery struct {
+	Page     int  `form:"page"      binding:"omitempty,min=1"`
+	Limit    int  `form:"limit"     binding:"omitempty,min=1,max=100"`
+	ActorID  *int `form:"actor_id"  binding:"omitempty,min=1"`
+	TargetID *int `form:"target_id" binding:"omitempty,min=1"`
+	// target_id kèm target_type; bỏ trống target_type khi lọc theo target_id => user
+	TargetType string `form:"target_type" binding:"omitempty,oneof=user role"`
+	Action     string `form:"action"    binding:"omitempty,max=50"`
+	From       string `form:"from"` // yyyy-mm-dd hoặc RFC3339
+	To         string `form:"to"`   // yyyy-mm-dd (tính hết ngày) hoặc RFC3339
+}
+
+/************ DTO (docs/response) ************/
+type AuditEventDoc struct {
+	ID         int                             `json:"id"`
+	ActorID    *int                            `json:"actor_id,omitempty"`
+	Action     string                          `json:"action" example:"user.update"`
+	TargetType string                          `json:"target_type" example:"user" enums:"user,role"`
+	TargetID   int                             `json:"target_id"`
+	Changes    map[string]services.AuditChange `json:"changes,omitempty"`
+	IP         string                          `json:"ip"`
+	RequestID  string                          `json:"request_id"`
+	CreatedAt  string                          `json:"created_at"`
+}
+
+type AuditEventPage struct {
+	Items []AuditEventDoc `json:"items"`
+	Total int64           `json:"total"`
+	Page  int             `json:"page"`
+	Limit int             `json:"limit"`
+}
+
+// ListAudit godoc
+// @Summary      Nhật ký audit các thay đổi user và role của admin
+// @Tags         Admin
+// @Security     BearerAuth
+// @Produce      json
+// @Param        page       query    int     false  "Trang (mặc định 1)"
+// @Param        limit      query    int     false  "Số dòng/trang (mặc định 20, tối đa 100)"
+// @Param        actor_id   query    int     false  "Lọc theo người thực hiện"
+// @Param        target_id  query    int     false  "Lọc theo đối tượng bị thay đổi (user, hoặc role khi target_type=role)"
+// @Param        target_type query   string  false  "Loại đối tượng (mặc định user khi có target_id)"  Enums(user, role)
+// @Param        action     query    string  false  "Lọc theo hành động"  Enums(user.create, user.update, user.delete, user.restore, user.purge, role.create, role.update, role.delete)
+// @Param        from       query    string  false  "Từ thời điểm (yyyy-mm-dd hoặc RFC3339)"
+// @Param        to         query    string  false  "Đến thời điểm (yyyy-mm-dd hoặc RFC3339)"
+// @Success      200  {object} AuditEventPage
+// @Failure      400  {object} ErrorResponse
+// @Router       /admin/audit [get]
+func (h *AuditHandler) ListAudit(c *gin.Context) {
+	var in ListAuditQuery
+	if err := c.ShouldBindQuery(&in); err != nil {
+		writeErr(c, http.StatusBadRequest, "invalid query")
+		return
+	}
+	page, limit := max(in.Page, 1), in.Limit
+	if limit == 0 {
+		limit = 20
+	}
+	f := services.AuditFilter{ActorID: in.ActorID, TargetID: in.TargetID, TargetType: in.TargetType, Action: in.Action}
+	if f.TargetID != nil && f.TargetType == "" {
+		f.TargetType = models.AuditTargetUser
+	}
+	var err error
+	if f.From, err = parseTimeParam(in.From, false); err != nil {
+		writeErr(c, http.StatusBadRequest, "invalid from")
+		return
+	}
+	if f.To, err = parseTimeParam(in.To, true); err != nil {
+		writeErr(c, http.StatusBadRequest, "invalid to")
+		return
+	}
+
+	items, total, err := h.svc.List(f, page, limit)
+	if err != nil {
+		writeErr(c, http.StatusInternalServerError, "server error")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items, "total": total, "page": page, "limit": limit})
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"crud_api_us/internal/models"
+	"crud_api_us/internal/repository"
+	"crud_api_us/internal/services"
+)
+
+type auditPage struct {
+	Items []struct {
+		models.AuditEvent
+		Changes map[string]services.AuditChange `json:"changes"`
+	}
+	Total int64
+}
+
+// create/update/delete qua API => 1 audit/thay đổi kèm actor, IP, request id, diff từng field (không có hash mật khẩu)
+func TestAuditTrail(t *testing.T) {
+	db := newTestDB(t)
+	repos := repository.NewMySQLRepos(db)
+	us := seedUsers(t, db,
+		models.User{Username: "admin", Email: "admin@x.com", Role: models.RoleAdmin},
+		models.User{Username: "admin2", Email: "admin2@x.com", Role: models.RoleAdmin},
+	)
+	admin, admin2 := us[0], us[1]
+	h := NewUserHandler(repos, nil)
+	a := NewAuditHandler(repos)
+	route := func(u models.User, requestID string) *gin.Engine {
+		r := gin.New()
+		r.Use(asUser(u), func(c *gin.Context) { c.Set("request_id", requestID) })
+		r.POST("/users", h.CreateUser)
+		r.PATCH("/users/:id", h.PatchUser)
+		r.DELETE("/users/:id", h.DeleteUser)
+		r.GET("/audit", a.ListAudit)
+		return r
+	}
+
+	w := serve(route(admin, "req-1"), "POST", "/users", `{"username":"bob","email":"bob@x.com","password":"secret1","city":"Hue"}`)
+	if w.Code != 201 {
+		t.Fatalf("create: %d %s", w.Code, w.Body)
+	}
+	var bob models.User
+	_ = json.Unmarshal(w.Body.Bytes(), &bob)
+	bobPath := "/users/" + strconv.Itoa(bob.ID)
+	steps := []struct {
+		as           models.User
+		requestID    string
+		method, body string
+		code         int
+	}{
+		{admin2, "req-2", "PATCH", `{"city":"Hanoi","password":"secret2"}`, 200},
+		{admin2, "req-3", "PATCH", `{"city":"Hanoi"}`, 200}, // không đổi gì => không ghi audit
+		{admin, "req-4", "PATCH", `{"email":"admin2@x.com"}`, 409},
+		{admin, "req-5", "DELETE", ``, 204},
+	}
+	for _, s := range steps {
+		if w := serve(route(s.as, s.requestID), s.method, bobPath, s.body); w.Code != s.code {
+			t.Fatalf("%s %s: %d %s", s.requestID, s.method, w.Code, w.Body)
+		}
+	}
+
+	list := func(query string) auditPage {
+		t.Helper()
+		w := serve(route(admin, "req-q"), "GET", "/audit"+query, "")
+		if w.Code != 200 {
+			t.Fatalf("GET /audit%s: %d %s", query, w.Code, w.Body)
+		}
+		var p auditPage
+		if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	all := list("?target_id=" + strconv.Itoa(bob.ID))
+	if all.Total != 3 {
+		t.Fatalf("audit total = %d, want 3: %+v", all.Total, all.Items)
+	}
+	// mới nhất trước
+	del, upd, crt := all.Items[0], all.Items[1], all.Items[2]
+	for _, tt := range []struct {
+		e                 models.AuditEvent
+		action, requestID string
+		actor             int
+	}{
+		{crt.AuditEvent, models.AuditUserCreate, "req-1", admin.ID},
+		{upd.AuditEvent, models.AuditUserUpdate, "req-2", admin2.ID},
+		{del.AuditEvent, models.AuditUserDelete, "req-5", admin.ID},
+	} {
+		e := tt.e
+		if e.Action != tt.action || e.RequestID != tt.requestID || e.ActorID == nil || *e.ActorID != tt.actor ||
+			e.TargetType != models.AuditTargetUser || e.IP != "192.0.2.1" {
+			t.Errorf("event = %+v, want %s by %d (%s)", e, tt.action, tt.actor, tt.requestID)
+		}
+	}
+	if c := crt.Changes; c["username"].After != "bob" || c["city"].After != "Hue" || c["phone"] != (services.AuditChange{}) {
+		t.Errorf("create diff = %v", c)
+	}
+	want := map[string]services.AuditChange{
+		"city":     {Before: "Hue", After: "Hanoi"},
+		"password": {Before: "[redacted]", After: "[redacted]"},
+	}
+	if len(upd.Changes) != len(want) || upd.Changes["city"] != want["city"] || upd.Changes["password"] != want["password"] {
+		t.Errorf("update diff = %v, want %v", upd.Changes, want)
+	}
+	for _, e := range all.Items {
+		if _, ok := e.Changes["password_hash"]; ok {
+			t.Errorf("%s leaks password_hash", e.Action)
+		}
+	}
+
+	// bộ lọc
+	tomorrow := time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+	for query, n := range map[string]int64{
+		"?actor_id=" + strconv.Itoa(admin2.ID):                     1,
+		"?action=user.delete":                                      1,
+		"?action=user.update&actor_id=" + strconv.Itoa(admin.ID):   0,
+		"?target_id=" + strconv.Itoa(bob.ID) + "&target_type=role": 0,
+		"?from=" + tomorrow:                                        0,
+		"?to=" + time.Now().Format("2006-01-02"):                   3,
+	} {
+		if p := list(query); p.Total != n || len(p.Items) != int(n) {
+			t.Errorf("%s: total %d (%d items), want %d", query, p.Total, len(p.Items), n)
+		}
+	}
+	for _, query := range []string{"?from=yesterday", "?target_type=group", "?actor_id=0", "?limit=500"} {
+		if w := serve(route(admin, "req-q"), "GET", "/audit"+query, ""); w.Code != 400 {
+			t.Errorf("%s: status %d, want 400", query, w.Code)
+		}
+	}
+}
+
+// audit được ghi trong cùng transaction với thay đổi: ghi audit lỗi => thay đổi bị rollback
+func TestAuditSameTransaction(t *testing.T) {
+	db := newTestDB(t)
+	repos := repository.NewMySQLRepos(db)
+	us := seedUsers(t, db,
+		models.User{Username: "admin", Email: "admin@x.com", Role: models.RoleAdmin},
+		models.User{Username: "bob", Email: "bob@x.com", Role: models.RoleUser},
+	)
+	h := NewUserHandler(repos, nil)
+	r := gin.New()
+	r.Use(asUser(us[0]))
+	r.PATCH("/users/:id", h.PatchUser)
+	r.DELETE("/users/:id", h.DeleteUser)
+
+	if err := db.Migrator().DropTable(&models.AuditEvent{}); err != nil {
+		t.Fatal(err)
+	}
+	path := "/users/" + strconv.Itoa(us[1].ID)
+	if w := serve(r, "PATCH", path, `{"full_name":"Bob"}`); w.Code != 500 {
+		t.Errorf("patch without audit table: %d, want 500", w.Code)
+	}
+	if w := serve(r, "DELETE", path, ``); w.Code != 500 {
+		t.Errorf("delete without audit table: %d, want 500", w.Code)
+	}
+	var bob models.User
+	if err := db.First(&bob, us[1].ID).Error; err != nil || bob.FullName != "" || bob.Version != 1 {
+		t.Errorf("change committed without audit: %+v, %v", bob, err)
+	}
+}
@@ -1,29 +1,47 @@
 package handlers
 
 import (
+	"log"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 
+	"crud_api_us/internal/mailer"
+	"crud_api_us/internal/models"
 	"crud_api_us/internal/repository"
 	"crud_api_us/internal/services"
+	"crud_api_us/internal/tokens"
 )
 
 /************ Handler ************/
 type AuthHandler struct {
-	users *UserHandler // dùng lại service tạo user
-	auth  *services.AuthService
-	cfg   services.JWTConfig
+	users    *UserHandler // dùng lại service tạo user
+	auth     *services.AuthService
+	account  *services.AccountService
+	mfa      *services.MFAService
+	passkeys *services.PasskeyService
+	sessions *services.SessionService
+	tokens   *tokens.Manager
+	cfg      services.JWTConfig
 }
 
-func NewAuthHandler(userRepo repository.UserRepository, authRepo repository.AuthRepository, cfg services.JWTConfig) *AuthHandler {
+// users: handler quản lý user (dùng chung service và policy ABAC cho các API /admin/users/:id/*)
+func NewAuthHandler(repos repository.Repos, users *UserHandler, cfg services.JWTConfig, tm *tokens.Manager, acc services.AccountConfig, mfaCfg services.MFAConfig,
+	passkeys *services.PasskeyService, guard *services.LoginGuard, mail mailer.Mailer) *AuthHandler {
+	mfa := services.NewMFAService(repos, mfaCfg)
 	return &AuthHandler{
-		users: NewUserHandler(userRepo),
-		auth:  services.NewAuthService(userRepo, authRepo, cfg),
-		cfg:   cfg,
+		users:    users,
+		auth:     services.NewAuthService(repos, tm, mfa, passkeys, guard, cfg),
+		account:  services.NewAccountService(repos, mail, acc),
+		mfa:      mfa,
+		passkeys: passkeys,
+		sessions: services.NewSessionService(repos),
+		tokens:   tm,
+		cfg:      cfg,
 	}
 }
 
@@ -34,6 +52,25 @@ type RegisterRequest struct {
 	ConfirmPassword string `json:"confirm_password" binding:"required,eqfield=Password"`
 }
 
+// ChangePasswordRequest: new_password dùng chung rule password của UpdateUserRequest
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password"     binding:"required"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token"        binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
 type LoginRequest struct {
 	Identifier string `json:"identifier" binding:"required"`              // username hoặc email
 	Password   string `json:"password"  binding:"required,min=6,max=100"` // mật khẩu
@@ -44,6 +81,7 @@ type LoginRequest struct {
 
 type ErrorResponse struct {
 	Error string `json:"error"`
+	Code  string `json:"code,omitempty"` // mã lỗi chi tiết (vd: account_banned)
 }
 
 type UserDoc struct {
@@ -62,8 +100,11 @@ type UserDoc struct {
 	Country     string  `json:"country,omitempty"`
 	PostalCode  string  `json:"postal_code,omitempty"`
 	Status      string  `json:"status,omitempty"`
-	CreatedAt   string  `json:"created_at,omitempty"`
-	UpdatedAt   string  `json:"updated_at,omitempty"`
+	LastLoginAt *string `json:"last_login_at,omitempty"`
+	// null = chưa xác thực email
+	EmailVerifiedAt *string `json:"email_verified_at"`
+	CreatedAt       string  `json:"created_at,omitempty"`
+	UpdatedAt       string  `json:"updated_at,omitempty"`
 }
 
 type RegisterResponse struct {
@@ -76,9 +117,55 @@ type LoginResponse struct {
 	AccessToken string  `json:"access_token"` // JWT
 	ExpiresIn   int     `json:"expires_in"`   // giây
 	User        UserDoc `json:"user"`
+	// role bắt buộc 2FA nhưng chưa bật: phải đăng ký TOTP rồi đăng nhập lại
+	MFAEnrollmentRequired bool `json:"mfa_enrollment_required,omitempty"`
+}
+
+// MFAChallengeResponse: đăng nhập bước 1 thành công, cần gửi mã 2FA tới POST /auth/login/mfa
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfa_required"` // luôn true
+	MFAToken    string `json:"mfa_token"`
+	ExpiresIn   int    `json:"expires_in"` // giây
+}
+
+type LoginEventDoc struct {
+	ID         int    `json:"id"`
+	UserID     int    `json:"user_id,omitempty"`
+	Identifier string `json:"identifier"`
+	Success    bool   `json:"success"`
+	Reason     string `json:"reason,omitempty"`
+	Method     string `json:"method"` // password | passkey
+	IP         string `json:"ip"`
+	UserAgent  string `json:"user_agent"`
+	CreatedAt  string `json:"created_at"`
+}
+
+type LoginEventPage struct {
+	Items []LoginEventDoc `json:"items"`
+	Total int64           `json:"total"`
+	Page  int             `json:"page"`
+	Limit int             `json:"limit"`
 }
 
 /************ Helpers ************/
+func clientMeta(c *gin.Context) services.ClientMeta {
+	return services.ClientMeta{
+		IP: c.ClientIP(), UserAgent: c.Request.UserAgent(),
+		Lang: mailer.PickLang(c.GetHeader("Accept-Language")),
+	}
+}
+
+// writeLoginHistory trả 1 trang login_events của userID
+func (h *AuthHandler) writeLoginHistory(c *gin.Context, userID int) {
+	page, limit := parsePage(c)
+	items, total, err := h.auth.LoginHistory(userID, page, limit)
+	if err != nil {
+		writeErr(c, http.StatusInternalServerError, "server error")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items, "total": total, "page": page, "limit": limit})
+}
+
 func (h *AuthHandler) setRefreshCookie(c *gin.Context, token string, exp time.Time) {
 	c.SetCookie(h.cfg.CookieName, token, int(time.Until(exp).Seconds()),
 		"/", "", false, true) // Path=/, HttpOnly; Secure=false cho localhost
@@ -87,6 +174,32 @@ func (h *AuthHandler) clearRefreshCookie(c *gin.Context) {
 	c.SetCookie(h.cfg.CookieName, "", -1, "/", "", false, true)
 }
 
+// writeThrottled: 429 khi tài khoản/IP đang bị chặn do đăng nhập sai nhiều lần
+func writeThrottled(c *gin.Context, retryAfter time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	writeErrCode(c, http.StatusTooManyRequests, "too_many_attempts", "too many failed login attempts, please try again later")
+}
+
+// writeTokens: set refresh cookie (nếu có) + trả access token (dùng chung cho login/refresh)
+func (h *AuthHandler) writeTokens(c *gin.Context, res services.LoginResult) {
+	if res.Refresh != "" {
+		h.setRefreshCookie(c, res.Refresh, res.RefreshExp)
+	}
+
+	out := gin.H{
+		"token_type":   "Bearer",
+		"access_token": res.AccessToken,
+		"expires_in":   int(time.Until(res.AccessExp).Seconds()),
+		"user": gin.H{
+			"id": res.User.ID, "username": res.User.Username, "email": res.User.Email, "role": res.User.Role,
+		},
+	}
+	if res.MFAEnrollRequired {
+		out["mfa_enrollment_required"] = true
+	}
+	c.JSON(http.StatusOK, out)
+}
+
 /************ Endpoints ************/
 
 // Register godoc
@@ -112,11 +225,11 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		username = in.Email[:at]
 	}
 
-	out, err := h.users.svc.Create(services.CreateParams{
+	out, err := h.users.svc.Create(actorFrom(c), services.CreateParams{
 		Username: username,
 		Email:    strings.ToLower(strings.TrimSpace(in.Email)),
 		Password: in.Password,
-		Role:     "user", // mặc định user
+		Role:     models.RoleUser, // mặc định user (email chưa xác thực)
 	})
 	if err != nil {
 		switch err {
@@ -130,22 +243,30 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
+	if err := h.account.SendVerification(out, clientMeta(c).Lang); err != nil {
+		log.Printf("[auth] send verification for user %d: %v", out.ID, err)
+	}
+
 	// Không auto-login, FE sẽ chuyển sang form đăng nhập
 	c.JSON(http.StatusCreated, gin.H{
-		"message": "registered successfully, please login",
+		"message": "registered successfully, please check your email to verify your account",
 		"user":    out,
 	})
 }
 
 // Login godoc
 // @Summary      Đăng nhập (lấy access/refresh token)
+// @Description  User đã bật 2FA: trả MFAChallengeResponse thay cho token, gửi mã tới POST /auth/login/mfa.
 // @Tags         Auth
 // @Accept       json
 // @Produce      json
 // @Param        req  body     LoginRequest  true  "Login payload"
 // @Success      200  {object} LoginResponse
+// @Success      200  {object} MFAChallengeResponse
 // @Failure      400  {object} ErrorResponse
 // @Failure      401  {object} ErrorResponse
+// @Failure      403  {object} ErrorResponse "account_inactive | account_banned | email_not_verified"
+// @Failure      429  {object} ErrorResponse "too_many_attempts (kèm Retry-After)"
 // @Router       /auth/login [post]
 func (h *AuthHandler) Login(c *gin.Context) {
 	var in LoginRequest
@@ -153,27 +274,69 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		writeErr(c, http.StatusBadRequest, "invalid body")
 		return
 	}
-	res, err := h.auth.Login(in.Identifier, in.Password)
+	res, err := h.auth.Login(in.Identifier, in.Password, clientMeta(c))
 	if err != nil {
-		if err == repository.ErrNotFound || strings.Contains(err.Error(), "invalid_credentials") {
+		switch err {
+		case repository.ErrNotFound, services.ErrInvalidCredentials:
 			writeErr(c, http.StatusUnauthorized, "invalid credentials")
+		case services.ErrAccountInactive:
+			writeErrCode(c, http.StatusForbidden, err.Error(), "account is inactive")
+		case services.ErrAccountBanned:
+			writeErrCode(c, http.StatusForbidden, err.Error(), "account is banned")
+		case services.ErrEmailNotVerified:
+			writeErrCode(c, http.StatusForbidden, err.Error(), "email is not verified")
+		case services.ErrThrottled:
+			writeThrottled(c, res.RetryAfter)
+		default:
+			writeErr(c, http.StatusInternalServerError, "server error")
+		}
+		return
+	}
+	if res.MFAToken != "" {
+		c.JSON(http.StatusOK, gin.H{
+			"mfa_required": true,
+			"mfa_token":    res.MFAToken,
+			"expires_in":   int(time.Until(res.MFAExp).Seconds()),
+		})
+		return
+	}
+	h.writeTokens(c, res)
+}
+
+// Refresh godoc
+// @Summary      Cấp lại access token (rotate refresh token trong cookie)
+// @Tags         Auth
+// @Produce      json
+// @Success      200  {object} LoginResponse
+// @Failure      401  {object} ErrorResponse
+// @Failure      403  {object} ErrorResponse "account_inactive | account_banned"
+// @Router       /auth/refresh [post]
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	cookie, err := c.Cookie(h.cfg.CookieName)
+	if err != nil || cookie == "" {
+		writeErr(c, http.StatusUnauthorized, "missing refresh token")
+		return
+	}
+	res, err := h.auth.Refresh(cookie, clientMeta(c))
+	if err != nil {
+		switch err {
+		case services.ErrInvalidToken:
+			h.clearRefreshCookie(c)
+			writeErr(c, http.StatusUnauthorized, "invalid refresh token")
+			return
+		case services.ErrTokenReused:
+			h.clearRefreshCookie(c)
+			writeErr(c, http.StatusUnauthorized, "refresh token reuse detected, please login again")
+			return
+		case services.ErrAccountInactive, services.ErrAccountBanned:
+			h.clearRefreshCookie(c)
+			writeErrCode(c, http.StatusForbidden, err.Error(), "account is not active")
 			return
 		}
 		writeErr(c, http.StatusInternalServerError, "server error")
 		return
 	}
-
-	// set refresh token cookie
-	h.setRefreshCookie(c, res.Refresh, res.RefreshExp)
-
-	c.JSON(http.StatusOK, gin.H{
-		"token_type":   "Bearer",
-		"access_token": res.AccessToken,
-		"expires_in":   int(time.Until(res.AccessExp).Seconds()),
-		"user": gin.H{
-			"id": res.User.ID, "username": res.User.Username, "email": res.User.Email, "role": res.User.Role,
-		},
-	})
+	h.writeTokens(c, res)
 }
 
 // Logout godoc
@@ -183,15 +346,8 @@ func (h *AuthHandler) Login(c *gin.Context) {
 // @Success      204  {string} string "No Content"
 // @Router       /auth/logout [post]
 func (h *AuthHandler) Logout(c *gin.Context) {
-	cookie, err := c.Cookie(h.cfg.CookieName)
-	if err == nil && cookie != "" {
-		// lấy jti từ refresh token
-		tok, _ := jwt.ParseWithClaims(cookie, &services.Claims{}, func(t *jwt.Token) (interface{}, error) {
-			return []byte(h.cfg.Secret), nil
-		})
-		if claims, ok := tok.Claims.(*services.Claims); ok && tok.Valid {
-			_ = h.auth.Logout(claims.ID)
-		}
+	if cookie, err := c.Cookie(h.cfg.CookieName); err == nil && cookie != "" {
+		_ = h.auth.Logout(cookie)
 	}
 	h.clearRefreshCookie(c)
 	c.Status(http.StatusNoContent)
@@ -214,3 +370,301 @@ func (h *AuthHandler) Me(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, u)
 }
+
+// Các field user không được tự đổi qua PATCH /auth/me (password có endpoint riêng)
+var selfDeniedFields = map[string]bool{"role": true, "status": true, "username": true, "password": true}
+
+// UpdateMe godoc
+// @Summary      Tự cập nhật hồ sơ (JSON Merge Patch - RFC 7396)
+// @Description  Không đổi được username/role/status; đổi mật khẩu dùng POST /auth/me/password.
+// @Description  Đổi email => email mới phải xác thực lại (link được gửi tới email mới).
+// @Tags         Auth
+// @Security     BearerAuth
+// @Accept       json
+// @Accept       application/merge-patch+json
+// @Produce      json
+// @Param        patch  body     UpdateUserRequest  true  "Các field cần đổi"
+// @Success      200  {object} UserDoc
+// @Failure      400  {object} ErrorResponse
+// @Failure      401  {object} ErrorResponse
+// @Failure      409  {object} ErrorResponse
+// @Failure      415  {object} ErrorResponse
+// @Router       /auth/me [patch]
+func (h *AuthHandler) UpdateMe(c *gin.Context) {
+	uid := c.GetInt("uid")
+	patch, _, ok := h.users.bindMergePatch(c, uid, selfDeniedFields)
+	if !ok {
+		return
+	}
+	out, err := h.users.svc.Patch(actorFrom(c), uid, patch, 0, false)
+	if err != nil {
+		switch err {
+		case repository.ErrNotFound:
+			writeErr(c, http.StatusNotFound, "not found")
+		case services.ErrDuplicate:
+			writeErr(c, http.StatusConflict, "email already exists")
+		case services.ErrBadInput:
+			writeErr(c, http.StatusBadRequest, "invalid body")
+		default:
+			writeErr(c, http.StatusInternalServerError, "server error")
+		}
+		return
+	}
+	if _, ok := patch["email"]; ok && out.EmailVerifiedAt == nil {
+		if err := h.account.SendVerification(out, clientMeta(c).Lang); err != nil {
+			log.Printf("[auth] send verification for user %d: %v", out.ID, err)
+		}
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// ChangePassword godoc
+// @Summary      Đổi mật khẩu
+// @Description  Cần mật khẩu hiện tại. Mọi phiên đăng nhập khác bị thu hồi và mọi access token đã cấp
+// @Description  mất hiệu lực; phiên hiện tại nhận access token mới (và refresh cookie mới nếu request có gửi kèm).
+// @Tags         Auth
+// @Security     BearerAuth
+// @Accept       json
+// @Produce      json
+// @Param        req  body  ChangePasswordRequest  true  "Mật khẩu hiện tại & mới"
+// @Success      200  {object} LoginResponse
+// @Failure      400  {object} ErrorResponse "invalid body | invalid_credentials"
+// @Failure      401  {object} ErrorResponse
+// @Router       /auth/me/password [post]
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	var in ChangePasswordRequest
+	if err := c.ShouldBindJSON(&in); err != nil {
+		writeErr(c, http.StatusBadRequest, "invalid body")
+		return
+	}
+	if err := validatePartial(&UpdateUserRequest{Password: in.NewPassword}, []string{"password"}); err != nil {
+		writeErr(c, http.StatusBadRequest, "invalid new_password")
+		return
+	}
+	uid := c.GetInt("uid")
+	// giữ lại phiên hiện tại (nếu request kèm refresh cookie)
+	keep := ""
+	cookie, _ := c.Cookie(h.cfg.CookieName)
+	if cookie != "" {
+		keep = h.auth.SessionFamily(uid, cookie)
+	}
+	if err := h.users.svc.ChangePassword(actorFrom(c), uid, in.CurrentPassword, in.NewPassword, keep); err != nil {
+		switch err {
+		case services.ErrInvalidCredentials:
+			// không dùng 401 để FE không hiểu nhầm là access token hết hạn
+			writeErrCode(c, http.StatusBadRequest, "invalid_credentials", "current password is incorrect")
+		case services.ErrBadInput:
+			writeErr(c, http.StatusBadRequest, "invalid body")
+		case repository.ErrNotFound:
+			writeErr(c, http.StatusNotFound, "not found")
+		default:
+			writeErr(c, http.StatusInternalServerError, "server error")
+		}
+		return
+	}
+	// token version đã tăng => access token hiện tại hết hiệu lực, cấp lại cho phiên này
+	res, err := h.auth.ReissueSession(uid, cookie, c.GetBool("mfa"), clientMeta(c))
+	if err != nil {
+		writeErr(c, http.StatusInternalServerError, "server error")
+		return
+	}
+	h.writeTokens(c, res)
+}
+
+// ForgotPassword godoc
+// @Summary      Quên mật khẩu (gửi link đặt lại qua email)
+// @Description  Luôn trả 202 dù email có tồn tại hay không.
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        req  body  ForgotPasswordRequest  true  "Email tài khoản"
+// @Success      202  {object} map[string]string
+// @Failure      400  {object} ErrorResponse
+// @Router       /auth/password/forgot [post]
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var in ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&in); err != nil {
+		writeErr(c, http.StatusBadRequest, "invalid body")
+		return
+	}
+	if err := h.account.ForgotPassword(in.Email, clientMeta(c)); err != nil {
+		// không lộ lỗi ra ngoài (tránh dò email), chỉ ghi log
+		log.Printf("[auth] forgot password: %v", err)
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "if the email exists, a reset link has been sent"})
+}
+
+// ResetPassword godoc
+// @Summary      Đặt lại mật khẩu bằng token trong email
+// @Description  Token chỉ dùng được 1 lần. Mọi phiên đăng nhập của tài khoản bị thu hồi.
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        req  body  ResetPasswordRequest  true  "Token & mật khẩu mới"
+// @Success      204  {string} string "No Content"
+// @Failure      400  {object} ErrorResponse "invalid body | invalid_token"
+// @Router       /auth/password/reset [post]
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var in ResetPasswordRequest
+	if err := c.ShouldBindJSON(&in); err != nil {
+		writeErr(c, http.StatusBadRequest, "invalid body")
+		return
+	}
+	if err := validatePartial(&UpdateUserRequest{Password: in.NewPassword}, []string{"password"}); err != nil {
+		writeErr(c, http.StatusBadRequest, "invalid new_password")
+		return
+	}
+	if err := h.account.ResetPassword(actorFrom(c), in.Token, in.NewPassword); err != nil {
+		switch err {
+		case services.ErrInvalidToken:
+			writeErrCode(c, http.StatusBadRequest, "invalid_token", "reset token is invalid or expired")
+		case services.ErrBadInput:
+			writeErr(c, http.StatusBadRequest, "invalid body")
+		default:
+			writeErr(c, http.StatusInternalServerError, "server error")
+		}
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// VerifyEmail godoc
+// @Summary      Xác thực email (link trong email)
+// @Description  Có cấu hình EMAIL_VERIFY_REDIRECT_URL thì chuyển hướng về FE kèm ?status=ok|invalid.
+// @Tags         Auth
+// @Produce      json
+// @Param        token  query  string  true  "Token trong link"
+// @Success      200  {object} map[string]string
+// @Success      302  {string} string "Chuyển hướng về FE"
+// @Failure      400  {object} ErrorResponse "invalid_token"
+// @Router       /auth/verify-email [get]
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	_, err := h.account.VerifyEmail(c.Query("token"))
+	if redirect := h.account.VerifyRedirectURL(); redirect != "" {
+		status := "ok"
+		if err != nil {
+			status = "invalid"
+		}
+		sep := "?"
+		if strings.Contains(redirect, "?") {
+			sep = "&"
+		}
+		c.Redirect(http.StatusFound, redirect+sep+"status="+status)
+		return
+	}
+	if err != nil {
+		if err == services.ErrInvalidToken {
+			writeErrCode(c, http.StatusBadRequest, "invalid_token", "verification link is invalid or expired")
+			return
+		}
+		writeErr(c, http.StatusInternalServerError, "server error")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "email verified"})
+}
+
+// ResendVerification godoc
+// @Summary      Gửi lại link xác thực email
+// @Description  Trả 202 dù email có tồn tại hay không; gửi lại quá nhanh => 429 kèm Retry-After.
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        req  body  ResendVerificationRequest  true  "Email tài khoản"
+// @Success      202  {object} map[string]string
+// @Failure      400  {object} ErrorResponse
+// @Failure      429  {object} ErrorResponse
+// @Router       /auth/verify-email/resend [post]
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	var in ResendVerificationRequest
+	if err := c.ShouldBindJSON(&in); err != nil {
+		writeErr(c, http.StatusBadRequest, "invalid body")
+		return
+	}
+	wait, err := h.account.ResendVerification(in.Email, clientMeta(c).Lang)
+	if err == services.ErrThrottled {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		writeErr(c, http.StatusTooManyRequests, "please wait before requesting another email")
+		return
+	}
+	if err != nil {
+		log.Printf("[auth] resend verification: %v", err)
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "if the account needs verification, a new link has been sent"})
+}
+
+// MyLogins godoc
+// @Summary      Lịch sử đăng nhập của tôi
+// @Tags         Auth
+// @Security     BearerAuth
+// @Produce      json
+// @Param        page   query    int  false  "Trang (mặc định 1)"
+// @Param        limit  query    int  false  "Số dòng/trang (mặc định 20, tối đa 100)"
+// @Success      200  {object} LoginEventPage
+// @Failure      401  {object} ErrorResponse
+// @Router       /auth/me/logins [get]
+func (h *AuthHandler) MyLogins(c *gin.Context) {
+	h.writeLoginHistory(c, c.GetInt("uid"))
+}
+
+// UserLogins godoc
+// @Summary      Lịch sử đăng nhập của người dùng
+// @Tags         Admin
+// @Security     BearerAuth
+// @Produce      json
+// @Param        id     path     int  true   "User ID"
+// @Param        page   query    int  false  "Trang (mặc định 1)"
+// @Param        limit  query    int  false  "Số dòng/trang (mặc định 20, tối đa 100)"
+// @Success      200  {object} LoginEventPage
+// @Failure      403  {object} ErrorResponse "permission_denied | mã lý do của policy (POLICY_FILE)"
+// @Failure      404  {object} ErrorResponse
+// @Router       /admin/users/{id}/logins [get]
+func (h *AuthHandler) UserLogins(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	u, ok := h.users.loadForPolicy(c, id, false)
+	if !ok || !h.users.authorize(c, services.ActionUserLogins, services.UserAttrs(u), nil) {
+		return
+	}
+	h.writeLoginHistory(c, id)
+}
+
+// UnlockUser godoc
+// @Summary      Mở khoá đăng nhập cho người dùng
+// @Description  Xoá bộ đếm đăng nhập sai (theo username & email) để user đăng nhập lại ngay.
+// @Tags         Admin
+// @Security     BearerAuth
+// @Produce      json
+// @Param        id   path  int  true  "User ID"
+// @Success      204  {string} string "No Content"
+// @Failure      403  {object} ErrorResponse "permission_denied | privilege_escalation | mã lý do của policy (POLICY_FILE)"
+// @Failure      404  {object} ErrorResponse
+// @Router       /admin/users/{id}/unlock [post]
+func (h *AuthHandler) UnlockUser(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if !h.users.authorizeTarget(c, id, services.ActionUserUnlock) {
+		return
+	}
+	if err := h.auth.UnlockAccount(actorFrom(c), id); err != nil {
+		if writeGuardErr(c, err) {
+			return
+		}
+		if err == repository.ErrNotFound {
+			writeErr(c, http.StatusNotFound, "not found")
+			return
+		}
+		writeErr(c, http.StatusInternalServerError, "server error")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// JWKS godoc
+// @Summary      Khoá công khai xác thực access token (JWKS)
+// @Description  Phục vụ tại gốc server (ngoài /api/v1). Các service khác dùng để verify JWT theo kid; gồm cả khoá cũ còn hiệu lực sau khi rotate, rỗng khi JWT_ALG=HS256.
+// @Tags         Auth
+// @Produce      json
+// @Success      200  {object} keyset.JWKS
+// @Router       /.well-known/jwks.json [get]
+func (h *AuthHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.tokens.Keys().JWKS())
+}
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"crud_api_us/internal/janitor"
+)
+
+type JanitorHandler struct{ jobs *janitor.Runner }
+
+func NewJanitorHandler(jobs *janitor.Runner) *JanitorHandler {
+	return &JanitorHandler{jobs: jobs}
+}
+
+// ListJobs godoc
+// @Summary      Số liệu các job dọn dẹp nền (số lần chạy, số bản ghi đã xoá, lỗi gần nhất)
+// @Tags         Admin
+// @Security     BearerAuth
+// @Produce      json
+// @Success      200  {array}  janitor.Stats
+// @Failure      401  {object} ErrorResponse
+// @Failure      403  {object} ErrorResponse
+// @Router       /admin/jobs [get]
+func (h *JanitorHandler) ListJobs(c *gin.Context) {
+	c.JSON(http.StatusOK, h.jobs.Stats())
+}
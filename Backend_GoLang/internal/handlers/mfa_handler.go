@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"crud_api_us/internal/repository"
+	"crud_api_us/internal/services"
+)
+
+/************ DTO ************/
+type LoginMFARequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code"      binding:"required,max=32"` // 6 số từ app hoặc mã khôi phục
+}
+
+type VerifyTOTPRequest struct {
+	Code string `json:"code" binding:"required,len=6,numeric"`
+}
+
+type TOTPSetupResponse struct {
+	Secret     string `json:"secret"`      // base32, nhập tay vào app nếu không quét được QR
+	OtpauthURI string `json:"otpauth_uri"` // otpauth://totp/... (tạo QR code ở FE)
+}
+
+type RecoveryCodesResponse struct {
+	// chỉ hiển thị 1 lần, mỗi mã dùng được 1 lần thay cho mã TOTP
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+/************ Endpoints ************/
+
+// LoginMFA godoc
+// @Summary      Đăng nhập bước 2: mã 2FA
+// @Description  Gửi mfa_token nhận từ /auth/login kèm mã 6 số trong app authenticator (hoặc 1 mã khôi phục).
+// @Description  Thử quá 5 lần thì mfa_token hết hiệu lực, phải đăng nhập lại. Mã sai được tính như 1 lần đăng nhập sai.
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        req  body     LoginMFARequest  true  "mfa_token & mã"
+// @Success      200  {object} LoginResponse
+// @Failure      400  {object} ErrorResponse
+// @Failure      401  {object} ErrorResponse "invalid_token | invalid_mfa_code"
+// @Failure      403  {object} ErrorResponse "account_inactive | account_banned"
+// @Failure      429  {object} ErrorResponse "too_many_attempts (kèm Retry-After)"
+// @Router       /auth/login/mfa [post]
+func (h *AuthHandler) LoginMFA(c *gin.Context) {
+	var in LoginMFARequest
+	if err := c.ShouldBindJSON(&in); err != nil {
+		writeErr(c, http.StatusBadRequest, "invalid body")
+		return
+	}
+	res, err := h.auth.LoginMFA(in.MFAToken, in.Code, clientMeta(c))
+	if err != nil {
+		switch err {
+		case services.ErrInvalidToken:
+			writeErrCode(c, http.StatusUnauthorized, err.Error(), "mfa token is invalid or expired, please login again")
+		case services.ErrInvalidMFACode:
+			writeErrCode(c, http.StatusUnauthorized, err.Error(), "invalid verification code")
+		case services.ErrAccountInactive:
+			writeErrCode(c, http.StatusForbidden, err.Error(), "account is inactive")
+		case services.ErrAccountBanned:
+			writeErrCode(c, http.StatusForbidden, err.Error(), "account is banned")
+		case services.ErrThrottled:
+			writeThrottled(c, res.RetryAfter)
+		default:
+			writeErr(c, http.StatusInternalServerError, "server error")
+		}
+		return
+	}
+	h.writeTokens(c, res)
+}
+
+// MFAStatus godoc
+// @Summary      Trạng thái 2FA của tôi
+// @Tags         Auth
+// @Security     BearerAuth
+// @Produce      json
+// @Success      200  {object} services.MFAStatus
+// @Failure      401  {object} ErrorResponse
+// @Router       /auth/mfa [get]
+func (h *AuthHandler) MFAStatus(c *gin.Context) {
+	u, err := h.users.svc.Get(c.GetInt("uid"))
+	if err != nil {
+		writeErr(c, http.StatusInternalServerError, "server error")
+		return
+	}
+	st, err := h.mfa.Status(u)
+	if err != nil {
+		writeErr(c, http.StatusInternalServerError, "server error")
+		return
+	}
+	c.JSON(http.StatusOK, st)
+}
+
+// SetupTOTP godoc
+// @Summary      Bắt đầu bật 2FA (TOTP)
+// @Description  Trả secret + otpauth URI để thêm vào app authenticator. 2FA chỉ bật sau khi xác nhận mã ở /auth/mfa/totp/verify.
+// @Tags         Auth
+// @Security     BearerAuth
+// @Produce      json
+// @Success      200  {object} TOTPSetupResponse
+// @Failure      401  {object} ErrorResponse
+// @Failure      409  {object} ErrorResponse "mfa_already_enabled"
+// @Router       /auth/mfa/totp/setup [post]
+func (h *AuthHandler) SetupTOTP(c *gin.Context) {
+	secret, uri, err := h.mfa.SetupTOTP(c.GetInt("uid"))
+	if err != nil {
+		switch err {
+		case services.ErrMFAAlreadyEnabled:
+			writeErrCode(c, http.StatusConflict, err.Error(), "two-factor authentication is already enabled")
+		case repository.ErrNotFound:
+			writeErr(c, http.StatusNotFound, "not found")
+		default:
+			writeErr(c, http.StatusInternalServerError, "server error")
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"secret": secret, "otpauth_uri": uri})
+}
+
+// VerifyTOTP godoc
+// @Summary      Xác nhận mã TOTP để bật 2FA
+// @Description  Thành công => trả mã khôi phục (chỉ hiển thị 1 lần). Các lần đăng nhập sau cần mã 2FA.
+// @Tags         Auth
+// @Security     BearerAuth
+// @Accept       json
+// @Produce      json
+// @Param        req  body     VerifyTOTPRequest  true  "Mã 6 số trong app"
+// @Success      200  {object} RecoveryCodesResponse
+// @Failure      400  {object} ErrorResponse "invalid body | invalid_mfa_code | mfa_not_pending"
+// @Failure      401  {object} ErrorResponse
+// @Failure      409  {object} ErrorResponse "mfa_already_enabled"
+// @Router       /auth/mfa/totp/verify [post]
+func (h *AuthHandler) VerifyTOTP(c *gin.Context) {
+	var in VerifyTOTPRequest
+	if err := c.ShouldBindJSON(&in); err != nil {
+		writeErr(c, http.StatusBadRequest, "invalid body")
+		return
+	}
+	codes, err := h.mfa.ConfirmTOTP(actorFrom(c), c.GetInt("uid"), in.Code)
+	if err != nil {
+		switch err {
+		case services.ErrInvalidMFACode:
+			writeErrCode(c, http.StatusBadRequest, err.Error(), "invalid verification code")
+		case services.ErrMFANotPending:
+			writeErrCode(c, http.StatusBadRequest, err.Error(), "call /auth/mfa/totp/setup first")
+		case services.ErrMFAAlreadyEnabled:
+			writeErrCode(c, http.StatusConflict, err.Error(), "two-factor authentication is already enabled")
+		default:
+			writeErr(c, http.StatusInternalServerError, "server error")
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
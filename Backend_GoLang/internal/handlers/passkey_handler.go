@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"crud_api_us/internal/repository"
+	"crud_api_us/internal/services"
+)
+
+// phản hồi của authenticator chỉ vài KB; chặn body quá lớn
+const maxWebAuthnBody = 64 << 10
+
+/************ DTO (docs/response) ************/
+type WebAuthnBeginResponse struct {
+	SessionID string `json:"session_id"` // gửi lại ở bước finish (?session_id=)
+	// truyền nguyên cho navigator.credentials.create() / get() (dạng {"publicKey": {...}})
+	Options map[string]any `json:"options"`
+}
+
+type PasskeyDoc struct {
+	ID         int     `json:"id"`
+	Name       string  `json:"name"`
+	Transports string  `json:"transports"`
+	Synced     bool    `json:"synced"`
+	LastUsedAt *string `json:"last_used_at"`
+	CreatedAt  string  `json:"created_at"`
+}
+
+/************ Helpers ************/
+func readWebAuthnBody(c *gin.Context) ([]byte, bool) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebAuthnBody+1))
+	if err != nil || len(body) == 0 || len(body) > maxWebAuthnBody {
+		writeErr(c, http.StatusBadRequest, "invalid body")
+		return nil, false
+	}
+	return body, true
+}
+
+/************ Endpoints ************/
+
+// BeginPasskeyRegistration godoc
+// @Summary      Đăng ký passkey: bước 1
+// @Description  Trả options cho navigator.credentials.create() và session_id (dùng 1 lần, có hạn).
+// @Tags         Passkey
+// @Security     BearerAuth
+// @Produce      json
+// @Success      200  {object} WebAuthnBeginResponse
+// @Failure      401  {object} ErrorResponse
+// @Failure      403  {object} ErrorResponse "mfa_required (đã bật 2FA nhưng phiên chưa qua 2FA)"
+// @Router       /auth/webauthn/register/begin [post]
+func (h *AuthHandler) BeginPasskeyRegistration(c *gin.Context) {
+	id, opts, err := h.passkeys.BeginRegistration(c.GetInt("uid"))
+	if err != nil {
+		writeErr(c, http.StatusInternalServerError, "server error")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"session_id": id, "options": opts})
+}
+
+// FinishPasskeyRegistration godoc
+// @Summary      Đăng ký passkey: bước 2
+// @Description  Body là PublicKeyCredential (JSON) trả về từ navigator.credentials.create().
+// @Tags         Passkey
+// @Security     BearerAuth
+// @Accept       json
+// @Produce      json
+// @Param        session_id  query  string  true   "session_id từ bước 1"
+// @Param        name        query  string  false  "Tên gợi nhớ (vd: MacBook)"
+// @Param        credential  body   object  true   "PublicKeyCredential"
+// @Success      201  {object} PasskeyDoc
+// @Failure      400  {object} ErrorResponse "invalid body | invalid_token | passkey_failed"
+// @Failure      401  {object} ErrorResponse
+// @Failure      403  {object} ErrorResponse "mfa_required (đã bật 2FA nhưng phiên chưa qua 2FA)"
+// @Failure      409  {object} ErrorResponse
+// @Router       /auth/webauthn/register/finish [post]
+func (h *AuthHandler) FinishPasskeyRegistration(c *gin.Context) {
+	body, ok := readWebAuthnBody(c)
+	if !ok {
+		return
+	}
+	cred, err := h.passkeys.FinishRegistration(actorFrom(c), c.GetInt("uid"), c.Query("session_id"), c.Query("name"), body)
+	if err != nil {
+		switch err {
+		case services.ErrInvalidToken:
+			writeErrCode(c, http.StatusBadRequest, "invalid_token", "registration session is invalid or expired")
+		case services.ErrPasskeyFailed:
+			writeErrCode(c, http.StatusBadRequest, err.Error(), "passkey verification failed")
+		case services.ErrDuplicate:
+			writeErr(c, http.StatusConflict, "passkey already registered")
+		default:
+			writeErr(c, http.StatusInternalServerError, "server error")
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, cred)
+}
+
+// ListPasskeys godoc
+// @Summary      Danh sách passkey của tôi
+// @Tags         Passkey
+// @Security     BearerAuth
+// @Produce      json
+// @Success      200  {array}  PasskeyDoc
+// @Failure      401  {object} ErrorResponse
+// @Router       /auth/webauthn/credentials [get]
+func (h *AuthHandler) ListPasskeys(c *gin.Context) {
+	items, err := h.passkeys.List(c.GetInt("uid"))
+	if err != nil {
+		writeErr(c, http.StatusInternalServerError, "server error")
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+// DeletePasskey godoc
+// @Summary      Xoá passkey
+// @Tags         Passkey
+// @Security     BearerAuth
+// @Produce      json
+// @Param        id   path  int  true  "Passkey ID"
+// @Success      204  {string} string "No Content"
+// @Failure      401  {object} ErrorResponse
+// @Failure      404  {object} ErrorResponse
+// @Router       /auth/webauthn/credentials/{id} [delete]
+func (h *AuthHandler) DeletePasskey(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if err := h.passkeys.Delete(actorFrom(c), c.GetInt("uid"), id); err != nil {
+		if err == repository.ErrNotFound {
+			writeErr(c, http.StatusNotFound, "not found")
+			return
+		}
+		writeErr(c, http.StatusInternalServerError, "server error")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// BeginPasskeyLogin godoc
+// @Summary      Đăng nhập bằng passkey: bước 1
+// @Description  Trả options cho navigator.credentials.get() (không cần nhập username).
+// @Tags         Passkey
+// @Produce      json
+// @Success      200  {object} WebAuthnBeginResponse
+// @Router       /auth/webauthn/login/begin [post]
+func (h *AuthHandler) BeginPasskeyLogin(c *gin.Context) {
+	id, opts, err := h.passkeys.BeginLogin()
+	if err != nil {
+		writeErr(c, http.StatusInternalServerError, "server error")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"session_id": id, "options": opts})
+}
+
+// FinishPasskeyLogin godoc
+// @Summary      Đăng nhập bằng passkey: bước 2
+// @Description  Body là PublicKeyCredential (JSON) trả về từ navigator.credentials.get(). Thành công => cặp access/refresh như /auth/login.
+// @Tags         Passkey
+// @Accept       json
+// @Produce      json
+// @Param        session_id  query  string  true  "session_id từ bước 1"
+// @Param        credential  body   object  true  "PublicKeyCredential"
+// @Success      200  {object} LoginResponse
+// @Failure      400  {object} ErrorResponse
+// @Failure      401  {object} ErrorResponse "invalid_token | passkey_failed"
+// @Failure      403  {object} ErrorResponse "account_inactive | account_banned | email_not_verified"
+// @Router       /auth/webauthn/login/finish [post]
+func (h *AuthHandler) FinishPasskeyLogin(c *gin.Context) {
+	body, ok := readWebAuthnBody(c)
+	if !ok {
+		return
+	}
+	res, err := h.auth.LoginPasskey(c.Query("session_id"), body, clientMeta(c))
+	if err != nil {
+		switch err {
+		case services.ErrInvalidToken:
+			writeErrCode(c, http.StatusUnauthorized, err.Error(), "login session is invalid or expired")
+		case services.ErrPasskeyFailed:
+			writeErrCode(c, http.StatusUnauthorized, err.Error(), "passkey verification failed")
+		case services.ErrAccountInactive:
+			writeErrCode(c, http.StatusForbidden, err.Error(), "account is inactive")
+		case services.ErrAccountBanned:
+			writeErrCode(c, http.StatusForbidden, err.Error(), "account is banned")
+		case services.ErrEmailNotVerified:
+			writeErrCode(c, http.StatusForbidden, err.Error(), "email is not verified")
+		default:
+			writeErr(c, http.StatusInternalServerError, "server error")
+		}
+		return
+	}
+	h.writeTokens(c, res)
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"crud_api_us/internal/services"
+)
+
+type RoleHandler struct{ svc *services.RBACService }
+
+func NewRoleHandler(svc *services.RBACService) *RoleHandler { return &RoleHandler{svc: svc} }
+
+/************ DTO (request) ************/
+type CreateRoleRequest struct {
+	Name        string   `json:"name"        binding:"required,min=2,max=20" example:"support"`
+	Description string   `json:"description" binding:"omitempty,max=255"`
+	Permissions []string `json:"permissions" binding:"omitempty,dive,max=50" example:"users:read"`
+}
+
+// UpdateRoleRequest: field không gửi = giữ nguyên; permissions thay toàn bộ danh sách quyền
+type UpdateRoleRequest struct {
+	Description *string  `json:"description" binding:"omitempty,max=255"`
+	Permissions []string `json:"permissions" binding:"omitempty,dive,max=50" example:"users:read"`
+}
+
+/************ DTO (docs/response) ************/
+type RoleDoc struct {
+	ID          int             `json:"id"`
+	Name        string          `json:"name" example:"support"`
+	Description string          `json:"description"`
+	System      bool            `json:"system"` // role mặc định, không xoá được
+	Permissions []PermissionDoc `json:"permissions"`
+	CreatedAt   string          `json:"created_at"`
+	UpdatedAt   string          `json:"updated_at"`
+}
+
+type PermissionDoc struct {
+	Name        string `json:"name" example:"users:read"`
+	Description string `json:"description"`
+}
+
+func writeRoleErr(c *gin.Context, err error) {
+	switch err {
+	case services.ErrUnknownRole:
+		writeErr(c, http.StatusNotFound, "not found")
+	case services.ErrBadInput:
+		writeErr(c, http.StatusBadRequest, "invalid body")
+	case services.ErrUnknownPermission:
+		writeErrCode(c, http.StatusBadRequest, "unknown_permission", "unknown permission")
+	case services.ErrDuplicate:
+		writeErr(c, http.StatusConflict, "role already exists")
+	case services.ErrSystemRole:
+		writeErrCode(c, http.StatusConflict, "system_role", "built-in role cannot be changed")
+	case services.ErrRoleInUse:
+		writeErrCode(c, http.StatusConflict, "role_in_use", "role is assigned to users")
+	case services.ErrPrivilegeEscalation:
+		writeErrCode(c, http.StatusForbidden, "privilege_escalation", "cannot grant permissions you do not have")
+	case services.ErrOwnRole:
+		writeErrCode(c, http.StatusForbidden, "own_role", "cannot change permissions of your own role")
+	default:
+		writeErr(c, http.StatusInternalServerError, "server error")
+	}
+}
+
+// ListRoles godoc
+// @Summary      Danh sách vai trò kèm quyền
+// @Tags         Role
+// @Security     BearerAuth
+// @Produce      json
+// @Success      200  {array}  RoleDoc
+// @Failure      403  {object} ErrorResponse "permission_denied"
+// @Router       /admin/roles [get]
+func (h *RoleHandler) ListRoles(c *gin.Context) {
+	items, err := h.svc.Roles()
+	if err != nil {
+		writeErr(c, http.StatusInternalServerError, "server error")
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+// GetRole godoc
+// @Summary      Lấy vai trò theo tên
+// @Tags         Role
+// @Security     BearerAuth
+// @Produce      json
+// @Param        name  path  string  true  "Tên role"
+// @Success      200  {object} RoleDoc
+// @Failure      403  {object} ErrorResponse "permission_denied"
+// @Failure      404  {object} ErrorResponse
+// @Router       /admin/roles/{name} [get]
+func (h *RoleHandler) GetRole(c *gin.Context) {
+	r, err := h.svc.Role(c.Param("name"))
+	if err != nil {
+		writeRoleErr(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, r)
+}
+
+// ListPermissions godoc
+// @Summary      Danh mục quyền có thể gán cho vai trò
+// @Tags         Role
+// @Security     BearerAuth
+// @Produce      json
+// @Success      200  {array}  PermissionDoc
+// @Failure      403  {object} ErrorResponse "permission_denied"
+// @Router       /admin/permissions [get]
+func (h *RoleHandler) ListPermissions(c *gin.Context) {
+	items, err := h.svc.Permissions()
+	if err != nil {
+		writeErr(c, http.StatusInternalServerError, "server error")
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+// CreateRole godoc
+// @Summary      Tạo vai trò
+// @Description  Tên: chữ thường, số, "_" hoặc "-", bắt đầu bằng chữ. Quyền phải có trong GET /admin/permissions và người tạo phải đang có các quyền đó.
+// @Tags         Role
+// @Security     BearerAuth
+// @Accept       json
+// @Produce      json
+// @Param        role  body     CreateRoleRequest  true  "Role payload"
+// @Success      201   {object} RoleDoc
+// @Failure      400   {object} ErrorResponse "unknown_permission"
+// @Failure      403   {object} ErrorResponse "permission_denied | privilege_escalation"
+// @Failure      409   {object} ErrorResponse
+// @Router       /admin/roles [post]
+func (h *RoleHandler) CreateRole(c *gin.Context) {
+	var in CreateRoleRequest
+	if err := c.ShouldBindJSON(&in); err != nil {
+		writeErr(c, http.StatusBadRequest, "invalid body")
+		return
+	}
+	r, err := h.svc.CreateRole(actorFrom(c), services.RoleParams{
+		Name: in.Name, Description: &in.Description, Permissions: in.Permissions,
+	})
+	if err != nil {
+		writeRoleErr(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, r)
+}
+
+// UpdateRole godoc
+// @Summary      Sửa mô tả / thay danh sách quyền của vai trò
+// @Description  Quyền của role admin cố định (luôn có mọi quyền). Chỉ gán được quyền mình đang có, không sửa quyền của role mình đang mang. Thay đổi có hiệu lực ngay trên instance này, instance khác sau tối đa RBAC_CACHE_TTL.
+// @Tags         Role
+// @Security     BearerAuth
+// @Accept       json
+// @Produce      json
+// @Param        name  path     string             true  "Tên role"
+// @Param        role  body     UpdateRoleRequest  true  "Các field cần đổi"
+// @Success      200   {object} RoleDoc
+// @Failure      400   {object} ErrorResponse "unknown_permission"
+// @Failure      403   {object} ErrorResponse "permission_denied | privilege_escalation | own_role"
+// @Failure      404   {object} ErrorResponse
+// @Failure      409   {object} ErrorResponse "system_role"
+// @Router       /admin/roles/{name} [put]
+func (h *RoleHandler) UpdateRole(c *gin.Context) {
+	var in UpdateRoleRequest
+	if err := c.ShouldBindJSON(&in); err != nil {
+		writeErr(c, http.StatusBadRequest, "invalid body")
+		return
+	}
+	r, err := h.svc.UpdateRole(actorFrom(c), services.RoleParams{
+		Name: c.Param("name"), Description: in.Description, Permissions: in.Permissions,
+	})
+	if err != nil {
+		writeRoleErr(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, r)
+}
+
+// DeleteRole godoc
+// @Summary      Xoá vai trò
+// @Description  Không xoá được role mặc định (admin, user) và role còn user mang.
+// @Tags         Role
+// @Security     BearerAuth
+// @Param        name  path  string  true  "Tên role"
+// @Success      204  {string} string "No Content"
+// @Failure      403  {object} ErrorResponse "permission_denied"
+// @Failure      404  {object} ErrorResponse
+// @Failure      409  {object} ErrorResponse "system_role | role_in_use"
+// @Router       /admin/roles/{name} [delete]
+func (h *RoleHandler) DeleteRole(c *gin.Context) {
+	if err := h.svc.DeleteRole(actorFrom(c), c.Param("name")); err != nil {
+		writeRoleErr(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
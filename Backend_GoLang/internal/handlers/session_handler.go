@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"crud_api_us/internal/repository"
+	"crud_api_us/internal/services"
+)
+
+/************ DTO (docs/response) ************/
+type SessionDoc struct {
+	ID         string `json:"id"`
+	Name       string `json:"name" example:"Chrome trên Windows"`
+	IP         string `json:"ip"`
+	UserAgent  string `json:"user_agent"`
+	CreatedAt  string `json:"created_at"`   // lúc đăng nhập
+	LastUsedAt string `json:"last_used_at"` // lần refresh gần nhất
+	ExpiresAt  string `json:"expires_at"`
+	Current    bool   `json:"current"` // phiên đang gọi API
+}
+
+/************ Helpers ************/
+
+// currentSession: id phiên của refresh cookie trong request ("" nếu không có/không hợp lệ)
+func (h *AuthHandler) currentSession(c *gin.Context, uid int) string {
+	cookie, err := c.Cookie(h.cfg.CookieName)
+	if err != nil || cookie == "" {
+		return ""
+	}
+	return h.auth.SessionFamily(uid, cookie)
+}
+
+func (h *AuthHandler) writeSessions(c *gin.Context, uid int, current string) {
+	items, err := h.sessions.List(uid, current)
+	if err != nil {
+		writeErr(c, http.StatusInternalServerError, "server error")
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+func writeRevokeErr(c *gin.Context, err error) {
+	if writeGuardErr(c, err) {
+		return
+	}
+	if err == repository.ErrNotFound {
+		writeErr(c, http.StatusNotFound, "not found")
+		return
+	}
+	writeErr(c, http.StatusInternalServerError, "server error")
+}
+
+/************ Endpoints ************/
+
+// ListSessions godoc
+// @Summary      Các phiên đăng nhập (thiết bị) đang hoạt động của tôi
+// @Tags         Session
+// @Security     BearerAuth
+// @Produce      json
+// @Success      200  {array}  SessionDoc
+// @Failure      401  {object} ErrorResponse
+// @Router       /auth/sessions [get]
+func (h *AuthHandler) ListSessions(c *gin.Context) {
+	uid := c.GetInt("uid")
+	h.writeSessions(c, uid, h.currentSession(c, uid))
+}
+
+// RevokeSession godoc
+// @Summary      Đăng xuất 1 phiên/thiết bị của tôi
+// @Description  Thu hồi refresh token của phiên; access token đã cấp hết hiệu lực khi hết hạn.
+// @Tags         Session
+// @Security     BearerAuth
+// @Produce      json
+// @Param        id   path  string  true  "Session ID"
+// @Success      204  {string} string "No Content"
+// @Failure      401  {object} ErrorResponse
+// @Failure      404  {object} ErrorResponse
+// @Router       /auth/sessions/{id} [delete]
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	uid, id := c.GetInt("uid"), c.Param("id")
+	current := h.currentSession(c, uid)
+	if err := h.sessions.Revoke(actorFrom(c), uid, id); err != nil {
+		writeRevokeErr(c, err)
+		return
+	}
+	if id == current {
+		h.clearRefreshCookie(c)
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// RevokeOtherSessions godoc
+// @Summary      Đăng xuất khỏi mọi thiết bị khác
+// @Description  Giữ lại phiên của refresh cookie gửi kèm request (không có cookie => thu hồi tất cả).
+// @Tags         Session
+// @Security     BearerAuth
+// @Produce      json
+// @Success      204  {string} string "No Content"
+// @Failure      401  {object} ErrorResponse
+// @Router       /auth/sessions/revoke-others [post]
+func (h *AuthHandler) RevokeOtherSessions(c *gin.Context) {
+	uid := c.GetInt("uid")
+	if err := h.sessions.RevokeOthers(actorFrom(c), uid, h.currentSession(c, uid)); err != nil {
+		writeErr(c, http.StatusInternalServerError, "server error")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// UserSessions godoc
+// @Summary      Các phiên đăng nhập đang hoạt động của người dùng
+// @Tags         Admin
+// @Security     BearerAuth
+// @Produce      json
+// @Param        id   path  int  true  "User ID"
+// @Success      200  {array}  SessionDoc
+// @Failure      403  {object} ErrorResponse "permission_denied | mã lý do của policy (POLICY_FILE)"
+// @Failure      404  {object} ErrorResponse
+// @Router       /admin/users/{id}/sessions [get]
+func (h *AuthHandler) UserSessions(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	u, ok := h.users.loadForPolicy(c, id, false)
+	if !ok || !h.users.authorize(c, services.ActionUserSessions, services.UserAttrs(u), nil) {
+		return
+	}
+	h.writeSessions(c, id, "")
+}
+
+// RevokeUserSessions godoc
+// @Summary      Thu hồi mọi phiên đăng nhập của người dùng
+// @Description  Dùng khi xử lý sự cố: thu hồi mọi refresh token và vô hiệu ngay các access token đã cấp.
+// @Tags         Admin
+// @Security     BearerAuth
+// @Produce      json
+// @Param        id   path  int  true  "User ID"
+// @Success      204  {string} string "No Content"
+// @Failure      403  {object} ErrorResponse "permission_denied | privilege_escalation | mã lý do của policy (POLICY_FILE)"
+// @Failure      404  {object} ErrorResponse
+// @Router       /admin/users/{id}/sessions [delete]
+func (h *AuthHandler) RevokeUserSessions(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if !h.users.authorizeTarget(c, id, services.ActionUserSessions) {
+		return
+	}
+	if err := h.sessions.RevokeAll(actorFrom(c), id); err != nil {
+		writeRevokeErr(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// RevokeUserSession godoc
+// @Summary      Thu hồi 1 phiên đăng nhập của người dùng
+// @Tags         Admin
+// @Security     BearerAuth
+// @Produce      json
+// @Param        id   path  int     true  "User ID"
+// @Param        sid  path  string  true  "Session ID"
+// @Success      204  {string} string "No Content"
+// @Failure      403  {object} ErrorResponse "permission_denied | privilege_escalation | mã lý do của policy (POLICY_FILE)"
+// @Failure      404  {object} ErrorResponse
+// @Router       /admin/users/{id}/sessions/{sid} [delete]
+func (h *AuthHandler) RevokeUserSession(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if !h.users.authorizeTarget(c, id, services.ActionUserSessions) {
+		return
+	}
+	if err := h.sessions.Revoke(actorFrom(c), id, c.Param("sid")); err != nil {
+		writeRevokeErr(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
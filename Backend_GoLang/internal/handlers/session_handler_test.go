@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"crud_api_us/internal/keyset"
+	"crud_api_us/internal/models"
+	"crud_api_us/internal/repository"
+	"crud_api_us/internal/services"
+	"crud_api_us/internal/tokens"
+)
+
+// thu hồi phiên / mở khoá user có quyền cao hơn mình => 403 privilege_escalation
+func TestAdminActionsOnTargetWithinPermissions(t *testing.T) {
+	db := newTestDB(t)
+	repos := repository.NewMySQLRepos(db)
+	var perms []models.Permission
+	if err := db.Where("name IN ?", []string{models.PermUsersRead, models.PermUsersWrite}).Find(&perms).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&models.Role{Name: "support", Permissions: perms}).Error; err != nil {
+		t.Fatal(err)
+	}
+	us := seedUsers(t, db,
+		models.User{Username: "admin", Email: "admin@x.com", Role: models.RoleAdmin},
+		models.User{Username: "support", Email: "support@x.com", Role: "support"},
+		models.User{Username: "support2", Email: "support2@x.com", Role: "support"},
+		models.User{Username: "bob", Email: "bob@x.com", Role: models.RoleUser},
+	)
+	admin, support, support2, bob := us[0], us[1], us[2], us[3]
+
+	keys, err := keyset.New(keyset.Config{Alg: "HS256", Secret: "test-secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := NewAuthHandler(repos, NewUserHandler(repos, nil), services.JWTConfig{}, tokens.NewManager(keys, tokens.Config{}),
+		services.AccountConfig{}, services.MFAConfig{}, nil, nil, nil)
+	route := func(u models.User) *gin.Engine {
+		r := gin.New()
+		r.POST("/users/:id/unlock", asUser(u), a.UnlockUser)
+		r.DELETE("/users/:id/sessions", asUser(u), a.RevokeUserSessions)
+		r.DELETE("/users/:id/sessions/:sid", asUser(u), a.RevokeUserSession)
+		return r
+	}
+
+	tests := []struct {
+		name   string
+		as     models.User
+		target models.User
+		code   int
+	}{
+		{"support on admin", support, admin, 403},
+		{"support on same role", support, support2, 204},
+		{"support on user", support, bob, 204},
+		{"admin on support", admin, support, 204},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := strconv.Itoa(tt.target.ID)
+			for _, req := range []struct{ method, path string }{
+				{"POST", "/users/" + id + "/unlock"},
+				{"DELETE", "/users/" + id + "/sessions"},
+				{"DELETE", "/users/" + id + "/sessions/none"},
+			} {
+				w := serve(route(tt.as), req.method, req.path, "")
+				code := tt.code
+				if code == 204 && req.path == "/users/"+id+"/sessions/none" {
+					code = 404 // qua được kiểm tra quyền, phiên không tồn tại
+				}
+				if w.Code != code {
+					t.Fatalf("%s %s: status %d, want %d: %s", req.method, req.path, w.Code, code, w.Body)
+				}
+				if code == 403 {
+					var e struct{ Code string }
+					_ = json.Unmarshal(w.Body.Bytes(), &e)
+					if e.Code != "privilege_escalation" {
+						t.Errorf("%s %s: code %q, want privilege_escalation", req.method, req.path, e.Code)
+					}
+				}
+			}
+		})
+	}
+	// user không tồn tại => 404 trước khi xét quyền
+	if w := serve(route(support), "DELETE", "/users/999/sessions/none", ""); w.Code != 404 {
+		t.Errorf("missing user: status %d, want 404", w.Code)
+	}
+}
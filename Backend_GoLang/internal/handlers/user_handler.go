@@ -1,20 +1,32 @@
 package handlers
 
 import (
+	"encoding/json"
+	"maps"
 	"net/http"
+	"reflect"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 
+	"crud_api_us/internal/models"
+	"crud_api_us/internal/policy"
 	"crud_api_us/internal/repository"
 	"crud_api_us/internal/services"
 )
 
-// Handler nắm Service
-type UserHandler struct{ svc *services.UserService }
+// Handler nắm Service; policy != nil => mọi API quản lý user hỏi thêm policy ABAC (POLICY_FILE)
+type UserHandler struct {
+	svc    *services.UserService
+	policy *services.UserPolicy
+}
 
-func NewUserHandler(repo repository.UserRepository) *UserHandler {
-	return &UserHandler{svc: services.NewUserService(repo)}
+func NewUserHandler(repos repository.Repos, pol *services.UserPolicy) *UserHandler {
+	return &UserHandler{svc: services.NewUserService(repos), policy: pol}
 }
 
 /************* DTO (request) *************/
@@ -32,7 +44,7 @@ type CreateUserRequest struct {
 	State      string `json:"state"        binding:"omitempty,max=100"`
 	Country    string `json:"country"      binding:"omitempty,max=100"`
 	PostalCode string `json:"postal_code"  binding:"omitempty,max=20"`
-	Role       string `json:"role"         binding:"omitempty,oneof=user admin"`
+	Role       string `json:"role"         binding:"omitempty,max=20"`
 	Status     string `json:"status"       binding:"omitempty,oneof=active inactive banned"`
 }
 
@@ -50,29 +62,386 @@ type UpdateUserRequest struct {
 	State      string `json:"state"        binding:"omitempty,max=100"`
 	Country    string `json:"country"      binding:"omitempty,max=100"`
 	PostalCode string `json:"postal_code"  binding:"omitempty,max=20"`
-	Role       string `json:"role"         binding:"omitempty,oneof=user admin"`
+	Role       string `json:"role"         binding:"omitempty,max=20"`
 	Status     string `json:"status"       binding:"omitempty,oneof=active inactive banned"`
 }
 
+// ListUsersQuery: query string của GET /admin/users
+type ListUsersQuery struct {
+	Page        int    `form:"page"         binding:"omitempty,min=1"`
+	Limit       int    `form:"limit"        binding:"omitempty,min=1,max=100"`
+	Cursor      string `form:"cursor"`
+	Sort        string `form:"sort"         binding:"omitempty,oneof=id username email full_name created_at updated_at"`
+	Order       string `form:"order"        binding:"omitempty,oneof=asc desc"`
+	Role        string `form:"role"         binding:"omitempty,max=20"`
+	Status      string `form:"status"       binding:"omitempty,oneof=active inactive banned"`
+	Gender      string `form:"gender"       binding:"omitempty,oneof=male female other"`
+	Country     string `form:"country"      binding:"omitempty,max=100"`
+	CreatedFrom string `form:"created_from"` // yyyy-mm-dd hoặc RFC3339
+	CreatedTo   string `form:"created_to"`   // yyyy-mm-dd (tính hết ngày) hoặc RFC3339
+	Q           string `form:"q"            binding:"omitempty,max=100"`
+}
+
+// UserFilterQuery: bộ lọc phạm vi của GET /admin/users/search và /admin/users/deleted
+// (policy user:list kiểm tra như bộ lọc của ListUsers)
+type UserFilterQuery struct {
+	Role    string `form:"role"    binding:"omitempty,max=20"`
+	Status  string `form:"status"  binding:"omitempty,oneof=active inactive banned"`
+	Country string `form:"country" binding:"omitempty,max=100"`
+}
+
+func (q UserFilterQuery) filter() repository.UserFilter {
+	return repository.UserFilter{Role: q.Role, Status: q.Status, Country: q.Country}
+}
+
+// SearchUsersQuery: query string của GET /admin/users/search
+type SearchUsersQuery struct {
+	Q     string `form:"q"     binding:"required,max=100"`
+	Limit int    `form:"limit" binding:"omitempty,min=1,max=100"`
+	UserFilterQuery
+}
+
+// fields trả về con trỏ tới từng field theo tên JSON (dùng khi áp merge patch)
+func (r *UpdateUserRequest) fields() map[string]*string {
+	return map[string]*string{
+		"username": &r.Username, "email": &r.Email, "password": &r.Password,
+		"full_name": &r.FullName, "phone": &r.Phone, "gender": &r.Gender, "date_of_birth": &r.DOB,
+		"avatar_url": &r.AvatarURL, "street": &r.Street, "city": &r.City, "state": &r.State,
+		"country": &r.Country, "postal_code": &r.PostalCode, "role": &r.Role, "status": &r.Status,
+	}
+}
+
+// updateRequestFrom dựng UpdateUserRequest từ trạng thái hiện tại của user
+func updateRequestFrom(u models.User) UpdateUserRequest {
+	in := UpdateUserRequest{
+		Username: u.Username, Email: u.Email, FullName: u.FullName, Phone: u.Phone, Gender: u.Gender,
+		AvatarURL: u.AvatarURL, Street: u.Street, City: u.City, State: u.State,
+		Country: u.Country, PostalCode: u.PostalCode, Role: u.Role, Status: u.Status,
+	}
+	if u.DateOfBirth != nil {
+		in.DOB = u.DateOfBirth.Format("2006-01-02")
+	}
+	return in
+}
+
+// Các field không được set null trong merge patch (bắt buộc hoặc không có nghĩa khi "xoá")
+var nonNullablePatchFields = map[string]bool{
+	"username": true, "email": true, "password": true, "role": true, "status": true,
+}
+
+/************* DTO (docs/response) *************/
+type UserPageDoc struct {
+	Items      []UserDoc `json:"items"`
+	Total      int64     `json:"total"`
+	Page       int       `json:"page"`
+	Limit      int       `json:"limit"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+type SearchHitDoc struct {
+	User       UserDoc           `json:"user"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights,omitempty"`
+}
+
+type DeletedUserDoc struct {
+	UserDoc
+	DeletedAt string `json:"deleted_at"`
+}
+
+type DeletedUserPage struct {
+	Items []DeletedUserDoc `json:"items"`
+	Total int64            `json:"total"`
+	Page  int              `json:"page"`
+	Limit int              `json:"limit"`
+}
+
 /************* Helpers *************/
+
+// actorFrom: người thực hiện request (uid do middleware WithAuth gắn, request_id do RequestID gắn)
+func actorFrom(c *gin.Context) services.Actor {
+	return services.Actor{UserID: c.GetInt("uid"), IP: c.ClientIP(), RequestID: c.GetString("request_id")}
+}
 func writeErr(c *gin.Context, code int, msg string) { c.JSON(code, gin.H{"error": msg}) }
 
+// parsePage đọc ?page=&limit= (mặc định 1/20, limit tối đa 100)
+func parsePage(c *gin.Context) (page, limit int) {
+	page, _ = strconv.Atoi(c.Query("page"))
+	limit, _ = strconv.Atoi(c.Query("limit"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	return page, limit
+}
+
+// parseTimeParam nhận yyyy-mm-dd hoặc RFC3339; endOfDay=true thì yyyy-mm-dd tính đến hết ngày
+func parseTimeParam(s string, endOfDay bool) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return &t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return nil, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+// etag của user dựa trên cột version (đổi sau mỗi lần cập nhật)
+func etag(u models.User) string { return strconv.Quote(strconv.Itoa(u.Version)) }
+
+// ifMatchVersion đọc header If-Match: rỗng hoặc "*" => 0 (không kiểm tra).
+// ok=false nếu header không phải ETag hợp lệ của API này.
+func ifMatchVersion(c *gin.Context) (version int, ok bool) {
+	h := strings.TrimSpace(c.GetHeader("If-Match"))
+	if h == "" || h == "*" {
+		return 0, true
+	}
+	h = strings.TrimPrefix(h, "W/")
+	s, err := strconv.Unquote(h)
+	if err != nil {
+		return 0, false
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < 1 {
+		return 0, false
+	}
+	return v, true
+}
+
+// writeUserWithETag trả user kèm header ETag
+func writeUserWithETag(c *gin.Context, code int, u models.User) {
+	c.Header("ETag", etag(u))
+	c.JSON(code, u)
+}
+
+// writeErrCode: kèm mã lỗi máy đọc được (FE dùng để hiển thị thông báo riêng)
+func writeErrCode(c *gin.Context, code int, errCode, msg string) {
+	c.JSON(code, gin.H{"error": msg, "code": errCode})
+}
+
+// authorize: hook ABAC cho action trên resource (thuộc tính user bị tác động) với changes
+// (giá trị mới của các field bị đổi). ok=false => đã trả 403 kèm mã lý do của policy.
+func (h *UserHandler) authorize(c *gin.Context, action string, resource, changes policy.Attrs) bool {
+	if h.policy == nil {
+		return true
+	}
+	d, err := h.policy.Authorize(c.GetInt("uid"), c.GetString("role"), action, resource, changes)
+	if err != nil {
+		writeErr(c, http.StatusInternalServerError, "server error")
+		return false
+	}
+	if !d.Allowed {
+		writeErrCode(c, http.StatusForbidden, d.Reason, "forbidden by policy")
+		return false
+	}
+	return true
+}
+
+// listResource: resource của user:list = bộ lọc => policy có thể bắt buộc lọc theo country, ...
+// (bộ lọc này phải được đẩy xuống câu truy vấn, không chỉ dùng để hỏi policy)
+func listResource(f repository.UserFilter) policy.Attrs {
+	return policy.Attrs{"role": f.Role, "status": f.Status, "country": f.Country}
+}
+
+// policyChanges: giá trị mới của các field khác nhau giữa before và after
+// (mật khẩu không đưa giá trị, chỉ ghi nhận "set")
+func policyChanges(before, after UpdateUserRequest) policy.Attrs {
+	b, a := before.fields(), after.fields()
+	out := policy.Attrs{}
+	for k, v := range a {
+		if *v == *b[k] {
+			continue
+		}
+		if k == "password" {
+			out[k] = "set"
+			continue
+		}
+		out[k] = *v
+	}
+	return out
+}
+
+// patchChanges: policyChanges của merge patch p áp lên cur
+func patchChanges(cur models.User, p services.PatchParams) policy.Attrs {
+	after := updateRequestFrom(cur)
+	fields := after.fields()
+	for k, v := range p {
+		*fields[k] = ""
+		if v != nil {
+			*fields[k] = *v
+		}
+	}
+	return policyChanges(updateRequestFrom(cur), after)
+}
+
+// authorizeTarget: hook ABAC cho action lên user id (resource = thuộc tính của user đó).
+// ok=false => đã trả lỗi (404/403/500).
+func (h *UserHandler) authorizeTarget(c *gin.Context, id int, action string) bool {
+	if h.policy == nil {
+		return true
+	}
+	u, ok := h.loadForPolicy(c, id, false)
+	return ok && h.authorize(c, action, services.UserAttrs(u), nil)
+}
+
+// loadForPolicy đọc user id (deleted: user đã soft delete) để làm resource cho policy.
+// ok=false => đã trả lỗi (404/500).
+func (h *UserHandler) loadForPolicy(c *gin.Context, id int, deleted bool) (models.User, bool) {
+	get := h.svc.Get
+	if deleted {
+		get = h.svc.GetDeleted
+	}
+	u, err := get(id)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			writeErr(c, http.StatusNotFound, "not found")
+			return models.User{}, false
+		}
+		writeErr(c, http.StatusInternalServerError, "server error")
+		return models.User{}, false
+	}
+	return u, true
+}
+
+// confirmed: ?confirm=true — xác nhận thao tác lên chính mình (tự đổi role, tự khoá, tự xoá)
+func confirmed(c *gin.Context) bool {
+	ok, _ := strconv.ParseBool(c.Query("confirm"))
+	return ok
+}
+
+// writeGuardErr: lỗi bất biến của UserService (services.guardChange); false nếu err không thuộc nhóm này
+func writeGuardErr(c *gin.Context, err error) bool {
+	switch err {
+	case services.ErrLastAdmin:
+		writeErrCode(c, http.StatusConflict, "last_admin", "at least one active admin must remain")
+	case services.ErrConfirmRequired:
+		writeErrCode(c, http.StatusUnprocessableEntity, "confirmation_required",
+			"changing your own role/status or deleting yourself requires ?confirm=true")
+	case services.ErrRoleChangeDenied:
+		writeErrCode(c, http.StatusForbidden, "permission_denied", "changing roles requires the users:role permission")
+	case services.ErrPrivilegeEscalation:
+		writeErrCode(c, http.StatusForbidden, "privilege_escalation", "target role has permissions you do not have")
+	default:
+		return false
+	}
+	return true
+}
+
 /************* Handlers + Swagger *************/
 
 // ListUsers godoc
-// @Summary      Danh sách người dùng
+// @Summary      Danh sách người dùng (lọc, sắp xếp, phân trang)
 // @Tags         Admin
 // @Security     BearerAuth
 // @Produce      json
-// @Success      200  {array}  UserDoc
+// @Param        page          query    int     false  "Trang (mặc định 1, bỏ qua nếu có cursor)"
+// @Param        limit         query    int     false  "Số dòng/trang (mặc định 20, tối đa 100)"
+// @Param        cursor        query    string  false  "next_cursor của trang trước (keyset pagination)"
+// @Param        sort          query    string  false  "Cột sắp xếp"  Enums(id, username, email, full_name, created_at, updated_at)
+// @Param        order         query    string  false  "Chiều sắp xếp"  Enums(asc, desc)
+// @Param        role          query    string  false  "Lọc theo vai trò"
+// @Param        status        query    string  false  "Lọc theo trạng thái"  Enums(active, inactive, banned)
+// @Param        gender        query    string  false  "Lọc theo giới tính"  Enums(male, female, other)
+// @Param        country       query    string  false  "Lọc theo quốc gia"
+// @Param        created_from  query    string  false  "Tạo từ ngày (yyyy-mm-dd hoặc RFC3339)"
+// @Param        created_to    query    string  false  "Tạo đến ngày (yyyy-mm-dd hoặc RFC3339)"
+// @Param        q             query    string  false  "Tìm theo username/email/full_name/phone"
+// @Success      200  {object} UserPageDoc
+// @Failure      400  {object} ErrorResponse
+// @Failure      403  {object} ErrorResponse "permission_denied | mã lý do của policy (POLICY_FILE)"
 // @Router       /admin/users [get]
 func (h *UserHandler) ListUsers(c *gin.Context) {
-	users, err := h.svc.List()
+	var in ListUsersQuery
+	if err := c.ShouldBindQuery(&in); err != nil {
+		writeErr(c, http.StatusBadRequest, "invalid query")
+		return
+	}
+	if !h.authorize(c, services.ActionUserList, listResource(repository.UserFilter{Role: in.Role, Status: in.Status, Country: in.Country}), nil) {
+		return
+	}
+	q := repository.UserQuery{
+		Page: max(in.Page, 1), Limit: in.Limit, Cursor: in.Cursor,
+		Sort: in.Sort, Desc: in.Order == "desc",
+		Role: in.Role, Status: in.Status, Gender: in.Gender, Country: in.Country,
+		Search: in.Q,
+	}
+	if q.Limit == 0 {
+		q.Limit = 20
+	}
+	var err error
+	if q.CreatedFrom, err = parseTimeParam(in.CreatedFrom, false); err != nil {
+		writeErr(c, http.StatusBadRequest, "invalid created_from")
+		return
+	}
+	if q.CreatedTo, err = parseTimeParam(in.CreatedTo, true); err != nil {
+		writeErr(c, http.StatusBadRequest, "invalid created_to")
+		return
+	}
+
+	page, err := h.svc.List(q)
+	if err != nil {
+		if err == services.ErrBadInput {
+			writeErr(c, http.StatusBadRequest, "invalid query")
+			return
+		}
+		writeErr(c, http.StatusInternalServerError, "server error")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"items": page.Items, "total": page.Total,
+		"page": q.Page, "limit": q.Limit, "next_cursor": page.NextCursor,
+	})
+}
+
+// SearchUsers godoc
+// @Summary      Tìm kiếm người dùng (FULLTEXT, xếp theo độ liên quan)
+// @Description  Tìm trên full_name, email, username, phone, city, country. Đoạn khớp được bọc <mark>.
+// @Tags         Admin
+// @Security     BearerAuth
+// @Produce      json
+// @Param        q        query    string  true   "Từ khoá"
+// @Param        limit    query    int     false  "Số kết quả (mặc định 20, tối đa 100)"
+// @Param        role     query    string  false  "Chỉ tìm trong vai trò"
+// @Param        status   query    string  false  "Chỉ tìm trong trạng thái"  Enums(active, inactive, banned)
+// @Param        country  query    string  false  "Chỉ tìm trong quốc gia"
+// @Success      200  {array}  SearchHitDoc
+// @Failure      400  {object} ErrorResponse
+// @Failure      403  {object} ErrorResponse "permission_denied | mã lý do của policy (POLICY_FILE)"
+// @Router       /admin/users/search [get]
+func (h *UserHandler) SearchUsers(c *gin.Context) {
+	var in SearchUsersQuery
+	if err := c.ShouldBindQuery(&in); err != nil {
+		writeErr(c, http.StatusBadRequest, "invalid query")
+		return
+	}
+	if in.Limit == 0 {
+		in.Limit = 20
+	}
+	if !h.authorize(c, services.ActionUserList, listResource(in.filter()), nil) {
+		return
+	}
+	hits, err := h.svc.Search(in.Q, in.filter(), in.Limit)
 	if err != nil {
+		if err == services.ErrBadInput {
+			writeErr(c, http.StatusBadRequest, "invalid query")
+			return
+		}
 		writeErr(c, http.StatusInternalServerError, "server error")
 		return
 	}
-	c.JSON(http.StatusOK, users)
+	c.JSON(http.StatusOK, hits)
 }
 
 // GetUser godoc
@@ -82,20 +451,17 @@ func (h *UserHandler) ListUsers(c *gin.Context) {
 // @Produce      json
 // @Param        id  path      int  true  "User ID"
 // @Success      200  {object} UserDoc
+// @Header       200  {string} ETag "Phiên bản hiện tại, gửi lại qua If-Match khi sửa/xoá"
 // @Failure      404  {object} ErrorResponse
+// @Failure      403  {object} ErrorResponse "permission_denied | mã lý do của policy (POLICY_FILE)"
 // @Router       /admin/users/{id} [get]
 func (h *UserHandler) GetUser(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
-	u, err := h.svc.Get(id)
-	if err != nil {
-		if err == repository.ErrNotFound {
-			writeErr(c, http.StatusNotFound, "not found")
-			return
-		}
-		writeErr(c, http.StatusInternalServerError, "server error")
+	u, ok := h.loadForPolicy(c, id, false)
+	if !ok || !h.authorize(c, services.ActionUserRead, services.UserAttrs(u), nil) {
 		return
 	}
-	c.JSON(http.StatusOK, u)
+	writeUserWithETag(c, http.StatusOK, u)
 }
 
 // CreateUser godoc
@@ -104,10 +470,12 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 // @Security     BearerAuth
 // @Accept       json
 // @Produce      json
+// @Description  Gán role khác "user" cần quyền users:role và role đó không được có quyền vượt quá quyền của người tạo.
 // @Param        user  body     CreateUserRequest  true  "User payload"
 // @Success      201   {object} UserDoc
 // @Failure      400   {object} ErrorResponse
 // @Failure      409   {object} ErrorResponse
+// @Failure      403   {object} ErrorResponse "permission_denied | privilege_escalation | mã lý do của policy (POLICY_FILE)"
 // @Router       /admin/users [post]
 func (h *UserHandler) CreateUser(c *gin.Context) {
 	var in CreateUserRequest
@@ -115,11 +483,23 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 		writeErr(c, http.StatusBadRequest, "invalid body")
 		return
 	}
-	out, err := h.svc.Create(services.CreateParams{
+	// resource của user:create = user sắp tạo (role/status mặc định như service)
+	target := models.User{Email: in.Email, Country: in.Country, Role: in.Role, Status: in.Status}
+	if target.Role == "" {
+		target.Role = models.RoleUser
+	}
+	if target.Status == "" {
+		target.Status = "active"
+	}
+	if !h.authorize(c, services.ActionUserCreate, services.UserAttrs(target), policyChanges(UpdateUserRequest{}, UpdateUserRequest(in))) {
+		return
+	}
+	out, err := h.svc.Create(actorFrom(c), services.CreateParams{
 		Username: in.Username, Email: in.Email, Password: in.Password,
 		FullName: in.FullName, Phone: in.Phone, Gender: in.Gender, DOB: in.DOB,
 		AvatarURL: in.AvatarURL, Street: in.Street, City: in.City, State: in.State,
 		Country: in.Country, PostalCode: in.PostalCode, Role: in.Role, Status: in.Status,
+		EmailVerified: true, // admin tạo tài khoản => không cần xác thực email
 	})
 	if err != nil {
 		switch err {
@@ -127,12 +507,16 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 			writeErr(c, http.StatusConflict, "username/email already exists")
 		case services.ErrBadInput:
 			writeErr(c, http.StatusBadRequest, "invalid body")
+		case services.ErrUnknownRole:
+			writeErrCode(c, http.StatusBadRequest, "unknown_role", "unknown role")
 		default:
-			writeErr(c, http.StatusInternalServerError, "server error")
+			if !writeGuardErr(c, err) {
+				writeErr(c, http.StatusInternalServerError, "server error")
+			}
 		}
 		return
 	}
-	c.JSON(http.StatusCreated, out)
+	writeUserWithETag(c, http.StatusCreated, out)
 }
 
 // UpdateUser godoc
@@ -141,40 +525,197 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 // @Security     BearerAuth
 // @Accept       json
 // @Produce      json
-// @Param        id    path     int                true  "User ID"
-// @Param        user  body     UpdateUserRequest  true  "User payload"
+// @Param        id        path     int                true   "User ID"
+// @Param        If-Match  header   string             false  "ETag từ GET /admin/users/{id}"
+// @Param        user      body     UpdateUserRequest  true   "User payload"
+// @Param        confirm   query    bool               false  "Xác nhận tự đổi role / tự khoá chính mình"
 // @Success      200   {object} UserDoc
 // @Failure      400   {object} ErrorResponse
 // @Failure      404   {object} ErrorResponse
-// @Failure      409   {object} ErrorResponse
+// @Failure      409   {object} ErrorResponse "username/email đã tồn tại | last_admin"
+// @Failure      412   {object} ErrorResponse
+// @Failure      422   {object} ErrorResponse "confirmation_required"
+// @Failure      403   {object} ErrorResponse "permission_denied | privilege_escalation | mã lý do của policy (POLICY_FILE)"
 // @Router       /admin/users/{id} [put]
 func (h *UserHandler) UpdateUser(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		writeErr(c, http.StatusPreconditionFailed, "precondition failed")
+		return
+	}
 	var in UpdateUserRequest
 	if err := c.ShouldBindJSON(&in); err != nil {
 		writeErr(c, http.StatusBadRequest, "invalid body")
 		return
 	}
-	out, err := h.svc.Update(id, services.UpdateParams{
+	if h.policy != nil {
+		cur, ok := h.loadForPolicy(c, id, false)
+		if !ok || !h.authorize(c, services.ActionUserUpdate, services.UserAttrs(cur), policyChanges(updateRequestFrom(cur), in)) {
+			return
+		}
+	}
+	out, err := h.svc.Update(actorFrom(c), id, services.UpdateParams{
 		Username: in.Username, Email: in.Email, Password: in.Password,
 		FullName: in.FullName, Phone: in.Phone, Gender: in.Gender, DOB: in.DOB,
 		AvatarURL: in.AvatarURL, Street: in.Street, City: in.City, State: in.State,
 		Country: in.Country, PostalCode: in.PostalCode, Role: in.Role, Status: in.Status,
-	})
+	}, version, confirmed(c))
 	if err != nil {
 		switch err {
 		case repository.ErrNotFound:
 			writeErr(c, http.StatusNotFound, "not found")
+		case repository.ErrVersionMismatch:
+			writeErr(c, http.StatusPreconditionFailed, "user was modified by someone else")
 		case services.ErrDuplicate:
 			writeErr(c, http.StatusConflict, "username/email already exists")
 		case services.ErrBadInput:
 			writeErr(c, http.StatusBadRequest, "invalid body")
+		case services.ErrUnknownRole:
+			writeErrCode(c, http.StatusBadRequest, "unknown_role", "unknown role")
 		default:
-			writeErr(c, http.StatusInternalServerError, "server error")
+			if !writeGuardErr(c, err) {
+				writeErr(c, http.StatusInternalServerError, "server error")
+			}
+		}
+		return
+	}
+	writeUserWithETag(c, http.StatusOK, out)
+}
+
+// PatchUser godoc
+// @Summary      Cập nhật một phần người dùng (JSON Merge Patch - RFC 7396)
+// @Description  Chỉ gửi các field cần đổi. null xoá giá trị (không áp dụng cho username/email/password/role/status).
+// @Tags         Admin
+// @Security     BearerAuth
+// @Accept       json
+// @Accept       application/merge-patch+json
+// @Produce      json
+// @Param        id        path     int                true   "User ID"
+// @Param        If-Match  header   string             false  "ETag từ GET /admin/users/{id}"
+// @Param        patch     body     UpdateUserRequest  true   "Các field cần đổi"
+// @Param        confirm   query    bool               false  "Xác nhận tự đổi role / tự khoá chính mình"
+// @Success      200   {object} UserDoc
+// @Failure      400   {object} ErrorResponse
+// @Failure      404   {object} ErrorResponse
+// @Failure      412   {object} ErrorResponse
+// @Failure      409   {object} ErrorResponse "username/email đã tồn tại | last_admin"
+// @Failure      415   {object} ErrorResponse
+// @Failure      422   {object} ErrorResponse "confirmation_required"
+// @Failure      403   {object} ErrorResponse "permission_denied | privilege_escalation | mã lý do của policy (POLICY_FILE)"
+// @Router       /admin/users/{id} [patch]
+func (h *UserHandler) PatchUser(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		writeErr(c, http.StatusPreconditionFailed, "precondition failed")
+		return
+	}
+	patch, cur, ok := h.bindMergePatch(c, id, nil)
+	if !ok || !h.authorize(c, services.ActionUserUpdate, services.UserAttrs(cur), patchChanges(cur, patch)) {
+		return
+	}
+	out, err := h.svc.Patch(actorFrom(c), id, patch, version, confirmed(c))
+	if err != nil {
+		switch err {
+		case repository.ErrNotFound:
+			writeErr(c, http.StatusNotFound, "not found")
+		case repository.ErrVersionMismatch:
+			writeErr(c, http.StatusPreconditionFailed, "user was modified by someone else")
+		case services.ErrDuplicate:
+			writeErr(c, http.StatusConflict, "username/email already exists")
+		case services.ErrBadInput:
+			writeErr(c, http.StatusBadRequest, "invalid body")
+		case services.ErrUnknownRole:
+			writeErrCode(c, http.StatusBadRequest, "unknown_role", "unknown role")
+		default:
+			if !writeGuardErr(c, err) {
+				writeErr(c, http.StatusInternalServerError, "server error")
+			}
 		}
 		return
 	}
-	c.JSON(http.StatusOK, out)
+	writeUserWithETag(c, http.StatusOK, out)
+}
+
+// bindMergePatch đọc body merge patch, áp lên trạng thái hiện tại của user rồi validate
+// bằng đúng rule của UpdateUserRequest. denied: các field không được phép đổi.
+// Trả về ok=false nếu đã ghi response lỗi; cur là trạng thái user trước khi áp patch.
+func (h *UserHandler) bindMergePatch(c *gin.Context, id int, denied map[string]bool) (services.PatchParams, models.User, bool) {
+	if ct := c.ContentType(); ct != "application/merge-patch+json" && ct != "application/json" {
+		writeErr(c, http.StatusUnsupportedMediaType, "content type must be application/merge-patch+json")
+		return nil, models.User{}, false
+	}
+	var raw map[string]json.RawMessage
+	if err := json.NewDecoder(c.Request.Body).Decode(&raw); err != nil || raw == nil {
+		writeErr(c, http.StatusBadRequest, "invalid body")
+		return nil, models.User{}, false
+	}
+
+	cur, err := h.svc.Get(id)
+	if err != nil {
+		if err == repository.ErrNotFound {
+			writeErr(c, http.StatusNotFound, "not found")
+			return nil, models.User{}, false
+		}
+		writeErr(c, http.StatusInternalServerError, "server error")
+		return nil, models.User{}, false
+	}
+
+	merged := updateRequestFrom(cur)
+	fields := merged.fields()
+	patch := services.PatchParams{}
+	for k, v := range raw {
+		dst, ok := fields[k]
+		if !ok || denied[k] {
+			writeErr(c, http.StatusBadRequest, "field not allowed: "+k)
+			return nil, models.User{}, false
+		}
+		if string(v) == "null" {
+			if nonNullablePatchFields[k] {
+				writeErr(c, http.StatusBadRequest, "field cannot be null: "+k)
+				return nil, models.User{}, false
+			}
+			*dst = ""
+			patch[k] = nil
+			continue
+		}
+		var str string
+		if err := json.Unmarshal(v, &str); err != nil {
+			writeErr(c, http.StatusBadRequest, "invalid value for field: "+k)
+			return nil, models.User{}, false
+		}
+		*dst = str
+		patch[k] = &str
+	}
+	if err := validatePartial(&merged, slices.Collect(maps.Keys(raw))); err != nil {
+		writeErr(c, http.StatusBadRequest, "invalid body")
+		return nil, models.User{}, false
+	}
+	return patch, cur, true
+}
+
+// validatePartial chạy rule của UpdateUserRequest chỉ cho các field (tên JSON) trong keys,
+// để dữ liệu cũ không còn khớp rule (vd username ngắn) không chặn việc sửa field khác
+func validatePartial(in *UpdateUserRequest, keys []string) error {
+	v, ok := binding.Validator.Engine().(interface {
+		StructPartial(s any, fields ...string) error
+	})
+	if !ok {
+		return binding.Validator.ValidateStruct(in)
+	}
+	var names []string
+	t := reflect.TypeOf(*in)
+	for i := 0; i < t.NumField(); i++ {
+		tag, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if slices.Contains(keys, tag) {
+			names = append(names, t.Field(i).Name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	return v.StructPartial(in, names...)
 }
 
 // DeleteUser godoc
@@ -182,15 +723,35 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 // @Tags         Admin
 // @Security     BearerAuth
 // @Produce      json
-// @Param        id  path  int  true  "User ID"
+// @Param        id        path    int     true   "User ID"
+// @Param        If-Match  header  string  false  "ETag từ GET /admin/users/{id}"
+// @Param        confirm   query   bool    false  "Xác nhận tự xoá chính mình"
 // @Success      204  {string} string "No Content"
 // @Failure      404  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse "last_admin"
+// @Failure      412  {object}  ErrorResponse
+// @Failure      422  {object}  ErrorResponse "confirmation_required"
+// @Failure      403  {object} ErrorResponse "permission_denied | privilege_escalation | mã lý do của policy (POLICY_FILE)"
 // @Router       /admin/users/{id} [delete]
 func (h *UserHandler) DeleteUser(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
-	ok, err := h.svc.Delete(id)
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		writeErr(c, http.StatusPreconditionFailed, "precondition failed")
+		return
+	}
+	if !h.authorizeTarget(c, id, services.ActionUserDelete) {
+		return
+	}
+	ok, err := h.svc.Delete(actorFrom(c), id, version, confirmed(c))
 	if err != nil {
-		writeErr(c, http.StatusInternalServerError, "server error")
+		if err == repository.ErrVersionMismatch {
+			writeErr(c, http.StatusPreconditionFailed, "user was modified by someone else")
+			return
+		}
+		if !writeGuardErr(c, err) {
+			writeErr(c, http.StatusInternalServerError, "server error")
+		}
 		return
 	}
 	if !ok {
@@ -199,3 +760,98 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 	}
 	c.Status(http.StatusNoContent)
 }
+
+// ListDeletedUsers godoc
+// @Summary      Danh sách người dùng đã xoá (soft delete)
+// @Tags         Admin
+// @Security     BearerAuth
+// @Produce      json
+// @Param        page     query    int     false  "Trang (mặc định 1)"
+// @Param        limit    query    int     false  "Số dòng/trang (mặc định 20, tối đa 100)"
+// @Param        role     query    string  false  "Lọc theo vai trò"
+// @Param        status   query    string  false  "Lọc theo trạng thái"  Enums(active, inactive, banned)
+// @Param        country  query    string  false  "Lọc theo quốc gia"
+// @Success      200  {object} DeletedUserPage
+// @Failure      400  {object} ErrorResponse
+// @Failure      403  {object} ErrorResponse "permission_denied | mã lý do của policy (POLICY_FILE)"
+// @Router       /admin/users/deleted [get]
+func (h *UserHandler) ListDeletedUsers(c *gin.Context) {
+	page, limit := parsePage(c)
+	var in UserFilterQuery
+	if err := c.ShouldBindQuery(&in); err != nil {
+		writeErr(c, http.StatusBadRequest, "invalid query")
+		return
+	}
+	if !h.authorize(c, services.ActionUserList, listResource(in.filter()), nil) {
+		return
+	}
+	items, total, err := h.svc.ListDeleted(in.filter(), page, limit)
+	if err != nil {
+		writeErr(c, http.StatusInternalServerError, "server error")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items, "total": total, "page": page, "limit": limit})
+}
+
+// RestoreUser godoc
+// @Summary      Khôi phục người dùng đã xoá
+// @Tags         Admin
+// @Security     BearerAuth
+// @Produce      json
+// @Param        id  path  int  true  "User ID"
+// @Success      200  {object} UserDoc
+// @Failure      404  {object} ErrorResponse
+// @Failure      409  {object} ErrorResponse "username/email đã được user khác dùng"
+// @Failure      403  {object} ErrorResponse "permission_denied | mã lý do của policy (POLICY_FILE)"
+// @Router       /admin/users/{id}/restore [post]
+func (h *UserHandler) RestoreUser(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if h.policy != nil {
+		cur, ok := h.loadForPolicy(c, id, true)
+		if !ok || !h.authorize(c, services.ActionUserRestore, services.UserAttrs(cur), nil) {
+			return
+		}
+	}
+	out, err := h.svc.Restore(actorFrom(c), id)
+	if err != nil {
+		switch err {
+		case repository.ErrNotFound:
+			writeErr(c, http.StatusNotFound, "not found")
+		case services.ErrDuplicate:
+			writeErr(c, http.StatusConflict, "username/email already used by another user")
+		default:
+			writeErr(c, http.StatusInternalServerError, "server error")
+		}
+		return
+	}
+	writeUserWithETag(c, http.StatusOK, out)
+}
+
+// PurgeUser godoc
+// @Summary      Xoá vĩnh viễn người dùng (chỉ áp dụng cho user đã xoá)
+// @Tags         Admin
+// @Security     BearerAuth
+// @Produce      json
+// @Param        id  path  int  true  "User ID"
+// @Success      204  {string} string "No Content"
+// @Failure      404  {object} ErrorResponse
+// @Failure      403  {object} ErrorResponse "permission_denied | mã lý do của policy (POLICY_FILE)"
+// @Router       /admin/users/{id}/purge [delete]
+func (h *UserHandler) PurgeUser(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if h.policy != nil {
+		cur, ok := h.loadForPolicy(c, id, true)
+		if !ok || !h.authorize(c, services.ActionUserPurge, services.UserAttrs(cur), nil) {
+			return
+		}
+	}
+	if err := h.svc.Purge(actorFrom(c), id); err != nil {
+		if err == repository.ErrNotFound {
+			writeErr(c, http.StatusNotFound, "not found")
+			return
+		}
+		writeErr(c, http.StatusInternalServerError, "server error")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
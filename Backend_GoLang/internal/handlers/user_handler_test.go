@@ -0,0 +1,599 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"crud_api_us/internal/models"
+	"crud_api_us/internal/policy"
+	"crud_api_us/internal/repository"
+	"crud_api_us/internal/services"
+)
+
+// newTestDB: SQLite trong bộ nhớ, riêng cho từng test, đã migrate như MySQL
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	// TranslateError: trùng unique => gorm.ErrDuplicatedKey (như "Duplicate entry" của MySQL)
+	db, err := gorm.Open(sqlite.Open("file:"+name+"?mode=memory&cache=shared&_foreign_keys=1"),
+		&gorm.Config{Logger: logger.Discard, TranslateError: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1) // SQLite: 1 writer, tránh "database is locked"
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := repository.MigrateAndSeed(db, nil); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// seedUsers tạo các user (ID theo thứ tự) và trả lại với ID đã gán
+func seedUsers(t *testing.T, db *gorm.DB, users ...models.User) []models.User {
+	t.Helper()
+	for i := range users {
+		if users[i].PasswordHash == "" {
+			users[i].PasswordHash = "x"
+		}
+		if users[i].Status == "" {
+			users[i].Status = "active"
+		}
+		if err := db.Create(&users[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return users
+}
+
+// asUser: giả lập middleware WithAuth (uid, role trong access token)
+func asUser(u models.User) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("uid", u.ID)
+		c.Set("role", u.Role)
+	}
+}
+
+func serve(r http.Handler, method, path, body string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func init() { gin.SetMode(gin.TestMode) }
+
+// search / deleted của support phải bị giới hạn như ListUsers: bắt buộc lọc theo country của mình
+func TestSearchAndDeletedScopedByPolicy(t *testing.T) {
+	db := newTestDB(t)
+	repos := repository.NewMySQLRepos(db)
+	us := seedUsers(t, db,
+		models.User{Username: "admin", Email: "admin@x.com", Role: "admin", Country: "US"},
+		models.User{Username: "support", Email: "support@acme.io", Role: "support", Country: "VN"},
+		models.User{Username: "an_vn", Email: "an@x.com", FullName: "An Nguyen", Role: "user", Country: "VN"},
+		models.User{Username: "anna_us", Email: "anna@x.com", FullName: "Anna Smith", Role: "user", Country: "US"},
+		models.User{Username: "anh_vn_gone", Email: "anh@x.com", Role: "user", Country: "VN"},
+		models.User{Username: "andy_us_gone", Email: "andy@x.com", Role: "user", Country: "US"},
+	)
+	admin, support := us[0], us[1]
+	if err := db.Delete(&models.User{}, []int{us[4].ID, us[5].ID}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	engine, err := policy.LoadFile("../../policies.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewUserHandler(repos, services.NewUserPolicy(engine, repos.Users))
+	route := func(u models.User) *gin.Engine {
+		r := gin.New()
+		r.GET("/search", asUser(u), h.SearchUsers)
+		r.GET("/deleted", asUser(u), h.ListDeletedUsers)
+		return r
+	}
+
+	tests := []struct {
+		name      string
+		as        models.User
+		path      string
+		code      int
+		reason    string
+		wantNames []string
+	}{
+		{"support search without scope", support, "/search?q=an", 403, policy.ReasonNoMatch, nil},
+		{"support search other country", support, "/search?q=an&country=US", 403, policy.ReasonNoMatch, nil},
+		{"support search own country", support, "/search?q=an&country=VN", 200, "", []string{"an_vn"}},
+		{"support deleted without scope", support, "/deleted", 403, policy.ReasonNoMatch, nil},
+		{"support deleted other country", support, "/deleted?country=US", 403, policy.ReasonNoMatch, nil},
+		{"support deleted own country", support, "/deleted?country=VN", 200, "", []string{"anh_vn_gone"}},
+		{"admin search everything", admin, "/search?q=an", 200, "", []string{"an_vn", "anna_us"}},
+		{"admin deleted everything", admin, "/deleted", 200, "", []string{"anh_vn_gone", "andy_us_gone"}},
+		{"invalid filter", admin, "/deleted?status=zombie", 400, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(route(tt.as), "GET", tt.path, "")
+			if w.Code != tt.code {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.code, w.Body)
+			}
+			if tt.reason != "" {
+				var e struct{ Code string }
+				_ = json.Unmarshal(w.Body.Bytes(), &e)
+				if e.Code != tt.reason {
+					t.Errorf("code = %q, want %q", e.Code, tt.reason)
+				}
+			}
+			if tt.code != 200 {
+				return
+			}
+			var names []string
+			if strings.HasPrefix(tt.path, "/search") {
+				var hits []struct{ User models.User }
+				_ = json.Unmarshal(w.Body.Bytes(), &hits)
+				for _, h := range hits {
+					names = append(names, h.User.Username)
+				}
+			} else {
+				var page struct{ Items []models.User }
+				_ = json.Unmarshal(w.Body.Bytes(), &page)
+				for _, u := range page.Items {
+					names = append(names, u.Username)
+				}
+			}
+			if !sameSet(names, tt.wantNames) {
+				t.Errorf("got %v, want %v", names, tt.wantNames)
+			}
+		})
+	}
+}
+
+func sameSet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := map[string]int{}
+	for _, s := range a {
+		seen[s]++
+	}
+	for _, s := range b {
+		if seen[s] == 0 {
+			return false
+		}
+		seen[s]--
+	}
+	return true
+}
+
+// seedRole tạo role name với các quyền perms
+func seedRole(t *testing.T, db *gorm.DB, name string, perms ...string) {
+	t.Helper()
+	var ps []models.Permission
+	if err := db.Where("name IN ?", perms).Find(&ps).Error; err != nil || len(ps) != len(perms) {
+		t.Fatalf("permissions %v: %v", perms, err)
+	}
+	if err := db.Create(&models.Role{Name: name, Permissions: ps}).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// bất biến của guardChange qua API: 409 last_admin, 422 confirmation_required, 403 khi thiếu users:role / vượt quyền
+func TestUserGuardInvariants(t *testing.T) {
+	tests := []struct {
+		name    string
+		admins  int    // số admin active (admin đầu tiên là actor "admin")
+		as      string // admin | manager | lead
+		method  string
+		target  string // admin | bob
+		query   string
+		body    string
+		code    int
+		errCode string
+	}{
+		{"ban last admin (self, confirmed)", 1, "admin", "PATCH", "admin", "?confirm=true", `{"status":"banned"}`, 409, "last_admin"},
+		{"demote last admin (self, confirmed)", 1, "admin", "PATCH", "admin", "?confirm=true", `{"role":"user"}`, 409, "last_admin"},
+		{"delete last admin (self, confirmed)", 1, "admin", "DELETE", "admin", "?confirm=true", ``, 409, "last_admin"},
+		{"self demote without confirm", 2, "admin", "PATCH", "admin", "", `{"role":"user"}`, 422, "confirmation_required"},
+		{"self ban without confirm", 2, "admin", "PATCH", "admin", "", `{"status":"inactive"}`, 422, "confirmation_required"},
+		{"self delete without confirm", 2, "admin", "DELETE", "admin", "", ``, 422, "confirmation_required"},
+		{"self demote confirmed with another admin", 2, "admin", "PATCH", "admin", "?confirm=true", `{"role":"user"}`, 200, ""},
+		{"self profile edit needs no confirm", 1, "admin", "PATCH", "admin", "", `{"full_name":"Root"}`, 200, ""},
+		{"role change without users:role", 1, "manager", "PATCH", "bob", "", `{"role":"manager"}`, 403, "permission_denied"},
+		{"profile edit without users:role", 1, "manager", "PATCH", "bob", "", `{"full_name":"Bob B"}`, 200, ""},
+		{"grant role above own permissions", 1, "lead", "PATCH", "bob", "", `{"role":"admin"}`, 403, "privilege_escalation"},
+		{"grant role within own permissions", 1, "lead", "PATCH", "bob", "", `{"role":"manager"}`, 200, ""},
+		{"edit user with more permissions", 1, "manager", "PATCH", "admin", "", `{"full_name":"x"}`, 403, "privilege_escalation"},
+		{"delete user with more permissions", 1, "manager", "DELETE", "admin", "", ``, 403, "privilege_escalation"},
+		{"full replace demoting last admin", 1, "admin", "PUT", "admin", "?confirm=true",
+			`{"username":"admin","email":"admin@x.com","role":"user","status":"active"}`, 409, "last_admin"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			repos := repository.NewMySQLRepos(db)
+			seedRole(t, db, "manager", models.PermUsersRead, models.PermUsersWrite, models.PermUsersDelete)
+			seedRole(t, db, "lead", models.PermUsersRead, models.PermUsersWrite, models.PermUsersDelete, models.PermUsersRole)
+			us := seedUsers(t, db,
+				models.User{Username: "admin", Email: "admin@x.com", Role: models.RoleAdmin},
+				models.User{Username: "manager", Email: "manager@x.com", Role: "manager"},
+				models.User{Username: "lead", Email: "lead@x.com", Role: "lead"},
+				models.User{Username: "bob", Email: "bob@x.com", Role: models.RoleUser},
+			)
+			if tt.admins > 1 {
+				seedUsers(t, db, models.User{Username: "admin2", Email: "admin2@x.com", Role: models.RoleAdmin})
+			}
+			byName := map[string]models.User{}
+			for _, u := range us {
+				byName[u.Username] = u
+			}
+
+			h := NewUserHandler(repos, nil)
+			r := gin.New()
+			r.Use(asUser(byName[tt.as]))
+			r.PUT("/users/:id", h.UpdateUser)
+			r.PATCH("/users/:id", h.PatchUser)
+			r.DELETE("/users/:id", h.DeleteUser)
+
+			path := "/users/" + strconv.Itoa(byName[tt.target].ID) + tt.query
+			w := serve(r, tt.method, path, tt.body)
+			if w.Code != tt.code {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.code, w.Body)
+			}
+			if tt.errCode != "" {
+				var e struct{ Code string }
+				_ = json.Unmarshal(w.Body.Bytes(), &e)
+				if e.Code != tt.errCode {
+					t.Errorf("code = %q, want %q", e.Code, tt.errCode)
+				}
+				// bị từ chối => không thay đổi gì
+				var after models.User
+				db.Unscoped().First(&after, byName[tt.target].ID)
+				if after.Version != byName[tt.target].Version || after.DeletedAt.Valid {
+					t.Errorf("target changed despite %d: %+v", w.Code, after)
+				}
+			}
+		})
+	}
+}
+
+func TestPatchUserMergePatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		ctype string
+		body  string
+		code  int
+		check func(t *testing.T, u models.User)
+	}{
+		{"null clears nullable fields", "application/merge-patch+json", `{"date_of_birth":null,"phone":null}`, 200, func(t *testing.T, u models.User) {
+			if u.DateOfBirth != nil || u.Phone != "" || u.FullName != "Bob Old" {
+				t.Errorf("dob %v phone %q full_name %q", u.DateOfBirth, u.Phone, u.FullName)
+			}
+		}},
+		{"absent fields untouched", "application/merge-patch+json", `{"status":"inactive"}`, 200, func(t *testing.T, u models.User) {
+			if u.Status != "inactive" || u.Phone != "0900" || u.DateOfBirth == nil || u.Role != models.RoleUser {
+				t.Errorf("user = %+v", u)
+			}
+		}},
+		{"unchanged value writes nothing", "application/json", `{"full_name":"Bob Old"}`, 200, func(t *testing.T, u models.User) {
+			if u.Version != 1 {
+				t.Errorf("version = %d, want 1 (no write)", u.Version)
+			}
+		}},
+		{"stale invalid data does not block other fields", "application/merge-patch+json", `{"city":"Hue"}`, 200, func(t *testing.T, u models.User) {
+			if u.City != "Hue" || u.Username != "bo" {
+				t.Errorf("city %q username %q", u.City, u.Username)
+			}
+		}},
+		{"unknown field", "application/merge-patch+json", `{"is_admin":"true"}`, 400, nil},
+		{"server-managed field", "application/merge-patch+json", `{"token_version":"9"}`, 400, nil},
+		{"null on required field", "application/merge-patch+json", `{"email":null}`, 400, nil},
+		{"non-string value", "application/merge-patch+json", `{"phone":123}`, 400, nil},
+		{"invalid email", "application/merge-patch+json", `{"email":"not-an-email"}`, 400, nil},
+		{"invalid enum", "application/merge-patch+json", `{"gender":"robot"}`, 400, nil},
+		{"patched field still validated", "application/merge-patch+json", `{"username":"ab"}`, 400, nil},
+		{"invalid date", "application/merge-patch+json", `{"date_of_birth":"31/12/2000"}`, 400, nil},
+		{"not an object", "application/merge-patch+json", `["x"]`, 400, nil},
+		{"wrong content type", "text/plain", `{"city":"Hue"}`, 415, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			dob, _ := time.Parse("2006-01-02", "1990-05-01")
+			us := seedUsers(t, db,
+				models.User{Username: "admin", Email: "admin@x.com", Role: models.RoleAdmin},
+				models.User{Username: "bo", Email: "bob@x.com", Role: models.RoleUser, FullName: "Bob Old", Phone: "0900", DateOfBirth: &dob},
+			)
+			h := NewUserHandler(repository.NewMySQLRepos(db), nil)
+			r := gin.New()
+			r.PATCH("/users/:id", asUser(us[0]), h.PatchUser)
+
+			w := serve(r, "PATCH", "/users/"+strconv.Itoa(us[1].ID), tt.body, "Content-Type", tt.ctype)
+			if w.Code != tt.code {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.code, w.Body)
+			}
+			var after models.User
+			db.First(&after, us[1].ID)
+			if tt.check != nil {
+				tt.check(t, after)
+			} else if after.Version != 1 {
+				t.Errorf("rejected patch changed the user (version %d)", after.Version)
+			}
+		})
+	}
+}
+
+// admin đặt mật khẩu mới cho user (PUT/PATCH) => mọi phiên của user bị thu hồi, access token cũ mất hiệu lực
+func TestAdminPasswordChangeRevokesSessions(t *testing.T) {
+	for _, method := range []string{"PATCH", "PUT"} {
+		t.Run(method, func(t *testing.T) {
+			db := newTestDB(t)
+			us := seedUsers(t, db,
+				models.User{Username: "admin", Email: "admin@x.com", Role: models.RoleAdmin},
+				models.User{Username: "bob", Email: "bob@x.com", Role: models.RoleUser},
+			)
+			admin, bob := us[0], us[1]
+			for _, jti := range []string{"s1", "s2"} {
+				if err := db.Create(&models.RefreshToken{TokenID: jti, FamilyID: jti, UserID: bob.ID, ExpiresAt: time.Now().Add(time.Hour)}).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+			var before models.User
+			db.First(&before, bob.ID)
+
+			h := NewUserHandler(repository.NewMySQLRepos(db), nil)
+			r := gin.New()
+			r.PUT("/users/:id", asUser(admin), h.UpdateUser)
+			r.PATCH("/users/:id", asUser(admin), h.PatchUser)
+			body := `{"password":"newpass1"}`
+			if method == "PUT" {
+				body = `{"username":"bob","email":"bob@x.com","password":"newpass1"}`
+			}
+			if w := serve(r, method, "/users/"+strconv.Itoa(bob.ID), body); w.Code != 200 {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+
+			var after models.User
+			db.First(&after, bob.ID)
+			if after.PasswordHash == before.PasswordHash {
+				t.Fatal("password not changed")
+			}
+			if after.TokenVersion != before.TokenVersion+1 {
+				t.Errorf("token_version = %d, want %d", after.TokenVersion, before.TokenVersion+1)
+			}
+			var alive int64
+			db.Model(&models.RefreshToken{}).Where("user_id = ? AND revoked = ?", bob.ID, false).Count(&alive)
+			if alive != 0 {
+				t.Errorf("%d refresh tokens still valid", alive)
+			}
+		})
+	}
+}
+
+// GET /admin/users: envelope có total + next_cursor, sort ngoài whitelist / cursor hỏng => 400
+func TestListUsersEnvelope(t *testing.T) {
+	db := newTestDB(t)
+	repos := repository.NewMySQLRepos(db)
+	us := seedUsers(t, db,
+		models.User{Username: "admin", Email: "admin@x.com", Role: models.RoleAdmin},
+		models.User{Username: "bob", Email: "bob@x.com", Role: models.RoleUser},
+		models.User{Username: "carol", Email: "carol@x.com", Role: models.RoleUser},
+	)
+	h := NewUserHandler(repos, nil)
+	r := gin.New()
+	r.GET("/users", asUser(us[0]), h.ListUsers)
+
+	type envelope struct {
+		Items      []models.User
+		Total      int64
+		Page       int
+		Limit      int
+		NextCursor string `json:"next_cursor"`
+	}
+	var names []string
+	path := "/users?sort=username&order=desc&limit=2"
+	for path != "" {
+		w := serve(r, "GET", path, "")
+		if w.Code != 200 {
+			t.Fatalf("%s: status = %d: %s", path, w.Code, w.Body)
+		}
+		var env envelope
+		if err := json.Unmarshal(w.Body.Bytes(), &env); err != nil {
+			t.Fatal(err)
+		}
+		if env.Total != 3 || env.Limit != 2 {
+			t.Errorf("envelope = %+v", env)
+		}
+		for _, u := range env.Items {
+			names = append(names, u.Username)
+		}
+		path = ""
+		if env.NextCursor != "" {
+			path = "/users?sort=username&order=desc&limit=2&cursor=" + env.NextCursor
+		}
+	}
+	if got := strings.Join(names, " "); got != "carol bob admin" {
+		t.Errorf("pages = %q", got)
+	}
+
+	for _, p := range []string{
+		"/users?sort=password_hash",
+		"/users?order=sideways",
+		"/users?limit=101",
+		"/users?cursor=bm9wZQ",
+		"/users?created_from=yesterday",
+	} {
+		if w := serve(r, "GET", p, ""); w.Code != 400 {
+			t.Errorf("%s: status = %d, want 400", p, w.Code)
+		}
+	}
+}
+
+// ETag theo version: If-Match cũ / sai định dạng => 412 cho PUT, PATCH, DELETE và không ghi gì
+func TestIfMatchPreconditions(t *testing.T) {
+	db := newTestDB(t)
+	repos := repository.NewMySQLRepos(db)
+	us := seedUsers(t, db,
+		models.User{Username: "admin", Email: "admin@x.com", Role: models.RoleAdmin},
+		models.User{Username: "bob", Email: "bob@x.com", Role: models.RoleUser},
+	)
+	h := NewUserHandler(repos, nil)
+	r := gin.New()
+	r.Use(asUser(us[0]))
+	r.GET("/users/:id", h.GetUser)
+	r.PUT("/users/:id", h.UpdateUser)
+	r.PATCH("/users/:id", h.PatchUser)
+	r.DELETE("/users/:id", h.DeleteUser)
+	path := "/users/" + strconv.Itoa(us[1].ID)
+	put := `{"username":"bob","email":"bob@x.com","full_name":"Bob PUT","role":"user","status":"active"}`
+
+	w := serve(r, "GET", path, "")
+	if w.Code != 200 || w.Header().Get("ETag") != `"1"` {
+		t.Fatalf("GET: %d ETag=%q", w.Code, w.Header().Get("ETag"))
+	}
+
+	steps := []struct {
+		method, ifMatch, body string
+		code                  int
+		etag                  string // ETag mong đợi sau bước này ("" = không đổi)
+	}{
+		{"PATCH", `"1"`, `{"full_name":"Bob 1"}`, 200, `"2"`},
+		{"PATCH", `"1"`, `{"full_name":"stale"}`, 412, ""},
+		{"PUT", `"1"`, put, 412, ""},
+		{"DELETE", `"1"`, ``, 412, ""},
+		{"PATCH", `W/"2"`, `{"full_name":"Bob 2"}`, 200, `"3"`},
+		{"PUT", `"3"`, put, 200, `"4"`},
+		{"PATCH", `3`, `{"full_name":"unquoted"}`, 412, ""},
+		{"PATCH", `"abc"`, `{"full_name":"garbage"}`, 412, ""},
+		{"PUT", `"0"`, put, 412, ""},
+		// không gửi / "*" => không kiểm tra version
+		{"PATCH", ``, `{"full_name":"Bob 5"}`, 200, `"5"`},
+		{"PATCH", `*`, `{"full_name":"Bob 6"}`, 200, `"6"`},
+		{"DELETE", `"5"`, ``, 412, ""},
+		{"DELETE", `"6"`, ``, 204, ""},
+	}
+	for i, s := range steps {
+		w := serve(r, s.method, path, s.body, "If-Match", s.ifMatch)
+		if w.Code != s.code {
+			t.Fatalf("step %d %s If-Match=%s: status = %d, want %d: %s", i, s.method, s.ifMatch, w.Code, s.code, w.Body)
+		}
+		if s.etag != "" && w.Header().Get("ETag") != s.etag {
+			t.Errorf("step %d: ETag = %q, want %s", i, w.Header().Get("ETag"), s.etag)
+		}
+	}
+
+	var bob models.User
+	db.Unscoped().First(&bob, us[1].ID)
+	if bob.FullName != "Bob 6" || bob.Version != 6 || !bob.DeletedAt.Valid {
+		t.Errorf("bob = %q v%d deleted=%v", bob.FullName, bob.Version, bob.DeletedAt.Valid)
+	}
+	if w := serve(r, "PATCH", "/users/999", `{"full_name":"x"}`, "If-Match", `"1"`); w.Code != 404 {
+		t.Errorf("missing user with If-Match: %d, want 404", w.Code)
+	}
+}
+
+// user đã xoá không giữ username/email; restore bị chặn khi đã có user khác dùng lại, purge chỉ cho user đã xoá
+func TestRestoreAndPurge(t *testing.T) {
+	db := newTestDB(t)
+	repos := repository.NewMySQLRepos(db)
+	us := seedUsers(t, db,
+		models.User{Username: "admin", Email: "admin@x.com", Role: models.RoleAdmin},
+		models.User{Username: "bob", Email: "bob@x.com", Role: models.RoleUser},
+		models.User{Username: "carol", Email: "carol@x.com", Role: models.RoleUser},
+	)
+	admin, oldBob, carol := us[0], us[1], us[2]
+	h := NewUserHandler(repos, nil)
+	r := gin.New()
+	r.Use(asUser(admin))
+	r.DELETE("/users/:id", h.DeleteUser)
+	r.GET("/deleted", h.ListDeletedUsers)
+	r.POST("/users/:id/restore", h.RestoreUser)
+	r.DELETE("/users/:id/purge", h.PurgeUser)
+	id := func(u models.User) string { return "/users/" + strconv.Itoa(u.ID) }
+
+	// user còn sống vẫn giữ unique
+	if err := db.Create(&models.User{Username: "carol", Email: "other@x.com", PasswordHash: "x"}).Error; err == nil {
+		t.Fatal("duplicate username among live users accepted")
+	}
+	if w := serve(r, "DELETE", id(oldBob), ""); w.Code != 204 {
+		t.Fatalf("delete: %d %s", w.Code, w.Body)
+	}
+	// đăng ký lại cùng username/email sau khi xoá
+	newBob := seedUsers(t, db, models.User{Username: "bob", Email: "bob@x.com", Role: models.RoleUser})[0]
+	for _, row := range []any{
+		&models.LoginEvent{UserID: &newBob.ID, Identifier: "bob", Success: true},
+		&models.SecurityEvent{UserID: newBob.ID, Type: models.SecurityEventRefreshReuse},
+		&models.RefreshToken{TokenID: "jti-bob", UserID: newBob.ID, ExpiresAt: time.Now().Add(time.Hour)},
+	} {
+		if err := db.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := serve(r, "GET", "/deleted", "")
+	var page struct {
+		Items []models.User
+		Total int64
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &page)
+	if w.Code != 200 || page.Total != 1 || len(page.Items) != 1 || page.Items[0].ID != oldBob.ID {
+		t.Fatalf("deleted list: %d %s", w.Code, w.Body)
+	}
+
+	steps := []struct {
+		name, method, path string
+		code               int
+	}{
+		{"restore while name reused", "POST", id(oldBob) + "/restore", 409},
+		{"restore live user", "POST", id(carol) + "/restore", 404},
+		{"restore missing user", "POST", "/users/999/restore", 404},
+		{"purge live user", "DELETE", id(carol) + "/purge", 404},
+		{"delete new bob", "DELETE", id(newBob), 204},
+		{"restore old bob", "POST", id(oldBob) + "/restore", 200},
+		{"restore twice", "POST", id(oldBob) + "/restore", 404},
+		{"purge new bob", "DELETE", id(newBob) + "/purge", 204},
+		{"purge twice", "DELETE", id(newBob) + "/purge", 404},
+	}
+	for _, s := range steps {
+		if w := serve(r, s.method, s.path, ""); w.Code != s.code {
+			t.Fatalf("%s: status = %d, want %d: %s", s.name, w.Code, s.code, w.Body)
+		} else if s.name == "restore old bob" && w.Header().Get("ETag") != `"2"` {
+			// restore tăng version => ETag lấy trước khi xoá không dùng được nữa
+			t.Errorf("restore ETag = %q, want \"2\"", w.Header().Get("ETag"))
+		}
+	}
+
+	var restored models.User
+	if err := db.First(&restored, oldBob.ID).Error; err != nil || restored.Username != "bob" {
+		t.Errorf("old bob not live again: %+v, %v", restored, err)
+	}
+	// purge xoá cả dữ liệu phụ thuộc
+	for _, m := range []any{&models.User{}, &models.LoginEvent{}, &models.SecurityEvent{}, &models.RefreshToken{}} {
+		col := "user_id"
+		if _, ok := m.(*models.User); ok {
+			col = "id"
+		}
+		var n int64
+		db.Unscoped().Model(m).Where(col+" = ?", newBob.ID).Count(&n)
+		if n != 0 {
+			t.Errorf("%T: %d rows of purged user left", m, n)
+		}
+	}
+	var actions []string
+	db.Model(&models.AuditEvent{}).Order("id").Pluck("action", &actions)
+	if got := strings.Join(actions, " "); got != "user.delete user.delete user.restore user.purge" {
+		t.Errorf("audit = %q", got)
+	}
+}
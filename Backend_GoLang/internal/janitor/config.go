@@ -0,0 +1,49 @@
+package janitor
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+type Config struct {
+	Enabled   bool
+	Interval  time.Duration // chu kỳ chạy các job dọn dẹp
+	BatchSize int           // số dòng tối đa mỗi câu DELETE
+
+	// Refresh token hết hạn quá RefreshTokenGrace mới bị xoá (token bị thu hồi cũng giữ tới lúc này
+	// để vẫn phát hiện được refresh token bị dùng lại)
+	RefreshTokenGrace time.Duration
+	// Lịch sử đăng nhập cũ hơn LoginEventRetention bị xoá (0 = giữ mãi)
+	LoginEventRetention time.Duration
+}
+
+// LoadConfigFromEnv đọc cấu hình dọn dẹp nền từ biến môi trường (.env)
+func LoadConfigFromEnv() Config {
+	_ = godotenv.Load()
+	cfg := Config{
+		Enabled:             os.Getenv("JANITOR") != "0",
+		Interval:            durationEnv("JANITOR_INTERVAL", time.Hour),
+		BatchSize:           500,
+		RefreshTokenGrace:   durationEnv("REFRESH_TOKEN_GRACE", 24*time.Hour),
+		LoginEventRetention: durationEnv("LOGIN_EVENT_RETENTION", 90*24*time.Hour),
+	}
+	if n, err := strconv.Atoi(os.Getenv("JANITOR_BATCH_SIZE")); err == nil && n > 0 {
+		cfg.BatchSize = n
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Hour
+	}
+	return cfg
+}
+
+// durationEnv: biến không đặt/sai định dạng => def; chấp nhận "0"
+func durationEnv(k string, def time.Duration) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(k))
+	if err != nil || d < 0 {
+		return def
+	}
+	return d
+}
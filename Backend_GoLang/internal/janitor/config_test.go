@@ -0,0 +1,36 @@
+package janitor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoadConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want Config
+	}{
+		{"defaults", nil, Config{Enabled: true, Interval: time.Hour, BatchSize: 500,
+			RefreshTokenGrace: 24 * time.Hour, LoginEventRetention: 90 * 24 * time.Hour}},
+		{"overrides", map[string]string{
+			"JANITOR_INTERVAL": "5m", "JANITOR_BATCH_SIZE": "50", "REFRESH_TOKEN_GRACE": "0", "LOGIN_EVENT_RETENTION": "720h",
+		}, Config{Enabled: true, Interval: 5 * time.Minute, BatchSize: 50, LoginEventRetention: 720 * time.Hour}},
+		{"invalid values fall back", map[string]string{
+			"JANITOR_INTERVAL": "0", "JANITOR_BATCH_SIZE": "-1", "REFRESH_TOKEN_GRACE": "-1h", "LOGIN_EVENT_RETENTION": "soon",
+		}, Config{Enabled: true, Interval: time.Hour, BatchSize: 500,
+			RefreshTokenGrace: 24 * time.Hour, LoginEventRetention: 90 * 24 * time.Hour}},
+		{"disabled", map[string]string{"JANITOR": "0"}, Config{Interval: time.Hour, BatchSize: 500,
+			RefreshTokenGrace: 24 * time.Hour, LoginEventRetention: 90 * 24 * time.Hour}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, k := range []string{"JANITOR", "JANITOR_INTERVAL", "JANITOR_BATCH_SIZE", "REFRESH_TOKEN_GRACE", "LOGIN_EVENT_RETENTION"} {
+				t.Setenv(k, tt.env[k])
+			}
+			if got := LoadConfigFromEnv(); got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+// Package janitor: chạy các tác vụ dọn dẹp định kỳ (xoá refresh token hết hạn, lịch sử
+// đăng nhập cũ, bộ đếm rate limit không dùng, ...) trong nền, dừng sạch khi server tắt.
+package janitor
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Job: 1 tác vụ định kỳ. Run trả về số bản ghi đã xoá.
+type Job struct {
+	Name  string
+	Every time.Duration // <= 0: không chạy
+	Run   func(ctx context.Context, now time.Time) (int64, error)
+}
+
+// Stats: số liệu của 1 job (GET /admin/jobs)
+type Stats struct {
+	Name         string     `json:"name"`
+	Every        string     `json:"every"`
+	Runs         int64      `json:"runs"`
+	Failures     int64      `json:"failures"`
+	Removed      int64      `json:"removed"`      // tổng số bản ghi đã xoá
+	LastRemoved  int64      `json:"last_removed"` // số bản ghi xoá ở lần chạy gần nhất
+	LastRunAt    *time.Time `json:"last_run_at,omitempty"`
+	LastDuration string     `json:"last_duration,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+}
+
+// Runner chạy mỗi job trong 1 goroutine: chạy ngay khi Start rồi lặp lại mỗi Every
+type Runner struct {
+	mu    sync.Mutex
+	jobs  []Job
+	stats map[string]*Stats
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func New() *Runner {
+	return &Runner{stats: map[string]*Stats{}}
+}
+
+// Add đăng ký job (gọi trước Start); job có Every <= 0 bị bỏ qua
+func (r *Runner) Add(j Job) {
+	if j.Every <= 0 || j.Run == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.jobs = append(r.jobs, j)
+	r.stats[j.Name] = &Stats{Name: j.Name, Every: j.Every.String()}
+}
+
+// Start chạy các job tới khi ctx bị huỷ hoặc Stop được gọi
+func (r *Runner) Start(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancel != nil {
+		return
+	}
+	ctx, r.cancel = context.WithCancel(ctx)
+	for _, j := range r.jobs {
+		r.wg.Add(1)
+		go r.loop(ctx, j)
+	}
+}
+
+// Stop huỷ các job và chờ lần chạy đang dở kết thúc
+func (r *Runner) Stop() {
+	r.mu.Lock()
+	cancel := r.cancel
+	r.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+	r.wg.Wait()
+}
+
+// Stats: số liệu của các job, theo tên
+func (r *Runner) Stats() []Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]Stats, 0, len(r.stats))
+	for _, s := range r.stats {
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, k int) bool { return out[i].Name < out[k].Name })
+	return out
+}
+
+func (r *Runner) loop(ctx context.Context, j Job) {
+	defer r.wg.Done()
+	t := time.NewTicker(j.Every)
+	defer t.Stop()
+	for {
+		r.run(ctx, j)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (r *Runner) run(ctx context.Context, j Job) {
+	start := time.Now()
+	n, err := j.Run(ctx, start)
+	took := time.Since(start)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.stats[j.Name]
+	s.Runs++
+	s.Removed += n
+	s.LastRemoved = n
+	s.LastRunAt = &start
+	s.LastDuration = took.String()
+	s.LastError = ""
+	if err != nil && ctx.Err() == nil {
+		s.Failures++
+		s.LastError = err.Error()
+		log.Printf("[janitor] %s: %v", j.Name, err)
+	} else if n > 0 {
+		log.Printf("[janitor] %s: removed %d in %s", j.Name, n, took)
+	}
+}
+
+// Batched gọi fn(size) lặp lại tới khi 1 lượt xoá ít hơn size bản ghi (hết việc) hoặc ctx bị huỷ,
+// để không giữ khoá bảng lâu khi có nhiều bản ghi cần xoá. Trả về tổng số bản ghi đã xoá.
+func Batched(ctx context.Context, size int, fn func(limit int) (int64, error)) (int64, error) {
+	var total int64
+	for {
+		n, err := fn(size)
+		total += n
+		if err != nil || n < int64(size) {
+			return total, err
+		}
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package janitor
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBatched(t *testing.T) {
+	tests := []struct {
+		name    string
+		rows    int64 // số bản ghi cần xoá
+		failAt  int   // lượt thứ mấy trả lỗi (0 = không lỗi)
+		want    int64
+		calls   int
+		wantErr bool
+	}{
+		{"nothing to do", 0, 0, 0, 1, false},
+		{"less than one batch", 3, 0, 3, 1, false},
+		{"exact batches", 10, 0, 10, 3, false}, // lượt cuối xoá 0 => biết đã hết
+		{"several batches", 12, 0, 12, 3, false},
+		{"error stops", 12, 2, 5, 2, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			left, calls := tt.rows, 0
+			got, err := Batched(context.Background(), 5, func(limit int) (int64, error) {
+				calls++
+				if limit != 5 {
+					t.Fatalf("limit = %d, want 5", limit)
+				}
+				if calls == tt.failAt {
+					return 0, errors.New("db down")
+				}
+				n := min(left, int64(limit))
+				left -= n
+				return n, nil
+			})
+			if got != tt.want || calls != tt.calls || (err != nil) != tt.wantErr {
+				t.Errorf("Batched = %d, %v after %d calls; want %d, err %v after %d", got, err, calls, tt.want, tt.wantErr, tt.calls)
+			}
+		})
+	}
+
+	// ctx bị huỷ giữa chừng => dừng sau lượt đang chạy
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	got, err := Batched(ctx, 5, func(limit int) (int64, error) {
+		calls++
+		cancel()
+		return int64(limit), nil
+	})
+	if got != 5 || calls != 1 || !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled Batched = %d, %v after %d calls", got, err, calls)
+	}
+}
+
+func TestRunner(t *testing.T) {
+	r := New()
+	var okRuns, failRuns atomic.Int64
+	started := make(chan struct{}, 1)
+	r.Add(Job{Name: "ok", Every: 10 * time.Millisecond, Run: func(ctx context.Context, now time.Time) (int64, error) {
+		okRuns.Add(1)
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		return 2, nil
+	}})
+	r.Add(Job{Name: "fail", Every: time.Hour, Run: func(ctx context.Context, now time.Time) (int64, error) {
+		failRuns.Add(1)
+		return 1, errors.New("boom")
+	}})
+	r.Add(Job{Name: "disabled", Every: 0, Run: func(context.Context, time.Time) (int64, error) {
+		t.Error("job with Every <= 0 ran")
+		return 0, nil
+	}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r.Start(ctx)
+	r.Start(ctx) // gọi lại không chạy thêm goroutine
+	<-started    // chạy ngay khi Start, không đợi hết Every
+	deadline := time.Now().Add(2 * time.Second)
+	for okRuns.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	r.Stop()
+
+	n := okRuns.Load()
+	time.Sleep(30 * time.Millisecond)
+	if okRuns.Load() != n {
+		t.Error("job still running after Stop")
+	}
+	if failRuns.Load() != 1 {
+		t.Errorf("hourly job ran %d times, want 1", failRuns.Load())
+	}
+
+	stats := r.Stats()
+	if len(stats) != 2 || stats[0].Name != "fail" || stats[1].Name != "ok" {
+		t.Fatalf("stats = %+v", stats)
+	}
+	fail, ok := stats[0], stats[1]
+	if fail.Runs != 1 || fail.Failures != 1 || fail.LastError != "boom" || fail.Removed != 1 || fail.Every != "1h0m0s" {
+		t.Errorf("fail stats = %+v", fail)
+	}
+	if ok.Runs != n || ok.Runs < 3 || ok.Removed != 2*ok.Runs || ok.LastRemoved != 2 || ok.Failures != 0 ||
+		ok.LastRunAt == nil || ok.LastDuration == "" {
+		t.Errorf("ok stats = %+v (runs %d)", ok, n)
+	}
+}
+
+// Stop chờ lần chạy đang dở; lỗi do ctx bị huỷ khi tắt server không tính là failure
+func TestRunnerStopWaitsForRunningJob(t *testing.T) {
+	r := New()
+	running := make(chan struct{})
+	var finished atomic.Bool
+	r.Add(Job{Name: "slow", Every: time.Hour, Run: func(ctx context.Context, now time.Time) (int64, error) {
+		close(running)
+		<-ctx.Done()
+		time.Sleep(20 * time.Millisecond)
+		finished.Store(true)
+		return 0, ctx.Err()
+	}})
+	r.Start(context.Background())
+	<-running
+	r.Stop()
+	if !finished.Load() {
+		t.Error("Stop returned before the running job finished")
+	}
+	if s := r.Stats()[0]; s.Runs != 1 || s.Failures != 0 || s.LastError != "" {
+		t.Errorf("stats after shutdown = %+v", s)
+	}
+}
@@ -0,0 +1,173 @@
+package keyset
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+type Config struct {
+	Alg    string // HS256 | RS256 | ES256 | EdDSA
+	Secret string // khoá HS256
+
+	// PEM khoá ký (PKCS#8 / PKCS#1 / SEC1). Đặt đường dẫn mà file chưa có => sinh khoá và ghi ra file.
+	// Không đặt => chỉ khởi động khi AllowEphemeral (sinh khoá mới mỗi lần khởi động).
+	PrivateKeyFile string
+	// cho phép khoá bất đối xứng chỉ sống trong process: token mất hiệu lực khi restart và các instance
+	// không verify được token của nhau => chỉ dùng khi dev / chạy 1 instance
+	AllowEphemeral bool
+	// PEM các khoá cũ (public hoặc private) vẫn được verify sau khi thay khoá ký
+	VerifyKeyFiles []string
+
+	RotateInterval time.Duration // >0: tự rotate khoá sinh tự động (không áp dụng cho khoá từ file)
+	Retain         time.Duration // giữ khoá cũ để verify sau khi rotate, nên >= thời hạn refresh token
+}
+
+// LoadConfigFromEnv đọc cấu hình khoá JWT từ biến môi trường (.env)
+func LoadConfigFromEnv() Config {
+	_ = godotenv.Load()
+	cfg := Config{
+		Alg:            getEnv("JWT_ALG", "HS256"),
+		Secret:         getEnv("JWT_SECRET", "change-me"),
+		PrivateKeyFile: os.Getenv("JWT_PRIVATE_KEY_FILE"),
+		AllowEphemeral: os.Getenv("JWT_ALLOW_EPHEMERAL_KEYS") == "1",
+	}
+	for _, f := range strings.Split(os.Getenv("JWT_VERIFY_KEY_FILES"), ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			cfg.VerifyKeyFiles = append(cfg.VerifyKeyFiles, f)
+		}
+	}
+	cfg.RotateInterval, _ = time.ParseDuration(os.Getenv("JWT_KEY_ROTATE_INTERVAL"))
+	cfg.Retain, _ = time.ParseDuration(os.Getenv("JWT_KEY_RETAIN"))
+	return cfg
+}
+
+func getEnv(k, def string) string {
+	if v := os.Getenv(k); v != "" {
+		return v
+	}
+	return def
+}
+
+// New tạo bộ khoá theo cấu hình
+func New(cfg Config) (*KeySet, error) {
+	s := &KeySet{retain: cfg.Retain}
+	if cfg.Alg == "HS256" {
+		if cfg.RotateInterval > 0 {
+			return nil, ErrCannotRotate
+		}
+		s.active = hmacKey(cfg.Secret)
+	} else {
+		if cfg.RotateInterval > 0 && cfg.PrivateKeyFile != "" {
+			return nil, errors.New("JWT_KEY_ROTATE_INTERVAL only applies to generated keys, rotate key files via JWT_VERIFY_KEY_FILES")
+		}
+		if cfg.RotateInterval > 0 && cfg.Retain <= 0 {
+			return nil, errors.New("JWT_KEY_RETAIN must be > 0 when rotating keys")
+		}
+		if cfg.PrivateKeyFile == "" && !cfg.AllowEphemeral {
+			return nil, ErrEphemeralKey
+		}
+		k, err := loadOrGenerate(cfg.Alg, cfg.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		s.active = k
+	}
+	s.keys = []*Key{s.active}
+
+	for _, f := range cfg.VerifyKeyFiles {
+		k, err := readKeyFile(f)
+		if err != nil {
+			return nil, err
+		}
+		if k.ID != s.active.ID {
+			k.private = nil
+			s.keys = append(s.keys, k)
+		}
+	}
+	return s, nil
+}
+
+func loadOrGenerate(alg, path string) (*Key, error) {
+	if path != "" {
+		k, err := readKeyFile(path)
+		if err == nil {
+			if k.private == nil {
+				return nil, fmt.Errorf("%s: signing key must be a private key", path)
+			}
+			if k.Method.Alg() != alg {
+				return nil, fmt.Errorf("%s: key is %s but JWT_ALG=%s", path, k.Method.Alg(), alg)
+			}
+			return k, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+	k, err := Generate(alg)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		log.Printf("[keyset] WARNING: generated ephemeral %s key kid=%s: tokens are lost on restart and other instances "+
+			"cannot verify them (set JWT_PRIVATE_KEY_FILE for production)", alg, k.ID)
+		return k, nil
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(k.private)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		return nil, err
+	}
+	log.Printf("[keyset] generated %s key kid=%s -> %s", alg, k.ID, path)
+	return k, nil
+}
+
+// readKeyFile đọc khoá PEM: PRIVATE KEY (PKCS#8), RSA PRIVATE KEY, EC PRIVATE KEY hoặc PUBLIC KEY
+func readKeyFile(path string) (*Key, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM block", path)
+	}
+	var priv, pub any
+	switch block.Type {
+	case "PRIVATE KEY":
+		priv, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		priv, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		priv, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		pub, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%s: unsupported PEM type %q", path, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if priv != nil {
+		signer, ok := priv.(interface{ Public() crypto.PublicKey })
+		if !ok {
+			return nil, fmt.Errorf("%s: unsupported key type %T", path, priv)
+		}
+		pub = signer.Public()
+	}
+	k, err := newKey(priv, pub)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return k, nil
+}
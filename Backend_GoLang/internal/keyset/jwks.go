@@ -0,0 +1,98 @@
+package keyset
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// JWK: khoá công khai theo RFC 7517
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS: khoá công khai của các khoá còn hiệu lực (HS256 không bao giờ được công bố)
+func (s *KeySet) JWKS() JWKS {
+	now := time.Now()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := JWKS{Keys: []JWK{}}
+	for _, k := range s.keys {
+		if !k.usable(now) {
+			continue
+		}
+		if j, err := publicJWK(k.public); err == nil {
+			j.Kid, j.Use, j.Alg = k.ID, "sig", k.Method.Alg()
+			out.Keys = append(out.Keys, j)
+		}
+	}
+	return out
+}
+
+func publicJWK(pub any) (JWK, error) {
+	b64 := base64.RawURLEncoding.EncodeToString
+	switch p := pub.(type) {
+	case *rsa.PublicKey:
+		return JWK{Kty: "RSA", N: b64(p.N.Bytes()), E: b64(big.NewInt(int64(p.E)).Bytes())}, nil
+	case *ecdsa.PublicKey:
+		size := (p.Curve.Params().BitSize + 7) / 8
+		return JWK{Kty: "EC", Crv: p.Curve.Params().Name, X: b64(p.X.FillBytes(make([]byte, size))), Y: b64(p.Y.FillBytes(make([]byte, size)))}, nil
+	case ed25519.PublicKey:
+		return JWK{Kty: "OKP", Crv: "Ed25519", X: b64(p)}, nil
+	}
+	return JWK{}, fmt.Errorf("unsupported key type %T", pub)
+}
+
+// thumbprint: JWK thumbprint SHA-256 (RFC 7638), các trường bắt buộc theo thứ tự từ điển
+func thumbprint(pub any) (string, error) {
+	j, err := publicJWK(pub)
+	if err != nil {
+		return "", err
+	}
+	var v any
+	switch j.Kty {
+	case "RSA":
+		v = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{j.E, j.Kty, j.N}
+	case "EC":
+		v = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{j.Crv, j.Kty, j.X, j.Y}
+	default:
+		v = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{j.Crv, j.Kty, j.X}
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,196 @@
+// Package keyset: bộ khoá ký/xác thực JWT có kid (HS256, RS256, ES256, EdDSA) + JWKS.
+// Khoá đang dùng (active) ký token mới; khoá cũ sau khi rotate chỉ còn dùng để verify
+// tới khi token cuối cùng nó ký hết hạn (RetireAt).
+package keyset
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrUnknownKey     = errors.New("unknown signing key")
+	ErrCannotRotate   = errors.New("HS256 key cannot be rotated in-process, change JWT_SECRET instead")
+	ErrUnsupportedAlg = errors.New("unsupported JWT algorithm (HS256 | RS256 | ES256 | EdDSA)")
+	ErrEphemeralKey   = errors.New("JWT_ALG other than HS256 needs JWT_PRIVATE_KEY_FILE " +
+		"(or JWT_ALLOW_EPHEMERAL_KEYS=1 for a single dev instance)")
+)
+
+// Key: 1 khoá trong bộ khoá
+type Key struct {
+	ID       string
+	Method   jwt.SigningMethod
+	private  any       // nil = chỉ dùng để verify
+	public   any       // khoá verify ([]byte với HS256)
+	RetireAt time.Time // zero = còn hiệu lực vô thời hạn
+}
+
+func (k *Key) usable(now time.Time) bool { return k.RetireAt.IsZero() || now.Before(k.RetireAt) }
+
+type KeySet struct {
+	mu     sync.RWMutex
+	active *Key
+	keys   []*Key        // active + các khoá chỉ verify
+	retain time.Duration // thời gian giữ khoá cũ sau khi rotate
+}
+
+// Sign ký claims bằng khoá active, header có kid
+func (s *KeySet) Sign(claims jwt.Claims) (string, error) {
+	s.mu.RLock()
+	k := s.active
+	s.mu.RUnlock()
+	t := jwt.NewWithClaims(k.Method, claims)
+	t.Header["kid"] = k.ID
+	return t.SignedString(k.private)
+}
+
+// Parse xác thực chữ ký (theo kid) + hạn dùng và đọc claims
+func (s *KeySet) Parse(tokenStr string, claims jwt.Claims, opts ...jwt.ParserOption) (*jwt.Token, error) {
+	opts = append(opts, jwt.WithValidMethods(s.Algs()))
+	return jwt.ParseWithClaims(tokenStr, claims, s.Keyfunc, opts...)
+}
+
+// Keyfunc chọn khoá verify theo kid. Token không có kid (cấp trước khi có bộ khoá)
+// chỉ được chấp nhận khi khoá active là HS256 (cùng secret cũ).
+func (s *KeySet) Keyfunc(t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+	now := time.Now()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, k := range s.keys {
+		match := k.ID == kid || kid == "" && k == s.active && k.Method == jwt.SigningMethodHS256
+		if match && k.usable(now) {
+			if t.Method.Alg() != k.Method.Alg() {
+				return nil, fmt.Errorf("kid %q: unexpected alg %s", kid, t.Method.Alg())
+			}
+			return k.public, nil
+		}
+	}
+	return nil, ErrUnknownKey
+}
+
+// Algs: các thuật toán đang được chấp nhận
+func (s *KeySet) Algs() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	seen := map[string]bool{}
+	var out []string
+	for _, k := range s.keys {
+		if alg := k.Method.Alg(); !seen[alg] {
+			seen[alg] = true
+			out = append(out, alg)
+		}
+	}
+	return out
+}
+
+// Rotate sinh khoá mới cùng thuật toán làm khoá active; khoá cũ còn verify thêm retain
+func (s *KeySet) Rotate() (*Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active.Method == jwt.SigningMethodHS256 {
+		return nil, ErrCannotRotate
+	}
+	k, err := Generate(s.active.Method.Alg())
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	s.active.RetireAt = now.Add(s.retain)
+	keep := []*Key{k}
+	for _, old := range s.keys {
+		if old.usable(now) {
+			keep = append(keep, old)
+		}
+	}
+	s.active, s.keys = k, keep
+	return k, nil
+}
+
+// StartRotation rotate định kỳ, trả về hàm dừng
+func (s *KeySet) StartRotation(every time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		t := time.NewTicker(every)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				if k, err := s.Rotate(); err != nil {
+					log.Printf("[keyset] rotate: %v", err)
+				} else {
+					log.Printf("[keyset] rotated, new kid=%s", k.ID)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// Generate sinh khoá bất đối xứng mới (kid = JWK thumbprint)
+func Generate(alg string) (*Key, error) {
+	var priv crypto.Signer
+	var err error
+	switch alg {
+	case "RS256":
+		priv, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "ES256":
+		priv, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "EdDSA":
+		_, priv, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, ErrUnsupportedAlg
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newKey(priv, priv.Public())
+}
+
+// newKey: priv == nil => khoá chỉ verify
+func newKey(priv, pub any) (*Key, error) {
+	var m jwt.SigningMethod
+	switch p := pub.(type) {
+	case *rsa.PublicKey:
+		if p.N.BitLen() < 2048 {
+			return nil, errors.New("RSA key must be at least 2048 bits")
+		}
+		m = jwt.SigningMethodRS256
+	case *ecdsa.PublicKey:
+		if p.Curve != elliptic.P256() {
+			return nil, errors.New("EC key must use curve P-256 (ES256)")
+		}
+		m = jwt.SigningMethodES256
+	case ed25519.PublicKey:
+		m = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", pub)
+	}
+	kid, err := thumbprint(pub)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{ID: kid, Method: m, private: priv, public: pub}, nil
+}
+
+// hmacKey: kid suy ra từ secret (không lộ secret), giống nhau giữa các instance
+func hmacKey(secret string) *Key {
+	sum := sha256.Sum256([]byte(secret + "|kid"))
+	return &Key{ID: "hs-" + hex.EncodeToString(sum[:8]), Method: jwt.SigningMethodHS256, private: []byte(secret), public: []byte(secret)}
+}
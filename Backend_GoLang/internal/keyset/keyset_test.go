@@ -0,0 +1,272 @@
+package keyset
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newEphemeral(t *testing.T, alg string, retain time.Duration) *KeySet {
+	t.Helper()
+	s, err := New(Config{Alg: alg, AllowEphemeral: true, Retain: retain})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func sign(t *testing.T, s *KeySet) string {
+	t.Helper()
+	tok, err := s.Sign(jwt.RegisteredClaims{Subject: "1", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tok
+}
+
+func TestKeyfunc(t *testing.T) {
+	rs := newEphemeral(t, "RS256", time.Hour)
+	hs, err := New(Config{Alg: "HS256", Secret: "s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := func(alg jwt.SigningMethod, kid string) *jwt.Token {
+		tok := &jwt.Token{Method: alg, Header: map[string]any{"alg": alg.Alg()}}
+		if kid != "" {
+			tok.Header["kid"] = kid
+		}
+		return tok
+	}
+
+	tests := []struct {
+		name    string
+		set     *KeySet
+		tok     *jwt.Token
+		wantErr bool
+	}{
+		{"matching kid and alg", rs, header(jwt.SigningMethodRS256, rs.active.ID), false},
+		{"RS256 key with HS256 header", rs, header(jwt.SigningMethodHS256, rs.active.ID), true},
+		{"unknown kid", rs, header(jwt.SigningMethodRS256, "nope"), true},
+		{"no kid, active key RS256", rs, header(jwt.SigningMethodRS256, ""), true},
+		{"no kid, active key HS256 (legacy token)", hs, header(jwt.SigningMethodHS256, ""), false},
+		{"HS256 key with RS256 header", hs, header(jwt.SigningMethodRS256, hs.active.ID), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := tt.set.Keyfunc(tt.tok)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Keyfunc err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && key == nil {
+				t.Fatal("nil key")
+			}
+		})
+	}
+
+	// alg confusion đầu-cuối: token HS256 ký bằng khoá công khai RSA, kid của khoá RSA
+	pubDER, _ := x509.MarshalPKIXPublicKey(rs.active.public)
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{Subject: "1"})
+	forged.Header["kid"] = rs.active.ID
+	str, _ := forged.SignedString(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}))
+	if _, err := rs.Parse(str, &jwt.RegisteredClaims{}); err == nil {
+		t.Fatal("HS256 token signed with the RSA public key accepted")
+	}
+	if _, err := rs.Parse(sign(t, rs), &jwt.RegisteredClaims{}); err != nil {
+		t.Fatalf("valid token rejected: %v", err)
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	b64len := func(n int) int { return (n*8 + 5) / 6 } // base64url không padding
+
+	rs := newEphemeral(t, "RS256", time.Hour).JWKS()
+	if len(rs.Keys) != 1 {
+		t.Fatalf("RS256 keys = %d", len(rs.Keys))
+	}
+	if j := rs.Keys[0]; j.Kty != "RSA" || j.Alg != "RS256" || j.Use != "sig" || j.Kid == "" || j.E != "AQAB" || len(j.N) != b64len(256) || j.X != "" {
+		t.Errorf("RS256 JWK = %+v", j)
+	}
+
+	es := newEphemeral(t, "ES256", time.Hour).JWKS()
+	if j := es.Keys[0]; j.Kty != "EC" || j.Crv != "P-256" || j.Alg != "ES256" || len(j.X) != b64len(32) || len(j.Y) != b64len(32) || j.N != "" {
+		t.Errorf("ES256 JWK = %+v", j)
+	}
+
+	ed := newEphemeral(t, "EdDSA", time.Hour).JWKS()
+	if j := ed.Keys[0]; j.Kty != "OKP" || j.Crv != "Ed25519" || j.Alg != "EdDSA" || len(j.X) != b64len(32) || j.Y != "" {
+		t.Errorf("EdDSA JWK = %+v", j)
+	}
+
+	// HS256: không bao giờ công bố secret, nhưng vẫn là mảng rỗng (không phải null)
+	hs, _ := New(Config{Alg: "HS256", Secret: "s"})
+	if keys := hs.JWKS().Keys; keys == nil || len(keys) != 0 {
+		t.Errorf("HS256 JWKS = %#v, want empty", keys)
+	}
+}
+
+func TestRotateRetain(t *testing.T) {
+	s := newEphemeral(t, "ES256", time.Hour)
+	old := s.active
+	oldTok := sign(t, s)
+
+	k, err := s.Rotate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.active != k || k.ID == old.ID {
+		t.Fatal("rotate did not switch active key")
+	}
+	if d := time.Until(old.RetireAt); d <= 59*time.Minute || d > time.Hour {
+		t.Errorf("old key RetireAt in %v, want ~1h", d)
+	}
+	// khoá cũ: còn verify, vẫn công bố, không còn ký
+	if _, err := s.Parse(oldTok, &jwt.RegisteredClaims{}); err != nil {
+		t.Errorf("token of retained key rejected: %v", err)
+	}
+	if n := len(s.JWKS().Keys); n != 2 {
+		t.Errorf("JWKS has %d keys, want 2", n)
+	}
+	newTok := sign(t, s)
+	if tok, _ := s.Parse(newTok, &jwt.RegisteredClaims{}); tok == nil || tok.Header["kid"] != k.ID {
+		t.Error("new tokens not signed with the new key")
+	}
+
+	// hết thời gian giữ
+	old.RetireAt = time.Now().Add(-time.Second)
+	if _, err := s.Parse(oldTok, &jwt.RegisteredClaims{}); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("token of retired key: %v, want ErrUnknownKey", err)
+	}
+	if n := len(s.JWKS().Keys); n != 1 {
+		t.Errorf("JWKS has %d keys after retire, want 1", n)
+	}
+	// lần rotate sau dọn khoá đã hết hạn
+	if _, err := s.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.keys) != 2 {
+		t.Errorf("keys after second rotate = %d, want 2 (active + previous)", len(s.keys))
+	}
+}
+
+func TestRotateRefusedForHS256(t *testing.T) {
+	if _, err := New(Config{Alg: "HS256", Secret: "s", RotateInterval: time.Hour}); err != ErrCannotRotate {
+		t.Errorf("New with rotation: %v, want ErrCannotRotate", err)
+	}
+	s, _ := New(Config{Alg: "HS256", Secret: "s"})
+	before := s.active
+	if _, err := s.Rotate(); err != ErrCannotRotate {
+		t.Errorf("Rotate: %v, want ErrCannotRotate", err)
+	}
+	if s.active != before || len(s.keys) != 1 {
+		t.Error("failed rotate changed the key set")
+	}
+}
+
+func writePEM(t *testing.T, typ string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadKeyFile(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	pkcs8 := func(k any) []byte { b, _ := x509.MarshalPKCS8PrivateKey(k); return b }
+	sec1, _ := x509.MarshalECPrivateKey(ecKey)
+	pkix, _ := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+
+	tests := []struct {
+		name    string
+		typ     string
+		der     []byte
+		alg     string
+		private bool
+	}{
+		{"PKCS#1 RSA", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey), "RS256", true},
+		{"PKCS#8 RSA", "PRIVATE KEY", pkcs8(rsaKey), "RS256", true},
+		{"PKCS#8 EC", "PRIVATE KEY", pkcs8(ecKey), "ES256", true},
+		{"SEC1 EC", "EC PRIVATE KEY", sec1, "ES256", true},
+		{"PKCS#8 Ed25519", "PRIVATE KEY", pkcs8(edKey), "EdDSA", true},
+		{"public key", "PUBLIC KEY", pkix, "RS256", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, err := readKeyFile(writePEM(t, tt.typ, tt.der))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if k.Method.Alg() != tt.alg || (k.private != nil) != tt.private {
+				t.Errorf("alg %s private %v; want %s %v", k.Method.Alg(), k.private != nil, tt.alg, tt.private)
+			}
+		})
+	}
+	// cùng khoá => cùng kid dù khác định dạng PEM
+	a, _ := readKeyFile(writePEM(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey)))
+	b, _ := readKeyFile(writePEM(t, "PUBLIC KEY", pkix))
+	if a.ID != b.ID {
+		t.Errorf("kid differs between PKCS#1 and public key: %s vs %s", a.ID, b.ID)
+	}
+
+	weak, _ := rsa.GenerateKey(rand.Reader, 1024)
+	p384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	for name, path := range map[string]string{
+		"RSA 1024":     writePEM(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(weak)),
+		"EC P-384":     writePEM(t, "PRIVATE KEY", pkcs8(p384)),
+		"unknown type": writePEM(t, "CERTIFICATE", []byte("x")),
+		"garbage DER":  writePEM(t, "PRIVATE KEY", []byte("x")),
+		"missing file": filepath.Join(t.TempDir(), "none.pem"),
+	} {
+		if _, err := readKeyFile(path); err == nil {
+			t.Errorf("%s accepted", name)
+		}
+	}
+}
+
+func TestEphemeralKeys(t *testing.T) {
+	if _, err := New(Config{Alg: "ES256"}); err != ErrEphemeralKey {
+		t.Errorf("no key file: %v, want ErrEphemeralKey", err)
+	}
+	if _, err := New(Config{Alg: "ES256", AllowEphemeral: true}); err != nil {
+		t.Errorf("AllowEphemeral: %v", err)
+	}
+	t.Setenv("JWT_ALLOW_EPHEMERAL_KEYS", "")
+	if LoadConfigFromEnv().AllowEphemeral {
+		t.Error("ephemeral keys allowed by default")
+	}
+	t.Setenv("JWT_ALLOW_EPHEMERAL_KEYS", "1")
+	if !LoadConfigFromEnv().AllowEphemeral {
+		t.Error("JWT_ALLOW_EPHEMERAL_KEYS=1 ignored")
+	}
+
+	// có JWT_PRIVATE_KEY_FILE: file chưa có => sinh và ghi ra, lần sau đọc lại đúng khoá đó
+	path := filepath.Join(t.TempDir(), "jwt.pem")
+	s1, err := New(Config{Alg: "ES256", PrivateKeyFile: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2, err := New(Config{Alg: "ES256", PrivateKeyFile: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s1.active.ID != s2.active.ID {
+		t.Error("key file not reused")
+	}
+	if _, err := New(Config{Alg: "RS256", PrivateKeyFile: path}); err == nil {
+		t.Error("ES256 key file accepted for JWT_ALG=RS256")
+	}
+}
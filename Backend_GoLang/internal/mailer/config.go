@@ -0,0 +1,77 @@
+package mailer
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+type Config struct {
+	Backend string // log | smtp | file | maildir | memory
+	From    string
+	FileDir string // thư mục cho backend file/maildir
+
+	SMTPHost, SMTPPort, SMTPUser, SMTPPass string
+	SMTPSecurity                           string // auto | starttls | tls | none
+	SMTPTimeout                            time.Duration
+}
+
+func getEnv(k, def string) string {
+	if v := os.Getenv(k); v != "" {
+		return v
+	}
+	return def
+}
+
+// LoadConfigFromEnv đọc cấu hình mail từ biến môi trường (.env)
+func LoadConfigFromEnv() Config {
+	_ = godotenv.Load()
+	backend := os.Getenv("MAIL_BACKEND")
+	if backend == "" {
+		// tương thích cấu hình cũ: chỉ đặt MAIL_FILE_DIR => ghi file
+		backend = "log"
+		if os.Getenv("MAIL_FILE_DIR") != "" {
+			backend = "file"
+		}
+	}
+	timeout, err := time.ParseDuration(getEnv("SMTP_TIMEOUT", "10s"))
+	if err != nil || timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return Config{
+		Backend:      backend,
+		From:         getEnv("MAIL_FROM", "CRUD API <no-reply@localhost>"),
+		FileDir:      getEnv("MAIL_FILE_DIR", "./mail"),
+		SMTPHost:     getEnv("SMTP_HOST", "127.0.0.1"),
+		SMTPPort:     getEnv("SMTP_PORT", "1025"),
+		SMTPUser:     os.Getenv("SMTP_USER"),
+		SMTPPass:     os.Getenv("SMTP_PASS"),
+		SMTPSecurity: getEnv("SMTP_SECURITY", "auto"),
+		SMTPTimeout:  timeout,
+	}
+}
+
+// New tạo Mailer theo cfg.Backend
+func New(cfg Config) (Mailer, error) {
+	switch cfg.Backend {
+	case "log":
+		return NewLogMailer(), nil
+	case "file", "maildir":
+		return &FileMailer{Dir: cfg.FileDir, From: cfg.From, Maildir: cfg.Backend == "maildir"}, nil
+	case "memory":
+		return NewMemoryMailer(), nil
+	case "smtp":
+		switch cfg.SMTPSecurity {
+		case "auto", "starttls", "tls", "none":
+		default:
+			return nil, fmt.Errorf("invalid SMTP_SECURITY %q", cfg.SMTPSecurity)
+		}
+		return &SMTPMailer{
+			Host: cfg.SMTPHost, Port: cfg.SMTPPort, Username: cfg.SMTPUser, Password: cfg.SMTPPass,
+			From: cfg.From, Security: cfg.SMTPSecurity, Timeout: cfg.SMTPTimeout,
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown MAIL_BACKEND %q", cfg.Backend)
+}
@@ -0,0 +1,48 @@
+package mailer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FileMailer ghi mỗi email thành 1 file .eml trong Dir (mở bằng mail client để xem).
+// Maildir = true: ghi theo cấu trúc Maildir (tmp/ -> new/) để đọc bằng mutt, Thunderbird, ...
+type FileMailer struct {
+	Dir     string
+	From    string
+	Maildir bool
+}
+
+func NewFileMailer(dir string) *FileMailer { return &FileMailer{Dir: dir, From: "no-reply@localhost"} }
+
+func (f *FileMailer) Send(m Message) error {
+	now := time.Now()
+	data := m.Bytes(f.From, now)
+	id := uuid.NewString()
+
+	if !f.Maildir {
+		if err := os.MkdirAll(f.Dir, 0o755); err != nil {
+			return err
+		}
+		name := fmt.Sprintf("%s-%s.eml", now.Format("20060102T150405"), id[:8])
+		return os.WriteFile(filepath.Join(f.Dir, name), data, 0o644)
+	}
+
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(f.Dir, sub), 0o755); err != nil {
+			return err
+		}
+	}
+	// ghi vào tmp rồi rename sang new => reader không bao giờ thấy file ghi dở
+	host, _ := os.Hostname()
+	name := fmt.Sprintf("%d.%s.%s", now.Unix(), id, host)
+	tmp := filepath.Join(f.Dir, "tmp", name)
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(f.Dir, "new", name))
+}
@@ -0,0 +1,84 @@
+package mailer
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Message: 1 email (Text bắt buộc, HTML tuỳ chọn => multipart/alternative)
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer: cổng gửi mail dùng chung cho các luồng (quên mật khẩu, xác thực email, ...)
+type Mailer interface {
+	Send(m Message) error
+}
+
+// LogMailer chỉ in email ra log (mặc định khi dev, không cần SMTP)
+type LogMailer struct{}
+
+func NewLogMailer() *LogMailer { return &LogMailer{} }
+
+func (LogMailer) Send(m Message) error {
+	log.Printf("[mail] to=%s subject=%q\n%s", m.To, m.Subject, m.Text)
+	return nil
+}
+
+// Bytes dựng email hoàn chỉnh theo RFC 5322 (header + body quoted-printable)
+func (m Message) Bytes(from string, now time.Time) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\nTo: %s\r\n", from, m.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Message-ID: <%s@%s>\r\n", uuid.NewString(), domainOf(from))
+	b.WriteString("MIME-Version: 1.0\r\n")
+
+	if m.HTML == "" {
+		b.WriteString("Content-Type: text/plain; charset=UTF-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n")
+		writeQP(&b, m.Text)
+		return b.Bytes()
+	}
+
+	mw := multipart.NewWriter(&b)
+	fmt.Fprintf(&b, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", mw.Boundary())
+	for _, part := range []struct{ ctype, body string }{
+		{"text/plain; charset=UTF-8", m.Text},
+		{"text/html; charset=UTF-8", m.HTML},
+	} {
+		w, _ := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.ctype},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		writeQP(w, part.body)
+	}
+	_ = mw.Close()
+	return b.Bytes()
+}
+
+func writeQP(w interface{ Write([]byte) (int, error) }, s string) {
+	qp := quotedprintable.NewWriter(w)
+	_, _ = qp.Write([]byte(s))
+	_ = qp.Close()
+}
+
+// domainOf: phần domain của địa chỉ "Tên <a@b>" hoặc "a@b"
+func domainOf(addr string) string {
+	addr = strings.TrimSuffix(addr, ">")
+	if i := strings.LastIndex(addr, "@"); i >= 0 {
+		return addr[i+1:]
+	}
+	return "localhost"
+}
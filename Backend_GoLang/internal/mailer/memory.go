@@ -0,0 +1,43 @@
+package mailer
+
+import "sync"
+
+// MemoryMailer giữ email trong RAM (outbox) thay vì gửi, dùng cho test
+type MemoryMailer struct {
+	mu     sync.Mutex
+	outbox []Message
+}
+
+func NewMemoryMailer() *MemoryMailer { return &MemoryMailer{} }
+
+func (m *MemoryMailer) Send(msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.outbox = append(m.outbox, msg)
+	return nil
+}
+
+// Messages trả về bản sao các email đã "gửi" (cũ nhất trước)
+func (m *MemoryMailer) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.outbox...)
+}
+
+// Last: email gửi gần nhất tới địa chỉ to
+func (m *MemoryMailer) Last(to string) (Message, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := len(m.outbox) - 1; i >= 0; i-- {
+		if m.outbox[i].To == to {
+			return m.outbox[i], true
+		}
+	}
+	return Message{}, false
+}
+
+func (m *MemoryMailer) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.outbox = nil
+}
@@ -0,0 +1,101 @@
+package mailer
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"time"
+)
+
+// SMTPMailer gửi qua SMTP server (MailHog/Mailpit khi dev, hoặc SMTP thật).
+// Security: "auto" (STARTTLS nếu server hỗ trợ), "starttls" (bắt buộc), "tls" (port 465), "none".
+type SMTPMailer struct {
+	Host, Port         string
+	Username, Password string
+	From               string
+	Security           string
+	Timeout            time.Duration
+	TLSConfig          *tls.Config // nil = mặc định (ServerName = Host, CA của hệ thống)
+}
+
+func (s *SMTPMailer) tlsConfig() *tls.Config {
+	if s.TLSConfig == nil {
+		return &tls.Config{ServerName: s.Host}
+	}
+	cfg := s.TLSConfig.Clone()
+	if cfg.ServerName == "" {
+		cfg.ServerName = s.Host
+	}
+	return cfg
+}
+
+func (s *SMTPMailer) Send(m Message) error {
+	envFrom, err := mail.ParseAddress(s.From)
+	if err != nil {
+		return fmt.Errorf("invalid MAIL_FROM: %w", err)
+	}
+	c, err := s.dial()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if s.Security != "tls" && s.Security != "none" {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(s.tlsConfig()); err != nil {
+				return err
+			}
+		} else if s.Security == "starttls" {
+			return fmt.Errorf("smtp server %s does not support STARTTLS", s.Host)
+		}
+	}
+	if s.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.Username, s.Password, s.Host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(envFrom.Address); err != nil {
+		return err
+	}
+	if err := c.Rcpt(m.To); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(m.Bytes(s.From, time.Now())); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+func (s *SMTPMailer) dial() (*smtp.Client, error) {
+	addr := net.JoinHostPort(s.Host, s.Port)
+	d := &net.Dialer{Timeout: s.Timeout}
+	var (
+		conn net.Conn
+		err  error
+	)
+	if s.Security == "tls" {
+		conn, err = tls.DialWithDialer(d, "tcp", addr, s.tlsConfig())
+	} else {
+		conn, err = d.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// giới hạn tổng thời gian 1 phiên gửi, tránh treo goroutine khi server không trả lời
+	_ = conn.SetDeadline(time.Now().Add(2 * s.Timeout))
+	c, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
@@ -0,0 +1,241 @@
+package mailer
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSMTP: SMTP server tối giản chạy trong process (EHLO, STARTTLS, AUTH PLAIN, MAIL, RCPT, DATA)
+type fakeSMTP struct {
+	ln        net.Listener
+	tlsCfg    *tls.Config
+	starttls  bool // quảng bá STARTTLS
+	implicit  bool // TLS ngay khi kết nối (port 465)
+	mu        sync.Mutex
+	sessions  []smtpSession
+	closeOnce sync.Once
+}
+
+type smtpSession struct {
+	TLS        bool
+	Auth       string // "user:pass" nếu client AUTH PLAIN
+	From, Rcpt string
+	Data       string
+}
+
+func newFakeSMTP(t *testing.T, starttls, implicit bool) (*fakeSMTP, *x509.CertPool) {
+	t.Helper()
+	cert, pool := selfSignedCert(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeSMTP{ln: ln, tlsCfg: &tls.Config{Certificates: []tls.Certificate{cert}}, starttls: starttls, implicit: implicit}
+	go f.serve()
+	t.Cleanup(func() { f.closeOnce.Do(func() { ln.Close() }) })
+	return f, pool
+}
+
+func (f *fakeSMTP) port() string {
+	_, p, _ := net.SplitHostPort(f.ln.Addr().String())
+	return p
+}
+
+func (f *fakeSMTP) got() []smtpSession {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]smtpSession(nil), f.sessions...)
+}
+
+func (f *fakeSMTP) serve() {
+	for {
+		conn, err := f.ln.Accept()
+		if err != nil {
+			return
+		}
+		go f.handle(conn)
+	}
+}
+
+func (f *fakeSMTP) handle(conn net.Conn) {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	var s smtpSession
+	if f.implicit {
+		conn = tls.Server(conn, f.tlsCfg)
+		s.TLS = true
+	}
+	r := bufio.NewReader(conn)
+	reply := func(lines ...string) {
+		for _, l := range lines {
+			_, _ = conn.Write([]byte(l + "\r\n"))
+		}
+	}
+	reply("220 fake ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			if f.starttls && !s.TLS {
+				reply("250-fake", "250-STARTTLS", "250 AUTH PLAIN")
+			} else {
+				reply("250-fake", "250 AUTH PLAIN")
+			}
+		case "STARTTLS":
+			reply("220 ready")
+			tc := tls.Server(conn, f.tlsCfg)
+			if err := tc.Handshake(); err != nil {
+				return
+			}
+			conn, r, s.TLS = tc, bufio.NewReader(tc), true
+		case "AUTH":
+			_, resp, _ := strings.Cut(arg, " ")
+			b, _ := base64.StdEncoding.DecodeString(resp)
+			parts := strings.Split(string(b), "\x00")
+			if len(parts) == 3 {
+				s.Auth = parts[1] + ":" + parts[2]
+			}
+			reply("235 ok")
+		case "MAIL":
+			s.From = strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")
+			reply("250 ok")
+		case "RCPT":
+			s.Rcpt = strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>")
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+			var b strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				b.WriteString(l)
+			}
+			s.Data = b.String()
+			f.mu.Lock()
+			f.sessions = append(f.sessions, s)
+			f.mu.Unlock()
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func selfSignedCert(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _ := x509.ParseCertificate(der)
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool
+}
+
+func TestSMTPMailer(t *testing.T) {
+	tests := []struct {
+		name               string
+		security           string
+		starttls, implicit bool
+		user               string
+		wantTLS            bool
+		wantErr            string
+	}{
+		{name: "none ignores STARTTLS", security: "none", starttls: true},
+		{name: "auto upgrades with STARTTLS", security: "auto", starttls: true, user: "bob", wantTLS: true},
+		{name: "auto falls back to plain", security: "auto", user: "bob"},
+		{name: "starttls required", security: "starttls", starttls: true, wantTLS: true},
+		{name: "starttls missing", security: "starttls", wantErr: "does not support STARTTLS"},
+		{name: "implicit tls", security: "tls", implicit: true, user: "bob", wantTLS: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, pool := newFakeSMTP(t, tt.starttls, tt.implicit)
+			m := &SMTPMailer{
+				Host: "127.0.0.1", Port: srv.port(), Username: tt.user, Password: "secret",
+				From: "CRUD API <no-reply@example.com>", Security: tt.security, Timeout: 2 * time.Second,
+				TLSConfig: &tls.Config{RootCAs: pool},
+			}
+			err := m.Send(Message{To: "alice@example.com", Subject: "Xin chào", Text: "hello\n"})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+			got := srv.got()
+			if len(got) != 1 {
+				t.Fatalf("got %d messages, want 1", len(got))
+			}
+			s := got[0]
+			if s.TLS != tt.wantTLS {
+				t.Errorf("TLS = %v, want %v", s.TLS, tt.wantTLS)
+			}
+			wantAuth := ""
+			if tt.user != "" {
+				wantAuth = tt.user + ":secret"
+			}
+			if s.Auth != wantAuth {
+				t.Errorf("auth = %q, want %q", s.Auth, wantAuth)
+			}
+			if s.From != "no-reply@example.com" || s.Rcpt != "alice@example.com" {
+				t.Errorf("envelope = %q -> %q", s.From, s.Rcpt)
+			}
+			if !strings.Contains(s.Data, "To: alice@example.com\r\n") || !strings.Contains(s.Data, "Subject: =?utf-8?q?Xin_ch=C3=A0o?=") {
+				t.Errorf("unexpected data:\n%s", s.Data)
+			}
+		})
+	}
+}
+
+func TestNewValidatesBackend(t *testing.T) {
+	if _, err := New(Config{Backend: "smtp", SMTPSecurity: "ssl"}); err == nil {
+		t.Error("want error for invalid SMTP_SECURITY")
+	}
+	if _, err := New(Config{Backend: "pigeon"}); err == nil {
+		t.Error("want error for unknown backend")
+	}
+	if m, err := New(Config{Backend: "memory"}); err != nil || m == nil {
+		t.Errorf("memory backend: %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htemplate "html/template"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	ttemplate "text/template"
+	"time"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+// Languages: các ngôn ngữ có bản dịch, phần tử đầu là mặc định
+var Languages = []string{"vi", "en"}
+
+// Tên template (file templates/<lang>/<name>.tmpl, mỗi file define "subject", "text", "content")
+const (
+	TemplatePasswordReset = "password_reset"
+	TemplateEmailVerify   = "email_verify"
+)
+
+// Data: dữ liệu truyền vào template
+type Data struct {
+	Name    string // tên hiển thị người nhận
+	Link    string // link hành động (đặt lại mật khẩu, xác thực, ...)
+	Expires string // thời hạn link, đã format theo ngôn ngữ (FormatDuration)
+}
+
+type mailTemplate struct {
+	text *ttemplate.Template // subject + bản text
+	html *htemplate.Template // layout + content (tự escape)
+}
+
+var templates = mustLoadTemplates()
+
+func mustLoadTemplates() map[string]mailTemplate {
+	htmlFuncs := htemplate.FuncMap{"button": button}
+	// bản text không dùng "button" nhưng vẫn phải khai báo để parse được "content"
+	textFuncs := ttemplate.FuncMap{"button": func(string, string) string { return "" }}
+
+	out := map[string]mailTemplate{}
+	for _, lang := range Languages {
+		files, err := fs.Glob(templateFS, "templates/"+lang+"/*.tmpl")
+		if err != nil {
+			panic(err)
+		}
+		for _, f := range files {
+			key := lang + "/" + strings.TrimSuffix(path.Base(f), ".tmpl")
+			out[key] = mailTemplate{
+				text: ttemplate.Must(ttemplate.New(key).Funcs(textFuncs).ParseFS(templateFS, f)),
+				html: htemplate.Must(htemplate.New(key).Funcs(htmlFuncs).ParseFS(templateFS, "templates/layout.tmpl", f)),
+			}
+		}
+	}
+	return out
+}
+
+// button: nút bấm dạng link (href/nhãn đều được escape)
+func button(link, label string) htemplate.HTML {
+	return htemplate.HTML(fmt.Sprintf(
+		`<p style="margin:24px 0"><a href="%s" style="display:inline-block;padding:12px 20px;background:#4f46e5;color:#ffffff;border-radius:8px;text-decoration:none">%s</a></p>`,
+		htemplate.HTMLEscapeString(link), htemplate.HTMLEscapeString(label)))
+}
+
+// Render dựng nội dung email từ template name theo ngôn ngữ lang
+// (lang không có bản dịch => ngôn ngữ mặc định). Caller tự điền Message.To.
+func Render(name, lang string, data Data) (Message, error) {
+	t, ok := templates[normalizeLang(lang)+"/"+name]
+	if !ok {
+		return Message{}, fmt.Errorf("mail template %q not found", name)
+	}
+	var subject, text, html bytes.Buffer
+	if err := t.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, err
+	}
+	if err := t.text.ExecuteTemplate(&text, "text", data); err != nil {
+		return Message{}, err
+	}
+	if err := t.html.ExecuteTemplate(&html, "layout", data); err != nil {
+		return Message{}, err
+	}
+	return Message{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+		HTML:    html.String(),
+	}, nil
+}
+
+func normalizeLang(lang string) string {
+	if slices.Contains(Languages, lang) {
+		return lang
+	}
+	return Languages[0]
+}
+
+// PickLang chọn ngôn ngữ hỗ trợ đầu tiên trong header Accept-Language ("" nếu không có)
+func PickLang(acceptLanguage string) string {
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, _, _ := strings.Cut(strings.TrimSpace(part), ";")
+		primary, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if slices.Contains(Languages, primary) {
+			return primary
+		}
+	}
+	return ""
+}
+
+// FormatDuration: thời hạn dạng dễ đọc theo ngôn ngữ (vd "30 phút", "3 days")
+func FormatDuration(d time.Duration, lang string) string {
+	lang = normalizeLang(lang)
+	units := map[string][3]string{"vi": {"ngày", "giờ", "phút"}, "en": {"day", "hour", "minute"}}[lang]
+	n, unit := int(d.Round(time.Minute)/time.Minute), units[2]
+	switch {
+	case d >= 48*time.Hour && d%(24*time.Hour) == 0:
+		n, unit = int(d/(24*time.Hour)), units[0]
+	case d >= time.Hour && d%time.Hour == 0:
+		n, unit = int(d/time.Hour), units[1]
+	}
+	if lang == "en" && n != 1 {
+		unit += "s"
+	}
+	return fmt.Sprintf("%d %s", n, unit)
+}
@@ -0,0 +1,124 @@
+package mailer
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderAllTemplates(t *testing.T) {
+	subjects := map[string]string{
+		"vi/" + TemplatePasswordReset: "Đặt lại mật khẩu",
+		"en/" + TemplatePasswordReset: "Reset your password",
+		"vi/" + TemplateEmailVerify:   "Xác thực",
+		"en/" + TemplateEmailVerify:   "Verify your email",
+	}
+	for _, lang := range Languages {
+		for _, name := range []string{TemplatePasswordReset, TemplateEmailVerify} {
+			t.Run(lang+"/"+name, func(t *testing.T) {
+				link := "https://app.example.com/x?token=a&b=<c>"
+				m, err := Render(name, lang, Data{Name: "<alice>", Link: link, Expires: FormatDuration(30*time.Minute, lang)})
+				if err != nil {
+					t.Fatalf("Render: %v", err)
+				}
+				if !strings.Contains(m.Subject, subjects[lang+"/"+name]) {
+					t.Errorf("subject = %q, want it to contain %q", m.Subject, subjects[lang+"/"+name])
+				}
+				if !strings.Contains(m.Text, link) || !strings.Contains(m.Text, "<alice>") {
+					t.Errorf("text missing link/name:\n%s", m.Text)
+				}
+				if !strings.Contains(m.Text, FormatDuration(30*time.Minute, lang)) {
+					t.Errorf("text missing expiry:\n%s", m.Text)
+				}
+				// HTML escape tên và link (kể cả trong button)
+				if strings.Contains(m.HTML, "<alice>") || strings.Contains(m.HTML, "b=<c>") {
+					t.Errorf("html not escaped:\n%s", m.HTML)
+				}
+				if !strings.Contains(m.HTML, "&lt;alice&gt;") || !strings.Contains(m.HTML, `href="https://app.example.com/x?token=a&amp;b=&lt;c&gt;"`) {
+					t.Errorf("html missing escaped name/link:\n%s", m.HTML)
+				}
+			})
+		}
+	}
+}
+
+func TestRenderFallsBackToDefaultLanguage(t *testing.T) {
+	de, err := Render(TemplatePasswordReset, "de", Data{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vi, _ := Render(TemplatePasswordReset, Languages[0], Data{})
+	if de.Subject != vi.Subject {
+		t.Errorf("subject = %q, want default language %q", de.Subject, vi.Subject)
+	}
+	if _, err := Render("nope", "en", Data{}); err == nil {
+		t.Error("want error for unknown template")
+	}
+}
+
+func TestPickLang(t *testing.T) {
+	tests := map[string]string{
+		"":                          "",
+		"en-US,en;q=0.9":            "en",
+		"fr-FR, vi;q=0.8, en;q=0.5": "vi",
+		"de":                        "",
+		"EN-gb":                     "en",
+	}
+	for header, want := range tests {
+		if got := PickLang(header); got != want {
+			t.Errorf("PickLang(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		lang string
+		want string
+	}{
+		{30 * time.Minute, "vi", "30 phút"},
+		{time.Minute, "en", "1 minute"},
+		{30 * time.Minute, "en", "30 minutes"},
+		{time.Hour, "en", "1 hour"},
+		{24 * time.Hour, "vi", "24 giờ"},
+		{72 * time.Hour, "en", "3 days"},
+		{90 * time.Minute, "en", "90 minutes"},
+	}
+	for _, tt := range tests {
+		if got := FormatDuration(tt.d, tt.lang); got != tt.want {
+			t.Errorf("FormatDuration(%v, %s) = %q, want %q", tt.d, tt.lang, got, tt.want)
+		}
+	}
+}
+
+// MemoryMailer: outbox cho test các luồng gửi mail
+func TestMemoryOutbox(t *testing.T) {
+	mm := NewMemoryMailer()
+	for _, name := range []string{TemplatePasswordReset, TemplateEmailVerify} {
+		m, err := Render(name, "en", Data{Name: "alice", Link: "https://x/" + name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.To = "alice@example.com"
+		if err := mm.Send(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = mm.Send(Message{To: "bob@example.com", Subject: "other"})
+
+	if n := len(mm.Messages()); n != 3 {
+		t.Fatalf("outbox has %d messages, want 3", n)
+	}
+	last, ok := mm.Last("alice@example.com")
+	if !ok || !strings.Contains(last.Text, "https://x/"+TemplateEmailVerify) {
+		t.Errorf("Last(alice) = %+v, %v", last, ok)
+	}
+	if _, ok := mm.Last("carol@example.com"); ok {
+		t.Error("Last(carol) found a message")
+	}
+	mm.Reset()
+	if n := len(mm.Messages()); n != 0 {
+		t.Errorf("after Reset outbox has %d messages", n)
+	}
+}
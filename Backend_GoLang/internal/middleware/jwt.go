@@ -1,19 +1,27 @@
 package middleware
 
 import (
-	"fmt"
 	"net/http"
-	"strconv"
 	"strings"
 
+	"crud_api_us/internal/tokens"
+
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 )
 
+// UserStateChecker kiểm tra user của token vẫn còn hợp lệ tại thời điểm request
+// (chưa bị xoá/khoá, token version khớp). reason != "": token bị từ chối, reason là mã
+// (vd "account_banned"); err != nil: lỗi hạ tầng (DB, ...), không phải lỗi của token.
+type UserStateChecker interface {
+	CheckUserState(uid, ver int) (reason string, err error)
+}
+
 // WithAuth xác thực access token trong header Authorization: Bearer <token>
-// - Dùng MapClaims để tương thích mọi kiểu claims (tránh nhầm với claims của refresh token).
-// - Trích xuất uid (uid|user_id|sub) và role.
-func WithAuth(secret string) gin.HandlerFunc {
+// - Chữ ký được kiểm tra bằng khoá theo kid trong bộ khoá (HS256/RS256/ES256/EdDSA).
+// - Claims kiểu cố định (tokens.Claims), bắt buộc typ=access và iss/aud đúng cấu hình.
+// - Refresh token, mfa_token hay token của hệ thống khác không dùng được làm bearer token.
+// - checker != nil: từ chối token của user đã bị khoá/xoá sau khi token được cấp.
+func WithAuth(tm *tokens.Manager, checker UserStateChecker) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		h := c.GetHeader("Authorization")
 		if h == "" || !strings.HasPrefix(h, "Bearer ") {
@@ -22,82 +30,90 @@ func WithAuth(secret string) gin.HandlerFunc {
 		}
 		tokenStr := strings.TrimSpace(strings.TrimPrefix(h, "Bearer "))
 
-		tok, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
-			// chỉ chấp nhận HMAC (HS256/384/512)
-			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method")
-			}
-			return []byte(secret), nil
-		})
-		if err != nil || !tok.Valid {
+		claims, err := tm.Parse(tokenStr, tokens.TypeAccess)
+		if err != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
 			return
 		}
 
-		claims, ok := tok.Claims.(jwt.MapClaims)
-		if !ok {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid claims"})
-			return
+		if checker != nil {
+			reason, err := checker.CheckUserState(claims.UserID, claims.Version)
+			if err != nil {
+				// DB lỗi không làm user bị đăng xuất và không lộ chi tiết lỗi ra client
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "server error", "code": "user_state_unavailable"})
+				return
+			}
+			if reason != "" {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "token no longer valid", "code": reason})
+				return
+			}
 		}
 
-		// Lấy uid từ các key phổ biến: uid | user_id | sub
-		uid := extractInt(claims["uid"])
-		if uid == 0 {
-			uid = extractInt(claims["user_id"])
-		}
-		if uid == 0 {
-			uid = extractInt(claims["sub"])
-		}
+		c.Set("uid", claims.UserID)
+		c.Set("role", claims.Role)
+		c.Set("mfa", claims.MFA)
+		c.Next()
+	}
+}
 
-		role := ""
-		if v, ok := claims["role"].(string); ok {
-			role = v
-		} else if v, ok := claims["rol"].(string); ok {
-			role = v
-		}
+// PermissionChecker tra quyền của role (bảng role_permissions, có cache)
+type PermissionChecker interface {
+	HasPermissions(role string, perms ...string) (bool, error)
+}
 
-		if uid == 0 || role == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid claims"})
+// RequirePermission: role của access token phải có đủ mọi quyền perms (vd "users:read").
+// Dùng sau WithAuth; thiếu quyền => 403 permission_denied.
+func RequirePermission(checker PermissionChecker, perms ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ok, err := checker.HasPermissions(c.GetString("role"), perms...)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "server error"})
+			return
+		}
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden", "code": "permission_denied"})
 			return
 		}
-
-		c.Set("uid", uid)
-		c.Set("role", role)
 		c.Next()
 	}
 }
 
-// extractInt: hỗ trợ số (float64 từ JSON), int, hoặc string số
-func extractInt(v any) int {
-	switch x := v.(type) {
-	case float64:
-		return int(x)
-	case int:
-		return x
-	case int64:
-		return int(x)
-	case string:
-		if i, err := strconv.Atoi(x); err == nil {
-			return i
-		}
-	}
-	return 0
+// RequireMFA: user thuộc các role bắt buộc 2FA phải dùng token của phiên đã qua bước 2FA
+// (claim mfa). Chưa bật 2FA thì chỉ dùng được các API đăng ký 2FA / xem thông tin.
+func RequireMFA(roles ...string) gin.HandlerFunc {
+	return requireMFA(nil, roles)
+}
+
+// MFAEnrollment cho biết user đã bật 2FA chưa
+type MFAEnrollment interface {
+	Enabled(userID int) (bool, error)
 }
 
-func RequireRoles(roles ...string) gin.HandlerFunc {
-	allow := map[string]struct{}{}
+// RequireMFAOrEnrollment như RequireMFA nhưng user chưa bật 2FA vẫn qua được (cho các API đăng ký 2FA
+// lần đầu); đã bật 2FA thì phải dùng token của phiên đã qua bước 2FA.
+func RequireMFAOrEnrollment(enrollment MFAEnrollment, roles ...string) gin.HandlerFunc {
+	return requireMFA(enrollment, roles)
+}
+
+func requireMFA(enrollment MFAEnrollment, roles []string) gin.HandlerFunc {
+	required := map[string]struct{}{}
 	for _, r := range roles {
-		allow[r] = struct{}{}
+		required[r] = struct{}{}
 	}
 	return func(c *gin.Context) {
-		roleAny, ok := c.Get("role")
-		if !ok {
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden"})
-			return
-		}
-		if _, ok := allow[roleAny.(string)]; !ok {
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden"})
-			return
+		if _, ok := required[c.GetString("role")]; ok && !c.GetBool("mfa") {
+			enrolled := true
+			if enrollment != nil {
+				var err error
+				if enrolled, err = enrollment.Enabled(c.GetInt("uid")); err != nil {
+					c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "server error"})
+					return
+				}
+			}
+			if enrolled {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "two-factor authentication required", "code": "mfa_required"})
+				return
+			}
 		}
 		c.Next()
 	}
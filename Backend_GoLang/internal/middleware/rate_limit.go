@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"crud_api_us/internal/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit giới hạn tần suất gọi các route của nhóm group theo policy trong limiter.
+// Danh tính: uid khi đã qua WithAuth (đặt middleware sau authMW), ngược lại là IP client
+// (c.ClientIP: X-Forwarded-For chỉ được tin khi request đến từ proxy trong TRUSTED_PROXIES).
+// Phản hồi có header RateLimit-Limit/Remaining/Reset/Policy; hết hạn mức => 429 + Retry-After.
+// limiter == nil hoặc nhóm không có policy => không giới hạn. Store lỗi => cho qua (chỉ ghi log).
+func RateLimit(limiter *ratelimit.Limiter, group string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limiter == nil {
+			c.Next()
+			return
+		}
+		identity := "ip:" + c.ClientIP()
+		if uid := c.GetInt("uid"); uid > 0 {
+			identity = "uid:" + strconv.Itoa(uid)
+		}
+
+		res, err := limiter.Allow(group, identity, time.Now())
+		if err != nil {
+			log.Printf("[ratelimit] %s %s: %v", group, identity, err)
+			c.Next()
+			return
+		}
+		if res.Limit == 0 {
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Limit", strconv.Itoa(res.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		c.Header("RateLimit-Reset", ceilSeconds(res.Reset))
+		c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%s", res.Limit, ceilSeconds(res.Window)))
+		if !res.Allowed {
+			c.Header("Retry-After", ceilSeconds(res.RetryAfter))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "too many requests", "code": "rate_limited"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// ceilSeconds: số giây làm tròn lên (header dùng đơn vị giây nguyên)
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
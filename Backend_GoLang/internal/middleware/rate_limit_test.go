@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"crud_api_us/internal/ratelimit"
+)
+
+func init() { gin.SetMode(gin.TestMode) }
+
+func TestRateLimitHeaders(t *testing.T) {
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), map[string]ratelimit.Policy{
+		"auth": {Limit: 2, Window: time.Minute},
+	})
+	r := gin.New()
+	setUID := func(c *gin.Context) {
+		if uid := c.GetHeader("X-Test-UID"); uid != "" {
+			c.Set("uid", 42)
+		}
+	}
+	r.GET("/x", setUID, RateLimit(limiter, "auth"), func(c *gin.Context) { c.Status(200) })
+	r.GET("/open", RateLimit(limiter, "unknown"), func(c *gin.Context) { c.Status(200) })
+	r.GET("/nil", RateLimit(nil, "auth"), func(c *gin.Context) { c.Status(200) })
+	get := func(path string, uid bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if uid {
+			req.Header.Set("X-Test-UID", "1")
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	tests := []struct {
+		code             int
+		remaining, reset string
+		retryAfter       string
+	}{
+		{200, "1", "30", ""},
+		{200, "0", "60", ""},
+		{429, "0", "60", "30"},
+	}
+	for i, tt := range tests {
+		w := get("/x", false)
+		if w.Code != tt.code {
+			t.Fatalf("request %d: status %d, want %d", i+1, w.Code, tt.code)
+		}
+		h := w.Header()
+		if h.Get("RateLimit-Limit") != "2" || h.Get("RateLimit-Policy") != "2;w=60" {
+			t.Errorf("request %d: limit %q policy %q", i+1, h.Get("RateLimit-Limit"), h.Get("RateLimit-Policy"))
+		}
+		if h.Get("RateLimit-Remaining") != tt.remaining || h.Get("RateLimit-Reset") != tt.reset {
+			t.Errorf("request %d: remaining %q reset %q; want %s, %s", i+1, h.Get("RateLimit-Remaining"), h.Get("RateLimit-Reset"), tt.remaining, tt.reset)
+		}
+		if h.Get("Retry-After") != tt.retryAfter {
+			t.Errorf("request %d: Retry-After %q, want %q", i+1, h.Get("Retry-After"), tt.retryAfter)
+		}
+	}
+	if w := get("/x", false); w.Body.String() != `{"code":"rate_limited","error":"too many requests"}` {
+		t.Errorf("429 body = %s", w.Body)
+	}
+
+	// đã đăng nhập: tính theo uid, không dùng chung hạn mức của IP
+	if w := get("/x", true); w.Code != 200 || w.Header().Get("RateLimit-Remaining") != "1" {
+		t.Errorf("uid identity: %d remaining %q", w.Code, w.Header().Get("RateLimit-Remaining"))
+	}
+	// không có policy / limiter nil: không giới hạn, không header
+	for _, path := range []string{"/open", "/nil"} {
+		for i := 0; i < 3; i++ {
+			if w := get(path, false); w.Code != 200 || w.Header().Get("RateLimit-Limit") != "" {
+				t.Fatalf("%s: status %d, RateLimit-Limit %q", path, w.Code, w.Header().Get("RateLimit-Limit"))
+			}
+		}
+	}
+}
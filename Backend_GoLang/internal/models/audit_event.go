@@ -0,0 +1,44 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Các hành động quản trị được ghi audit
+const (
+	AuditUserCreate    = "user.create"
+	AuditUserUpdate    = "user.update"
+	AuditUserDelete    = "user.delete"
+	AuditUserRestore   = "user.restore"
+	AuditUserPurge     = "user.purge"
+	AuditUserUnlock    = "user.unlock"
+	AuditMFAEnable     = "user.mfa_enable"
+	AuditPasskeyAdd    = "user.passkey_add"
+	AuditPasskeyRemove = "user.passkey_remove"
+	AuditSessionRevoke = "user.session_revoke"
+
+	// target_type = role, target_id = id của role
+	AuditRoleCreate = "role.create"
+	AuditRoleUpdate = "role.update"
+	AuditRoleDelete = "role.delete"
+)
+
+// Loại đối tượng của target_id
+const (
+	AuditTargetUser = "user"
+	AuditTargetRole = "role"
+)
+
+// AuditEvent: ai (actor) đã làm gì (action) với đối tượng nào (target: user hoặc role), thay đổi field nào
+type AuditEvent struct {
+	ID         int             `json:"id"                 gorm:"primaryKey;autoIncrement"`
+	ActorID    *int            `json:"actor_id,omitempty" gorm:"index"` // nil = không đăng nhập (tự đăng ký, hệ thống)
+	Action     string          `json:"action"             gorm:"type:varchar(50);index;not null"`
+	TargetType string          `json:"target_type"        gorm:"type:varchar(10);not null;default:user;index:idx_audit_target,priority:1"`
+	TargetID   int             `json:"target_id"          gorm:"index;index:idx_audit_target,priority:2;not null"`
+	Changes    json.RawMessage `json:"changes,omitempty"  gorm:"type:text"` // {"field":{"before":..,"after":..}}
+	IP         string          `json:"ip"                 gorm:"type:varchar(45)"`
+	RequestID  string          `json:"request_id"         gorm:"type:varchar(64);index"`
+	CreatedAt  time.Time       `json:"created_at"         gorm:"index"`
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// LoginAttempt: bộ đếm đăng nhập sai theo key ("uid:<user id>", "id:<identifier không khớp user nào>" hoặc "ip:<địa chỉ>")
+type LoginAttempt struct {
+	Key           string     `gorm:"column:attempt_key;type:varchar(191);primaryKey"`
+	Failures      int        `gorm:"not null;default:0"`
+	LastFailureAt time.Time  `gorm:"index"`
+	LockedUntil   *time.Time // != nil và > now: đang bị chặn
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// LoginEvent: lịch sử đăng nhập (cả thành công lẫn thất bại)
+type LoginEvent struct {
+	ID         int       `json:"id"                gorm:"primaryKey;autoIncrement"`
+	UserID     *int      `json:"user_id,omitempty" gorm:"index"` // nil nếu identifier không khớp user nào
+	Identifier string    `json:"identifier"        gorm:"type:varchar(255)"`
+	Success    bool      `json:"success"           gorm:"index"`
+	Reason     string    `json:"reason,omitempty"  gorm:"type:varchar(50)"`                           // lý do thất bại
+	Method     string    `json:"method"            gorm:"type:varchar(20);not null;default:password"` // password | passkey
+	IP         string    `json:"ip"                gorm:"type:varchar(45)"`
+	UserAgent  string    `json:"user_agent"        gorm:"type:varchar(255)"`
+	CreatedAt  time.Time `json:"created_at"        gorm:"index"`
+}
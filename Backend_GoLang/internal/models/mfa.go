@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// UserTOTP: secret TOTP (authenticator app) của user, mã hoá AES-GCM trước khi lưu.
+// ConfirmedAt == nil: đang đăng ký (chưa nhập mã đầu tiên) => chưa bật 2FA.
+type UserTOTP struct {
+	ID          int        `gorm:"primaryKey;autoIncrement"`
+	UserID      int        `gorm:"uniqueIndex;not null"`
+	User        User       `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	Secret      string     `gorm:"type:varchar(255);not null"` // base64(nonce|ciphertext)
+	ConfirmedAt *time.Time // thời điểm bật 2FA
+	LastStep    int64      `gorm:"not null;default:0"` // bước thời gian của mã dùng gần nhất (chống dùng lại mã)
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// MFARecoveryCode: mã khôi phục dùng 1 lần khi mất thiết bị (chỉ lưu SHA-256)
+type MFARecoveryCode struct {
+	ID        int        `gorm:"primaryKey;autoIncrement"`
+	UserID    int        `gorm:"index;not null"`
+	User      User       `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	CodeHash  string     `gorm:"type:char(64);not null"` // hex(sha256(code))
+	UsedAt    *time.Time // != nil: đã dùng
+	CreatedAt time.Time
+}
+
+// MFAChallenge: mfa_token của bước 1 đăng nhập (chỉ lưu SHA-256, dùng 1 lần, có hạn, giới hạn số lần thử).
+// Lưu DB để bước 2 chạy trên instance nào cũng được và không mất khi restart.
+type MFAChallenge struct {
+	ID           int        `gorm:"primaryKey;autoIncrement"`
+	UserID       int        `gorm:"index;not null"`
+	User         User       `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	TokenHash    string     `gorm:"type:char(64);uniqueIndex;not null"` // hex(sha256(mfa_token))
+	TokenVersion int        `gorm:"not null"`                           // token version lúc cấp: đổi mật khẩu/khoá => hết hiệu lực
+	Attempts     int        `gorm:"not null;default:0"`                 // số lần đã thử mã
+	ExpiresAt    time.Time  `gorm:"index;not null"`
+	UsedAt       *time.Time // != nil: đã đăng nhập thành công bằng challenge này
+	CreatedAt    time.Time
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// PasswordResetToken: token quên mật khẩu (chỉ lưu SHA-256, dùng 1 lần, có hạn)
+type PasswordResetToken struct {
+	ID        int        `gorm:"primaryKey;autoIncrement"`
+	UserID    int        `gorm:"index;not null"`
+	User      User       `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	TokenHash string     `gorm:"type:char(64);uniqueIndex;not null"` // hex(sha256(token))
+	ExpiresAt time.Time  `gorm:"index;not null"`
+	UsedAt    *time.Time // != nil: đã dùng hoặc bị vô hiệu
+	IP        string     `gorm:"type:varchar(45)"` // IP yêu cầu reset
+	CreatedAt time.Time
+}
@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// RateLimitBucket: trạng thái token bucket theo key "<nhóm route>|ip:<địa chỉ>" hoặc "<nhóm>|uid:<id>"
+type RateLimitBucket struct {
+	Key        string     `gorm:"column:bucket_key;type:varchar(191);primaryKey"`
+	Tokens     float64    `gorm:"not null"`
+	RefilledAt *time.Time `gorm:"index"` // nil = xô mới; GORM MySQL mặc định datetime(3) => đủ mili giây để nạp token
+}
@@ -3,12 +3,23 @@ package models
 import "time"
 
 type RefreshToken struct {
-	ID        int       `gorm:"primaryKey;autoIncrement"`
-	TokenID   string    `gorm:"type:varchar(64);uniqueIndex;not null"` // jti
-	UserID    int       `gorm:"index;not null"`
-	User      User      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
-	ExpiresAt time.Time `gorm:"index;not null"`
-	Revoked   bool      `gorm:"index;default:false"`
+	ID         int       `gorm:"primaryKey;autoIncrement"`
+	TokenID    string    `gorm:"type:varchar(64);uniqueIndex;not null"` // jti
+	UserID     int       `gorm:"index;not null"`
+	User       User      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	FamilyID   string    `gorm:"type:varchar(64);index"` // jti của token gốc (lúc login)
+	ParentID   string    `gorm:"type:varchar(64);index"` // jti của token đã rotate ra token này
+	ReplacedBy string    `gorm:"type:varchar(64)"`       // jti token con (rỗng = chưa rotate)
+	ExpiresAt  time.Time `gorm:"index;not null"`
+	Revoked    bool      `gorm:"index;default:false"`
+
+	// Thông tin phiên/thiết bị: IP & User-Agent của lần cấp token gần nhất,
+	// Name & StartedAt giữ nguyên qua các lần rotate (CreatedAt của token mới nhất = lần dùng gần nhất)
+	IP        string    `gorm:"type:varchar(45)"`
+	UserAgent string    `gorm:"type:varchar(255)"`
+	Name      string    `gorm:"type:varchar(100)"` // vd "Chrome trên Windows"
+	StartedAt time.Time // lúc đăng nhập (token gốc của family)
+
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
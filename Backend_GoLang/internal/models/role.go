@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// Role mặc định (seed khi migrate, không xoá được)
+const (
+	RoleAdmin = "admin" // luôn có mọi quyền
+	RoleUser  = "user"  // chỉ dùng API của chính mình (/auth/*)
+)
+
+// Quyền dùng trong middleware.RequirePermission
+const (
+	PermUsersRead   = "users:read"   // xem user, lịch sử đăng nhập, phiên
+	PermUsersWrite  = "users:write"  // tạo/sửa/khôi phục user, mở khoá, thu hồi phiên
+	PermUsersDelete = "users:delete" // xoá (mềm/vĩnh viễn) user
+	PermUsersRole   = "users:role"   // đổi role của user (kèm users:write)
+	PermAuditRead   = "audit:read"
+	PermRolesRead   = "roles:read"
+	PermRolesWrite  = "roles:write" // tạo/sửa/xoá role
+	PermSystemRead  = "system:read" // số liệu job nền
+)
+
+// Permissions: danh mục quyền (seed vào bảng permissions)
+var Permissions = []Permission{
+	{Name: PermUsersRead, Description: "Xem người dùng, lịch sử đăng nhập và phiên"},
+	{Name: PermUsersWrite, Description: "Tạo, sửa, khôi phục người dùng; mở khoá; thu hồi phiên"},
+	{Name: PermUsersDelete, Description: "Xoá người dùng"},
+	{Name: PermUsersRole, Description: "Gán vai trò cho người dùng"},
+	{Name: PermAuditRead, Description: "Xem nhật ký audit"},
+	{Name: PermRolesRead, Description: "Xem vai trò và quyền"},
+	{Name: PermRolesWrite, Description: "Tạo, sửa, xoá vai trò"},
+	{Name: PermSystemRead, Description: "Xem trạng thái hệ thống (job nền)"},
+}
+
+// Role: vai trò, khớp với users.role theo Name; quyền gán qua bảng role_permissions
+type Role struct {
+	ID          int          `json:"id"          gorm:"primaryKey;autoIncrement"`
+	Name        string       `json:"name"        gorm:"type:varchar(20);uniqueIndex;not null"`
+	Description string       `json:"description" gorm:"type:varchar(255)"`
+	System      bool         `json:"system"      gorm:"not null;default:false"` // role mặc định, không xoá được
+	Permissions []Permission `json:"permissions" gorm:"many2many:role_permissions;constraint:OnDelete:CASCADE"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// Permission: 1 quyền dạng <tài nguyên>:<hành động>, vd users:read
+type Permission struct {
+	ID          int    `json:"-"           gorm:"primaryKey;autoIncrement"`
+	Name        string `json:"name"        gorm:"type:varchar(50);uniqueIndex;not null"`
+	Description string `json:"description" gorm:"type:varchar(255)"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Các loại sự kiện bảo mật
+const (
+	SecurityEventRefreshReuse = "refresh_token_reuse"
+	SecurityEventPasskeyClone = "passkey_clone_warning" // bộ đếm chữ ký passkey không tăng
+)
+
+// SecurityEvent: nhật ký sự kiện bảo mật (token bị dùng lại, ...)
+type SecurityEvent struct {
+	ID        int    `gorm:"primaryKey;autoIncrement"`
+	UserID    int    `gorm:"index;not null"`
+	Type      string `gorm:"type:varchar(50);index;not null"`
+	Detail    string `gorm:"type:varchar(255)"`
+	CreatedAt time.Time
+}
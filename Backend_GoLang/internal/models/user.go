@@ -8,9 +8,11 @@ import (
 
 // User: bảng người dùng với đầy đủ trường chuyên nghiệp
 type User struct {
-	ID           int        `json:"id"            gorm:"primaryKey;autoIncrement"`
-	Username     string     `json:"username"      gorm:"type:varchar(50);uniqueIndex;not null"`
-	Email        string     `json:"email"         gorm:"type:varchar(255);uniqueIndex;not null"`
+	ID int `json:"id"            gorm:"primaryKey;autoIncrement"`
+	// username/email chỉ unique giữa các user chưa bị xoá: index unique (cột, alive)
+	// được tạo trong repository.MigrateAndSeed, alive = NULL khi đã soft delete
+	Username     string     `json:"username"      gorm:"type:varchar(50);not null"`
+	Email        string     `json:"email"         gorm:"type:varchar(255);not null"`
 	PasswordHash string     `json:"-"             gorm:"type:varchar(255);not null"` // ẩn trong JSON
 	FullName     string     `json:"full_name"     gorm:"type:varchar(100)"`
 	Phone        string     `json:"phone"         gorm:"type:varchar(20);index"`
@@ -25,9 +27,17 @@ type User struct {
 	Country    string `json:"country"     gorm:"type:varchar(100)"`
 	PostalCode string `json:"postal_code" gorm:"type:varchar(20)"`
 
-	Role   string `json:"role"   gorm:"type:varchar(20);default:user"`         // user|admin
+	Role   string `json:"role"   gorm:"type:varchar(20);default:user"`         // tên role (bảng roles), vd user|admin
 	Status string `json:"status" gorm:"type:varchar(20);default:active;index"` // active|inactive|banned
 
+	// TokenVersion tăng khi user bị khoá/xoá, đổi role hoặc đổi mật khẩu => mọi access token cũ (claim ver) hết hiệu lực
+	TokenVersion int `json:"-" gorm:"not null;default:0"`
+	// Version tăng sau mỗi lần cập nhật => ETag / If-Match (optimistic concurrency)
+	Version int `json:"-" gorm:"not null;default:1"`
+
+	// nil = chưa xác thực email (đăng ký mới, chưa bấm link trong email)
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+
 	LastLoginAt *time.Time     `json:"last_login_at,omitempty"`
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
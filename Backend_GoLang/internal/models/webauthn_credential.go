@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// WebAuthnCredential: passkey (khoá công khai WebAuthn) của user
+type WebAuthnCredential struct {
+	ID              int        `json:"id"           gorm:"primaryKey;autoIncrement"`
+	UserID          int        `json:"-"            gorm:"index;not null"`
+	User            User       `json:"-"            gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	CredentialID    []byte     `json:"-"            gorm:"type:varbinary(255);uniqueIndex;not null"`
+	PublicKey       []byte     `json:"-"            gorm:"type:blob;not null"` // COSE key
+	AttestationType string     `json:"-"            gorm:"type:varchar(32)"`
+	AAGUID          []byte     `json:"-"            gorm:"type:varbinary(16)"`
+	SignCount       uint32     `json:"-"            gorm:"not null;default:0"` // bộ đếm chữ ký (phát hiện khoá bị sao chép)
+	Transports      string     `json:"transports"   gorm:"type:varchar(100)"`  // usb,nfc,ble,internal,hybrid
+	BackupEligible  bool       `json:"-"`
+	BackupState     bool       `json:"synced"` // passkey được đồng bộ (iCloud Keychain, Google Password Manager, ...)
+	Name            string     `json:"name"         gorm:"type:varchar(100)"`
+	LastUsedAt      *time.Time `json:"last_used_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// WebAuthnSession: dữ liệu ceremony giữa bước begin và finish (dùng 1 lần, có hạn).
+// Lưu DB để begin/finish chạy trên instance khác nhau vẫn được; session_id chỉ lưu SHA-256.
+type WebAuthnSession struct {
+	ID          int       `gorm:"primaryKey;autoIncrement"`
+	SessionHash string    `gorm:"type:char(64);uniqueIndex;not null"` // hex(sha256(session_id))
+	UserID      int       `gorm:"not null;default:0"`                 // 0 = đăng nhập (chưa biết user)
+	Data        string    `gorm:"type:text;not null"`                 // JSON webauthn.SessionData
+	ExpiresAt   time.Time `gorm:"index;not null"`
+	CreatedAt   time.Time
+}
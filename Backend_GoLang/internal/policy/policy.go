@@ -0,0 +1,266 @@
+// Package policy: phân quyền theo thuộc tính (ABAC). Mỗi request gồm subject (người thực hiện),
+// action và resource (đối tượng bị tác động) kèm các thay đổi; rule khai báo trong file JSON
+// quyết định cho phép hay từ chối, kèm mã lý do. Engine thuần (không đụng DB/HTTP) => test độc lập được.
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Attrs: thuộc tính dạng chuỗi. Giá trị rỗng coi như không có thuộc tính.
+type Attrs map[string]string
+
+// Request: 1 lần hỏi quyền
+type Request struct {
+	Subject  Attrs  // người thực hiện: id, role, country, email_domain, ...
+	Action   string // vd user:update
+	Resource Attrs  // đối tượng bị tác động (tạo mới: đối tượng sắp tạo; danh sách: bộ lọc)
+	Changes  Attrs  // giá trị mới của các field bị đổi (tạo/sửa)
+}
+
+// Decision: kết quả; Reason là mã lý do khi bị từ chối
+type Decision struct {
+	Allowed bool
+	Rule    string // id rule quyết định ("" = mặc định)
+	Reason  string
+}
+
+// Mã lý do mặc định
+const (
+	ReasonNoMatch = "no_matching_policy" // không rule allow nào khớp (default deny)
+	ReasonDenied  = "policy_denied"      // rule deny không khai báo reason
+)
+
+// Rule: effect áp dụng khi role và action khớp và mọi điều kiện When đều đúng
+type Rule struct {
+	ID      string      `json:"id"`
+	Effect  string      `json:"effect"`  // allow | deny
+	Roles   []string    `json:"roles"`   // rỗng = mọi role
+	Actions []string    `json:"actions"` // "*" = mọi action, "user:*" = mọi action của user
+	When    []Condition `json:"when"`
+	Reason  string      `json:"reason"` // mã lý do trả về client (rule deny)
+}
+
+// Condition so sánh 1 thuộc tính (subject.x | resource.x | change.x) với Value.
+// Value là chuỗi, danh sách chuỗi (in/not_in) hoặc tham chiếu "$subject.country".
+// Thuộc tính không có => điều kiện sai (trừ op absent).
+type Condition struct {
+	Attr  string `json:"attr"`
+	Op    string `json:"op"` // eq | ne | in | not_in | exists | absent
+	Value any    `json:"value,omitempty"`
+
+	values []string // Value đã chuẩn hoá
+}
+
+// File: nội dung file policy
+type File struct {
+	Default string `json:"default"` // deny (mặc định) | allow: khi không rule nào khớp
+	Rules   []Rule `json:"rules"`
+}
+
+type Engine struct {
+	rules        []Rule
+	defaultAllow bool
+}
+
+var ops = []string{"eq", "ne", "in", "not_in", "exists", "absent"}
+
+// Parse đọc & kiểm tra nội dung file policy (JSON)
+func Parse(data []byte) (*Engine, error) {
+	var f File
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+	return New(f)
+}
+
+// LoadFile đọc file policy
+func LoadFile(path string) (*Engine, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	e, err := Parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return e, nil
+}
+
+// New kiểm tra các rule và dựng engine
+func New(f File) (*Engine, error) {
+	e := &Engine{}
+	switch f.Default {
+	case "", "deny":
+	case "allow":
+		e.defaultAllow = true
+	default:
+		return nil, fmt.Errorf("default: %q: expected allow|deny", f.Default)
+	}
+	for i, r := range f.Rules {
+		if r.ID == "" {
+			r.ID = fmt.Sprintf("rule-%d", i+1)
+		}
+		if r.Effect != "allow" && r.Effect != "deny" {
+			return nil, fmt.Errorf("rule %s: effect %q: expected allow|deny", r.ID, r.Effect)
+		}
+		if len(r.Actions) == 0 {
+			return nil, fmt.Errorf("rule %s: actions is required", r.ID)
+		}
+		r.When = slices.Clone(r.When)
+		for k := range r.When {
+			if err := r.When[k].compile(); err != nil {
+				return nil, fmt.Errorf("rule %s: %w", r.ID, err)
+			}
+		}
+		e.rules = append(e.rules, r)
+	}
+	return e, nil
+}
+
+func (c *Condition) compile() error {
+	scope, _, _ := strings.Cut(c.Attr, ".")
+	if !validAttr(c.Attr) || (scope != "subject" && scope != "resource" && scope != "change") {
+		return fmt.Errorf("attr %q: expected subject.<name> | resource.<name> | change.<name>", c.Attr)
+	}
+	if !slices.Contains(ops, c.Op) {
+		return fmt.Errorf("attr %s: op %q: expected one of %s", c.Attr, c.Op, strings.Join(ops, ", "))
+	}
+	switch v := c.Value.(type) {
+	case nil:
+	case string:
+		c.values = []string{v}
+	case []any:
+		for _, x := range v {
+			s, ok := x.(string)
+			if !ok {
+				return fmt.Errorf("attr %s: value must be a string or a list of strings", c.Attr)
+			}
+			c.values = append(c.values, s)
+		}
+	default:
+		return fmt.Errorf("attr %s: value must be a string or a list of strings", c.Attr)
+	}
+	needValue := c.Op != "exists" && c.Op != "absent"
+	if needValue && len(c.values) == 0 {
+		return fmt.Errorf("attr %s: op %s requires a value", c.Attr, c.Op)
+	}
+	if !needValue && len(c.values) > 0 {
+		return fmt.Errorf("attr %s: op %s takes no value", c.Attr, c.Op)
+	}
+	if (c.Op == "eq" || c.Op == "ne") && len(c.values) != 1 {
+		return fmt.Errorf("attr %s: op %s expects a single value", c.Attr, c.Op)
+	}
+	for _, v := range c.values {
+		if ref, ok := strings.CutPrefix(v, "$"); ok && !validAttr(ref) {
+			return fmt.Errorf("attr %s: bad reference %q", c.Attr, v)
+		}
+	}
+	return nil
+}
+
+func validAttr(s string) bool {
+	scope, name, ok := strings.Cut(s, ".")
+	return ok && scope != "" && name != ""
+}
+
+// Evaluate: rule deny khớp => từ chối (deny thắng allow); có rule allow khớp => cho phép;
+// không rule nào khớp => theo Default
+func (e *Engine) Evaluate(r Request) Decision {
+	var allow *Rule
+	for i := range e.rules {
+		rule := &e.rules[i]
+		if !rule.matches(r) {
+			continue
+		}
+		if rule.Effect == "deny" {
+			reason := rule.Reason
+			if reason == "" {
+				reason = ReasonDenied
+			}
+			return Decision{Rule: rule.ID, Reason: reason}
+		}
+		if allow == nil {
+			allow = rule
+		}
+	}
+	if allow != nil {
+		return Decision{Allowed: true, Rule: allow.ID}
+	}
+	if e.defaultAllow {
+		return Decision{Allowed: true}
+	}
+	return Decision{Reason: ReasonNoMatch}
+}
+
+func (rule *Rule) matches(r Request) bool {
+	if len(rule.Roles) > 0 && !slices.Contains(rule.Roles, r.Subject["role"]) {
+		return false
+	}
+	if !slices.ContainsFunc(rule.Actions, func(a string) bool { return actionMatches(a, r.Action) }) {
+		return false
+	}
+	for _, c := range rule.When {
+		if !c.holds(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func actionMatches(pattern, action string) bool {
+	if pattern == "*" || pattern == action {
+		return true
+	}
+	prefix, ok := strings.CutSuffix(pattern, "*")
+	return ok && strings.HasPrefix(action, prefix)
+}
+
+func (c Condition) holds(r Request) bool {
+	v := r.attr(c.Attr)
+	switch c.Op {
+	case "absent":
+		return v == ""
+	case "exists":
+		return v != ""
+	}
+	if v == "" {
+		return false
+	}
+	in := false
+	for _, want := range c.values {
+		if ref, ok := strings.CutPrefix(want, "$"); ok {
+			// tham chiếu tới thuộc tính không có => không khớp gì (tránh "" == "")
+			if want = r.attr(ref); want == "" {
+				continue
+			}
+		}
+		if strings.EqualFold(v, want) {
+			in = true
+			break
+		}
+	}
+	switch c.Op {
+	case "ne", "not_in":
+		return !in
+	}
+	return in
+}
+
+// attr: giá trị thuộc tính "subject.x" | "resource.x" | "change.x" ("" nếu không có)
+func (r Request) attr(path string) string {
+	scope, name, _ := strings.Cut(path, ".")
+	switch scope {
+	case "subject":
+		return r.Subject[name]
+	case "resource":
+		return r.Resource[name]
+	case "change":
+		return r.Changes[name]
+	}
+	return ""
+}
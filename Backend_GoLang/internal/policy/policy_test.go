@@ -0,0 +1,177 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+)
+
+func mustEngine(t *testing.T, f File) *Engine {
+	t.Helper()
+	e, err := New(f)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return e
+}
+
+func TestEvaluate(t *testing.T) {
+	e := mustEngine(t, File{Rules: []Rule{
+		{ID: "allow-support", Effect: "allow", Roles: []string{"support"}, Actions: []string{"user:*"},
+			When: []Condition{{Attr: "resource.country", Op: "eq", Value: "$subject.country"}}},
+		{ID: "deny-admin-grant", Effect: "deny", Actions: []string{"user:update"},
+			When: []Condition{{Attr: "change.role", Op: "eq", Value: "admin"}}, Reason: "cannot_grant_admin"},
+		{ID: "deny-no-reason", Effect: "deny", Actions: []string{"user:delete"},
+			When: []Condition{{Attr: "resource.status", Op: "in", Value: []any{"banned", "inactive"}}}},
+		{ID: "allow-read-all", Effect: "allow", Roles: []string{"auditor"}, Actions: []string{"*"}},
+	}})
+
+	vn := Attrs{"role": "support", "country": "VN"}
+	tests := []struct {
+		name   string
+		req    Request
+		allow  bool
+		rule   string
+		reason string
+	}{
+		{"allow same country", Request{Subject: vn, Action: "user:read", Resource: Attrs{"country": "vn"}},
+			true, "allow-support", ""},
+		{"default deny other country", Request{Subject: vn, Action: "user:read", Resource: Attrs{"country": "US"}},
+			false, "", ReasonNoMatch},
+		{"deny beats allow", Request{Subject: vn, Action: "user:update", Resource: Attrs{"country": "VN"},
+			Changes: Attrs{"role": "admin"}}, false, "deny-admin-grant", "cannot_grant_admin"},
+		{"deny without reason", Request{Subject: vn, Action: "user:delete", Resource: Attrs{"country": "VN", "status": "banned"}},
+			false, "deny-no-reason", ReasonDenied},
+		{"wildcard user:* matches", Request{Subject: vn, Action: "user:purge", Resource: Attrs{"country": "VN"}},
+			true, "allow-support", ""},
+		{"wildcard user:* does not match other resource", Request{Subject: vn, Action: "role:read", Resource: Attrs{"country": "VN"}},
+			false, "", ReasonNoMatch},
+		{"missing subject reference matches nothing", Request{Subject: Attrs{"role": "support"}, Action: "user:read",
+			Resource: Attrs{"country": "VN"}}, false, "", ReasonNoMatch},
+		{"missing resource attribute fails condition", Request{Subject: vn, Action: "user:read"},
+			false, "", ReasonNoMatch},
+		{"role filter", Request{Subject: Attrs{"role": "user", "country": "VN"}, Action: "user:read",
+			Resource: Attrs{"country": "VN"}}, false, "", ReasonNoMatch},
+		{"star matches any action", Request{Subject: Attrs{"role": "auditor"}, Action: "audit:read"},
+			true, "allow-read-all", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := e.Evaluate(tt.req)
+			if d.Allowed != tt.allow || d.Rule != tt.rule || d.Reason != tt.reason {
+				t.Fatalf("got %+v, want allowed=%v rule=%q reason=%q", d, tt.allow, tt.rule, tt.reason)
+			}
+		})
+	}
+}
+
+func TestEvaluateOps(t *testing.T) {
+	tests := []struct {
+		cond Condition
+		req  Request
+		want bool
+	}{
+		{Condition{Attr: "subject.country", Op: "ne", Value: "VN"}, Request{Subject: Attrs{"country": "US"}}, true},
+		{Condition{Attr: "subject.country", Op: "ne", Value: "VN"}, Request{}, false}, // thiếu thuộc tính => sai
+		{Condition{Attr: "change.role", Op: "not_in", Value: []any{"admin"}}, Request{Changes: Attrs{"role": "user"}}, true},
+		{Condition{Attr: "change.role", Op: "exists"}, Request{Changes: Attrs{"role": "user"}}, true},
+		{Condition{Attr: "change.role", Op: "exists"}, Request{Changes: Attrs{"role": ""}}, false},
+		{Condition{Attr: "change.role", Op: "absent"}, Request{}, true},
+		{Condition{Attr: "change.country", Op: "ne", Value: "$subject.country"},
+			Request{Subject: Attrs{"country": "VN"}, Changes: Attrs{"country": "US"}}, true},
+		{Condition{Attr: "change.country", Op: "eq", Value: "$subject.country"},
+			Request{Changes: Attrs{"country": "US"}}, false}, // tham chiếu rỗng không khớp
+	}
+	for _, tt := range tests {
+		e := mustEngine(t, File{Rules: []Rule{{Effect: "allow", Actions: []string{"*"}, When: []Condition{tt.cond}}}})
+		tt.req.Action = "x"
+		if got := e.Evaluate(tt.req).Allowed; got != tt.want {
+			t.Errorf("%s %s %v on %+v: got %v, want %v", tt.cond.Attr, tt.cond.Op, tt.cond.Value, tt.req, got, tt.want)
+		}
+	}
+}
+
+func TestDefaultAllow(t *testing.T) {
+	e := mustEngine(t, File{Default: "allow"})
+	if d := e.Evaluate(Request{Action: "user:read"}); !d.Allowed || d.Rule != "" {
+		t.Fatalf("got %+v, want default allow", d)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name, json, want string
+	}{
+		{"bad json", `{`, "unexpected end"},
+		{"bad default", `{"default":"maybe"}`, `default: "maybe"`},
+		{"bad effect", `{"rules":[{"effect":"maybe","actions":["x"]}]}`, `effect "maybe"`},
+		{"no actions", `{"rules":[{"id":"r","effect":"allow"}]}`, "rule r: actions is required"},
+		{"bad attr scope", `{"rules":[{"effect":"allow","actions":["x"],"when":[{"attr":"foo.x","op":"eq","value":"1"}]}]}`, `attr "foo.x"`},
+		{"attr without name", `{"rules":[{"effect":"allow","actions":["x"],"when":[{"attr":"subject.","op":"eq","value":"1"}]}]}`, `attr "subject."`},
+		{"bad op", `{"rules":[{"effect":"allow","actions":["x"],"when":[{"attr":"subject.x","op":"like","value":"1"}]}]}`, `op "like"`},
+		{"missing value", `{"rules":[{"effect":"allow","actions":["x"],"when":[{"attr":"subject.x","op":"in"}]}]}`, "requires a value"},
+		{"value on exists", `{"rules":[{"effect":"allow","actions":["x"],"when":[{"attr":"subject.x","op":"exists","value":"1"}]}]}`, "takes no value"},
+		{"eq with list", `{"rules":[{"effect":"allow","actions":["x"],"when":[{"attr":"subject.x","op":"eq","value":["a","b"]}]}]}`, "single value"},
+		{"non-string value", `{"rules":[{"effect":"allow","actions":["x"],"when":[{"attr":"subject.x","op":"eq","value":1}]}]}`, "string or a list"},
+		{"non-string list item", `{"rules":[{"effect":"allow","actions":["x"],"when":[{"attr":"subject.x","op":"in","value":["a",1]}]}]}`, "string or a list"},
+		{"bad reference", `{"rules":[{"effect":"allow","actions":["x"],"when":[{"attr":"subject.x","op":"eq","value":"$country"}]}]}`, `bad reference "$country"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.json))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("got %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// policies.json đi kèm repo: các kịch bản support / country / admin
+func TestShippedPolicies(t *testing.T) {
+	e, err := LoadFile("../../policies.json")
+	if err != nil {
+		t.Fatalf("LoadFile: %v", err)
+	}
+	admin := Attrs{"id": "1", "role": "admin", "country": "US"}
+	support := Attrs{"id": "2", "role": "support", "country": "VN", "email_domain": "acme.io"}
+	vnUser := Attrs{"id": "3", "role": "user", "country": "VN", "email_domain": "x.com"}
+	usUser := Attrs{"id": "4", "role": "user", "country": "US", "email_domain": "x.com"}
+	orgUser := Attrs{"id": "5", "role": "user", "country": "JP", "email_domain": "acme.io"}
+	vnAdmin := Attrs{"id": "6", "role": "admin", "country": "VN", "email_domain": "x.com"}
+
+	tests := []struct {
+		name     string
+		subject  Attrs
+		action   string
+		resource Attrs
+		changes  Attrs
+		reason   string // "" = cho phép
+	}{
+		{"admin manages anyone", admin, "user:update", vnUser, Attrs{"role": "admin"}, ""},
+		{"admin purges", admin, "user:purge", vnAdmin, nil, ""},
+		{"support edits own country", support, "user:update", vnUser, Attrs{"full_name": "A"}, ""},
+		{"support edits own organization", support, "user:update", orgUser, Attrs{"full_name": "A"}, ""},
+		{"support other country", support, "user:update", usUser, Attrs{"full_name": "A"}, ReasonNoMatch},
+		{"support lists own country", support, "user:list", Attrs{"country": "VN"}, nil, ""},
+		{"support lists without filter", support, "user:list", Attrs{}, nil, ReasonNoMatch},
+		{"support creates in own country", support, "user:create", Attrs{"role": "user", "country": "VN"}, Attrs{"country": "VN"}, ""},
+		{"support cannot grant admin", support, "user:update", vnUser, Attrs{"role": "admin"}, "cannot_grant_admin"},
+		{"support cannot create admin", support, "user:create", Attrs{"role": "admin", "country": "VN"}, Attrs{"role": "admin"}, "cannot_grant_admin"},
+		{"support cannot touch admin", support, "user:read", vnAdmin, nil, "cannot_manage_admin"},
+		{"support cannot revoke admin sessions", support, "user:sessions", vnAdmin, nil, "cannot_manage_admin"},
+		{"support unlocks own country", support, "user:unlock", vnUser, nil, ""},
+		{"support cannot unlock other country", support, "user:unlock", usUser, nil, ReasonNoMatch},
+		{"support cannot move user abroad", support, "user:update", vnUser, Attrs{"country": "US"}, "country_out_of_scope"},
+		{"support cannot delete", support, "user:delete", vnUser, nil, ReasonNoMatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := e.Evaluate(Request{Subject: tt.subject, Action: tt.action, Resource: tt.resource, Changes: tt.changes})
+			if tt.reason == "" && !d.Allowed {
+				t.Fatalf("denied (%s), want allowed", d.Reason)
+			}
+			if tt.reason != "" && (d.Allowed || d.Reason != tt.reason) {
+				t.Fatalf("got %+v, want denied with %q", d, tt.reason)
+			}
+		})
+	}
+}
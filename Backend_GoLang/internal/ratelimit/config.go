@@ -0,0 +1,110 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// Config: policy theo nhóm route. Key của Policies là tên nhóm dùng trong router (auth, register, ...).
+type Config struct {
+	Enabled  bool
+	Store    string // memory | db (db: dùng chung giữa nhiều instance)
+	Policies map[string]Policy
+}
+
+// DefaultPolicies: áp dụng khi env/file không khai báo nhóm tương ứng
+var DefaultPolicies = map[string]Policy{
+	"auth":     {Limit: 30, Window: time.Minute},  // đăng nhập, refresh, quên mật khẩu, ...
+	"register": {Limit: 10, Window: time.Hour},    // đăng ký tài khoản (theo IP)
+	"account":  {Limit: 120, Window: time.Minute}, // API của user đã đăng nhập (/auth/me, ...)
+	"admin":    {Limit: 300, Window: time.Minute}, // API quản trị
+}
+
+func getEnv(k, def string) string {
+	if v := os.Getenv(k); v != "" {
+		return v
+	}
+	return def
+}
+
+// LoadConfigFromEnv đọc cấu hình rate limit:
+//   - RATE_LIMITS="auth=30/1m,register=10/1h": ghi đè từng nhóm
+//   - RATE_LIMIT_FILE: file JSON {"auth":"30/1m",...}, áp dụng trước RATE_LIMITS
+func LoadConfigFromEnv() (Config, error) {
+	_ = godotenv.Load()
+	cfg := Config{
+		Enabled:  getEnv("RATE_LIMIT", "1") != "0",
+		Store:    getEnv("RATE_LIMIT_STORE", "db"),
+		Policies: map[string]Policy{},
+	}
+	for name, p := range DefaultPolicies {
+		cfg.Policies[name] = p
+	}
+
+	if path := os.Getenv("RATE_LIMIT_FILE"); path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, fmt.Errorf("RATE_LIMIT_FILE: %w", err)
+		}
+		var raw map[string]string
+		if err := json.Unmarshal(b, &raw); err != nil {
+			return cfg, fmt.Errorf("RATE_LIMIT_FILE: %w", err)
+		}
+		for name, spec := range raw {
+			if err := cfg.set(name, spec); err != nil {
+				return cfg, fmt.Errorf("RATE_LIMIT_FILE: %w", err)
+			}
+		}
+	}
+
+	for _, item := range strings.Split(os.Getenv("RATE_LIMITS"), ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		name, spec, ok := strings.Cut(item, "=")
+		if !ok {
+			return cfg, fmt.Errorf("RATE_LIMITS: %q: expected <group>=<limit>/<window>", item)
+		}
+		if err := cfg.set(name, spec); err != nil {
+			return cfg, fmt.Errorf("RATE_LIMITS: %w", err)
+		}
+	}
+	return cfg, nil
+}
+
+func (c *Config) set(name, spec string) error {
+	name = strings.TrimSpace(name)
+	p, err := ParsePolicy(spec)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	c.Policies[name] = p
+	return nil
+}
+
+// ParsePolicy đọc dạng "<limit>/<window>", vd "30/1m". "off" hoặc limit 0 => không giới hạn.
+func ParsePolicy(spec string) (Policy, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "off" {
+		return Policy{}, nil
+	}
+	n, w, ok := strings.Cut(spec, "/")
+	limit, err := strconv.Atoi(strings.TrimSpace(n))
+	if !ok || err != nil || limit < 0 {
+		return Policy{}, fmt.Errorf("invalid policy %q", spec)
+	}
+	window, err := time.ParseDuration(strings.TrimSpace(w))
+	if err != nil || window <= 0 {
+		return Policy{}, fmt.Errorf("invalid window in %q", spec)
+	}
+	return Policy{Limit: limit, Window: window}, nil
+}
+
+// Disabled: policy không giới hạn
+func (p Policy) Disabled() bool { return p.Limit <= 0 }
@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Policy
+		wantErr bool
+	}{
+		{"30/1m", Policy{Limit: 30, Window: time.Minute}, false},
+		{" 10 / 1h ", Policy{Limit: 10, Window: time.Hour}, false},
+		{"off", Policy{}, false},
+		{"0/1m", Policy{Window: time.Minute}, false},
+		{"30", Policy{}, true},
+		{"-1/1m", Policy{}, true},
+		{"x/1m", Policy{}, true},
+		{"30/1", Policy{}, true},
+		{"30/0s", Policy{}, true},
+		{"30/-1m", Policy{}, true},
+		{"30/abc", Policy{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePolicy(tt.spec)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParsePolicy(%q) = %+v, %v; want %+v, err %v", tt.spec, got, err, tt.want, tt.wantErr)
+		}
+	}
+	for _, spec := range []string{"off", "0/1m"} {
+		if p, _ := ParsePolicy(spec); !p.Disabled() {
+			t.Errorf("%q not disabled", spec)
+		}
+	}
+}
+
+func TestLoadConfigFromEnv(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "ratelimit.json")
+	if err := os.WriteFile(file, []byte(`{"auth":"5/1m","admin":"off"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("RATE_LIMIT_FILE", file)
+	t.Setenv("RATE_LIMITS", "auth=7/10s, custom=1/1h")
+	cfg, err := LoadConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Policy{
+		"auth":     {Limit: 7, Window: 10 * time.Second}, // RATE_LIMITS ghi đè file
+		"admin":    {},
+		"custom":   {Limit: 1, Window: time.Hour},
+		"register": DefaultPolicies["register"],
+		"account":  DefaultPolicies["account"],
+	}
+	for name, p := range want {
+		if cfg.Policies[name] != p {
+			t.Errorf("%s = %+v, want %+v", name, cfg.Policies[name], p)
+		}
+	}
+
+	for _, bad := range []string{"auth", "auth=30/0s", "auth=many/1m"} {
+		t.Setenv("RATE_LIMITS", bad)
+		if _, err := LoadConfigFromEnv(); err == nil {
+			t.Errorf("RATE_LIMITS=%q accepted", bad)
+		}
+	}
+}
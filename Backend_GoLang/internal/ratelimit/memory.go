@@ -0,0 +1,35 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryStore: bucket trong RAM, chỉ đúng khi chạy 1 instance
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]Bucket
+}
+
+func NewMemoryStore() *MemoryStore { return &MemoryStore{buckets: map[string]Bucket{}} }
+
+func (s *MemoryStore) Update(key string, fn func(Bucket) Bucket) (Bucket, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := fn(s.buckets[key])
+	s.buckets[key] = b
+	return b, nil
+}
+
+func (s *MemoryStore) Prune(before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	for k, b := range s.buckets {
+		if b.RefilledAt.Before(before) {
+			delete(s.buckets, k)
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,111 @@
+// Package ratelimit: giới hạn tần suất request theo thuật toán token bucket.
+// Mỗi key (nhóm route + danh tính) có 1 "xô" chứa tối đa Limit token, được nạp lại đều
+// Limit token mỗi Window; mỗi request lấy 1 token, hết token => bị từ chối.
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// Policy: tối đa Limit request trong Window (cho phép dồn tối đa Limit request 1 lúc)
+type Policy struct {
+	Limit  int
+	Window time.Duration
+}
+
+func (p Policy) rate() float64 { return float64(p.Limit) / p.Window.Seconds() } // token / giây
+
+// Bucket: trạng thái xô của 1 key
+type Bucket struct {
+	Tokens     float64
+	RefilledAt time.Time // zero = xô mới (đầy)
+}
+
+// Store lưu bucket theo key. Update phải đọc-sửa-ghi nguyên tử (nhiều instance dùng chung store).
+type Store interface {
+	Update(key string, fn func(Bucket) Bucket) (Bucket, error)
+	// Prune xoá các bucket không được dùng kể từ before
+	Prune(before time.Time) (int64, error)
+}
+
+// Result: kết quả 1 lần lấy token
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Window     time.Duration
+	Remaining  int
+	Reset      time.Duration // thời gian tới khi xô đầy lại
+	RetryAfter time.Duration // bị từ chối: thời gian tới khi có token tiếp theo
+}
+
+// Limiter áp policy theo nhóm route lên từng danh tính (ip/uid)
+type Limiter struct {
+	store    Store
+	policies map[string]Policy
+}
+
+func NewLimiter(s Store, policies map[string]Policy) *Limiter {
+	return &Limiter{store: s, policies: policies}
+}
+
+// Policy của nhóm; ok = false khi nhóm không khai báo hoặc bị tắt
+func (l *Limiter) Policy(group string) (Policy, bool) {
+	p, ok := l.policies[group]
+	return p, ok && !p.Disabled()
+}
+
+// Allow lấy 1 token trong xô của (group, identity)
+func (l *Limiter) Allow(group, identity string, now time.Time) (Result, error) {
+	p, ok := l.Policy(group)
+	if !ok {
+		return Result{Allowed: true}, nil
+	}
+	allowed := false
+	b, err := l.store.Update(group+"|"+identity, func(b Bucket) Bucket {
+		b = refill(b, p, now)
+		if b.Tokens >= 1 {
+			b.Tokens--
+			allowed = true
+		}
+		return b
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	res := Result{
+		Allowed:   allowed,
+		Limit:     p.Limit,
+		Window:    p.Window,
+		Remaining: int(math.Floor(b.Tokens)),
+		Reset:     seconds((float64(p.Limit) - b.Tokens) / p.rate()),
+	}
+	if !allowed {
+		res.RetryAfter = seconds((1 - b.Tokens) / p.rate())
+	}
+	return res, nil
+}
+
+// Prune xoá các bucket không dùng lâu hơn window dài nhất (đã nạp đầy, tương đương xô mới)
+func (l *Limiter) Prune(now time.Time) (int64, error) {
+	var longest time.Duration
+	for _, p := range l.policies {
+		if !p.Disabled() && p.Window > longest {
+			longest = p.Window
+		}
+	}
+	return l.store.Prune(now.Add(-longest))
+}
+
+func refill(b Bucket, p Policy, now time.Time) Bucket {
+	if b.RefilledAt.IsZero() {
+		return Bucket{Tokens: float64(p.Limit), RefilledAt: now}
+	}
+	if elapsed := now.Sub(b.RefilledAt).Seconds(); elapsed > 0 {
+		b.Tokens = math.Min(float64(p.Limit), b.Tokens+elapsed*p.rate())
+		b.RefilledAt = now
+	}
+	return b
+}
+
+func seconds(s float64) time.Duration { return time.Duration(s * float64(time.Second)) }
@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAllowRefill(t *testing.T) {
+	l := NewLimiter(NewMemoryStore(), map[string]Policy{"auth": {Limit: 3, Window: 30 * time.Second}}) // 1 token / 10s
+	now := time.Unix(1_700_000_000, 0)
+	allow := func(at time.Time) Result {
+		t.Helper()
+		res, err := l.Allow("auth", "ip:1", at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	// xô mới đầy: dồn được Limit request
+	for i := 2; i >= 0; i-- {
+		res := allow(now)
+		if !res.Allowed || res.Remaining != i {
+			t.Fatalf("burst: %+v, want allowed remaining %d", res, i)
+		}
+	}
+	res := allow(now)
+	if res.Allowed || res.Remaining != 0 {
+		t.Fatalf("empty bucket: %+v", res)
+	}
+	if res.RetryAfter != 10*time.Second || res.Reset != 30*time.Second {
+		t.Errorf("RetryAfter = %v, Reset = %v; want 10s, 30s", res.RetryAfter, res.Reset)
+	}
+
+	// nạp lại theo thời gian: sau 4s còn 0.4 token => chờ thêm 6s
+	// (số thực: so sánh sau khi làm tròn ms)
+	res = allow(now.Add(4 * time.Second))
+	if res.Allowed || res.RetryAfter.Round(time.Millisecond) != 6*time.Second || res.Reset.Round(time.Millisecond) != 26*time.Second {
+		t.Errorf("after 4s: %+v", res)
+	}
+	if res = allow(now.Add(10 * time.Second)); !res.Allowed || res.Remaining != 0 {
+		t.Errorf("after 10s: %+v, want 1 token", res)
+	}
+	// đồng hồ lùi không làm tăng token
+	if res = allow(now.Add(5 * time.Second)); res.Allowed {
+		t.Errorf("clock went back: %+v", res)
+	}
+	// để lâu không vượt quá Limit
+	if res = allow(now.Add(time.Hour)); !res.Allowed || res.Remaining != 2 || res.Reset != 10*time.Second {
+		t.Errorf("after 1h: %+v", res)
+	}
+
+	// mỗi danh tính có xô riêng
+	if res, _ := l.Allow("auth", "ip:2", now); !res.Allowed || res.Remaining != 2 {
+		t.Errorf("other identity: %+v, want fresh bucket", res)
+	}
+}
+
+func TestAllowWithoutPolicy(t *testing.T) {
+	l := NewLimiter(NewMemoryStore(), map[string]Policy{"off": {}})
+	for _, group := range []string{"off", "unknown"} {
+		for i := 0; i < 5; i++ {
+			res, err := l.Allow(group, "ip:1", time.Now())
+			if err != nil || !res.Allowed || res.Limit != 0 {
+				t.Fatalf("%s: %+v, %v; want unlimited", group, res, err)
+			}
+		}
+	}
+}
+
+func TestPrune(t *testing.T) {
+	l := NewLimiter(NewMemoryStore(), map[string]Policy{
+		"auth":  {Limit: 1, Window: time.Minute},
+		"admin": {Limit: 1, Window: time.Hour},
+		"off":   {Window: 24 * time.Hour}, // bị tắt => không tính
+	})
+	now := time.Unix(1_700_000_000, 0)
+	l.Allow("auth", "ip:old", now)
+	l.Allow("auth", "ip:new", now.Add(30*time.Minute))
+	n, err := l.Prune(now.Add(time.Hour + time.Second))
+	if err != nil || n != 1 {
+		t.Fatalf("Prune = %d, %v; want 1", n, err)
+	}
+	// bucket còn lại giữ nguyên trạng thái
+	if res, _ := l.Allow("auth", "ip:new", now.Add(30*time.Minute)); res.Allowed {
+		t.Error("remaining bucket was reset")
+	}
+}
@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"sync"
+	"time"
+
+	"crud_api_us/internal/models"
+)
+
+// AttemptStore lưu bộ đếm đăng nhập sai. Bản DB dùng chung được giữa nhiều instance,
+// bản memory chỉ dùng cho 1 instance (dev/test).
+type AttemptStore interface {
+	// Reserve giữ chỗ 1 lượt thử của key một cách nguyên tử: key đang bị chặn => ok=false, không đếm
+	// (r.Next là trạng thái hiện tại); ngược lại đếm luôn lượt này như 1 lần sai, để các request song song
+	// thấy ngay. Lần sai trước cũ hơn window => đếm lại từ đầu. lockFor(số lần sai) trả về thời gian bị chặn (0 = không chặn).
+	Reserve(key string, now time.Time, window time.Duration, lockFor func(failures int) time.Duration) (r Reservation, ok bool, err error)
+	// Refund hoàn lại lượt đã giữ chỗ (lượt thử hoá ra không sai)
+	Refund(r Reservation) error
+	Reset(key string) error
+	// Prune xoá các key không bị chặn và không sai lần nào kể từ before
+	Prune(before time.Time) (int64, error)
+}
+
+// Reservation: 1 lượt thử đã giữ chỗ, kèm trạng thái trước/sau khi đếm
+type Reservation struct {
+	Key        string
+	Prev, Next models.LoginAttempt
+}
+
+// nextAttempt: trạng thái sau 1 lần sai (dùng chung cho các store)
+func nextAttempt(a models.LoginAttempt, now time.Time, window time.Duration, lockFor func(int) time.Duration) models.LoginAttempt {
+	if now.Sub(a.LastFailureAt) > window && (a.LockedUntil == nil || now.After(*a.LockedUntil)) {
+		a.Failures = 0
+	}
+	a.Failures++
+	a.LastFailureAt = now
+	if d := lockFor(a.Failures); d > 0 {
+		until := now.Add(d)
+		a.LockedUntil = &until
+	}
+	return a
+}
+
+// refunded: trạng thái sau khi hoàn lượt r. Chưa ai đếm thêm từ lúc giữ chỗ => trả về đúng r.Prev
+// (kể cả thời điểm sai cuối và khoá tạm do chính lượt này gây ra); ngược lại chỉ giảm bộ đếm.
+func refunded(cur models.LoginAttempt, r Reservation) models.LoginAttempt {
+	if cur.Failures == r.Next.Failures {
+		return r.Prev
+	}
+	if cur.Failures > 0 {
+		cur.Failures--
+	}
+	return cur
+}
+
+func locked(a models.LoginAttempt, now time.Time) bool {
+	return a.LockedUntil != nil && a.LockedUntil.After(now)
+}
+
+type memoryAttemptStore struct {
+	mu    sync.Mutex
+	items map[string]models.LoginAttempt
+}
+
+func NewMemoryAttemptStore() AttemptStore {
+	return &memoryAttemptStore{items: map[string]models.LoginAttempt{}}
+}
+
+func (s *memoryAttemptStore) Reserve(key string, now time.Time, window time.Duration, lockFor func(int) time.Duration) (Reservation, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a := s.items[key]
+	a.Key = key
+	if locked(a, now) {
+		return Reservation{Key: key, Prev: a, Next: a}, false, nil
+	}
+	r := Reservation{Key: key, Prev: a, Next: nextAttempt(a, now, window, lockFor)}
+	s.items[key] = r.Next
+	if len(s.items) > 10000 { // tránh map phình mãi khi bị dò nhiều identifier
+		s.prune(now.Add(-window))
+	}
+	return r, true, nil
+}
+
+func (s *memoryAttemptStore) Refund(r Reservation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cur, ok := s.items[r.Key]
+	if !ok {
+		return nil // đã bị Reset/Prune
+	}
+	if a := refunded(cur, r); a.Failures > 0 {
+		s.items[r.Key] = a
+	} else {
+		delete(s.items, r.Key)
+	}
+	return nil
+}
+
+func (s *memoryAttemptStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.items, key)
+	return nil
+}
+
+func (s *memoryAttemptStore) Prune(before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.prune(before), nil
+}
+
+func (s *memoryAttemptStore) prune(before time.Time) int64 {
+	var n int64
+	now := time.Now()
+	for k, a := range s.items {
+		if a.LastFailureAt.Before(before) && (a.LockedUntil == nil || a.LockedUntil.Before(now)) {
+			delete(s.items, k)
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"time"
+
+	"crud_api_us/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type mysqlAttemptStore struct{ db *gorm.DB }
+
+func NewMySQLAttemptStore(db *gorm.DB) AttemptStore { return &mysqlAttemptStore{db: db} }
+
+func (s *mysqlAttemptStore) Reserve(key string, now time.Time, window time.Duration, lockFor func(int) time.Duration) (Reservation, bool, error) {
+	r := Reservation{Key: key}
+	ok := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// tạo dòng nếu chưa có rồi khoá dòng (SELECT ... FOR UPDATE) => kiểm tra + đếm tuần tự giữa các instance
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.LoginAttempt{Key: key, LastFailureAt: now}).Error; err != nil {
+			return err
+		}
+		var a models.LoginAttempt
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("attempt_key = ?", key).Take(&a).Error; err != nil {
+			return err
+		}
+		r.Prev, r.Next = a, a
+		if locked(a, now) {
+			return nil
+		}
+		ok = true
+		r.Next = nextAttempt(a, now, window, lockFor)
+		return saveAttempt(tx, r.Next)
+	})
+	return r, ok, err
+}
+
+func (s *mysqlAttemptStore) Refund(r Reservation) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var cur models.LoginAttempt
+		res := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("attempt_key = ?", r.Key).Limit(1).Find(&cur)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error // không còn dòng (đã bị Reset/Prune) => không có gì để hoàn
+		}
+		if a := refunded(cur, r); a.Failures > 0 {
+			return saveAttempt(tx, a)
+		}
+		return tx.Where("attempt_key = ?", r.Key).Delete(&models.LoginAttempt{}).Error
+	})
+}
+
+func saveAttempt(tx *gorm.DB, a models.LoginAttempt) error {
+	return tx.Model(&models.LoginAttempt{}).Where("attempt_key = ?", a.Key).Updates(map[string]any{
+		"failures": a.Failures, "last_failure_at": a.LastFailureAt, "locked_until": a.LockedUntil,
+	}).Error
+}
+
+func (s *mysqlAttemptStore) Reset(key string) error {
+	return s.db.Where("attempt_key = ?", key).Delete(&models.LoginAttempt{}).Error
+}
+
+func (s *mysqlAttemptStore) Prune(before time.Time) (int64, error) {
+	res := s.db.Where("last_failure_at < ? AND (locked_until IS NULL OR locked_until < ?)", before, time.Now()).
+		Delete(&models.LoginAttempt{})
+	return res.RowsAffected, res.Error
+}
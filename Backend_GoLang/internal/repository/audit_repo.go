@@ -0,0 +1,22 @@
+package repository
+
+import (
+	"time"
+
+	"crud_api_us/internal/models"
+)
+
+// AuditQuery: bộ lọc cho nhật ký audit (trường rỗng/nil = không lọc)
+type AuditQuery struct {
+	ActorID, TargetID *int
+	TargetType        string // models.AuditTargetUser | models.AuditTargetRole
+	Action            string
+	From, To          *time.Time
+	Offset, Limit     int
+}
+
+type AuditRepository interface {
+	Save(e *models.AuditEvent) error
+	// List trả về 1 trang audit (mới nhất trước) + tổng số bản ghi khớp bộ lọc
+	List(q AuditQuery) ([]models.AuditEvent, int64, error)
+}
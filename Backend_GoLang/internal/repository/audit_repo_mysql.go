@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"crud_api_us/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type mysqlAuditRepo struct{ db *gorm.DB }
+
+func NewMySQLAuditRepo(db *gorm.DB) AuditRepository { return &mysqlAuditRepo{db: db} }
+
+func (r *mysqlAuditRepo) Save(e *models.AuditEvent) error {
+	return r.db.Create(e).Error
+}
+
+func (r *mysqlAuditRepo) List(q AuditQuery) ([]models.AuditEvent, int64, error) {
+	var (
+		items []models.AuditEvent
+		total int64
+	)
+	tx := r.db.Model(&models.AuditEvent{})
+	if q.ActorID != nil {
+		tx = tx.Where("actor_id = ?", *q.ActorID)
+	}
+	if q.TargetType != "" {
+		tx = tx.Where("target_type = ?", q.TargetType)
+	}
+	if q.TargetID != nil {
+		tx = tx.Where("target_id = ?", *q.TargetID)
+	}
+	if q.Action != "" {
+		tx = tx.Where("action = ?", q.Action)
+	}
+	if q.From != nil {
+		tx = tx.Where("created_at >= ?", *q.From)
+	}
+	if q.To != nil {
+		tx = tx.Where("created_at < ?", *q.To)
+	}
+	tx = tx.Session(&gorm.Session{})
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := tx.Order("id DESC").Offset(q.Offset).Limit(q.Limit).Find(&items).Error
+	return items, total, err
+}
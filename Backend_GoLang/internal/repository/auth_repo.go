@@ -1,9 +1,46 @@
 package repository
 
-import "crud_api_us/internal/models"
+import (
+	"errors"
+	"time"
+
+	"crud_api_us/internal/models"
+)
+
+var (
+	// Refresh token đã bị thu hồi (hoặc vừa bị request khác rotate trước)
+	ErrTokenRevoked = errors.New("token revoked")
+)
 
 type AuthRepository interface {
 	FindByUsernameOrEmail(identifier string) (models.User, error)
 	SaveRefreshToken(token *models.RefreshToken) error
+	FindRefreshTokenByJTI(jti string) (models.RefreshToken, error)
+	// RotateRefreshToken thu hồi oldJTI và lưu token mới trong cùng 1 transaction
+	RotateRefreshToken(oldJTI string, next *models.RefreshToken) error
 	RevokeRefreshTokenByJTI(jti string) error
+	RevokeRefreshTokensByUser(userID int) error
+	// RevokeRefreshTokensByUserExcept thu hồi mọi refresh token của user trừ chuỗi rotate keepFamilyID
+	RevokeRefreshTokensByUserExcept(userID int, keepFamilyID string) error
+	// ListSessions: token mới nhất (chưa thu hồi, chưa hết hạn) của từng phiên đăng nhập, mới dùng gần nhất trước
+	ListSessions(userID int, now time.Time) ([]models.RefreshToken, error)
+	// RevokeSession thu hồi phiên familyID của user; ErrNotFound nếu không có phiên đang hoạt động
+	RevokeSession(userID int, familyID string) error
+	// RevokeRefreshTokenDescendants thu hồi mọi token được rotate ra từ jti (con, cháu, ...)
+	RevokeRefreshTokenDescendants(jti string) (int64, error)
+	SaveSecurityEvent(e *models.SecurityEvent) error
+	// PruneRefreshTokens xoá tối đa limit refresh token hết hạn trước before (kể cả đã thu hồi)
+	PruneRefreshTokens(before time.Time, limit int) (int64, error)
+
+	SaveLoginEvent(e *models.LoginEvent) error
+	// ListLoginEvents trả về 1 trang lịch sử đăng nhập (mới nhất trước) + tổng số bản ghi
+	ListLoginEvents(userID, offset, limit int) ([]models.LoginEvent, int64, error)
+	TouchLastLogin(userID int, at time.Time) error
+	// PruneLoginEvents xoá tối đa limit bản ghi lịch sử đăng nhập tạo trước before
+	PruneLoginEvents(before time.Time, limit int) (int64, error)
+
+	SavePasswordReset(t *models.PasswordResetToken) error
+	// UsePasswordReset đánh dấu token đã dùng (kèm vô hiệu các token khác của user) và trả về user id.
+	// ErrNotFound nếu token không tồn tại, đã dùng hoặc hết hạn.
+	UsePasswordReset(tokenHash string, now time.Time) (int, error)
 }
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"errors"
+	"time"
 
 	"crud_api_us/internal/models"
 
@@ -27,8 +28,178 @@ func (r *mysqlAuthRepo) SaveRefreshToken(t *models.RefreshToken) error {
 	return r.db.Create(t).Error
 }
 
+func (r *mysqlAuthRepo) FindRefreshTokenByJTI(jti string) (models.RefreshToken, error) {
+	var t models.RefreshToken
+	if err := r.db.Where("token_id = ?", jti).First(&t).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.RefreshToken{}, ErrNotFound
+		}
+		return models.RefreshToken{}, err
+	}
+	return t, nil
+}
+
+func (r *mysqlAuthRepo) RotateRefreshToken(oldJTI string, next *models.RefreshToken) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// chỉ 1 request được rotate thành công (điều kiện revoked = false)
+		res := tx.Model(&models.RefreshToken{}).
+			Where("token_id = ? AND revoked = ?", oldJTI, false).
+			Updates(map[string]any{"revoked": true, "replaced_by": next.TokenID})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrTokenRevoked
+		}
+		return tx.Create(next).Error
+	})
+}
+
 func (r *mysqlAuthRepo) RevokeRefreshTokenByJTI(jti string) error {
 	return r.db.Model(&models.RefreshToken{}).
 		Where("token_id = ? AND revoked = ?", jti, false).
 		Update("revoked", true).Error
 }
+
+func (r *mysqlAuthRepo) RevokeRefreshTokensByUser(userID int) error {
+	return r.db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked = ?", userID, false).
+		Update("revoked", true).Error
+}
+
+func (r *mysqlAuthRepo) RevokeRefreshTokensByUserExcept(userID int, keepFamilyID string) error {
+	return r.db.Model(&models.RefreshToken{}).
+		// token từ trước khi có family: family_id có thể NULL (NULL <> ? không bao giờ đúng)
+		Where("user_id = ? AND revoked = ? AND (family_id IS NULL OR family_id <> ?)", userID, false, keepFamilyID).
+		Update("revoked", true).Error
+}
+
+func (r *mysqlAuthRepo) ListSessions(userID int, now time.Time) ([]models.RefreshToken, error) {
+	// mỗi family chỉ có 1 token chưa thu hồi (token cũ bị revoke khi rotate)
+	var items []models.RefreshToken
+	err := r.db.Where("user_id = ? AND revoked = ? AND expires_at > ?", userID, false, now).
+		Order("created_at DESC").Find(&items).Error
+	return items, err
+}
+
+func (r *mysqlAuthRepo) RevokeSession(userID int, familyID string) error {
+	// token từ trước khi có family: family_id rỗng hoặc NULL, id phiên = jti
+	res := r.db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked = ? AND (family_id = ? OR (COALESCE(family_id, '') = '' AND token_id = ?))", userID, false, familyID, familyID).
+		Update("revoked", true)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mysqlAuthRepo) RevokeRefreshTokenDescendants(jti string) (int64, error) {
+	var total int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		parents := []string{jti}
+		for len(parents) > 0 {
+			var children []string
+			if err := tx.Model(&models.RefreshToken{}).
+				Where("parent_id IN ?", parents).
+				Pluck("token_id", &children).Error; err != nil {
+				return err
+			}
+			if len(children) == 0 {
+				break
+			}
+			res := tx.Model(&models.RefreshToken{}).
+				Where("token_id IN ? AND revoked = ?", children, false).
+				Update("revoked", true)
+			if res.Error != nil {
+				return res.Error
+			}
+			total += res.RowsAffected
+			parents = children
+		}
+		return nil
+	})
+	return total, err
+}
+
+func (r *mysqlAuthRepo) SaveSecurityEvent(e *models.SecurityEvent) error {
+	return r.db.Create(e).Error
+}
+
+func (r *mysqlAuthRepo) PruneRefreshTokens(before time.Time, limit int) (int64, error) {
+	return pruneBatch(r.db, &models.RefreshToken{}, limit, "expires_at < ?", before)
+}
+
+func (r *mysqlAuthRepo) SaveLoginEvent(e *models.LoginEvent) error {
+	return r.db.Create(e).Error
+}
+
+func (r *mysqlAuthRepo) ListLoginEvents(userID, offset, limit int) ([]models.LoginEvent, int64, error) {
+	var (
+		items []models.LoginEvent
+		total int64
+	)
+	q := r.db.Model(&models.LoginEvent{}).Where("user_id = ?", userID)
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := q.Order("id DESC").Offset(offset).Limit(limit).Find(&items).Error
+	return items, total, err
+}
+
+func (r *mysqlAuthRepo) TouchLastLogin(userID int, at time.Time) error {
+	// UpdateColumn: không đụng updated_at
+	return r.db.Model(&models.User{}).Where("id = ?", userID).UpdateColumn("last_login_at", at).Error
+}
+
+func (r *mysqlAuthRepo) PruneLoginEvents(before time.Time, limit int) (int64, error) {
+	return pruneBatch(r.db, &models.LoginEvent{}, limit, "created_at < ?", before)
+}
+
+// pruneBatch xoá tối đa limit dòng của model khớp điều kiện (theo id tăng dần): lấy id trước
+// rồi DELETE ... WHERE id IN, mỗi câu lệnh chỉ khoá 1 lô nhỏ
+func pruneBatch(db *gorm.DB, model any, limit int, cond string, args ...any) (int64, error) {
+	var ids []int
+	if err := db.Model(model).Where(cond, args...).Order("id").Limit(limit).Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	res := db.Where("id IN ?", ids).Delete(model)
+	return res.RowsAffected, res.Error
+}
+
+func (r *mysqlAuthRepo) SavePasswordReset(t *models.PasswordResetToken) error {
+	return r.db.Create(t).Error
+}
+
+func (r *mysqlAuthRepo) UsePasswordReset(tokenHash string, now time.Time) (int, error) {
+	var userID int
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var t models.PasswordResetToken
+		if err := tx.Where("token_hash = ?", tokenHash).First(&t).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNotFound
+			}
+			return err
+		}
+		// điều kiện nằm trong UPDATE => 2 request dùng cùng token thì chỉ 1 request thắng
+		res := tx.Model(&models.PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL AND expires_at > ?", t.ID, now).
+			Update("used_at", now)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		userID = t.UserID
+		return tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", t.UserID).
+			Update("used_at", now).Error
+	})
+	return userID, err
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"crud_api_us/internal/models"
+)
+
+// chỉ xoá token hết hạn trước mốc (kể cả đã thu hồi), theo lô, id nhỏ trước
+func TestPruneRefreshTokens(t *testing.T) {
+	db := newTestDB(t)
+	u := models.User{Username: "alice", Email: "alice@x.com", PasswordHash: "x", Status: "active"}
+	if err := db.Create(&u).Error; err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	cutoff := now.Add(-24 * time.Hour) // grace 24h
+	add := func(jti string, expires time.Time, revoked bool) {
+		t.Helper()
+		if err := db.Create(&models.RefreshToken{TokenID: jti, UserID: u.ID, ExpiresAt: expires, Revoked: revoked}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		add("old-"+strconv.Itoa(i), cutoff.Add(-time.Duration(i+1)*time.Hour), i%2 == 0)
+	}
+	add("in-grace", now.Add(-time.Hour), false)   // hết hạn nhưng còn trong grace => giữ để phát hiện reuse
+	add("revoked-live", now.Add(time.Hour), true) // bị thu hồi nhưng chưa hết hạn
+	add("live", now.Add(7*24*time.Hour), false)
+
+	r := NewMySQLAuthRepo(db)
+	for _, want := range []int64{2, 2, 1, 0} {
+		n, err := r.PruneRefreshTokens(cutoff, 2)
+		if err != nil || n != want {
+			t.Fatalf("PruneRefreshTokens = %d, %v; want %d", n, err, want)
+		}
+	}
+	var left []string
+	db.Model(&models.RefreshToken{}).Order("id").Pluck("token_id", &left)
+	if len(left) != 3 || left[0] != "in-grace" || left[1] != "revoked-live" || left[2] != "live" {
+		t.Errorf("left = %v", left)
+	}
+}
+
+func TestPruneLoginEvents(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Now()
+	for i, age := range []time.Duration{100, 95, 91, 89, 1} {
+		e := models.LoginEvent{Identifier: "e" + strconv.Itoa(i), CreatedAt: now.Add(-age * 24 * time.Hour)}
+		if err := db.Create(&e).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := NewMySQLAuthRepo(db)
+	n, err := r.PruneLoginEvents(now.Add(-90*24*time.Hour), 500)
+	if err != nil || n != 3 {
+		t.Fatalf("PruneLoginEvents = %d, %v; want 3", n, err)
+	}
+	var left []string
+	db.Model(&models.LoginEvent{}).Order("id").Pluck("identifier", &left)
+	if len(left) != 2 || left[0] != "e3" || left[1] != "e4" {
+		t.Errorf("left = %v", left)
+	}
+}
@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"time"
+
+	"crud_api_us/internal/models"
+)
+
+type MFARepository interface {
+	// GetTOTP trả về cấu hình TOTP của user (ErrNotFound nếu chưa từng đăng ký)
+	GetTOTP(userID int) (models.UserTOTP, error)
+	// SaveTOTP tạo mới hoặc thay secret đang chờ xác nhận của user
+	SaveTOTP(t *models.UserTOTP) error
+	// ConfirmTOTP bật 2FA cho user và thay toàn bộ mã khôi phục bằng codeHashes
+	ConfirmTOTP(userID int, step int64, at time.Time, codeHashes []string) error
+	// UseTOTPStep ghi nhận bước thời gian vừa dùng; ErrNotFound nếu bước đó (hoặc mới hơn) đã được dùng
+	UseTOTPStep(userID int, step int64) error
+	// UseRecoveryCode đánh dấu mã khôi phục đã dùng; ErrNotFound nếu không có / đã dùng
+	UseRecoveryCode(userID int, codeHash string, at time.Time) error
+	CountRecoveryCodes(userID int) (int64, error)
+
+	SaveChallenge(c *models.MFAChallenge) error
+	// AttemptChallenge tính 1 lần thử mã cho challenge còn hiệu lực (chưa dùng, chưa hết hạn,
+	// attempts < maxAttempts) và trả về challenge sau khi tăng. ErrNotFound nếu không còn hiệu lực.
+	AttemptChallenge(tokenHash string, maxAttempts int, now time.Time) (models.MFAChallenge, error)
+	// UseChallenge đánh dấu challenge đã dùng; ErrNotFound nếu request khác đã dùng trước
+	UseChallenge(id int, at time.Time) error
+	// PruneChallenges xoá tối đa limit challenge hết hạn trước before
+	PruneChallenges(before time.Time, limit int) (int64, error)
+}
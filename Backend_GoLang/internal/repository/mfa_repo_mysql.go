@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"crud_api_us/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type mysqlMFARepo struct{ db *gorm.DB }
+
+func NewMySQLMFARepo(db *gorm.DB) MFARepository { return &mysqlMFARepo{db: db} }
+
+func (r *mysqlMFARepo) GetTOTP(userID int) (models.UserTOTP, error) {
+	var t models.UserTOTP
+	if err := r.db.Where("user_id = ?", userID).First(&t).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return t, ErrNotFound
+		}
+		return t, err
+	}
+	return t, nil
+}
+
+func (r *mysqlMFARepo) SaveTOTP(t *models.UserTOTP) error {
+	if t.ID == 0 {
+		return r.db.Create(t).Error
+	}
+	return r.db.Model(&models.UserTOTP{}).Where("id = ?", t.ID).
+		Updates(map[string]any{"secret": t.Secret, "confirmed_at": t.ConfirmedAt, "last_step": t.LastStep}).Error
+}
+
+func (r *mysqlMFARepo) ConfirmTOTP(userID int, step int64, at time.Time, codeHashes []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.UserTOTP{}).Where("user_id = ? AND confirmed_at IS NULL", userID).
+			Updates(map[string]any{"confirmed_at": at, "last_step": step})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+		codes := make([]models.MFARecoveryCode, len(codeHashes))
+		for i, h := range codeHashes {
+			codes[i] = models.MFARecoveryCode{UserID: userID, CodeHash: h}
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+func (r *mysqlMFARepo) UseTOTPStep(userID int, step int64) error {
+	// điều kiện nằm trong UPDATE => cùng 1 mã gửi đồng thời thì chỉ 1 request thắng
+	res := r.db.Model(&models.UserTOTP{}).
+		Where("user_id = ? AND confirmed_at IS NOT NULL AND last_step < ?", userID, step).
+		Update("last_step", step)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mysqlMFARepo) UseRecoveryCode(userID int, codeHash string, at time.Time) error {
+	res := r.db.Model(&models.MFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", at)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mysqlMFARepo) CountRecoveryCodes(userID int) (int64, error) {
+	var n int64
+	err := r.db.Model(&models.MFARecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&n).Error
+	return n, err
+}
+
+func (r *mysqlMFARepo) SaveChallenge(c *models.MFAChallenge) error {
+	return r.db.Create(c).Error
+}
+
+func (r *mysqlMFARepo) AttemptChallenge(tokenHash string, maxAttempts int, now time.Time) (models.MFAChallenge, error) {
+	var c models.MFAChallenge
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("token_hash = ?", tokenHash).First(&c).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNotFound
+			}
+			return err
+		}
+		// điều kiện nằm trong UPDATE => các request đồng thời không vượt quá maxAttempts lần thử
+		res := tx.Model(&models.MFAChallenge{}).
+			Where("id = ? AND used_at IS NULL AND expires_at > ? AND attempts < ?", c.ID, now, maxAttempts).
+			UpdateColumn("attempts", gorm.Expr("attempts + 1"))
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return tx.First(&c, c.ID).Error
+	})
+	return c, err
+}
+
+func (r *mysqlMFARepo) UseChallenge(id int, at time.Time) error {
+	res := r.db.Model(&models.MFAChallenge{}).Where("id = ? AND used_at IS NULL", id).Update("used_at", at)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mysqlMFARepo) PruneChallenges(before time.Time, limit int) (int64, error) {
+	return pruneBatch(r.db, &models.MFAChallenge{}, limit, "expires_at < ?", before)
+}
@@ -1,15 +1,53 @@
 package repository
 
 import (
+	"log"
+
 	"crud_api_us/internal/models"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 func MigrateAndSeed(db *gorm.DB, seed []models.User) error {
-	if err := db.AutoMigrate(&models.User{}, &models.RefreshToken{}); err != nil {
+	// user có từ trước khi có xác thực email => coi như đã xác thực (backfill 1 lần bên dưới)
+	m := db.Migrator()
+	backfillVerified := m.HasTable(&models.User{}) && !m.HasColumn(&models.User{}, "EmailVerifiedAt")
+	// refresh token có từ trước khi lưu thông tin phiên => lấy created_at làm lúc đăng nhập
+	backfillStarted := m.HasTable(&models.RefreshToken{}) && !m.HasColumn(&models.RefreshToken{}, "StartedAt")
+	// audit có từ trước khi có target_type => mặc định user, các dòng role.* là role
+	backfillTargetType := m.HasTable(&models.AuditEvent{}) && !m.HasColumn(&models.AuditEvent{}, "TargetType")
+	if err := db.AutoMigrate(&models.User{}, &models.RefreshToken{}, &models.SecurityEvent{}, &models.LoginEvent{}, &models.AuditEvent{},
+		&models.PasswordResetToken{}, &models.UserTOTP{}, &models.MFARecoveryCode{}, &models.MFAChallenge{}, &models.WebAuthnCredential{}, &models.WebAuthnSession{},
+		&models.LoginAttempt{}, &models.RateLimitBucket{},
+		&models.Permission{}, &models.Role{}); err != nil {
 		return err
 	}
+	if backfillVerified {
+		if err := db.Exec("UPDATE users SET email_verified_at = created_at WHERE email_verified_at IS NULL").Error; err != nil {
+			return err
+		}
+	}
+	if backfillStarted {
+		if err := db.Exec("UPDATE refresh_tokens SET started_at = created_at WHERE started_at IS NULL").Error; err != nil {
+			return err
+		}
+	}
+	if backfillTargetType {
+		if err := db.Exec("UPDATE audit_events SET target_type = ? WHERE action LIKE ?", models.AuditTargetRole, "role.%").Error; err != nil {
+			return err
+		}
+	}
+	if err := seedRoles(db); err != nil {
+		return err
+	}
+	if err := ensureUserAliveUniques(db); err != nil {
+		return err
+	}
+	if err := ensureUserFulltext(db); err != nil {
+		// không chặn khởi động: Search sẽ tự fallback sang LIKE
+		log.Printf("[migrate] cannot create FULLTEXT index: %v", err)
+	}
 	var count int64
 	if err := db.Model(&models.User{}).Count(&count).Error; err != nil {
 		return err
@@ -21,3 +59,80 @@ func MigrateAndSeed(db *gorm.DB, seed []models.User) error {
 	}
 	return nil
 }
+
+// seedRoles: danh mục quyền và 2 role mặc định (idempotent). Role admin luôn được cấp mọi quyền,
+// kể cả quyền mới thêm ở phiên bản sau => giữ nguyên hành vi "admin dùng được mọi API quản trị".
+func seedRoles(db *gorm.DB) error {
+	perms := append([]models.Permission(nil), models.Permissions...)
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&perms).Error; err != nil {
+		return err
+	}
+	roles := []models.Role{
+		{Name: models.RoleAdmin, Description: "Quản trị viên", System: true},
+		{Name: models.RoleUser, Description: "Người dùng", System: true},
+	}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&roles).Error; err != nil {
+		return err
+	}
+	var admin models.Role
+	if err := db.Where("name = ?", models.RoleAdmin).Take(&admin).Error; err != nil {
+		return err
+	}
+	var all []models.Permission
+	if err := db.Find(&all).Error; err != nil {
+		return err
+	}
+	return db.Model(&admin).Association("Permissions").Replace(all)
+}
+
+// ensureUserAliveUniques: MySQL không có partial index, nên dùng cột sinh
+// alive = IF(deleted_at IS NULL, 1, NULL) và unique (username|email, alive).
+// NULL không xung đột trong unique index => user đã xoá không giữ username/email.
+func ensureUserAliveUniques(db *gorm.DB) error {
+	m := db.Migrator()
+	if db.Dialector.Name() != "mysql" {
+		// SQLite/Postgres hỗ trợ partial index
+		for _, col := range []string{"username", "email"} {
+			if err := db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS uq_users_" + col + "_alive ON users (" + col +
+				") WHERE deleted_at IS NULL").Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if !m.HasColumn(&models.User{}, "alive") {
+		if err := db.Exec("ALTER TABLE users ADD COLUMN alive TINYINT(1) " +
+			"GENERATED ALWAYS AS (IF(deleted_at IS NULL, 1, NULL)) VIRTUAL").Error; err != nil {
+			return err
+		}
+	}
+	for _, col := range []string{"username", "email"} {
+		name := "uq_users_" + col + "_alive"
+		if !m.HasIndex(&models.User{}, name) {
+			if err := db.Exec("CREATE UNIQUE INDEX " + name + " ON users (" + col + ", alive)").Error; err != nil {
+				return err
+			}
+		}
+		// index unique cũ (từ tag uniqueIndex) chặn đăng ký lại sau khi xoá
+		if old := "idx_users_" + col; m.HasIndex(&models.User{}, old) {
+			if err := m.DropIndex(&models.User{}, old); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Index FULLTEXT cho tìm kiếm user (chỉ MySQL/MariaDB)
+const userFulltextIndex = "ft_users_search"
+
+func ensureUserFulltext(db *gorm.DB) error {
+	if db.Dialector.Name() != "mysql" {
+		return nil
+	}
+	if db.Migrator().HasIndex(&models.User{}, userFulltextIndex) {
+		return nil
+	}
+	return db.Exec("ALTER TABLE users ADD FULLTEXT INDEX " + userFulltextIndex +
+		" (full_name, email, username, phone, city, country)").Error
+}
@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"time"
+
+	"crud_api_us/internal/models"
+	"crud_api_us/internal/ratelimit"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type mysqlRateLimitStore struct{ db *gorm.DB }
+
+// NewMySQLRateLimitStore: bucket lưu trong DB để nhiều instance dùng chung hạn mức
+func NewMySQLRateLimitStore(db *gorm.DB) ratelimit.Store { return &mysqlRateLimitStore{db: db} }
+
+func (s *mysqlRateLimitStore) Update(key string, fn func(ratelimit.Bucket) ratelimit.Bucket) (ratelimit.Bucket, error) {
+	var out ratelimit.Bucket
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// tạo dòng nếu chưa có (RefilledAt NULL = xô mới) rồi khoá dòng => các instance trừ token tuần tự
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.RateLimitBucket{Key: key}).Error; err != nil {
+			return err
+		}
+		var row models.RateLimitBucket
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("bucket_key = ?", key).Take(&row).Error; err != nil {
+			return err
+		}
+		b := ratelimit.Bucket{Tokens: row.Tokens}
+		if row.RefilledAt != nil {
+			b.RefilledAt = *row.RefilledAt
+		}
+		out = fn(b)
+		return tx.Model(&models.RateLimitBucket{}).Where("bucket_key = ?", key).Updates(map[string]any{
+			"tokens": out.Tokens, "refilled_at": out.RefilledAt,
+		}).Error
+	})
+	return out, err
+}
+
+func (s *mysqlRateLimitStore) Prune(before time.Time) (int64, error) {
+	res := s.db.Where("refilled_at < ?", before).Delete(&models.RateLimitBucket{})
+	return res.RowsAffected, res.Error
+}
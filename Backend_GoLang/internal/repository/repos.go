@@ -0,0 +1,38 @@
+package repository
+
+import "gorm.io/gorm"
+
+// Repos gom các repository dùng chung 1 kết nối (DB gốc hoặc 1 transaction)
+type Repos struct {
+	Users    UserRepository
+	Auth     AuthRepository
+	Audit    AuditRepository
+	MFA      MFARepository
+	WebAuthn WebAuthnRepository
+	Roles    RoleRepository
+
+	tx func(fn func(Repos) error) error
+}
+
+// InTx chạy fn trong 1 transaction; mọi repository trong Repos truyền cho fn đều dùng
+// transaction đó => fn trả lỗi thì toàn bộ thay đổi bị rollback.
+// Đã ở trong transaction (hoặc không hỗ trợ transaction) thì gọi thẳng fn.
+func (r Repos) InTx(fn func(Repos) error) error {
+	if r.tx == nil {
+		return fn(r)
+	}
+	return r.tx(fn)
+}
+
+func NewMySQLRepos(db *gorm.DB) Repos {
+	r := newMySQLRepos(db)
+	r.tx = func(fn func(Repos) error) error {
+		return db.Transaction(func(tx *gorm.DB) error { return fn(newMySQLRepos(tx)) })
+	}
+	return r
+}
+
+func newMySQLRepos(db *gorm.DB) Repos {
+	return Repos{Users: NewMySQLUserRepo(db), Auth: NewMySQLAuthRepo(db), Audit: NewMySQLAuditRepo(db),
+		MFA: NewMySQLMFARepo(db), WebAuthn: NewMySQLWebAuthnRepo(db), Roles: NewMySQLRoleRepo(db)}
+}
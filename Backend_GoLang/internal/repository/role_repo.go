@@ -0,0 +1,22 @@
+package repository
+
+import "crud_api_us/internal/models"
+
+type RoleRepository interface {
+	// List: mọi role kèm quyền, theo tên
+	List() ([]models.Role, error)
+	// Get role theo tên kèm quyền (ErrNotFound nếu không có)
+	Get(name string) (models.Role, error)
+	Create(r *models.Role) error
+	UpdateDescription(id int, description string) error
+	// SetPermissions thay toàn bộ quyền của role
+	SetPermissions(id int, perms []models.Permission) error
+	Delete(id int) error
+	// CountUsers: số user (kể cả đã xoá mềm) đang mang role
+	CountUsers(name string) (int64, error)
+
+	// ListPermissions: danh mục quyền
+	ListPermissions() ([]models.Permission, error)
+	// FindPermissions trả về các quyền có tên trong names (bỏ qua tên không tồn tại)
+	FindPermissions(names []string) ([]models.Permission, error)
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"errors"
+
+	"crud_api_us/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type mysqlRoleRepo struct{ db *gorm.DB }
+
+func NewMySQLRoleRepo(db *gorm.DB) RoleRepository { return &mysqlRoleRepo{db: db} }
+
+func (r *mysqlRoleRepo) List() ([]models.Role, error) {
+	var items []models.Role
+	err := r.db.Preload("Permissions", orderByName).Order("name").Find(&items).Error
+	return items, err
+}
+
+func (r *mysqlRoleRepo) Get(name string) (models.Role, error) {
+	var role models.Role
+	if err := r.db.Preload("Permissions", orderByName).Where("name = ?", name).Take(&role).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.Role{}, ErrNotFound
+		}
+		return models.Role{}, err
+	}
+	return role, nil
+}
+
+func (r *mysqlRoleRepo) Create(role *models.Role) error {
+	return r.db.Create(role).Error
+}
+
+func (r *mysqlRoleRepo) UpdateDescription(id int, description string) error {
+	return r.db.Model(&models.Role{ID: id}).Update("description", description).Error
+}
+
+func (r *mysqlRoleRepo) SetPermissions(id int, perms []models.Permission) error {
+	role := models.Role{ID: id}
+	if len(perms) == 0 {
+		return r.db.Model(&role).Association("Permissions").Clear()
+	}
+	return r.db.Model(&role).Association("Permissions").Replace(perms)
+}
+
+func (r *mysqlRoleRepo) Delete(id int) error {
+	if err := r.db.Model(&models.Role{ID: id}).Association("Permissions").Clear(); err != nil {
+		return err
+	}
+	res := r.db.Delete(&models.Role{}, id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mysqlRoleRepo) CountUsers(name string) (int64, error) {
+	var n int64
+	err := r.db.Unscoped().Model(&models.User{}).Where("role = ?", name).Count(&n).Error
+	return n, err
+}
+
+func (r *mysqlRoleRepo) ListPermissions() ([]models.Permission, error) {
+	var items []models.Permission
+	err := orderByName(r.db).Find(&items).Error
+	return items, err
+}
+
+func (r *mysqlRoleRepo) FindPermissions(names []string) ([]models.Permission, error) {
+	items := []models.Permission{}
+	if len(names) == 0 {
+		return items, nil
+	}
+	err := orderByName(r.db.Where("name IN ?", names)).Find(&items).Error
+	return items, err
+}
+
+func orderByName(db *gorm.DB) *gorm.DB { return db.Order("name") }
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"errors"
+	"time"
 
 	"crud_api_us/internal/models"
 )
@@ -9,13 +10,65 @@ import (
 var (
 	// Dùng cho case "không tìm thấy"
 	ErrNotFound = errors.New("not found")
+	// Version (If-Match) không khớp: user đã bị người khác sửa
+	ErrVersionMismatch = errors.New("version mismatch")
+	// Tham số truy vấn không hợp lệ (sort lạ, cursor hỏng, ...)
+	ErrInvalidQuery = errors.New("invalid query")
 )
 
+// UserQuery: lọc + sắp xếp + phân trang cho List.
+// Có Cursor (keyset) thì bỏ qua Page.
+type UserQuery struct {
+	Page, Limit int
+	Cursor      string
+	Sort        string // id|username|email|full_name|created_at|updated_at (mặc định id)
+	Desc        bool
+
+	Role, Status, Gender, Country string
+	CreatedFrom, CreatedTo        *time.Time
+	Search                        string // LIKE trên username/email/full_name/phone
+}
+
+// UserFilter: bộ lọc của Search / ListDeleted (rỗng = không lọc)
+type UserFilter struct {
+	Role, Status, Country string
+}
+
+type UserPage struct {
+	Items      []models.User
+	Total      int64  // tổng số dòng khớp bộ lọc (không tính phân trang)
+	NextCursor string // rỗng nếu đã hết
+}
+
+// UserSearchHit: 1 kết quả tìm kiếm kèm điểm liên quan (LIKE fallback => Score = 0)
+type UserSearchHit struct {
+	models.User
+	Score float64 `gorm:"column:score"`
+}
+
 // Interface dùng chung cho mọi implementation (MySQL, memory, ...).
 type UserRepository interface {
-	List() ([]models.User, error)
+	List(q UserQuery) (UserPage, error)
+	// Search: tìm kiếm toàn văn (FULLTEXT), xếp theo độ liên quan
+	Search(terms []string, f UserFilter, limit int) ([]UserSearchHit, error)
 	Get(id int) (models.User, error)
 	Create(u *models.User) error
-	Update(id int, in *models.User) (models.User, error)
-	Delete(id int) (bool, error)
+	// version > 0: chỉ ghi khi version trong DB khớp (ngược lại ErrVersionMismatch)
+	Update(id int, in *models.User, version int) (models.User, error)
+	// UpdateColumns chỉ ghi các cột trong cols (key = tên cột)
+	UpdateColumns(id int, cols map[string]any, version int) (models.User, error)
+	Delete(id int, version int) (bool, error)
+	IncrementTokenVersion(id int) error
+	// CountActiveAdmins đếm admin đang active (chưa xoá) trừ exceptID, khoá các dòng đó tới hết transaction
+	CountActiveAdmins(exceptID int) (int64, error)
+	// MarkEmailVerified xác thực email chỉ khi email hiện tại vẫn là email được ký trong link
+	MarkEmailVerified(id int, email string, at time.Time) error
+
+	// User đã soft delete
+	ListDeleted(f UserFilter, offset, limit int) ([]models.User, int64, error)
+	// GetDeleted: user đã soft delete theo id (ErrNotFound nếu không có hoặc chưa bị xoá)
+	GetDeleted(id int) (models.User, error)
+	Restore(id int) (models.User, error)
+	// Purge xoá vĩnh viễn user đã soft delete (kèm dữ liệu phụ thuộc)
+	Purge(id int) error
 }
@@ -1,20 +1,222 @@
 package repository
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"strings"
+	"time"
+	"unicode"
 
 	"crud_api_us/internal/models"
 
+	mysqldrv "github.com/go-sql-driver/mysql"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type mysqlUserRepo struct{ db *gorm.DB }
 
 func NewMySQLUserRepo(db *gorm.DB) UserRepository { return &mysqlUserRepo{db: db} }
 
-func (r *mysqlUserRepo) List() ([]models.User, error) {
+// Các cột được phép sort (tránh SQL injection qua ORDER BY)
+var userSortColumns = map[string]bool{
+	"id": true, "username": true, "email": true, "full_name": true, "created_at": true, "updated_at": true,
+}
+
+// userCursor: vị trí dòng cuối của trang trước (keyset pagination)
+type userCursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d"`
+	Value string `json:"v"`
+	ID    int    `json:"id"`
+}
+
+func (r *mysqlUserRepo) List(q UserQuery) (UserPage, error) {
+	sort := q.Sort
+	if sort == "" {
+		sort = "id"
+	}
+	if !userSortColumns[sort] {
+		return UserPage{}, ErrInvalidQuery
+	}
+	dir := "ASC"
+	if q.Desc {
+		dir = "DESC"
+	}
+
+	tx := applyUserFilter(r.db.Model(&models.User{}), UserFilter{Role: q.Role, Status: q.Status, Country: q.Country})
+	if q.Gender != "" {
+		tx = tx.Where("gender = ?", q.Gender)
+	}
+	if q.CreatedFrom != nil {
+		tx = tx.Where("created_at >= ?", *q.CreatedFrom)
+	}
+	if q.CreatedTo != nil {
+		tx = tx.Where("created_at < ?", *q.CreatedTo)
+	}
+	if s := strings.TrimSpace(q.Search); s != "" {
+		like := "%" + escapeLike(s) + "%"
+		tx = tx.Where("username LIKE ? OR email LIKE ? OR full_name LIKE ? OR phone LIKE ?", like, like, like, like)
+	}
+
+	tx = tx.Session(&gorm.Session{}) // cho phép dùng lại điều kiện cho cả Count và Find
+
+	var page UserPage
+	if err := tx.Count(&page.Total).Error; err != nil {
+		return UserPage{}, err
+	}
+
+	tx = tx.Order(sort + " " + dir)
+	if sort != "id" {
+		tx = tx.Order("id " + dir) // tie-breaker để thứ tự ổn định
+	}
+	if q.Cursor != "" {
+		cur, err := decodeUserCursor(q.Cursor)
+		if err != nil || cur.Sort != sort || cur.Desc != q.Desc {
+			return UserPage{}, ErrInvalidQuery
+		}
+		op := ">"
+		if q.Desc {
+			op = "<"
+		}
+		if sort == "id" {
+			tx = tx.Where("id "+op+" ?", cur.ID)
+		} else {
+			v, err := cursorValue(sort, cur.Value)
+			if err != nil {
+				return UserPage{}, ErrInvalidQuery
+			}
+			tx = tx.Where("("+sort+" "+op+" ?) OR ("+sort+" = ? AND id "+op+" ?)", v, v, cur.ID)
+		}
+	} else if q.Page > 1 {
+		tx = tx.Offset((q.Page - 1) * q.Limit)
+	}
+
+	// lấy dư 1 dòng để biết còn trang sau hay không
+	if err := tx.Limit(q.Limit + 1).Find(&page.Items).Error; err != nil {
+		return UserPage{}, err
+	}
+	if len(page.Items) > q.Limit {
+		page.Items = page.Items[:q.Limit]
+		page.NextCursor = encodeUserCursor(sort, q.Desc, page.Items[len(page.Items)-1])
+	}
+	return page, nil
+}
+
+func applyUserFilter(tx *gorm.DB, f UserFilter) *gorm.DB {
+	if f.Role != "" {
+		tx = tx.Where("role = ?", f.Role)
+	}
+	if f.Status != "" {
+		tx = tx.Where("status = ?", f.Status)
+	}
+	if f.Country != "" {
+		tx = tx.Where("country = ?", f.Country)
+	}
+	return tx
+}
+
+func encodeUserCursor(sort string, desc bool, u models.User) string {
+	c := userCursor{Sort: sort, Desc: desc, ID: u.ID}
+	switch sort {
+	case "username":
+		c.Value = u.Username
+	case "email":
+		c.Value = u.Email
+	case "full_name":
+		c.Value = u.FullName
+	case "created_at":
+		c.Value = u.CreatedAt.Format(time.RFC3339Nano)
+	case "updated_at":
+		c.Value = u.UpdatedAt.Format(time.RFC3339Nano)
+	}
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeUserCursor(s string) (userCursor, error) {
+	var c userCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	return c, json.Unmarshal(b, &c)
+}
+
+// cursorValue đổi giá trị trong cursor về đúng kiểu của cột
+func cursorValue(sort, v string) (any, error) {
+	if sort == "created_at" || sort == "updated_at" {
+		return time.Parse(time.RFC3339Nano, v)
+	}
+	return v, nil
+}
+
+// escapeLike thoát ký tự đại diện của LIKE
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+const userSearchColumns = "full_name, email, username, phone, city, country"
+
+func (r *mysqlUserRepo) Search(terms []string, f UserFilter, limit int) ([]UserSearchHit, error) {
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	if r.db.Dialector.Name() == "mysql" {
+		hits, err := r.searchFulltext(terms, f, limit)
+		if !isFulltextUnsupported(err) {
+			return hits, err
+		}
+	}
+	return r.searchLike(terms, f, limit)
+}
+
+func (r *mysqlUserRepo) searchFulltext(terms []string, f UserFilter, limit int) ([]UserSearchHit, error) {
+	// BOOLEAN MODE + tiền tố "term*" để khớp gần đúng (vd "ngu" khớp "nguyen")
+	// tách tiếp theo ký tự không phải chữ/số ("@", "." là toán tử/ký tự ngắt từ của FULLTEXT)
+	var parts []string
+	for _, t := range terms {
+		for _, w := range strings.FieldsFunc(t, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+			parts = append(parts, w+"*")
+		}
+	}
+	against := strings.Join(parts, " ")
+	match := "MATCH(" + userSearchColumns + ") AGAINST (? IN BOOLEAN MODE)"
+
+	var hits []UserSearchHit
+	err := applyUserFilter(r.db.Model(&models.User{}), f).
+		Select("users.*, "+match+" AS score", against).
+		Where(match, against).
+		Order("score DESC").Order("id").
+		Limit(limit).
+		Scan(&hits).Error
+	return hits, err
+}
+
+func (r *mysqlUserRepo) searchLike(terms []string, f UserFilter, limit int) ([]UserSearchHit, error) {
+	// gom các điều kiện OR vào 1 nhóm để không phá điều kiện soft delete
+	cond := r.db
+	for _, t := range terms {
+		like := "%" + escapeLike(t) + "%"
+		cond = cond.Or("full_name LIKE ? OR email LIKE ? OR username LIKE ? OR phone LIKE ? OR city LIKE ? OR country LIKE ?",
+			like, like, like, like, like, like)
+	}
 	var users []models.User
-	return users, r.db.Order("id").Find(&users).Error
+	if err := applyUserFilter(r.db.Where(cond), f).Order("id").Limit(limit).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	hits := make([]UserSearchHit, len(users))
+	for i, u := range users {
+		hits[i] = UserSearchHit{User: u}
+	}
+	return hits, nil
+}
+
+// isFulltextUnsupported: engine/bảng chưa có FULLTEXT index (1191) hoặc không hỗ trợ (1214)
+func isFulltextUnsupported(err error) bool {
+	var me *mysqldrv.MySQLError
+	return errors.As(err, &me) && (me.Number == 1191 || me.Number == 1214)
 }
 
 func (r *mysqlUserRepo) Get(id int) (models.User, error) {
@@ -32,37 +234,137 @@ func (r *mysqlUserRepo) Create(u *models.User) error {
 	return r.db.Create(u).Error
 }
 
-func (r *mysqlUserRepo) Update(id int, in *models.User) (models.User, error) {
+func (r *mysqlUserRepo) Update(id int, in *models.User, version int) (models.User, error) {
+	cols := map[string]any{
+		"username": in.Username, "email": in.Email, "full_name": in.FullName, "phone": in.Phone,
+		"gender": in.Gender, "date_of_birth": in.DateOfBirth, "avatar_url": in.AvatarURL,
+		"street": in.Street, "city": in.City, "state": in.State, "country": in.Country,
+		"postal_code": in.PostalCode, "role": in.Role, "status": in.Status,
+	}
+	if in.PasswordHash != "" {
+		cols["password_hash"] = in.PasswordHash
+	}
+	return r.UpdateColumns(id, cols, version)
+}
+
+func (r *mysqlUserRepo) UpdateColumns(id int, cols map[string]any, version int) (models.User, error) {
+	cols["version"] = gorm.Expr("version + 1")
+	tx := r.db.Model(&models.User{}).Where("id = ?", id)
+	if version > 0 {
+		// kiểm tra & ghi trong cùng 1 câu UPDATE => không có race giữa 2 admin
+		tx = tx.Where("version = ?", version)
+	}
+	res := tx.Updates(cols)
+	if res.Error != nil {
+		return models.User{}, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return models.User{}, r.missOrConflict(id)
+	}
+	return r.Get(id)
+}
+
+func (r *mysqlUserRepo) Delete(id int, version int) (bool, error) {
+	tx := r.db
+	if version > 0 {
+		tx = tx.Where("version = ?", version)
+	}
+	res := tx.Delete(&models.User{}, id)
+	if res.Error != nil || res.RowsAffected > 0 {
+		return res.RowsAffected > 0, res.Error
+	}
+	if err := r.missOrConflict(id); err != ErrNotFound {
+		return false, err
+	}
+	return false, nil
+}
+
+// missOrConflict: UPDATE/DELETE không ảnh hưởng dòng nào => user không tồn tại hay sai version?
+func (r *mysqlUserRepo) missOrConflict(id int) error {
+	if _, err := r.Get(id); err != nil {
+		return err
+	}
+	return ErrVersionMismatch
+}
+
+func (r *mysqlUserRepo) IncrementTokenVersion(id int) error {
+	return r.db.Model(&models.User{}).Where("id = ?", id).
+		UpdateColumn("token_version", gorm.Expr("token_version + 1")).Error
+}
+
+func (r *mysqlUserRepo) CountActiveAdmins(exceptID int) (int64, error) {
+	var n int64
+	// FOR UPDATE: 2 admin hạ quyền nhau cùng lúc không thể cùng thấy "còn admin khác"
+	err := r.db.Model(&models.User{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("role = ? AND status NOT IN ? AND id <> ?", models.RoleAdmin, []string{"inactive", "banned"}, exceptID).
+		Count(&n).Error
+	return n, err
+}
+
+func (r *mysqlUserRepo) MarkEmailVerified(id int, email string, at time.Time) error {
+	res := r.db.Model(&models.User{}).Where("id = ? AND email = ?", id, email).
+		Updates(map[string]any{"email_verified_at": at, "version": gorm.Expr("version + 1")})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mysqlUserRepo) ListDeleted(f UserFilter, offset, limit int) ([]models.User, int64, error) {
+	var (
+		users []models.User
+		total int64
+	)
+	q := applyUserFilter(r.db.Unscoped().Model(&models.User{}).Where("deleted_at IS NOT NULL"), f)
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := q.Order("deleted_at DESC").Offset(offset).Limit(limit).Find(&users).Error
+	return users, total, err
+}
+
+func (r *mysqlUserRepo) GetDeleted(id int) (models.User, error) {
 	var u models.User
-	if err := r.db.First(&u, id).Error; err != nil {
+	if err := r.db.Unscoped().Where("deleted_at IS NOT NULL").First(&u, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return models.User{}, ErrNotFound
 		}
 		return models.User{}, err
 	}
-	u.Username = in.Username
-	u.Email = in.Email
-	u.FullName = in.FullName
-	u.Phone = in.Phone
-	u.Gender = in.Gender
-	u.DateOfBirth = in.DateOfBirth
-	u.AvatarURL = in.AvatarURL
-	u.Street = in.Street
-	u.City = in.City
-	u.State = in.State
-	u.Country = in.Country
-	u.PostalCode = in.PostalCode
-	u.Role = in.Role
-	u.Status = in.Status
-	if in.PasswordHash != "" {
-		u.PasswordHash = in.PasswordHash
+	return u, nil
+}
+
+func (r *mysqlUserRepo) Restore(id int) (models.User, error) {
+	res := r.db.Unscoped().Model(&models.User{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Updates(map[string]any{"deleted_at": nil, "version": gorm.Expr("version + 1")})
+	if res.Error != nil {
+		return models.User{}, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return models.User{}, ErrNotFound
 	}
-	return u, r.db.Save(&u).Error
+	return r.Get(id)
 }
 
-func (r *mysqlUserRepo) Delete(id int) (bool, error) {
-	res := r.db.Delete(&models.User{}, id)
-	return res.RowsAffected > 0, res.Error
+func (r *mysqlUserRepo) Purge(id int) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Unscoped().Where("deleted_at IS NOT NULL").Delete(&models.User{}, id)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		// refresh_tokens có FK ON DELETE CASCADE; các bảng log thì xoá tay
+		if err := tx.Where("user_id = ?", id).Delete(&models.LoginEvent{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", id).Delete(&models.SecurityEvent{}).Error
+	})
 }
 
 // Auto-migrate + seed (giữ nguyên nếu bạn đã có)
@@ -0,0 +1,295 @@
+package repository
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	mysqldrv "github.com/go-sql-driver/mysql"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"crud_api_us/internal/models"
+)
+
+// newTestDB: SQLite trong bộ nhớ, riêng cho từng test, đã migrate như MySQL
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	db, err := gorm.Open(sqlite.Open("file:"+name+"?mode=memory&cache=shared&_foreign_keys=1"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := MigrateAndSeed(db, nil); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// seedListUsers: 5 user, created_at trùng nhau theo cặp để thử tie-breaker theo id
+func seedListUsers(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, u := range []models.User{
+		{Username: "carol", Email: "carol@x.com", FullName: "Carol Tran", Role: "user", Gender: "female", Country: "VN"},
+		{Username: "alice", Email: "alice@x.com", FullName: "Alice Nguyen", Role: "admin", Gender: "female", Country: "US"},
+		{Username: "eve", Email: "eve@x.com", FullName: "Eve Le", Role: "user", Gender: "female", Country: "VN", Status: "banned"},
+		{Username: "bob", Email: "bob@x.com", FullName: "Bob Pham", Role: "user", Gender: "male", Country: "US", Phone: "0901"},
+		{Username: "dave", Email: "dave@x.com", FullName: "Dave Nguyen", Role: "user", Gender: "male", Country: "VN"},
+	} {
+		u.PasswordHash = "x"
+		if u.Status == "" {
+			u.Status = "active"
+		}
+		u.CreatedAt = base.Add(time.Duration(i/2) * 24 * time.Hour)
+		if err := db.Create(&u).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// listAll đi hết các trang bằng next_cursor, trả username theo thứ tự nhận được
+func listAll(t *testing.T, r UserRepository, q UserQuery) []string {
+	t.Helper()
+	var names []string
+	for i := 0; ; i++ {
+		if i > 10 {
+			t.Fatal("cursor does not terminate")
+		}
+		page, err := r.List(q)
+		if err != nil {
+			t.Fatalf("List(%+v): %v", q, err)
+		}
+		if page.Total != 5 {
+			t.Errorf("total = %d, want 5 on every page", page.Total)
+		}
+		for _, u := range page.Items {
+			names = append(names, u.Username)
+		}
+		if page.NextCursor == "" {
+			return names
+		}
+		q.Cursor = page.NextCursor
+	}
+}
+
+func TestListKeysetPagination(t *testing.T) {
+	db := newTestDB(t)
+	seedListUsers(t, db)
+	r := NewMySQLUserRepo(db)
+
+	tests := []struct {
+		sort string
+		desc bool
+		want string
+	}{
+		{"", false, "carol alice eve bob dave"},
+		{"id", true, "dave bob eve alice carol"},
+		{"username", false, "alice bob carol dave eve"},
+		{"username", true, "eve dave carol bob alice"},
+		{"full_name", false, "alice bob carol dave eve"},
+		// created_at trùng theo cặp => thứ tự trong cặp theo id, cùng chiều
+		{"created_at", false, "carol alice eve bob dave"},
+		{"created_at", true, "dave bob eve alice carol"},
+	}
+	for _, tt := range tests {
+		for _, limit := range []int{1, 2, 5} {
+			got := strings.Join(listAll(t, r, UserQuery{Limit: limit, Sort: tt.sort, Desc: tt.desc}), " ")
+			if got != tt.want {
+				t.Errorf("sort=%q desc=%v limit=%d: got %q, want %q", tt.sort, tt.desc, limit, got, tt.want)
+			}
+		}
+	}
+
+	// page/limit (offset) cho cùng kết quả
+	page, err := r.List(UserQuery{Page: 2, Limit: 2, Sort: "username"})
+	if err != nil || len(page.Items) != 2 || page.Items[0].Username != "carol" || page.NextCursor == "" {
+		t.Errorf("page 2 = %+v, %v", page, err)
+	}
+}
+
+func rawCursor(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+func TestListRejectsBadSortAndCursor(t *testing.T) {
+	db := newTestDB(t)
+	seedListUsers(t, db)
+	r := NewMySQLUserRepo(db)
+
+	first, err := r.List(UserQuery{Limit: 2, Sort: "username"})
+	if err != nil || first.NextCursor == "" {
+		t.Fatalf("List: %+v, %v", first, err)
+	}
+	for name, q := range map[string]UserQuery{
+		"column not whitelisted": {Limit: 2, Sort: "password_hash"},
+		"injection in sort":      {Limit: 2, Sort: "id; DROP TABLE users"},
+		"garbage cursor":         {Limit: 2, Sort: "username", Cursor: "not-base64!"},
+		"cursor of other sort":   {Limit: 2, Sort: "email", Cursor: first.NextCursor},
+		"cursor of other order":  {Limit: 2, Sort: "username", Desc: true, Cursor: first.NextCursor},
+		"bad time in cursor":     {Limit: 2, Sort: "created_at", Cursor: rawCursor(`{"s":"created_at","v":"yesterday","id":1}`)},
+	} {
+		if _, err := r.List(q); err != ErrInvalidQuery {
+			t.Errorf("%s: err = %v, want ErrInvalidQuery", name, err)
+		}
+	}
+
+	// cursor chỉ chứa sort, chiều, giá trị cột sort và id của dòng cuối
+	c, err := decodeUserCursor(first.NextCursor)
+	if err != nil || c != (userCursor{Sort: "username", Desc: false, Value: "bob", ID: 4}) {
+		t.Errorf("cursor = %+v, %v", c, err)
+	}
+}
+
+func TestListFilters(t *testing.T) {
+	db := newTestDB(t)
+	seedListUsers(t, db)
+	r := NewMySQLUserRepo(db)
+	day := func(d int) *time.Time {
+		v := time.Date(2024, 1, 1+d, 0, 0, 0, 0, time.UTC)
+		return &v
+	}
+
+	tests := []struct {
+		name string
+		q    UserQuery
+		want string
+	}{
+		{"role", UserQuery{Role: "admin"}, "alice"},
+		{"status", UserQuery{Status: "banned"}, "eve"},
+		{"gender and country", UserQuery{Gender: "male", Country: "VN"}, "dave"},
+		{"created range", UserQuery{CreatedFrom: day(1), CreatedTo: day(2)}, "bob eve"},
+		{"search full_name", UserQuery{Search: "nguyen"}, "alice dave"},
+		{"search phone", UserQuery{Search: "0901"}, "bob"},
+		{"search and filter", UserQuery{Search: "nguyen", Country: "VN"}, "dave"},
+		{"no match", UserQuery{Role: "support"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.q.Limit, tt.q.Sort = 20, "username"
+			page, err := r.List(tt.q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, u := range page.Items {
+				names = append(names, u.Username)
+			}
+			if got := strings.Join(names, " "); got != tt.want || page.Total != int64(len(names)) || page.NextCursor != "" {
+				t.Errorf("got %q (total %d, cursor %q), want %q", got, page.Total, page.NextCursor, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsFulltextUnsupported(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&mysqldrv.MySQLError{Number: 1191, Message: "Can't find FULLTEXT index matching the column list"}, true},
+		{&mysqldrv.MySQLError{Number: 1214, Message: "The used table type doesn't support FULLTEXT indexes"}, true},
+		{fmt.Errorf("search: %w", &mysqldrv.MySQLError{Number: 1191}), true},
+		{&mysqldrv.MySQLError{Number: 1064, Message: "syntax error"}, false},
+		{errors.New("Can't find FULLTEXT index"), false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := isFulltextUnsupported(tt.err); got != tt.want {
+			t.Errorf("isFulltextUnsupported(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+// SQLite không có FULLTEXT => Search dùng LIKE (OR giữa các từ), vẫn giữ bộ lọc và bỏ user đã xoá
+func TestSearchFallsBackToLike(t *testing.T) {
+	db := newTestDB(t)
+	seedListUsers(t, db)
+	if err := db.Create(&models.User{Username: "nguyen_gone", Email: "gone@x.com", PasswordHash: "x", Status: "active"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Where("username = ?", "nguyen_gone").Delete(&models.User{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	r := NewMySQLUserRepo(db)
+
+	tests := []struct {
+		name  string
+		terms []string
+		f     UserFilter
+		limit int
+		want  string
+	}{
+		{"full_name", []string{"nguyen"}, UserFilter{}, 20, "alice dave"},
+		{"any term matches", []string{"nguyen", "pham"}, UserFilter{}, 20, "alice bob dave"},
+		{"email", []string{"carol@x"}, UserFilter{}, 20, "carol"},
+		{"country", []string{"vn"}, UserFilter{}, 20, "carol eve dave"},
+		{"filter applies to every term", []string{"nguyen", "pham"}, UserFilter{Country: "US"}, 20, "alice bob"},
+		{"filter status", []string{"vn"}, UserFilter{Status: "banned"}, 20, "eve"},
+		{"limit", []string{"nguyen", "pham"}, UserFilter{}, 2, "alice bob"},
+		{"no terms", nil, UserFilter{}, 20, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits, err := r.Search(tt.terms, tt.f, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, h := range hits {
+				if h.Score != 0 {
+					t.Errorf("%s: LIKE fallback score = %v, want 0", h.Username, h.Score)
+				}
+				names = append(names, h.Username)
+			}
+			if got := strings.Join(names, " "); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// version được kiểm tra ngay trong câu UPDATE/DELETE: người thứ 2 dùng cùng version thua
+func TestUserVersionCheck(t *testing.T) {
+	db := newTestDB(t)
+	seedListUsers(t, db)
+	r := NewMySQLUserRepo(db)
+
+	u, err := r.UpdateColumns(1, map[string]any{"full_name": "first"}, 1)
+	if err != nil || u.Version != 2 || u.FullName != "first" {
+		t.Fatalf("first writer: %+v, %v", u, err)
+	}
+	if _, err := r.UpdateColumns(1, map[string]any{"full_name": "second"}, 1); err != ErrVersionMismatch {
+		t.Errorf("second writer: %v, want ErrVersionMismatch", err)
+	}
+	if _, err := r.Update(1, &models.User{Username: "carol", Email: "carol@x.com"}, 1); err != ErrVersionMismatch {
+		t.Errorf("Update with stale version: %v, want ErrVersionMismatch", err)
+	}
+	if _, err := r.UpdateColumns(99, map[string]any{"full_name": "x"}, 1); err != ErrNotFound {
+		t.Errorf("missing user: %v, want ErrNotFound", err)
+	}
+	// version = 0: ghi đè không kiểm tra, vẫn tăng version
+	if u, err := r.UpdateColumns(1, map[string]any{"full_name": "forced"}, 0); err != nil || u.Version != 3 {
+		t.Errorf("unconditional update: v%d, %v", u.Version, err)
+	}
+
+	if ok, err := r.Delete(1, 2); ok || err != ErrVersionMismatch {
+		t.Errorf("Delete stale = %v, %v; want false, ErrVersionMismatch", ok, err)
+	}
+	if ok, err := r.Delete(99, 1); ok || err != nil {
+		t.Errorf("Delete missing = %v, %v; want false, nil", ok, err)
+	}
+	if ok, err := r.Delete(1, 3); !ok || err != nil {
+		t.Errorf("Delete current = %v, %v", ok, err)
+	}
+	var got models.User
+	db.Unscoped().First(&got, 1)
+	if got.FullName != "forced" || got.Version != 3 {
+		t.Errorf("stale writes leaked: %q v%d", got.FullName, got.Version)
+	}
+}
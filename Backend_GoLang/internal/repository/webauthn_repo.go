@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"time"
+
+	"crud_api_us/internal/models"
+)
+
+type WebAuthnRepository interface {
+	ListCredentials(userID int) ([]models.WebAuthnCredential, error)
+	SaveCredential(c *models.WebAuthnCredential) error
+	// UseCredential cập nhật bộ đếm chữ ký sau 1 lần đăng nhập. Điều kiện sign_count = prevCount
+	// => 2 request dùng đồng thời cùng 1 chữ ký thì chỉ 1 request thắng (ErrNotFound cho request thua).
+	UseCredential(id int, prevCount, signCount uint32, backupState bool, at time.Time) error
+	// DeleteCredential xoá passkey id của userID (ErrNotFound nếu không có)
+	DeleteCredential(userID, id int) error
+
+	SaveSession(s *models.WebAuthnSession) error
+	// TakeSession lấy và xoá session (dùng 1 lần); ErrNotFound nếu không có hoặc đã hết hạn
+	TakeSession(sessionHash string, now time.Time) (models.WebAuthnSession, error)
+	// PruneSessions xoá tối đa limit session hết hạn trước before
+	PruneSessions(before time.Time, limit int) (int64, error)
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"crud_api_us/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type mysqlWebAuthnRepo struct{ db *gorm.DB }
+
+func NewMySQLWebAuthnRepo(db *gorm.DB) WebAuthnRepository { return &mysqlWebAuthnRepo{db: db} }
+
+func (r *mysqlWebAuthnRepo) ListCredentials(userID int) ([]models.WebAuthnCredential, error) {
+	var items []models.WebAuthnCredential
+	err := r.db.Where("user_id = ?", userID).Order("id").Find(&items).Error
+	return items, err
+}
+
+func (r *mysqlWebAuthnRepo) SaveCredential(c *models.WebAuthnCredential) error {
+	return r.db.Create(c).Error
+}
+
+func (r *mysqlWebAuthnRepo) UseCredential(id int, prevCount, signCount uint32, backupState bool, at time.Time) error {
+	res := r.db.Model(&models.WebAuthnCredential{}).
+		Where("id = ? AND sign_count = ?", id, prevCount).
+		Updates(map[string]any{"sign_count": signCount, "backup_state": backupState, "last_used_at": at})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mysqlWebAuthnRepo) DeleteCredential(userID, id int) error {
+	res := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&models.WebAuthnCredential{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mysqlWebAuthnRepo) SaveSession(s *models.WebAuthnSession) error {
+	return r.db.Create(s).Error
+}
+
+func (r *mysqlWebAuthnRepo) TakeSession(sessionHash string, now time.Time) (models.WebAuthnSession, error) {
+	var s models.WebAuthnSession
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("session_hash = ?", sessionHash).First(&s).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNotFound
+			}
+			return err
+		}
+		// xoá theo id: 2 request dùng cùng session thì chỉ 1 request xoá được
+		res := tx.Delete(&models.WebAuthnSession{}, s.ID)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 || !now.Before(s.ExpiresAt) {
+			return ErrNotFound
+		}
+		return nil
+	})
+	return s, err
+}
+
+func (r *mysqlWebAuthnRepo) PruneSessions(before time.Time, limit int) (int64, error) {
+	return pruneBatch(r.db, &models.WebAuthnSession{}, limit, "expires_at < ?", before)
+}
@@ -1,6 +1,7 @@
 package router
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/url"
@@ -12,10 +13,16 @@ import (
 
 	"crud_api_us/internal/database"
 	"crud_api_us/internal/handlers"
+	"crud_api_us/internal/janitor"
+	"crud_api_us/internal/keyset"
+	"crud_api_us/internal/mailer"
 	"crud_api_us/internal/middleware"
 	"crud_api_us/internal/models"
+	"crud_api_us/internal/policy"
+	"crud_api_us/internal/ratelimit"
 	"crud_api_us/internal/repository"
 	"crud_api_us/internal/services"
+	"crud_api_us/internal/tokens"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -45,8 +52,24 @@ func parseCORSOrigins() (exact []string, suffixes []string) {
 	return
 }
 
-func New() *gin.Engine {
-	r := gin.Default()
+// parseTrustedProxies: TRUSTED_PROXIES="10.0.0.0/8,127.0.0.1" (IP hoặc CIDR của reverse proxy / load balancer).
+// Chỉ request đi qua các proxy này mới được lấy IP client từ X-Forwarded-For / X-Real-IP;
+// bỏ trống => không tin proxy nào, IP client = địa chỉ kết nối (client không tự khai IP để lách giới hạn).
+func parseTrustedProxies() []string {
+	var out []string
+	for _, s := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// New dựng router; jobs là các tác vụ dọn dẹp nền, main gọi jobs.Start/Stop theo vòng đời server.
+// stopKeys dừng việc tự rotate khoá JWT (JWT_KEY_ROTATE_INTERVAL), gọi khi tắt server.
+func New() (r *gin.Engine, jobs *janitor.Runner, stopKeys func()) {
+	r = gin.Default()
+	r.Use(middleware.RequestID())
 
 	// ===== CORS =====
 	exact, suffixes := parseCORSOrigins()
@@ -55,8 +78,8 @@ func New() *gin.Engine {
 	cfg := cors.Config{
 		AllowMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		// chấp nhận cả dạng viết hoa/thường của header
-		AllowHeaders:     []string{"Authorization", "authorization", "Content-Type", "content-type", "Accept", "X-Requested-With"},
-		ExposeHeaders:    []string{"Content-Length", "Set-Cookie"},
+		AllowHeaders:     []string{"Authorization", "authorization", "Content-Type", "content-type", "Accept", "X-Requested-With", "If-Match", "X-Request-ID"},
+		ExposeHeaders:    []string{"Content-Length", "Set-Cookie", "ETag", "X-Request-ID", "Retry-After", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy"},
 		AllowCredentials: true,           // nếu dùng cookie/refresh token
 		MaxAge:           12 * time.Hour, // cache preflight
 	}
@@ -100,6 +123,16 @@ func New() *gin.Engine {
 	r.OPTIONS("/*path", func(c *gin.Context) { c.Status(204) })
 	// ===== End CORS =====
 
+	// ===== Rate limit: policy theo nhóm route (RATE_LIMITS / RATE_LIMIT_FILE) =====
+	rlCfg, err := ratelimit.LoadConfigFromEnv()
+	if err != nil {
+		panic("rate limit config: " + err.Error())
+	}
+	// IP client (rate limit, chống dò mật khẩu, audit) chỉ lấy từ header của proxy tin cậy
+	if err := r.SetTrustedProxies(parseTrustedProxies()); err != nil {
+		panic("TRUSTED_PROXIES: " + err.Error())
+	}
+
 	// Healthcheck
 	r.GET("/healthz", func(c *gin.Context) { c.JSON(200, gin.H{"ok": true}) })
 
@@ -112,7 +145,7 @@ func New() *gin.Engine {
 	if err != nil {
 		panic("cannot connect MySQL: " + err.Error())
 	}
-	if err := db.AutoMigrate(&models.User{}, &models.RefreshToken{}); err != nil {
+	if err := repository.MigrateAndSeed(db, nil); err != nil {
 		panic("migrate failed: " + err.Error())
 	}
 
@@ -130,69 +163,255 @@ func New() *gin.Engine {
 		adminPass = "Admin@123"
 	}
 
-	// ---- ensure admin: an toàn & idempotent ----
-	var admin models.User
-	// tìm theo username OR email (tránh tạo trùng)
-	if err := db.Where("username = ? OR email = ?", "admin", adminEmail).First(&admin).Error; err != nil &&
-		!errors.Is(err, gorm.ErrRecordNotFound) {
+	// ---- ensure admin: chỉ khi chưa có admin active nào (DB mới) ----
+	// Không ép role/status của tài khoản đã có: admin đã tự hạ quyền/bị khoá giữ nguyên
+	// (UserService đảm bảo luôn còn ít nhất 1 admin active). Mất hết admin (sửa tay DB):
+	// ADMIN_RECOVER=1 khôi phục tài khoản "admin"/ADMIN_EMAIL thành admin active.
+	var activeAdmins int64
+	if err := db.Model(&models.User{}).
+		Where("role = ? AND status NOT IN ?", models.RoleAdmin, []string{"inactive", "banned"}).
+		Count(&activeAdmins).Error; err != nil {
 		panic("query admin failed: " + err.Error())
 	}
-
-	if admin.ID == 0 {
-		// chưa có -> tạo mới
-		admin = models.User{
-			Username:     "admin",
-			Email:        adminEmail,
-			FullName:     "Administrator",
-			Role:         "admin",
-			Status:       "active",
-			PasswordHash: hash(adminPass),
+	if activeAdmins == 0 {
+		var admin models.User
+		// tìm theo username OR email (tránh tạo trùng)
+		if err := db.Where("username = ? OR email = ?", "admin", adminEmail).First(&admin).Error; err != nil &&
+			!errors.Is(err, gorm.ErrRecordNotFound) {
+			panic("query admin failed: " + err.Error())
 		}
-		if err := db.Create(&admin).Error; err != nil {
-			// nếu race condition/duplicate thì bỏ qua
-			if !strings.Contains(err.Error(), "Duplicate entry") {
-				panic("create admin failed: " + err.Error())
+
+		switch {
+		case admin.ID == 0:
+			// chưa có -> tạo mới
+			admin = models.User{
+				Username:     "admin",
+				Email:        adminEmail,
+				FullName:     "Administrator",
+				Role:         models.RoleAdmin,
+				Status:       "active",
+				PasswordHash: hash(adminPass),
+			}
+			now := time.Now()
+			admin.EmailVerifiedAt = &now
+			if err := db.Create(&admin).Error; err != nil {
+				// nếu race condition/duplicate thì bỏ qua
+				if !strings.Contains(err.Error(), "Duplicate entry") {
+					panic("create admin failed: " + err.Error())
+				}
 			}
+		case os.Getenv("ADMIN_RECOVER") == "1":
+			updates := map[string]any{"role": models.RoleAdmin, "status": "active", "version": gorm.Expr("version + 1")}
+			if err := db.Model(&admin).Updates(updates).Error; err != nil {
+				panic("recover admin failed: " + err.Error())
+			}
+			fmt.Println("[ADMIN] no active admin: restored", admin.Username, "as active admin (ADMIN_RECOVER=1)")
+		default:
+			fmt.Println("[ADMIN] WARNING: no active admin; set ADMIN_RECOVER=1 to restore", admin.Username)
 		}
-	} else {
-		// đã có -> đảm bảo vai trò & email
-		updates := map[string]any{}
-		if admin.Role != "admin" {
-			updates["role"] = "admin"
+	}
+	// ---- end ensure admin ----
+
+	repos := repository.NewMySQLRepos(db)
+	jwtCfg := services.LoadJWTConfigFromEnv()
+
+	// khoá ký JWT (JWT_ALG); khoá cũ sau khi rotate còn verify tới khi refresh token cuối cùng hết hạn
+	keyCfg := keyset.LoadConfigFromEnv()
+	if keyCfg.Retain <= 0 {
+		keyCfg.Retain = jwtCfg.RefreshTTL
+	}
+	keys, err := keyset.New(keyCfg)
+	if err != nil {
+		panic("jwt keys: " + err.Error())
+	}
+	stopKeys = func() {}
+	if keyCfg.RotateInterval > 0 {
+		stopKeys = keys.StartRotation(keyCfg.RotateInterval)
+	}
+	// access/refresh token: typ + iss/aud (JWT_ISSUER, JWT_AUDIENCE), lệch giờ JWT_LEEWAY
+	tm := tokens.NewManager(keys, tokens.LoadConfigFromEnv())
+
+	mail, err := mailer.New(mailer.LoadConfigFromEnv())
+	if err != nil {
+		panic("mailer config: " + err.Error())
+	}
+
+	// ABAC cho API quản lý user (POLICY_FILE, vd ./policies.json); không khai báo => chỉ RBAC
+	var userPolicy *services.UserPolicy
+	if path := os.Getenv("POLICY_FILE"); path != "" {
+		engine, err := policy.LoadFile(path)
+		if err != nil {
+			panic("policy: " + err.Error())
 		}
-		if admin.Email == "" {
-			updates["email"] = adminEmail
+		userPolicy = services.NewUserPolicy(engine, repos.Users)
+	}
+	u := handlers.NewUserHandler(repos, userPolicy)
+	passkeys, err := services.NewPasskeyService(repos, services.LoadWebAuthnConfigFromEnv())
+	if err != nil {
+		panic("webauthn config: " + err.Error())
+	}
+
+	// chống dò mật khẩu: bộ đếm trong DB để nhiều instance dùng chung (LOGIN_GUARD_STORE=memory cho dev)
+	var guard *services.LoginGuard
+	if bf := services.LoadBruteForceConfigFromEnv(); bf.Enabled {
+		store := repository.NewMySQLAttemptStore(db)
+		if bf.Store == "memory" {
+			store = repository.NewMemoryAttemptStore()
 		}
-		if len(updates) > 0 {
-			_ = db.Model(&admin).Updates(updates).Error
+		guard = services.NewLoginGuard(store, bf)
+	}
+
+	// bucket trong DB để nhiều instance dùng chung hạn mức (RATE_LIMIT_STORE=memory cho dev)
+	var limiter *ratelimit.Limiter
+	if rlCfg.Enabled {
+		store := repository.NewMySQLRateLimitStore(db)
+		if rlCfg.Store == "memory" {
+			store = ratelimit.NewMemoryStore()
 		}
+		limiter = ratelimit.NewLimiter(store, rlCfg.Policies)
 	}
-	// ---- end ensure admin ----
+	authRL := middleware.RateLimit(limiter, "auth")
+	accountRL := middleware.RateLimit(limiter, "account")
 
-	userRepo := repository.NewMySQLUserRepo(db)
-	authRepo := repository.NewMySQLAuthRepo(db)
-	jwtCfg := services.LoadJWTConfigFromEnv()
+	mfaCfg := services.LoadMFAConfigFromEnv()
+	a := handlers.NewAuthHandler(repos, u, jwtCfg, tm, services.LoadAccountConfigFromEnv(), mfaCfg, passkeys, guard, mail)
+	au := handlers.NewAuditHandler(repos)
+
+	// dọn dẹp nền: refresh token hết hạn, lịch sử đăng nhập cũ, bộ đếm login/rate limit
+	jobs = newJanitor(janitor.LoadConfigFromEnv(), repos, guard, limiter)
+	jh := handlers.NewJanitorHandler(jobs)
 
-	u := handlers.NewUserHandler(userRepo)
-	a := handlers.NewAuthHandler(userRepo, authRepo, jwtCfg)
-	authMW := middleware.WithAuth(jwtCfg.Secret)
+	// chặn token của user đã bị khoá/xoá sau khi token được cấp (AUTH_USER_STATE_CHECK=0 để tắt)
+	var stateChecker middleware.UserStateChecker
+	if jwtCfg.CheckUserState {
+		stateChecker = services.NewUserStateChecker(repos.Users, jwtCfg.UserStateTTL)
+	}
+	authMW := middleware.WithAuth(tm, stateChecker)
+	// quyền theo role (RBAC_CACHE_TTL): can(quyền...) chặn role thiếu quyền
+	rbac := services.NewRBACService(repos, services.LoadRBACConfigFromEnv())
+	ro := handlers.NewRoleHandler(rbac)
+	can := func(perms ...string) gin.HandlerFunc { return middleware.RequirePermission(rbac, perms...) }
+	// role trong MFA_REQUIRED_ROLES phải đăng nhập có 2FA mới dùng được các API dưới
+	mfaMW := middleware.RequireMFA(mfaCfg.RequiredRoles...)
+	// API quản lý 2FA: chưa bật 2FA vẫn vào được để đăng ký, đã bật thì cần phiên đã qua 2FA
+	mfaManageMW := middleware.RequireMFAOrEnrollment(services.NewMFAService(repos, mfaCfg), mfaCfg.RequiredRoles...)
+
+	r.GET("/.well-known/jwks.json", a.JWKS)
 
 	v1 := r.Group("/api/v1")
 	{
-		v1.POST("/auth/register", a.Register)
-		v1.POST("/auth/login", a.Login)
-		v1.POST("/auth/logout", a.Logout)
-		v1.GET("/auth/me", authMW, a.Me)
+		v1.POST("/auth/register", middleware.RateLimit(limiter, "register"), a.Register)
+		v1.POST("/auth/login", authRL, a.Login)
+		v1.POST("/auth/login/mfa", authRL, a.LoginMFA)
+		v1.POST("/auth/refresh", authRL, a.Refresh)
+		v1.POST("/auth/logout", authRL, a.Logout)
+		v1.POST("/auth/password/forgot", authRL, a.ForgotPassword)
+		v1.POST("/auth/password/reset", authRL, a.ResetPassword)
+		v1.GET("/auth/verify-email", authRL, a.VerifyEmail)
+		v1.POST("/auth/verify-email/resend", authRL, a.ResendVerification)
+		v1.GET("/auth/me", authMW, accountRL, a.Me)
+		v1.PATCH("/auth/me", authMW, accountRL, mfaMW, a.UpdateMe)
+		v1.POST("/auth/me/password", authMW, accountRL, mfaMW, a.ChangePassword)
+		v1.GET("/auth/me/logins", authMW, accountRL, mfaMW, a.MyLogins)
+		v1.GET("/auth/sessions", authMW, accountRL, mfaMW, a.ListSessions)
+		v1.POST("/auth/sessions/revoke-others", authMW, accountRL, mfaMW, a.RevokeOtherSessions)
+		v1.DELETE("/auth/sessions/:id", authMW, accountRL, mfaMW, a.RevokeSession)
+		mfaFactorRoutes(v1, a, authMW, accountRL, mfaManageMW)
+		v1.POST("/auth/webauthn/login/begin", authRL, a.BeginPasskeyLogin)
+		v1.POST("/auth/webauthn/login/finish", authRL, a.FinishPasskeyLogin)
+		v1.GET("/auth/webauthn/credentials", authMW, accountRL, a.ListPasskeys)
+		v1.DELETE("/auth/webauthn/credentials/:id", authMW, accountRL, mfaMW, a.DeletePasskey)
 
-		admin := v1.Group("/admin", authMW, middleware.RequireRoles("admin"))
+		// hạn mức admin tính theo uid (sau authMW); từng API yêu cầu quyền riêng (bảng role_permissions)
+		admin := v1.Group("/admin", authMW, middleware.RateLimit(limiter, "admin"), mfaMW)
 		{
-			admin.GET("/users", u.ListUsers)
-			admin.GET("/users/:id", u.GetUser)
-			admin.POST("/users", u.CreateUser)
-			admin.PUT("/users/:id", u.UpdateUser)
-			admin.DELETE("/users/:id", u.DeleteUser)
+			admin.GET("/users", can(models.PermUsersRead), u.ListUsers)
+			admin.GET("/users/search", can(models.PermUsersRead), u.SearchUsers)
+			admin.GET("/users/deleted", can(models.PermUsersRead), u.ListDeletedUsers)
+			admin.GET("/users/:id", can(models.PermUsersRead), u.GetUser)
+			admin.POST("/users", can(models.PermUsersWrite), u.CreateUser)
+			admin.PUT("/users/:id", can(models.PermUsersWrite), u.UpdateUser)
+			admin.PATCH("/users/:id", can(models.PermUsersWrite), u.PatchUser)
+			admin.DELETE("/users/:id", can(models.PermUsersDelete), u.DeleteUser)
+			admin.GET("/users/:id/logins", can(models.PermUsersRead), a.UserLogins)
+			admin.POST("/users/:id/unlock", can(models.PermUsersWrite), a.UnlockUser)
+			admin.GET("/users/:id/sessions", can(models.PermUsersRead), a.UserSessions)
+			admin.DELETE("/users/:id/sessions", can(models.PermUsersWrite), a.RevokeUserSessions)
+			admin.DELETE("/users/:id/sessions/:sid", can(models.PermUsersWrite), a.RevokeUserSession)
+			admin.POST("/users/:id/restore", can(models.PermUsersWrite), u.RestoreUser)
+			admin.DELETE("/users/:id/purge", can(models.PermUsersDelete), u.PurgeUser)
+			admin.GET("/audit", can(models.PermAuditRead), au.ListAudit)
+			admin.GET("/roles", can(models.PermRolesRead), ro.ListRoles)
+			admin.GET("/roles/:name", can(models.PermRolesRead), ro.GetRole)
+			admin.GET("/permissions", can(models.PermRolesRead), ro.ListPermissions)
+			admin.POST("/roles", can(models.PermRolesWrite), ro.CreateRole)
+			admin.PUT("/roles/:name", can(models.PermRolesWrite), ro.UpdateRole)
+			admin.DELETE("/roles/:name", can(models.PermRolesWrite), ro.DeleteRole)
+			admin.GET("/jobs", can(models.PermSystemRead), jh.ListJobs)
 		}
 	}
 
-	return r
+	return r, jobs, stopKeys
+}
+
+// mfaFactorHandlers: các API xem / thêm yếu tố 2FA (TOTP, passkey)
+type mfaFactorHandlers interface {
+	MFAStatus(c *gin.Context)
+	SetupTOTP(c *gin.Context)
+	VerifyTOTP(c *gin.Context)
+	BeginPasskeyRegistration(c *gin.Context)
+	FinishPasskeyRegistration(c *gin.Context)
+}
+
+// mfaFactorRoutes đăng ký các API thêm yếu tố 2FA sau mw (auth, rate limit, RequireMFAOrEnrollment):
+// đã bật 2FA thì phải dùng phiên đã qua 2FA, tránh kẻ chỉ có mật khẩu tự gắn thêm TOTP / passkey.
+func mfaFactorRoutes(v1 *gin.RouterGroup, h mfaFactorHandlers, mw ...gin.HandlerFunc) {
+	g := v1.Group("/auth", mw...)
+	g.GET("/mfa", h.MFAStatus)
+	g.POST("/mfa/totp/setup", h.SetupTOTP)
+	g.POST("/mfa/totp/verify", h.VerifyTOTP)
+	g.POST("/webauthn/register/begin", h.BeginPasskeyRegistration)
+	g.POST("/webauthn/register/finish", h.FinishPasskeyRegistration)
+}
+
+// newJanitor đăng ký các job dọn dẹp định kỳ (JANITOR=0 => không chạy job nào)
+func newJanitor(cfg janitor.Config, repos repository.Repos, guard *services.LoginGuard, limiter *ratelimit.Limiter) *janitor.Runner {
+	jobs := janitor.New()
+	if !cfg.Enabled {
+		return jobs
+	}
+	jobs.Add(janitor.Job{Name: "refresh_tokens", Every: cfg.Interval, Run: func(ctx context.Context, now time.Time) (int64, error) {
+		return janitor.Batched(ctx, cfg.BatchSize, func(limit int) (int64, error) {
+			return repos.Auth.PruneRefreshTokens(now.Add(-cfg.RefreshTokenGrace), limit)
+		})
+	}})
+	// mfa_token / phiên WebAuthn hết hạn không còn dùng được => xoá ngay
+	jobs.Add(janitor.Job{Name: "mfa_challenges", Every: cfg.Interval, Run: func(ctx context.Context, now time.Time) (int64, error) {
+		return janitor.Batched(ctx, cfg.BatchSize, func(limit int) (int64, error) {
+			return repos.MFA.PruneChallenges(now, limit)
+		})
+	}})
+	jobs.Add(janitor.Job{Name: "webauthn_sessions", Every: cfg.Interval, Run: func(ctx context.Context, now time.Time) (int64, error) {
+		return janitor.Batched(ctx, cfg.BatchSize, func(limit int) (int64, error) {
+			return repos.WebAuthn.PruneSessions(now, limit)
+		})
+	}})
+	if cfg.LoginEventRetention > 0 {
+		jobs.Add(janitor.Job{Name: "login_events", Every: cfg.Interval, Run: func(ctx context.Context, now time.Time) (int64, error) {
+			return janitor.Batched(ctx, cfg.BatchSize, func(limit int) (int64, error) {
+				return repos.Auth.PruneLoginEvents(now.Add(-cfg.LoginEventRetention), limit)
+			})
+		}})
+	}
+	if guard != nil {
+		jobs.Add(janitor.Job{Name: "login_attempts", Every: cfg.Interval, Run: func(_ context.Context, now time.Time) (int64, error) {
+			return guard.Prune(now)
+		}})
+	}
+	if limiter != nil {
+		jobs.Add(janitor.Job{Name: "rate_limit_buckets", Every: cfg.Interval, Run: func(_ context.Context, now time.Time) (int64, error) {
+			return limiter.Prune(now)
+		}})
+	}
+	return jobs
 }
@@ -0,0 +1,113 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"crud_api_us/internal/middleware"
+	"crud_api_us/internal/ratelimit"
+)
+
+func init() { gin.SetMode(gin.TestMode) }
+
+// X-Forwarded-For chỉ được tin khi kết nối đến từ TRUSTED_PROXIES
+func TestTrustedProxies(t *testing.T) {
+	tests := []struct {
+		name    string
+		trusted string
+		want    []int // status của 2 request cùng kết nối, khác X-Forwarded-For
+	}{
+		{"no proxy trusted: spoofed header ignored", "", []int{200, 429}},
+		{"other proxy trusted: header ignored", "10.0.0.0/8", []int{200, 429}},
+		{"peer is trusted proxy: header is the client", "192.0.2.1, 10.0.0.0/8", []int{200, 200}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TRUSTED_PROXIES", tt.trusted)
+			r := gin.New()
+			if err := r.SetTrustedProxies(parseTrustedProxies()); err != nil {
+				t.Fatal(err)
+			}
+			limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), map[string]ratelimit.Policy{
+				"auth": {Limit: 1, Window: time.Minute},
+			})
+			var ips []string
+			r.POST("/login", middleware.RateLimit(limiter, "auth"), func(c *gin.Context) {
+				ips = append(ips, c.ClientIP())
+				c.Status(200)
+			})
+			for i, xff := range []string{"203.0.113.1", "203.0.113.2"} {
+				req := httptest.NewRequest("POST", "/login", nil) // RemoteAddr 192.0.2.1:1234
+				req.Header.Set("X-Forwarded-For", xff)
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+				if w.Code != tt.want[i] {
+					t.Fatalf("request %d (XFF %s): status %d, want %d", i+1, xff, w.Code, tt.want[i])
+				}
+			}
+			if tt.want[1] == http.StatusTooManyRequests && ips[0] != "192.0.2.1" {
+				t.Errorf("ClientIP = %s, want connection address", ips[0])
+			}
+		})
+	}
+	t.Setenv("TRUSTED_PROXIES", "not-an-ip")
+	if err := gin.New().SetTrustedProxies(parseTrustedProxies()); err == nil {
+		t.Error("invalid TRUSTED_PROXIES accepted")
+	}
+}
+
+type stubFactorHandlers struct{}
+
+func (stubFactorHandlers) MFAStatus(c *gin.Context)                 { c.Status(200) }
+func (stubFactorHandlers) SetupTOTP(c *gin.Context)                 { c.Status(200) }
+func (stubFactorHandlers) VerifyTOTP(c *gin.Context)                { c.Status(200) }
+func (stubFactorHandlers) BeginPasskeyRegistration(c *gin.Context)  { c.Status(200) }
+func (stubFactorHandlers) FinishPasskeyRegistration(c *gin.Context) { c.Status(200) }
+
+type enrolledUsers map[int]bool
+
+func (e enrolledUsers) Enabled(uid int) (bool, error) { return e[uid], nil }
+
+// đã bật 2FA mà phiên chưa qua 2FA (chỉ có mật khẩu) => không được gắn thêm TOTP / passkey
+func TestMFAFactorRoutesRequireMFA(t *testing.T) {
+	r := gin.New()
+	fakeAuth := func(c *gin.Context) {
+		c.Set("uid", map[string]int{"enrolled": 1, "new": 2}[c.GetHeader("X-User")])
+		c.Set("role", "admin")
+		c.Set("mfa", c.GetHeader("X-MFA") == "1")
+	}
+	mfaFactorRoutes(r.Group("/api/v1"), stubFactorHandlers{}, fakeAuth,
+		middleware.RequireMFAOrEnrollment(enrolledUsers{1: true}, "admin"))
+
+	routes := []struct{ method, path string }{
+		{"GET", "/api/v1/auth/mfa"},
+		{"POST", "/api/v1/auth/mfa/totp/setup"},
+		{"POST", "/api/v1/auth/mfa/totp/verify"},
+		{"POST", "/api/v1/auth/webauthn/register/begin"},
+		{"POST", "/api/v1/auth/webauthn/register/finish"},
+	}
+	sessions := []struct {
+		user, mfa string
+		want      int
+	}{
+		{"enrolled", "0", http.StatusForbidden},
+		{"enrolled", "1", http.StatusOK},
+		{"new", "0", http.StatusOK}, // chưa bật 2FA: được đăng ký yếu tố đầu tiên
+	}
+	for _, rt := range routes {
+		for _, s := range sessions {
+			req := httptest.NewRequest(rt.method, rt.path, nil)
+			req.Header.Set("X-User", s.user)
+			req.Header.Set("X-MFA", s.mfa)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != s.want {
+				t.Errorf("%s %s as %s (mfa=%s): status %d, want %d", rt.method, rt.path, s.user, s.mfa, w.Code, s.want)
+			}
+		}
+	}
+}
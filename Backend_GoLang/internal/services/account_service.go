@@ -0,0 +1,291 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"crud_api_us/internal/mailer"
+	"crud_api_us/internal/models"
+	"crud_api_us/internal/repository"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// AccountConfig: cấu hình các luồng tài khoản gửi qua email
+type AccountConfig struct {
+	ResetTTL time.Duration // thời hạn token quên mật khẩu
+	ResetURL string        // trang đặt lại mật khẩu của FE, token được gắn vào ?token=
+
+	VerifySecret         string        // khoá ký link xác thực email (mặc định suy ra từ JWT_SECRET)
+	VerifyTTL            time.Duration // thời hạn link xác thực
+	VerifyURL            string        // endpoint GET /auth/verify-email, token được gắn vào ?token=
+	VerifyRedirectURL    string        // có giá trị => sau khi xác thực chuyển hướng về FE (?status=ok|invalid)
+	VerifyResendInterval time.Duration // khoảng cách tối thiểu giữa 2 lần gửi link cho cùng 1 email
+}
+
+var ErrThrottled = errors.New("throttled") // gửi lại link quá nhanh
+
+func LoadAccountConfigFromEnv() AccountConfig {
+	return AccountConfig{
+		ResetTTL:             parseDurationEnv("PASSWORD_RESET_TTL", 30*time.Minute),
+		ResetURL:             getEnv("PASSWORD_RESET_URL", "http://localhost:5173/reset-password"),
+		VerifySecret:         getEnv("EMAIL_VERIFY_SECRET", getEnv("JWT_SECRET", "change-me")),
+		VerifyTTL:            parseDurationEnv("EMAIL_VERIFY_TTL", 72*time.Hour),
+		VerifyURL:            getEnv("EMAIL_VERIFY_URL", "http://localhost:8080/api/v1/auth/verify-email"),
+		VerifyRedirectURL:    os.Getenv("EMAIL_VERIFY_REDIRECT_URL"),
+		VerifyResendInterval: parseDurationEnv("EMAIL_VERIFY_RESEND_INTERVAL", time.Minute),
+	}
+}
+
+// parseDurationEnv: giá trị sai/không dương => dùng def
+func parseDurationEnv(k string, def time.Duration) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(k))
+	if err != nil || d <= 0 {
+		return def
+	}
+	return d
+}
+
+// AccountService: quên/đặt lại mật khẩu, xác thực email
+type AccountService struct {
+	repos repository.Repos
+	mail  mailer.Mailer
+	cfg   AccountConfig
+
+	mu       sync.Mutex
+	lastSent map[string]time.Time // email (lowercase) -> lần gửi link xác thực gần nhất
+}
+
+func NewAccountService(r repository.Repos, m mailer.Mailer, cfg AccountConfig) *AccountService {
+	return &AccountService{repos: r, mail: m, cfg: cfg, lastSent: map[string]time.Time{}}
+}
+
+// ForgotPassword tạo token reset và gửi link qua email.
+// Email không tồn tại / tài khoản bị khoá => không làm gì nhưng vẫn trả nil (chống dò email).
+func (s *AccountService) ForgotPassword(email string, meta ClientMeta) error {
+	email = strings.TrimSpace(email)
+	u, err := s.repos.Auth.FindByUsernameOrEmail(email)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	if !strings.EqualFold(u.Email, email) || checkStatus(u) != nil {
+		return nil
+	}
+
+	token, hash, err := newSecretToken()
+	if err != nil {
+		return err
+	}
+	if err := s.repos.Auth.SavePasswordReset(&models.PasswordResetToken{
+		UserID: u.ID, TokenHash: hash, ExpiresAt: time.Now().Add(s.cfg.ResetTTL), IP: meta.IP,
+	}); err != nil {
+		return err
+	}
+
+	msg, err := mailer.Render(mailer.TemplatePasswordReset, meta.Lang, mailer.Data{
+		Name: u.Username, Link: withToken(s.cfg.ResetURL, token), Expires: mailer.FormatDuration(s.cfg.ResetTTL, meta.Lang),
+	})
+	if err != nil {
+		return err
+	}
+	s.sendAsync(u, msg)
+	return nil
+}
+
+// ResetPassword dùng token (1 lần) để đặt mật khẩu mới và thu hồi mọi phiên đăng nhập của user.
+func (s *AccountService) ResetPassword(actor Actor, token, newPassword string) error {
+	hash, err := hashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	return s.repos.InTx(func(tx repository.Repos) error {
+		uid, err := tx.Auth.UsePasswordReset(hashToken(token), time.Now())
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return ErrInvalidToken
+			}
+			return err
+		}
+		before, err := tx.Users.Get(uid)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return ErrInvalidToken
+			}
+			return err
+		}
+		actor.UserID = uid // người giữ token chính là chủ tài khoản
+		if err := updatePassword(tx, actor, before, hash); err != nil {
+			return err
+		}
+		return revokeSessions(tx, uid)
+	})
+}
+
+// verifyClaims: nội dung link xác thực email. Ký bằng khoá riêng (không phải khoá access token)
+// nên không thể dùng làm bearer token; gắn với email => đổi email thì link cũ mất hiệu lực.
+type verifyClaims struct {
+	Email string `json:"email"`
+	jwt.RegisteredClaims
+}
+
+const verifyAudience = "email_verify"
+
+func (s *AccountService) verifyKey() []byte {
+	sum := sha256.Sum256([]byte(s.cfg.VerifySecret + "|" + verifyAudience))
+	return sum[:]
+}
+
+func (s *AccountService) VerifyRedirectURL() string { return s.cfg.VerifyRedirectURL }
+
+// SendVerification gửi link xác thực email cho user (gửi nền, lỗi chỉ ghi log)
+func (s *AccountService) SendVerification(u models.User, lang string) error {
+	now := time.Now()
+	tok, err := jwt.NewWithClaims(jwt.SigningMethodHS256, verifyClaims{
+		Email: u.Email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.Itoa(u.ID),
+			Audience:  jwt.ClaimStrings{verifyAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.cfg.VerifyTTL)),
+		},
+	}).SignedString(s.verifyKey())
+	if err != nil {
+		return err
+	}
+	s.markSent(u.Email, now)
+
+	msg, err := mailer.Render(mailer.TemplateEmailVerify, lang, mailer.Data{
+		Name: u.Username, Link: withToken(s.cfg.VerifyURL, tok), Expires: mailer.FormatDuration(s.cfg.VerifyTTL, lang),
+	})
+	if err != nil {
+		return err
+	}
+	s.sendAsync(u, msg)
+	return nil
+}
+
+// VerifyEmail kiểm tra link xác thực và đánh dấu email đã xác thực (gọi lại nhiều lần vẫn ok)
+func (s *AccountService) VerifyEmail(token string) (models.User, error) {
+	var claims verifyClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (any, error) {
+		return s.verifyKey(), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithAudience(verifyAudience))
+	if err != nil {
+		return models.User{}, ErrInvalidToken
+	}
+	uid, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return models.User{}, ErrInvalidToken
+	}
+	u, err := s.repos.Users.Get(uid)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return models.User{}, ErrInvalidToken
+		}
+		return models.User{}, err
+	}
+	if !strings.EqualFold(u.Email, claims.Email) {
+		return models.User{}, ErrInvalidToken
+	}
+	if u.EmailVerifiedAt != nil {
+		return u, nil
+	}
+	if err := s.repos.Users.MarkEmailVerified(uid, u.Email, time.Now()); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return models.User{}, ErrInvalidToken
+		}
+		return models.User{}, err
+	}
+	return s.repos.Users.Get(uid)
+}
+
+// ResendVerification gửi lại link xác thực. Throttle theo email (kể cả email không tồn tại,
+// để không lộ email nào có tài khoản): gọi quá nhanh => ErrThrottled + thời gian phải chờ.
+func (s *AccountService) ResendVerification(email, lang string) (time.Duration, error) {
+	email = strings.TrimSpace(email)
+	if wait := s.throttleWait(email, time.Now()); wait > 0 {
+		return wait, ErrThrottled
+	}
+	s.markSent(email, time.Now())
+
+	u, err := s.repos.Auth.FindByUsernameOrEmail(email)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	if !strings.EqualFold(u.Email, email) || u.EmailVerifiedAt != nil || checkStatus(u) != nil {
+		return 0, nil
+	}
+	return 0, s.SendVerification(u, lang)
+}
+
+func (s *AccountService) throttleWait(email string, now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	last, ok := s.lastSent[strings.ToLower(email)]
+	if !ok {
+		return 0
+	}
+	return max(0, last.Add(s.cfg.VerifyResendInterval).Sub(now))
+}
+
+func (s *AccountService) markSent(email string, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// dọn các mục đã hết hạn để map không phình mãi
+	for k, t := range s.lastSent {
+		if now.Sub(t) > s.cfg.VerifyResendInterval {
+			delete(s.lastSent, k)
+		}
+	}
+	s.lastSent[strings.ToLower(email)] = now
+}
+
+// sendAsync gửi nền: thời gian phản hồi không phụ thuộc việc gửi mail (và việc email có tồn tại)
+func (s *AccountService) sendAsync(u models.User, msg mailer.Message) {
+	msg.To = u.Email
+	go func() {
+		if err := s.mail.Send(msg); err != nil {
+			log.Printf("[account] send %q to user %d: %v", msg.Subject, u.ID, err)
+		}
+	}()
+}
+
+// newSecretToken: token ngẫu nhiên gửi cho user + hash SHA-256 để lưu DB
+func newSecretToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, hashToken(token), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// withToken gắn token vào query string của link
+func withToken(link, token string) string {
+	sep := "?"
+	if strings.Contains(link, "?") {
+		sep = "&"
+	}
+	return link + sep + "token=" + url.QueryEscape(token)
+}
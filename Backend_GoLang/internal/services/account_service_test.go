@@ -0,0 +1,129 @@
+package services
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"crud_api_us/internal/mailer"
+	"crud_api_us/internal/models"
+)
+
+// waitMail: sendAsync gửi nền => chờ tới khi outbox có thư cho to
+func waitMail(t *testing.T, mm *mailer.MemoryMailer, to string) mailer.Message {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if m, ok := mm.Last(to); ok {
+			return m
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("no mail sent to %s", to)
+	return mailer.Message{}
+}
+
+// tokenFromMail lấy ?token= từ link đầu tiên có prefix base trong thư
+func tokenFromMail(t *testing.T, m mailer.Message, base string) string {
+	t.Helper()
+	i := strings.Index(m.Text, base)
+	if i < 0 {
+		t.Fatalf("mail has no link to %s:\n%s", base, m.Text)
+	}
+	link := strings.Fields(m.Text[i:])[0]
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok := u.Query().Get("token")
+	if tok == "" {
+		t.Fatalf("link %q has no token", link)
+	}
+	return tok
+}
+
+func TestForgotPasswordSendsResetMail(t *testing.T) {
+	alice := models.User{ID: 1, Username: "alice", Email: "alice@example.com", Status: "active"}
+	banned := models.User{ID: 2, Username: "bob", Email: "bob@example.com", Status: "banned"}
+	repos := newFakeRepos(alice, banned)
+	mm := mailer.NewMemoryMailer()
+	s := NewAccountService(repos, mm, AccountConfig{ResetTTL: 30 * time.Minute, ResetURL: "https://app.example.com/reset"})
+
+	if err := s.ForgotPassword(" Alice@Example.com ", ClientMeta{IP: "10.0.0.1", Lang: "en"}); err != nil {
+		t.Fatalf("ForgotPassword: %v", err)
+	}
+	m := waitMail(t, mm, alice.Email)
+	if !strings.Contains(m.Subject, "Reset your password") || !strings.Contains(m.Text, "30 minutes") {
+		t.Errorf("unexpected mail: %q\n%s", m.Subject, m.Text)
+	}
+	tok := tokenFromMail(t, m, "https://app.example.com/reset?token=")
+
+	// chỉ lưu hash của token, kèm hạn dùng và IP yêu cầu
+	resets := repos.Auth.(*fakeAuth).resets
+	if len(resets) != 1 {
+		t.Fatalf("saved %d reset tokens, want 1", len(resets))
+	}
+	r := resets[0]
+	if r.UserID != alice.ID || r.TokenHash != hashToken(tok) || r.TokenHash == tok || r.IP != "10.0.0.1" {
+		t.Errorf("saved token = %+v", r)
+	}
+	if d := time.Until(r.ExpiresAt); d <= 29*time.Minute || d > 30*time.Minute {
+		t.Errorf("expires in %v, want ~30m", d)
+	}
+
+	// email lạ / tài khoản bị khoá: vẫn nil, không gửi gì
+	for _, email := range []string{"nobody@example.com", banned.Email, "alice"} {
+		if err := s.ForgotPassword(email, ClientMeta{}); err != nil {
+			t.Errorf("ForgotPassword(%s): %v", email, err)
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := len(mm.Messages()); n != 1 {
+		t.Errorf("outbox has %d messages, want 1", n)
+	}
+}
+
+func TestSendVerificationRoundTrip(t *testing.T) {
+	alice := models.User{ID: 7, Username: "alice", Email: "alice@example.com", Status: "active"}
+	repos := newFakeRepos(alice)
+	mm := mailer.NewMemoryMailer()
+	cfg := AccountConfig{VerifySecret: "s3cret", VerifyTTL: 72 * time.Hour, VerifyURL: "https://api.example.com/verify"}
+	s := NewAccountService(repos, mm, cfg)
+
+	if err := s.SendVerification(alice, "vi"); err != nil {
+		t.Fatalf("SendVerification: %v", err)
+	}
+	m := waitMail(t, mm, alice.Email)
+	if !strings.Contains(m.Subject, "Xác thực") || !strings.Contains(m.Text, "3 ngày") {
+		t.Errorf("unexpected mail: %q\n%s", m.Subject, m.Text)
+	}
+	tok := tokenFromMail(t, m, "https://api.example.com/verify?token=")
+
+	// khoá khác / token sửa => không hợp lệ
+	other := NewAccountService(repos, mm, AccountConfig{VerifySecret: "other"})
+	if _, err := other.VerifyEmail(tok); err != ErrInvalidToken {
+		t.Errorf("VerifyEmail with other key: %v, want ErrInvalidToken", err)
+	}
+	if _, err := s.VerifyEmail(tok + "x"); err != ErrInvalidToken {
+		t.Errorf("VerifyEmail tampered: %v, want ErrInvalidToken", err)
+	}
+
+	u, err := s.VerifyEmail(tok)
+	if err != nil || u.EmailVerifiedAt == nil {
+		t.Fatalf("VerifyEmail = %+v, %v", u, err)
+	}
+	// gọi lại vẫn ok
+	if _, err := s.VerifyEmail(tok); err != nil {
+		t.Errorf("second VerifyEmail: %v", err)
+	}
+
+	// đổi email => link cũ mất hiệu lực
+	fu := repos.Users.(*fakeUsers)
+	changed := fu.users[alice.ID]
+	changed.Email, changed.EmailVerifiedAt = "alice@new.example.com", nil
+	fu.users[alice.ID] = changed
+	if _, err := s.VerifyEmail(tok); err != ErrInvalidToken {
+		t.Errorf("VerifyEmail after email change: %v, want ErrInvalidToken", err)
+	}
+}
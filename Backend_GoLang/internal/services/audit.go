@@ -0,0 +1,117 @@
+package services
+
+import (
+	"encoding/json"
+	"time"
+
+	"crud_api_us/internal/models"
+	"crud_api_us/internal/repository"
+)
+
+// Actor: ai đang thực hiện thay đổi (lấy từ request), dùng để ghi audit
+type Actor struct {
+	UserID    int // 0 = chưa đăng nhập (vd tự đăng ký)
+	IP        string
+	RequestID string
+}
+
+// AuditChange: giá trị trước/sau của 1 field
+type AuditChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// redacted: chỉ ghi nhận mật khẩu có đổi, không bao giờ lưu hash
+const redacted = "[redacted]"
+
+// userDiff so sánh 2 trạng thái user theo từng field (key = tên JSON).
+// before/after nil = user chưa tồn tại / đã bị xoá.
+func userDiff(before, after *models.User) map[string]AuditChange {
+	var b, a map[string]any
+	if before != nil {
+		b = auditFields(*before)
+	}
+	if after != nil {
+		a = auditFields(*after)
+	}
+	out := map[string]AuditChange{}
+	for _, k := range auditFieldNames {
+		// "" và nil coi như nhau => create/delete chỉ ghi các field có giá trị
+		if b[k] != a[k] && !(isEmpty(b[k]) && isEmpty(a[k])) {
+			out[k] = AuditChange{Before: b[k], After: a[k]}
+		}
+	}
+	if before != nil && after != nil && before.PasswordHash != after.PasswordHash {
+		out["password"] = AuditChange{Before: redacted, After: redacted}
+	}
+	return out
+}
+
+func isEmpty(v any) bool { return v == nil || v == "" }
+
+var auditFieldNames = []string{
+	"username", "email", "full_name", "phone", "gender", "date_of_birth", "avatar_url",
+	"street", "city", "state", "country", "postal_code", "role", "status",
+}
+
+// auditFields: các field được ghi audit (không có PasswordHash, token version, ...)
+func auditFields(u models.User) map[string]any {
+	m := map[string]any{
+		"username": u.Username, "email": u.Email, "full_name": u.FullName, "phone": u.Phone,
+		"gender": u.Gender, "avatar_url": u.AvatarURL, "street": u.Street, "city": u.City,
+		"state": u.State, "country": u.Country, "postal_code": u.PostalCode,
+		"role": u.Role, "status": u.Status, "date_of_birth": nil,
+	}
+	if u.DateOfBirth != nil {
+		m["date_of_birth"] = u.DateOfBirth.Format("2006-01-02")
+	}
+	return m
+}
+
+// writeAudit ghi 1 sự kiện audit lên user targetID bằng audit repo của transaction hiện tại
+func writeAudit(r repository.AuditRepository, actor Actor, action string, targetID int, changes map[string]AuditChange) error {
+	return saveAudit(r, actor, action, models.AuditTargetUser, targetID, changes)
+}
+
+// writeRoleAudit như writeAudit, target là role roleID
+func writeRoleAudit(r repository.AuditRepository, actor Actor, action string, roleID int, changes map[string]AuditChange) error {
+	return saveAudit(r, actor, action, models.AuditTargetRole, roleID, changes)
+}
+
+func saveAudit(r repository.AuditRepository, actor Actor, action, targetType string, targetID int, changes map[string]AuditChange) error {
+	e := models.AuditEvent{
+		Action: action, TargetType: targetType, TargetID: targetID,
+		IP: actor.IP, RequestID: truncate(actor.RequestID, 64),
+	}
+	if actor.UserID > 0 {
+		uid := actor.UserID
+		e.ActorID = &uid
+	}
+	if len(changes) > 0 {
+		b, err := json.Marshal(changes)
+		if err != nil {
+			return err
+		}
+		e.Changes = b
+	}
+	return r.Save(&e)
+}
+
+type AuditService struct{ repo repository.AuditRepository }
+
+func NewAuditService(r repository.AuditRepository) *AuditService { return &AuditService{repo: r} }
+
+// AuditFilter: bộ lọc GET /admin/audit
+type AuditFilter struct {
+	ActorID, TargetID *int
+	TargetType        string
+	Action            string
+	From, To          *time.Time
+}
+
+func (s *AuditService) List(f AuditFilter, page, limit int) ([]models.AuditEvent, int64, error) {
+	return s.repo.List(repository.AuditQuery{
+		ActorID: f.ActorID, TargetID: f.TargetID, TargetType: f.TargetType, Action: f.Action, From: f.From, To: f.To,
+		Offset: (page - 1) * limit, Limit: limit,
+	})
+}
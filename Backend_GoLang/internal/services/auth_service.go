@@ -2,25 +2,34 @@ package services
 
 import (
 	"errors"
+	"fmt"
+	"log"
 	"os"
-	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"crud_api_us/internal/models"
 	"crud_api_us/internal/repository"
+	"crud_api_us/internal/tokens"
 
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type JWTConfig struct {
-	Secret     string
+	Secret     string // khoá HS256 (JWT_ALG=HS256)
 	AccessTTL  time.Duration
 	RefreshTTL time.Duration
 	CookieName string
+
+	// Kiểm tra trạng thái user (active/xoá/token version) ở mỗi request có access token
+	CheckUserState bool
+	UserStateTTL   time.Duration // 0 = luôn đọc DB, >0 = cache trong RAM
+
+	// Từ chối đăng nhập khi email chưa xác thực
+	RequireVerifiedEmail bool
 }
 
 func LoadJWTConfigFromEnv() JWTConfig {
@@ -32,7 +41,26 @@ func LoadJWTConfigFromEnv() JWTConfig {
 	access, _ := time.ParseDuration(getEnv("ACCESS_TOKEN_TTL", "15m"))
 	refresh, _ := time.ParseDuration(getEnv("REFRESH_TOKEN_TTL", "168h"))
 	cname := getEnv("REFRESH_COOKIE_NAME", "refresh_token")
-	return JWTConfig{Secret: secret, AccessTTL: access, RefreshTTL: refresh, CookieName: cname}
+	stateTTL, _ := time.ParseDuration(getEnv("AUTH_USER_STATE_CACHE_TTL", "0s"))
+	return JWTConfig{
+		Secret: secret, AccessTTL: access, RefreshTTL: refresh, CookieName: cname,
+		CheckUserState: getEnv("AUTH_USER_STATE_CHECK", "1") != "0",
+		UserStateTTL:   stateTTL,
+
+		RequireVerifiedEmail: getEnv("AUTH_REQUIRE_EMAIL_VERIFIED", "0") == "1",
+	}
+}
+
+// truncate cắt chuỗi tối đa n byte, không cắt giữa ký tự UTF-8
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	s = s[:n]
+	for !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s
 }
 
 func getEnv(k, def string) string {
@@ -42,14 +70,32 @@ func getEnv(k, def string) string {
 	return def
 }
 
+var (
+	ErrInvalidCredentials = errors.New("invalid_credentials")
+	ErrInvalidToken       = errors.New("invalid_token") // sai chữ ký / hết hạn / đã thu hồi
+	ErrTokenReused        = errors.New("token_reused")  // refresh token đã rotate bị dùng lại
+	ErrTokenRevoked       = errors.New("token_revoked") // token version cũ (user bị khoá/xoá sau khi cấp)
+	ErrEmailNotVerified   = errors.New("email_not_verified")
+	ErrAccountInactive    = errors.New("account_inactive")
+	ErrAccountBanned      = errors.New("account_banned")
+)
+
 type AuthService struct {
-	users repository.UserRepository
-	auth  repository.AuthRepository
-	jwt   JWTConfig
+	repos    repository.Repos // kiểm tra quyền actor khi thao tác quản trị (guardTarget)
+	users    repository.UserRepository
+	auth     repository.AuthRepository
+	audit    repository.AuditRepository
+	mfa      *MFAService     // nil = không hỗ trợ 2FA
+	passkeys *PasskeyService // nil = không hỗ trợ passkey
+	guard    *LoginGuard     // nil = không giới hạn số lần đăng nhập sai
+	tokens   *tokens.Manager // ký/xác thực access & refresh token
+	jwt      JWTConfig
 }
 
-func NewAuthService(users repository.UserRepository, auth repository.AuthRepository, cfg JWTConfig) *AuthService {
-	return &AuthService{users: users, auth: auth, jwt: cfg}
+func NewAuthService(r repository.Repos, tm *tokens.Manager, mfa *MFAService, passkeys *PasskeyService, guard *LoginGuard, cfg JWTConfig) *AuthService {
+	return &AuthService{
+		repos: r, users: r.Users, auth: r.Auth, audit: r.Audit, mfa: mfa, passkeys: passkeys, guard: guard, tokens: tm, jwt: cfg,
+	}
 }
 
 // ---------- helpers ----------
@@ -60,34 +106,71 @@ func (s *AuthService) hashPassword(pw string) (string, error) {
 	b, err := bcrypt.GenerateFromPassword([]byte(pw), bcrypt.DefaultCost)
 	return string(b), err
 }
+
+// dummyPasswordHash: hash bcrypt cố định (cùng cost với hashPassword) để so khi không tìm thấy user =>
+// thời gian phản hồi như khi sai mật khẩu, không dò được username/email nào tồn tại
+const dummyPasswordHash = "$2a$10$LE.Ue8th.avLAw1A9L3hCujMKDNCP3KRcLFt8AJWOlQNr0o/EC8KS"
+
 func (s *AuthService) checkPassword(hash, pw string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(pw))
 }
 
-type Claims struct {
-	UserID   int    `json:"uid"`
-	Username string `json:"username"`
-	Role     string `json:"role"`
-	jwt.RegisteredClaims
+// checkStatus: chỉ tài khoản active mới được đăng nhập / refresh
+func checkStatus(u models.User) error {
+	switch u.Status {
+	case "inactive":
+		return ErrAccountInactive
+	case "banned":
+		return ErrAccountBanned
+	}
+	return nil
 }
 
-func (s *AuthService) makeToken(user models.User, ttl time.Duration, jti string) (string, time.Time, error) {
-	now := time.Now()
-	exp := now.Add(ttl)
-	claims := &Claims{
+func (s *AuthService) makeToken(user models.User, typ string, ttl time.Duration, jti string, mfa bool) (string, time.Time, error) {
+	return s.tokens.Issue(tokens.Claims{
 		UserID:   user.ID,
 		Username: user.Username,
 		Role:     user.Role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			IssuedAt:  jwt.NewNumericDate(now),
-			ExpiresAt: jwt.NewNumericDate(exp),
-			Subject:   strconv.Itoa(user.ID),
-			ID:        jti,
-		},
+		Version:  user.TokenVersion,
+		MFA:      mfa,
+	}, typ, jti, ttl)
+}
+
+// parseRefresh xác thực refresh token (chữ ký, iss/aud, hạn dùng, typ=refresh)
+func (s *AuthService) parseRefresh(tokenStr string) (*tokens.Claims, error) {
+	claims, err := s.tokens.Parse(tokenStr, tokens.TypeRefresh)
+	if err != nil {
+		return nil, ErrInvalidToken
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signed, err := token.SignedString([]byte(s.jwt.Secret))
-	return signed, exp, err
+	return claims, nil
+}
+
+// issueTokens tạo cặp access/refresh mới cho user (refresh chưa được lưu DB).
+// mfa: phiên đã qua bước 2FA (claim mfa, giữ nguyên khi refresh). meta: thiết bị đang dùng phiên.
+func (s *AuthService) issueTokens(user models.User, mfa bool, meta ClientMeta) (LoginResult, *models.RefreshToken, error) {
+	access, accessExp, err := s.makeToken(user, tokens.TypeAccess, s.jwt.AccessTTL, uuid.NewString(), mfa)
+	if err != nil {
+		return LoginResult{}, nil, err
+	}
+	refreshJTI := uuid.NewString()
+	refresh, refreshExp, err := s.makeToken(user, tokens.TypeRefresh, s.jwt.RefreshTTL, refreshJTI, mfa)
+	if err != nil {
+		return LoginResult{}, nil, err
+	}
+	row := &models.RefreshToken{
+		TokenID:   refreshJTI,
+		UserID:    user.ID,
+		FamilyID:  refreshJTI, // token gốc của family; Refresh sẽ ghi đè khi rotate
+		ExpiresAt: refreshExp,
+		IP:        truncate(meta.IP, 45),
+		UserAgent: truncate(meta.UserAgent, 255),
+		Name:      truncate(deviceName(meta.UserAgent), 100),
+		StartedAt: time.Now(),
+	}
+	return LoginResult{
+		AccessToken: access, AccessExp: accessExp,
+		Refresh: refresh, RefreshExp: refreshExp, User: user,
+	}, row, nil
 }
 
 // ---------- API ----------
@@ -97,46 +180,400 @@ type LoginResult struct {
 	Refresh     string
 	RefreshExp  time.Time
 	User        models.User
+
+	// MFAToken != "": cần bước 2 (POST /auth/login/mfa), chưa cấp access/refresh
+	MFAToken string
+	MFAExp   time.Time
+	// role bắt buộc 2FA nhưng user chưa bật => phải đăng ký TOTP mới dùng được các API cần 2FA
+	MFAEnrollRequired bool
+
+	// lỗi ErrThrottled: thời gian phải chờ trước khi thử lại
+	RetryAfter time.Duration
 }
 
-func (s *AuthService) Login(identifier, password string) (LoginResult, error) {
+// ClientMeta: thông tin client của request (ghi lịch sử đăng nhập)
+type ClientMeta struct {
+	IP        string
+	UserAgent string
+	Lang      string // ngôn ngữ email gửi cho client (từ Accept-Language), "" = mặc định
+}
+
+func (s *AuthService) Login(identifier, password string, meta ClientMeta) (LoginResult, error) {
+	ev := &models.LoginEvent{Identifier: identifier, IP: meta.IP, UserAgent: truncate(meta.UserAgent, 255)}
+	// tìm user trước để đếm lần sai theo tài khoản (không phụ thuộc nhập username hay email)
 	user, err := s.auth.FindByUsernameOrEmail(identifier)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		s.recordLogin(ev, err)
+		return LoginResult{}, err
+	}
+	// đang bị chặn => trả lỗi ngay, không tốn 1 lần so bcrypt
+	att, wait := s.reserveAttempt(user.ID, identifier, meta.IP)
+	if wait > 0 {
+		s.recordLogin(ev, ErrThrottled)
+		return LoginResult{RetryAfter: wait}, ErrThrottled
+	}
+	var res LoginResult
+	if err == nil {
+		res, err = s.login(user, password, ev)
+	} else {
+		_ = s.checkPassword(dummyPasswordHash, password)
+	}
+	// còn chờ mã 2FA => chưa xoá bộ đếm đăng nhập sai (LoginMFA xoá khi mã đúng)
+	s.settleAttempt(att, err, res.MFAToken != "")
+	if err == nil && res.MFAToken != "" {
+		ev.Reason = "mfa_required" // mật khẩu đúng, chờ mã 2FA
+	}
+	s.recordLogin(ev, err)
+	if err != nil || res.MFAToken != "" {
+		return res, err
+	}
+	now := ev.CreatedAt
+	_ = s.auth.TouchLastLogin(res.User.ID, now)
+	res.User.LastLoginAt = &now
+	return res, nil
+}
+
+// LoginPasskey: đăng nhập không mật khẩu bằng passkey (bước finish của WebAuthn).
+// Passkey yêu cầu user verification (vân tay/PIN) nên phiên được tính là đã qua 2FA.
+func (s *AuthService) LoginPasskey(sessionID string, body []byte, meta ClientMeta) (LoginResult, error) {
+	ev := &models.LoginEvent{Method: "passkey", IP: meta.IP, UserAgent: truncate(meta.UserAgent, 255)}
+	res, err := s.loginPasskey(sessionID, body, ev)
+	s.recordLogin(ev, err)
 	if err != nil {
 		return LoginResult{}, err
 	}
+	now := ev.CreatedAt
+	_ = s.auth.TouchLastLogin(res.User.ID, now)
+	res.User.LastLoginAt = &now
+	return res, nil
+}
+
+func (s *AuthService) loginPasskey(sessionID string, body []byte, ev *models.LoginEvent) (LoginResult, error) {
+	if s.passkeys == nil {
+		return LoginResult{}, ErrPasskeyFailed
+	}
+	user, err := s.passkeys.FinishLogin(sessionID, body)
+	if user.ID != 0 {
+		ev.UserID, ev.Identifier = &user.ID, user.Username
+	}
+	if err != nil {
+		return LoginResult{}, err
+	}
+	if err := checkStatus(user); err != nil {
+		return LoginResult{}, err
+	}
+	if s.jwt.RequireVerifiedEmail && user.EmailVerifiedAt == nil {
+		return LoginResult{}, ErrEmailNotVerified
+	}
+	return s.startSession(user, true, ClientMeta{IP: ev.IP, UserAgent: ev.UserAgent})
+}
+
+func (s *AuthService) login(user models.User, password string, ev *models.LoginEvent) (LoginResult, error) {
+	ev.UserID = &user.ID
 	if err := s.checkPassword(user.PasswordHash, password); err != nil {
-		return LoginResult{}, errors.New("invalid_credentials")
+		return LoginResult{}, ErrInvalidCredentials
+	}
+	if err := checkStatus(user); err != nil {
+		return LoginResult{}, err
 	}
-	// access
-	accessJTI := uuid.NewString()
-	access, accessExp, err := s.makeToken(user, s.jwt.AccessTTL, accessJTI)
+	if s.jwt.RequireVerifiedEmail && user.EmailVerifiedAt == nil {
+		return LoginResult{}, ErrEmailNotVerified
+	}
+	mfaOn := false
+	if s.mfa != nil {
+		var err error
+		if mfaOn, err = s.mfa.Enabled(user.ID); err != nil {
+			return LoginResult{}, err
+		}
+	}
+	if mfaOn {
+		tok, exp, err := s.mfa.NewChallenge(user)
+		if err != nil {
+			return LoginResult{}, err
+		}
+		return LoginResult{User: user, MFAToken: tok, MFAExp: exp}, nil
+	}
+	res, err := s.startSession(user, false, ClientMeta{IP: ev.IP, UserAgent: ev.UserAgent})
 	if err != nil {
 		return LoginResult{}, err
 	}
-	// refresh
-	refreshJTI := uuid.NewString()
-	refresh, refreshExp, err := s.makeToken(user, s.jwt.RefreshTTL, refreshJTI)
+	res.MFAEnrollRequired = s.mfa != nil && s.mfa.RequiredFor(user.Role)
+	return res, nil
+}
+
+// startSession cấp cặp token mới và lưu refresh JTI (kèm thông tin thiết bị)
+func (s *AuthService) startSession(user models.User, mfa bool, meta ClientMeta) (LoginResult, error) {
+	res, row, err := s.issueTokens(user, mfa, meta)
 	if err != nil {
 		return LoginResult{}, err
 	}
-	// persist refresh JTI
-	if err := s.auth.SaveRefreshToken(&models.RefreshToken{
-		TokenID:   refreshJTI,
-		UserID:    user.ID,
-		ExpiresAt: refreshExp,
+	if err := s.auth.SaveRefreshToken(row); err != nil {
+		return LoginResult{}, err
+	}
+	return res, nil
+}
+
+// ---------- 2FA ----------
+
+// LoginMFA: bước 2 của đăng nhập — kiểm tra mfa_token + mã 2FA (TOTP hoặc mã khôi phục) rồi cấp token.
+// Mỗi mfa_token dùng thành công được 1 lần và chỉ cho thử mfaChallengeMaxAttempts lần;
+// mã sai còn được tính vào bộ đếm đăng nhập sai của tài khoản (LoginGuard).
+func (s *AuthService) LoginMFA(mfaToken, code string, meta ClientMeta) (LoginResult, error) {
+	if s.mfa == nil || mfaToken == "" {
+		return LoginResult{}, ErrInvalidToken
+	}
+	ch, err := s.mfa.AttemptChallenge(mfaToken)
+	if err != nil {
+		return LoginResult{}, err
+	}
+	user, err := s.users.Get(ch.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return LoginResult{}, ErrInvalidToken
+		}
+		return LoginResult{}, err
+	}
+
+	ev := &models.LoginEvent{UserID: &user.ID, Identifier: user.Username, IP: meta.IP, UserAgent: truncate(meta.UserAgent, 255)}
+	att, wait := s.reserveAttempt(user.ID, user.Username, meta.IP)
+	if wait > 0 {
+		s.recordLogin(ev, ErrThrottled)
+		return LoginResult{RetryAfter: wait}, ErrThrottled
+	}
+	res, err := s.loginMFA(user, ch, code, meta)
+	s.settleAttempt(att, err, false)
+	s.recordLogin(ev, err)
+	if err != nil {
+		return LoginResult{}, err
+	}
+	now := ev.CreatedAt
+	_ = s.auth.TouchLastLogin(user.ID, now)
+	res.User.LastLoginAt = &now
+	return res, nil
+}
+
+func (s *AuthService) loginMFA(user models.User, ch models.MFAChallenge, code string, meta ClientMeta) (LoginResult, error) {
+	if err := checkStatus(user); err != nil {
+		return LoginResult{}, err
+	}
+	if user.TokenVersion != ch.TokenVersion {
+		return LoginResult{}, ErrInvalidToken
+	}
+	res, row, err := s.issueTokens(user, true, meta)
+	if err != nil {
+		return LoginResult{}, err
+	}
+	if err := s.mfa.CompleteChallenge(ch, code, func(tx repository.Repos) error {
+		return tx.Auth.SaveRefreshToken(row)
 	}); err != nil {
 		return LoginResult{}, err
 	}
+	return res, nil
+}
 
-	return LoginResult{
-		AccessToken: access, AccessExp: accessExp,
-		Refresh: refresh, RefreshExp: refreshExp, User: user,
-	}, nil
+// reserveAttempt giữ chỗ 1 lượt thử trong LoginGuard; wait > 0 = đang bị chặn.
+// Lỗi store => cho qua (không khoá nhầm mọi người).
+func (s *AuthService) reserveAttempt(userID int, identifier, ip string) (*Attempt, time.Duration) {
+	if s.guard == nil {
+		return nil, 0
+	}
+	att, wait, err := s.guard.Reserve(userID, identifier, ip, time.Now())
+	if err != nil {
+		log.Printf("[auth] login guard reserve: %v", err)
+		return nil, 0
+	}
+	return att, wait
+}
+
+// settleAttempt chốt lượt đã giữ chỗ sau khi kiểm tra mật khẩu/mã 2FA: sai => giữ nguyên (đã đếm);
+// đúng => xoá bộ đếm của tài khoản; còn lại (chờ 2FA, tài khoản bị khoá, lỗi server) => hoàn lượt
+func (s *AuthService) settleAttempt(att *Attempt, err error, pending bool) {
+	if att == nil {
+		return
+	}
+	var gerr error
+	switch {
+	case errors.Is(err, repository.ErrNotFound), errors.Is(err, ErrInvalidCredentials), errors.Is(err, ErrInvalidMFACode):
+		return
+	case err == nil && !pending:
+		gerr = s.guard.Succeed(att)
+	default:
+		gerr = s.guard.Release(att)
+	}
+	if gerr != nil {
+		log.Printf("[auth] login guard: %v", gerr)
+	}
+}
+
+// UnlockAccount (admin) xoá bộ đếm đăng nhập sai của user để đăng nhập lại ngay
+func (s *AuthService) UnlockAccount(actor Actor, userID int) error {
+	u, err := s.users.Get(userID)
+	if err != nil {
+		return err
+	}
+	if err := guardTarget(s.repos, actor, u); err != nil {
+		return err
+	}
+	if s.guard != nil {
+		if err := s.guard.Unlock(u); err != nil {
+			return err
+		}
+	}
+	return writeAudit(s.audit, actor, models.AuditUserUnlock, userID, nil)
+}
+
+// recordLogin ghi 1 dòng login_events; lỗi ghi log không làm hỏng việc đăng nhập
+func (s *AuthService) recordLogin(ev *models.LoginEvent, err error) {
+	ev.CreatedAt = time.Now()
+	switch {
+	case err == nil:
+		ev.Success = ev.Reason == ""
+	case errors.Is(err, repository.ErrNotFound):
+		ev.Reason = "user_not_found"
+	case errors.Is(err, ErrInvalidCredentials):
+		ev.Reason = "invalid_password"
+	case errors.Is(err, ErrAccountInactive), errors.Is(err, ErrAccountBanned), errors.Is(err, ErrEmailNotVerified),
+		errors.Is(err, ErrInvalidMFACode), errors.Is(err, ErrInvalidToken), errors.Is(err, ErrPasskeyFailed), errors.Is(err, ErrThrottled):
+		ev.Reason = err.Error()
+	default:
+		ev.Reason = "server_error"
+	}
+	_ = s.auth.SaveLoginEvent(ev)
+}
+
+// LoginHistory: lịch sử đăng nhập của 1 user, phân trang (page bắt đầu từ 1)
+func (s *AuthService) LoginHistory(userID, page, limit int) ([]models.LoginEvent, int64, error) {
+	return s.auth.ListLoginEvents(userID, (page-1)*limit, limit)
+}
+
+// SessionFamily: chuỗi rotate (phiên đăng nhập) của refresh token hiện tại nếu nó hợp lệ
+// và thuộc về userID; rỗng nếu không xác định được.
+func (s *AuthService) SessionFamily(userID int, refreshToken string) string {
+	claims, err := s.parseRefresh(refreshToken)
+	if err != nil || claims.UserID != userID {
+		return ""
+	}
+	stored, err := s.auth.FindRefreshTokenByJTI(claims.ID)
+	if err != nil || stored.UserID != userID || stored.Revoked {
+		return ""
+	}
+	return stored.FamilyID
+}
+
+// Refresh xác thực refresh token (chữ ký + DB: chưa revoke, chưa hết hạn),
+// thu hồi nó và cấp cặp access/refresh mới (rotation).
+func (s *AuthService) Refresh(refreshToken string, meta ClientMeta) (LoginResult, error) {
+	claims, err := s.parseRefresh(refreshToken)
+	if err != nil {
+		return LoginResult{}, err
+	}
+	stored, err := s.auth.FindRefreshTokenByJTI(claims.ID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return LoginResult{}, ErrInvalidToken
+		}
+		return LoginResult{}, err
+	}
+	if stored.UserID != claims.UserID {
+		return LoginResult{}, ErrInvalidToken
+	}
+	if stored.Revoked && stored.ReplacedBy != "" {
+		// token đã rotate mà vẫn được gửi lên => nhiều khả năng bị đánh cắp
+		return LoginResult{}, s.handleRefreshReuse(stored)
+	}
+	if stored.Revoked || time.Now().After(stored.ExpiresAt) {
+		return LoginResult{}, ErrInvalidToken
+	}
+
+	// lấy lại user để role/username trong access token luôn mới nhất
+	user, err := s.users.Get(stored.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return LoginResult{}, ErrInvalidToken
+		}
+		return LoginResult{}, err
+	}
+	if err := checkStatus(user); err != nil {
+		return LoginResult{}, err
+	}
+	if claims.Version != user.TokenVersion {
+		return LoginResult{}, ErrInvalidToken
+	}
+	return s.rotate(stored, user, claims.MFA, meta)
 }
 
-func (s *AuthService) Logout(refreshJTI string) error {
-	if strings.TrimSpace(refreshJTI) == "" {
+// rotate thu hồi refresh token stored và cấp cặp token mới trong cùng phiên đăng nhập
+func (s *AuthService) rotate(stored models.RefreshToken, user models.User, mfa bool, meta ClientMeta) (LoginResult, error) {
+	res, row, err := s.issueTokens(user, mfa, meta)
+	if err != nil {
+		return LoginResult{}, err
+	}
+	// cùng phiên đăng nhập: giữ tên thiết bị & thời điểm đăng nhập
+	if stored.Name != "" {
+		row.Name = stored.Name
+	}
+	if !stored.StartedAt.IsZero() {
+		row.StartedAt = stored.StartedAt
+	}
+	row.FamilyID = stored.FamilyID
+	if row.FamilyID == "" { // token cũ (trước khi có family)
+		row.FamilyID = stored.TokenID
+	}
+	row.ParentID = stored.TokenID
+	if err := s.auth.RotateRefreshToken(stored.TokenID, row); err != nil {
+		if errors.Is(err, repository.ErrTokenRevoked) {
+			return LoginResult{}, ErrInvalidToken
+		}
+		return LoginResult{}, err
+	}
+	return res, nil
+}
+
+// ReissueSession cấp lại token cho phiên hiện tại sau khi TokenVersion tăng (vd đổi mật khẩu):
+// refreshToken còn hiệu lực => rotate sang cặp token mới cùng phiên; không có/không hợp lệ
+// => chỉ cấp access token mới (Refresh rỗng). mfa: claim mfa của access token hiện tại.
+func (s *AuthService) ReissueSession(userID int, refreshToken string, mfa bool, meta ClientMeta) (LoginResult, error) {
+	user, err := s.users.Get(userID)
+	if err != nil {
+		return LoginResult{}, err
+	}
+	if err := checkStatus(user); err != nil {
+		return LoginResult{}, err
+	}
+	if claims, err := s.parseRefresh(refreshToken); err == nil && claims.UserID == userID {
+		stored, err := s.auth.FindRefreshTokenByJTI(claims.ID)
+		if err == nil && stored.UserID == userID && !stored.Revoked && time.Now().Before(stored.ExpiresAt) {
+			return s.rotate(stored, user, claims.MFA, meta)
+		}
+	}
+	access, accessExp, err := s.makeToken(user, tokens.TypeAccess, s.jwt.AccessTTL, uuid.NewString(), mfa)
+	if err != nil {
+		return LoginResult{}, err
+	}
+	return LoginResult{AccessToken: access, AccessExp: accessExp, User: user}, nil
+}
+
+// handleRefreshReuse thu hồi toàn bộ token hậu duệ của token bị dùng lại và ghi sự kiện bảo mật
+func (s *AuthService) handleRefreshReuse(stored models.RefreshToken) error {
+	n, err := s.auth.RevokeRefreshTokenDescendants(stored.TokenID)
+	if err != nil {
+		return err
+	}
+	if err := s.auth.SaveSecurityEvent(&models.SecurityEvent{
+		UserID: stored.UserID,
+		Type:   models.SecurityEventRefreshReuse,
+		Detail: fmt.Sprintf("jti=%s family=%s revoked=%d", stored.TokenID, stored.FamilyID, n),
+	}); err != nil {
+		return err
+	}
+	return ErrTokenReused
+}
+
+// Logout thu hồi refresh token; token sai/hết hạn thì bỏ qua
+func (s *AuthService) Logout(refreshToken string) error {
+	claims, err := s.parseRefresh(refreshToken)
+	if err != nil {
 		return nil
 	}
-	return s.auth.RevokeRefreshTokenByJTI(refreshJTI)
+	return s.auth.RevokeRefreshTokenByJTI(claims.ID)
 }
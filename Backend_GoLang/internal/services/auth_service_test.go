@@ -0,0 +1,269 @@
+package services
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"crud_api_us/internal/keyset"
+	"crud_api_us/internal/models"
+	"crud_api_us/internal/repository"
+	"crud_api_us/internal/tokens"
+)
+
+// newTestDB: SQLite trong bộ nhớ, riêng cho từng test, đã migrate như MySQL
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	db, err := gorm.Open(sqlite.Open("file:"+name+"?mode=memory&cache=shared&_foreign_keys=1"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1) // SQLite: 1 writer; transaction lỡ dùng kết nối gốc sẽ treo => lộ ra ngay
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := repository.MigrateAndSeed(db, nil); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// createUser lưu u (mặc định active, mật khẩu "secret1" với bcrypt cost thấp cho nhanh)
+func createUser(t *testing.T, db *gorm.DB, u models.User) models.User {
+	t.Helper()
+	if u.PasswordHash == "" {
+		h, _ := bcrypt.GenerateFromPassword([]byte("secret1"), bcrypt.MinCost)
+		u.PasswordHash = string(h)
+	}
+	if u.Status == "" {
+		u.Status = "active"
+	}
+	if u.Role == "" {
+		u.Role = models.RoleUser
+	}
+	if err := db.Create(&u).Error; err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func newTestTokens(t *testing.T) *tokens.Manager {
+	t.Helper()
+	keys, err := keyset.New(keyset.Config{Alg: "HS256", Secret: "test-secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tokens.NewManager(keys, tokens.Config{Issuer: "test", Audience: "test"})
+}
+
+func newTestAuthService(t *testing.T, repos repository.Repos, mfa *MFAService) *AuthService {
+	t.Helper()
+	return NewAuthService(repos, newTestTokens(t), mfa, nil, nil, JWTConfig{AccessTTL: 15 * time.Minute, RefreshTTL: time.Hour})
+}
+
+// user không tồn tại vẫn phải tốn 1 lần so bcrypt cùng cost với hash thật
+func TestDummyPasswordHash(t *testing.T) {
+	cost, err := bcrypt.Cost([]byte(dummyPasswordHash))
+	if err != nil || cost != bcrypt.DefaultCost {
+		t.Fatalf("cost = %d, %v; want %d", cost, err, bcrypt.DefaultCost)
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(dummyPasswordHash), []byte("secret1")); err != bcrypt.ErrMismatchedHashAndPassword {
+		t.Fatalf("compare: %v, want mismatch", err)
+	}
+}
+
+// loginAlice: đăng nhập mật khẩu, trả về refresh token và dòng tương ứng trong DB
+func loginAlice(t *testing.T, s *AuthService, repos repository.Repos) (string, models.RefreshToken) {
+	t.Helper()
+	res, err := s.Login("alice", "secret1", ClientMeta{IP: "10.0.0.1", UserAgent: "curl/8.0"})
+	if err != nil || res.Refresh == "" {
+		t.Fatalf("Login = %+v, %v", res, err)
+	}
+	return res.Refresh, refreshRow(t, s, repos, res.Refresh)
+}
+
+func refreshRow(t *testing.T, s *AuthService, repos repository.Repos, token string) models.RefreshToken {
+	t.Helper()
+	c, err := s.tokens.Parse(token, tokens.TypeRefresh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	row, err := repos.Auth.FindRefreshTokenByJTI(c.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return row
+}
+
+func TestRefreshRotates(t *testing.T) {
+	db := newTestDB(t)
+	repos := repository.NewMySQLRepos(db)
+	s := newTestAuthService(t, repos, nil)
+	createUser(t, db, models.User{Username: "alice", Email: "alice@example.com"})
+	tok, root := loginAlice(t, s, repos)
+	if root.FamilyID != root.TokenID || root.ParentID != "" {
+		t.Fatalf("login token: family %q parent %q, want family = own jti", root.FamilyID, root.ParentID)
+	}
+
+	res, err := s.Refresh(tok, ClientMeta{IP: "10.0.0.2"})
+	if err != nil || res.AccessToken == "" || res.Refresh == "" || res.Refresh == tok {
+		t.Fatalf("Refresh = %+v, %v", res, err)
+	}
+	child := refreshRow(t, s, repos, res.Refresh)
+	parent, _ := repos.Auth.FindRefreshTokenByJTI(root.TokenID)
+	if !parent.Revoked || parent.ReplacedBy != child.TokenID {
+		t.Errorf("parent: revoked %v replaced_by %q, want true %q", parent.Revoked, parent.ReplacedBy, child.TokenID)
+	}
+	if child.Revoked || child.ParentID != root.TokenID || child.FamilyID != root.FamilyID {
+		t.Errorf("child: revoked %v parent %q family %q", child.Revoked, child.ParentID, child.FamilyID)
+	}
+	// cùng phiên: giữ tên thiết bị và lúc đăng nhập, cập nhật IP
+	if child.Name != root.Name || !child.StartedAt.Equal(root.StartedAt) || child.IP != "10.0.0.2" {
+		t.Errorf("session info not carried over: %+v", child)
+	}
+	if _, err := s.Refresh(res.AccessToken, ClientMeta{}); err != ErrInvalidToken {
+		t.Errorf("access token as refresh: %v, want ErrInvalidToken", err)
+	}
+}
+
+// 2 request refresh cùng lúc với 1 token: đúng 1 request nhận cặp token mới
+func TestRefreshConcurrentSingleWinner(t *testing.T) {
+	db := newTestDB(t)
+	repos := repository.NewMySQLRepos(db)
+	s := newTestAuthService(t, repos, nil)
+	createUser(t, db, models.User{Username: "alice", Email: "alice@example.com"})
+	tok, root := loginAlice(t, s, repos)
+
+	const n = 8
+	var wg sync.WaitGroup
+	errs := make([]error, n)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = s.Refresh(tok, ClientMeta{})
+		}()
+	}
+	wg.Wait()
+	wins := 0
+	for _, err := range errs {
+		switch err {
+		case nil:
+			wins++
+		case ErrInvalidToken, ErrTokenReused: // thua cuộc đua / tới sau khi token đã rotate
+		default:
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if wins != 1 {
+		t.Fatalf("%d refreshes succeeded, want 1", wins)
+	}
+	var children int64
+	db.Model(&models.RefreshToken{}).Where("parent_id = ?", root.TokenID).Count(&children)
+	if children != 1 {
+		t.Errorf("%d child tokens, want 1", children)
+	}
+}
+
+// dùng lại token đã rotate => thu hồi mọi token sinh ra từ nó (con, cháu) + ghi sự kiện bảo mật
+func TestRefreshReuseRevokesFamily(t *testing.T) {
+	db := newTestDB(t)
+	repos := repository.NewMySQLRepos(db)
+	s := newTestAuthService(t, repos, nil)
+	u := createUser(t, db, models.User{Username: "alice", Email: "alice@example.com"})
+	t0, root := loginAlice(t, s, repos)
+	other, _ := loginAlice(t, s, repos) // phiên khác, không bị ảnh hưởng
+
+	r1, err := s.Refresh(t0, ClientMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r2, err := s.Refresh(r1.Refresh, ClientMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// kẻ trộm dùng lại token gốc
+	if _, err := s.Refresh(t0, ClientMeta{}); err != ErrTokenReused {
+		t.Fatalf("reuse: %v, want ErrTokenReused", err)
+	}
+	var alive int64
+	db.Model(&models.RefreshToken{}).Where("family_id = ? AND revoked = ?", root.FamilyID, false).Count(&alive)
+	if alive != 0 {
+		t.Errorf("%d tokens of the family still valid", alive)
+	}
+	if _, err := s.Refresh(r2.Refresh, ClientMeta{}); err != ErrInvalidToken {
+		t.Errorf("newest descendant after reuse: %v, want ErrInvalidToken", err)
+	}
+	if _, err := s.Refresh(other, ClientMeta{}); err != nil {
+		t.Errorf("other session revoked: %v", err)
+	}
+	var events []models.SecurityEvent
+	db.Where("user_id = ? AND type = ?", u.ID, models.SecurityEventRefreshReuse).Find(&events)
+	if len(events) != 1 || !strings.Contains(events[0].Detail, "jti="+root.TokenID) {
+		t.Errorf("security events = %+v", events)
+	}
+
+	// dùng lại token con giữa chuỗi (đã rotate) cũng bị phát hiện
+	if _, err := s.Refresh(r1.Refresh, ClientMeta{}); err != ErrTokenReused {
+		t.Errorf("reuse of middle token: %v, want ErrTokenReused", err)
+	}
+}
+
+// token cấp trước khi có family (family_id/parent_id NULL) vẫn rotate được và được phát hiện dùng lại
+func TestRefreshLegacyTokenWithoutFamily(t *testing.T) {
+	db := newTestDB(t)
+	repos := repository.NewMySQLRepos(db)
+	s := newTestAuthService(t, repos, nil)
+	u := createUser(t, db, models.User{Username: "alice", Email: "alice@example.com"})
+
+	legacy, exp, err := s.makeToken(u, tokens.TypeRefresh, time.Hour, "legacy-jti", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec("INSERT INTO refresh_tokens (token_id, user_id, expires_at, revoked, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)",
+		"legacy-jti", u.ID, exp, false, time.Now(), time.Now()).Error; err != nil {
+		t.Fatal(err)
+	}
+	_, current := loginAlice(t, s, repos)
+	sessions, err := NewSessionService(repos).List(u.ID, "")
+	if err != nil || len(sessions) != 2 {
+		t.Fatalf("sessions = %+v, %v; want legacy + current", sessions, err)
+	}
+
+	res, err := s.Refresh(legacy, ClientMeta{})
+	if err != nil {
+		t.Fatalf("Refresh legacy: %v", err)
+	}
+	child := refreshRow(t, s, repos, res.Refresh)
+	if child.FamilyID != "legacy-jti" || child.ParentID != "legacy-jti" {
+		t.Errorf("child of legacy token: family %q parent %q, want legacy-jti", child.FamilyID, child.ParentID)
+	}
+	if _, err := s.Refresh(legacy, ClientMeta{}); err != ErrTokenReused {
+		t.Errorf("legacy reuse: %v, want ErrTokenReused", err)
+	}
+	if row, _ := repos.Auth.FindRefreshTokenByJTI(child.TokenID); !row.Revoked {
+		t.Error("descendant of reused legacy token still valid")
+	}
+
+	// "đăng xuất thiết bị khác" cũng thu hồi token legacy (family_id NULL)
+	if err := db.Exec("INSERT INTO refresh_tokens (token_id, user_id, expires_at, revoked, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)",
+		"legacy-2", u.ID, exp, false, time.Now(), time.Now()).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := NewSessionService(repos).RevokeOthers(Actor{UserID: u.ID}, u.ID, current.FamilyID); err != nil {
+		t.Fatal(err)
+	}
+	if row, _ := repos.Auth.FindRefreshTokenByJTI("legacy-2"); !row.Revoked {
+		t.Error("legacy token survived revoke-others")
+	}
+	if row, _ := repos.Auth.FindRefreshTokenByJTI(current.TokenID); row.Revoked {
+		t.Error("current session revoked by revoke-others")
+	}
+}
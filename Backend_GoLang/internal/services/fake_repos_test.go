@@ -0,0 +1,73 @@
+package services
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"crud_api_us/internal/models"
+	"crud_api_us/internal/repository"
+)
+
+// fakeUsers / fakeAuth: repository trong bộ nhớ, chỉ cài các hàm mà test dùng
+type fakeUsers struct {
+	repository.UserRepository
+	mu    sync.Mutex
+	users map[int]models.User
+}
+
+func (f *fakeUsers) Get(id int) (models.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	u, ok := f.users[id]
+	if !ok {
+		return models.User{}, repository.ErrNotFound
+	}
+	return u, nil
+}
+
+func (f *fakeUsers) MarkEmailVerified(id int, email string, at time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	u, ok := f.users[id]
+	if !ok || u.Email != email {
+		return repository.ErrNotFound
+	}
+	u.EmailVerifiedAt = &at
+	f.users[id] = u
+	return nil
+}
+
+type fakeAuth struct {
+	repository.AuthRepository
+	users    *fakeUsers
+	resets   []models.PasswordResetToken
+	mu       sync.Mutex
+	security []models.SecurityEvent
+}
+
+func (f *fakeAuth) FindByUsernameOrEmail(identifier string) (models.User, error) {
+	f.users.mu.Lock()
+	defer f.users.mu.Unlock()
+	for _, u := range f.users.users {
+		if strings.EqualFold(u.Email, identifier) || u.Username == identifier {
+			return u, nil
+		}
+	}
+	return models.User{}, repository.ErrNotFound
+}
+
+func (f *fakeAuth) SavePasswordReset(t *models.PasswordResetToken) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.resets = append(f.resets, *t)
+	return nil
+}
+
+func newFakeRepos(users ...models.User) repository.Repos {
+	fu := &fakeUsers{users: map[int]models.User{}}
+	for _, u := range users {
+		fu.users[u.ID] = u
+	}
+	return repository.Repos{Users: fu, Auth: &fakeAuth{users: fu}}
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"crud_api_us/internal/models"
+	"crud_api_us/internal/repository"
+)
+
+// BruteForceConfig: chống dò mật khẩu trên /auth/login.
+// Sau FreeAttempts lần sai, mỗi lần sai tiếp theo phải chờ BackoffBase * 2^k (tối đa LockDuration);
+// đủ MaxFailures lần sai => khoá tạm LockDuration.
+type BruteForceConfig struct {
+	Enabled             bool
+	Store               string // memory | db (db: dùng chung giữa nhiều instance)
+	AccountFreeAttempts int
+	AccountMaxFailures  int
+	IPFreeAttempts      int
+	IPMaxFailures       int
+	BackoffBase         time.Duration
+	LockDuration        time.Duration
+	Window              time.Duration // không sai thêm lần nào trong Window => bộ đếm về 0
+}
+
+func LoadBruteForceConfigFromEnv() BruteForceConfig {
+	return BruteForceConfig{
+		Enabled:             getEnv("LOGIN_GUARD", "1") != "0",
+		Store:               getEnv("LOGIN_GUARD_STORE", "db"),
+		AccountFreeAttempts: parseIntEnv("LOGIN_ACCOUNT_FREE_ATTEMPTS", 3),
+		AccountMaxFailures:  parseIntEnv("LOGIN_ACCOUNT_MAX_FAILURES", 10),
+		IPFreeAttempts:      parseIntEnv("LOGIN_IP_FREE_ATTEMPTS", 20),
+		IPMaxFailures:       parseIntEnv("LOGIN_IP_MAX_FAILURES", 100),
+		BackoffBase:         parseDurationEnv("LOGIN_BACKOFF_BASE", time.Second),
+		LockDuration:        parseDurationEnv("LOGIN_LOCK_DURATION", 15*time.Minute),
+		Window:              parseDurationEnv("LOGIN_FAILURE_WINDOW", time.Hour),
+	}
+}
+
+// parseIntEnv: giá trị sai/không dương => dùng def
+func parseIntEnv(k string, def int) int {
+	n, err := strconv.Atoi(getEnv(k, ""))
+	if err != nil || n <= 0 {
+		return def
+	}
+	return n
+}
+
+// LoginGuard đếm số lần đăng nhập sai theo tài khoản và theo IP
+type LoginGuard struct {
+	store repository.AttemptStore
+	cfg   BruteForceConfig
+}
+
+func NewLoginGuard(store repository.AttemptStore, cfg BruteForceConfig) *LoginGuard {
+	return &LoginGuard{store: store, cfg: cfg}
+}
+
+// accountKey: identifier khớp user => đếm theo user ID (username, email, hoa/thường... dùng chung 1 bộ đếm);
+// không khớp user nào => đếm theo identifier
+func accountKey(userID int, identifier string) string {
+	if userID > 0 {
+		return "uid:" + strconv.Itoa(userID)
+	}
+	return "id:" + truncate(strings.ToLower(strings.TrimSpace(identifier)), 180)
+}
+func ipKey(ip string) string { return "ip:" + ip }
+
+// Attempt: 1 lượt đăng nhập đã giữ chỗ bởi Reserve (đã được đếm như 1 lần sai)
+type Attempt struct {
+	account, ip repository.Reservation
+}
+
+// Reserve giữ chỗ 1 lượt thử cho tài khoản (userID, 0 nếu identifier không khớp user nào) và IP.
+// Đang bị chặn => trả về thời gian còn phải chờ (> 0), không đếm. Lượt thử được đếm ngay từ đầu
+// nên các request song song không cùng lọt qua trước khi kịp ghi nhận lần sai; lượt hoá ra
+// không sai thì hoàn lại bằng Succeed/Release.
+func (g *LoginGuard) Reserve(userID int, identifier, ip string, now time.Time) (*Attempt, time.Duration, error) {
+	acc, ok, err := g.store.Reserve(accountKey(userID, identifier), now, g.cfg.Window, g.lockFor(g.cfg.AccountFreeAttempts, g.cfg.AccountMaxFailures))
+	if err != nil {
+		return nil, 0, err
+	}
+	if !ok {
+		return nil, acc.Next.LockedUntil.Sub(now), nil
+	}
+	byIP, ok, err := g.store.Reserve(ipKey(ip), now, g.cfg.Window, g.lockFor(g.cfg.IPFreeAttempts, g.cfg.IPMaxFailures))
+	if err != nil || !ok {
+		if rerr := g.store.Refund(acc); rerr != nil && err == nil {
+			err = rerr
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		return nil, byIP.Next.LockedUntil.Sub(now), nil
+	}
+	return &Attempt{account: acc, ip: byIP}, 0, nil
+}
+
+// Succeed: đăng nhập đúng => xoá bộ đếm của tài khoản, hoàn lượt của IP (bộ đếm theo IP giữ nguyên:
+// đăng nhập đúng 1 tài khoản không được phép "xoá dấu" việc dò các tài khoản khác từ cùng IP)
+func (g *LoginGuard) Succeed(a *Attempt) error {
+	if err := g.store.Reset(a.account.Key); err != nil {
+		return err
+	}
+	return g.store.Refund(a.ip)
+}
+
+// Release hoàn lượt đã giữ chỗ nhưng không sai (vd: mật khẩu đúng, còn chờ mã 2FA, tài khoản bị khoá)
+func (g *LoginGuard) Release(a *Attempt) error {
+	if err := g.store.Refund(a.account); err != nil {
+		return err
+	}
+	return g.store.Refund(a.ip)
+}
+
+// Unlock mở khoá tài khoản: xoá bộ đếm theo user ID (và theo username/email nếu có từ trước khi user tồn tại)
+func (g *LoginGuard) Unlock(u models.User) error {
+	for _, key := range []string{accountKey(u.ID, ""), accountKey(0, u.Username), accountKey(0, u.Email)} {
+		if err := g.store.Reset(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Prune xoá bộ đếm đã hết tác dụng (không bị chặn, không sai thêm trong cửa sổ đếm)
+func (g *LoginGuard) Prune(now time.Time) (int64, error) {
+	return g.store.Prune(now.Add(-g.cfg.Window))
+}
+
+func (g *LoginGuard) lockFor(free, maxFailures int) func(int) time.Duration {
+	return func(failures int) time.Duration {
+		switch {
+		case failures >= maxFailures:
+			return g.cfg.LockDuration
+		case failures <= free:
+			return 0
+		}
+		d := g.cfg.BackoffBase << min(failures-free-1, 30)
+		if d <= 0 || d > g.cfg.LockDuration {
+			return g.cfg.LockDuration
+		}
+		return d
+	}
+}
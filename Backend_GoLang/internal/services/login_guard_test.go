@@ -0,0 +1,90 @@
+package services
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"crud_api_us/internal/models"
+	"crud_api_us/internal/repository"
+)
+
+func newTestGuard() *LoginGuard {
+	return NewLoginGuard(repository.NewMemoryAttemptStore(), BruteForceConfig{
+		Enabled: true, AccountFreeAttempts: 2, AccountMaxFailures: 5, IPFreeAttempts: 100, IPMaxFailures: 100,
+		BackoffBase: time.Hour, LockDuration: 24 * time.Hour, Window: 24 * time.Hour,
+	})
+}
+
+// nhiều request song song: chỉ các lượt trước khi bị chặn lọt qua, dù chưa lượt nào kịp báo sai
+func TestLoginGuardReserveIsAtomic(t *testing.T) {
+	g := newTestGuard()
+	now := time.Now()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// username hay email đều là cùng 1 tài khoản (user 7)
+			ident := "alice"
+			if i%2 == 1 {
+				ident = "ALICE@example.com"
+			}
+			if _, wait, err := g.Reserve(7, ident, "10.0.0.1", now); err == nil && wait == 0 {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	// 2 lượt miễn phí + lượt thứ 3 (lượt này kích hoạt chờ)
+	if allowed != 3 {
+		t.Fatalf("allowed %d attempts, want 3", allowed)
+	}
+	if _, wait, _ := g.Reserve(7, "someone-else-typed", "10.0.0.2", now); wait <= 0 {
+		t.Error("account key must not depend on identifier/IP")
+	}
+	if _, wait, _ := g.Reserve(0, "alice", "10.0.0.1", now); wait != 0 {
+		t.Errorf("unknown identifier throttled (wait %v)", wait)
+	}
+}
+
+func TestLoginGuardRefund(t *testing.T) {
+	g := newTestGuard()
+	now := time.Now()
+	reserve := func(ip string) *Attempt {
+		t.Helper()
+		a, wait, err := g.Reserve(1, "bob", ip, now)
+		if err != nil || wait != 0 || a == nil {
+			t.Fatalf("Reserve: wait=%v err=%v", wait, err)
+		}
+		return a
+	}
+	// lượt không sai (vd: chờ 2FA) được hoàn => không bao giờ bị chặn
+	for i := 0; i < 10; i++ {
+		if err := g.Release(reserve("10.0.0.1")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 2 lần sai rồi đăng nhập đúng: bộ đếm tài khoản về 0, lượt của IP được hoàn
+	reserve("10.0.0.1")
+	reserve("10.0.0.1")
+	if err := g.Succeed(reserve("10.0.0.1")); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		reserve("10.0.0.1")
+	}
+	if _, wait, _ := g.Reserve(1, "bob", "10.0.0.1", now); wait <= 0 {
+		t.Error("want throttled after 3 failures")
+	}
+
+	// Unlock xoá bộ đếm theo user ID
+	if err := g.Unlock(models.User{ID: 1, Username: "bob", Email: "bob@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	reserve("10.0.0.1")
+}
@@ -0,0 +1,296 @@
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"crud_api_us/internal/models"
+	"crud_api_us/internal/repository"
+	"crud_api_us/internal/totp"
+)
+
+// MFAConfig: cấu hình xác thực 2 lớp (TOTP)
+type MFAConfig struct {
+	Issuer        string        // tên hiển thị trong app authenticator
+	EncryptionKey string        // khoá mã hoá secret TOTP trong DB (mặc định suy ra từ JWT_SECRET)
+	ChallengeTTL  time.Duration // thời hạn mfa_token trả về ở bước 1 của đăng nhập
+	RequiredRoles []string      // các role bắt buộc bật 2FA (vd: admin)
+}
+
+func LoadMFAConfigFromEnv() MFAConfig {
+	var roles []string
+	for _, r := range strings.Split(getEnv("MFA_REQUIRED_ROLES", ""), ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			roles = append(roles, r)
+		}
+	}
+	return MFAConfig{
+		Issuer:        getEnv("MFA_ISSUER", "CRUD API"),
+		EncryptionKey: getEnv("MFA_ENCRYPTION_KEY", getEnv("JWT_SECRET", "change-me")+"|mfa_secret"),
+		ChallengeTTL:  parseDurationEnv("MFA_CHALLENGE_TTL", 5*time.Minute),
+		RequiredRoles: roles,
+	}
+}
+
+const recoveryCodeCount = 10
+
+var (
+	ErrMFAAlreadyEnabled = errors.New("mfa_already_enabled")
+	ErrMFANotPending     = errors.New("mfa_not_pending") // chưa gọi setup
+	ErrInvalidMFACode    = errors.New("invalid_mfa_code")
+)
+
+// MFAService: đăng ký TOTP, mã khôi phục và kiểm tra mã 2FA khi đăng nhập
+type MFAService struct {
+	repos repository.Repos
+	cfg   MFAConfig
+	aead  cipher.AEAD
+}
+
+func NewMFAService(r repository.Repos, cfg MFAConfig) *MFAService {
+	key := sha256.Sum256([]byte(cfg.EncryptionKey)) // AES-256
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		panic(err) // không xảy ra: khoá luôn đủ 32 byte
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	return &MFAService{repos: r, cfg: cfg, aead: aead}
+}
+
+// RequiredFor: role có bắt buộc 2FA không
+func (s *MFAService) RequiredFor(role string) bool {
+	for _, r := range s.cfg.RequiredRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Enabled: user đã bật 2FA (đã xác nhận TOTP) chưa
+func (s *MFAService) Enabled(userID int) (bool, error) {
+	t, err := s.repos.MFA.GetTOTP(userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return t.ConfirmedAt != nil, nil
+}
+
+// MFAStatus: trạng thái 2FA của user (GET /auth/mfa)
+type MFAStatus struct {
+	Enabled           bool  `json:"enabled"`
+	Required          bool  `json:"required"`
+	RecoveryCodesLeft int64 `json:"recovery_codes_left"`
+}
+
+func (s *MFAService) Status(u models.User) (MFAStatus, error) {
+	on, err := s.Enabled(u.ID)
+	if err != nil {
+		return MFAStatus{}, err
+	}
+	st := MFAStatus{Enabled: on, Required: s.RequiredFor(u.Role)}
+	if on {
+		if st.RecoveryCodesLeft, err = s.repos.MFA.CountRecoveryCodes(u.ID); err != nil {
+			return MFAStatus{}, err
+		}
+	}
+	return st, nil
+}
+
+// SetupTOTP tạo secret mới (chưa bật 2FA) và trả về secret + otpauth URI để quét QR.
+// Gọi lại khi chưa xác nhận thì secret cũ bị thay.
+func (s *MFAService) SetupTOTP(userID int) (secret, uri string, err error) {
+	u, err := s.repos.Users.Get(userID)
+	if err != nil {
+		return "", "", err
+	}
+	cur, err := s.repos.MFA.GetTOTP(userID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return "", "", err
+	}
+	if cur.ConfirmedAt != nil {
+		return "", "", ErrMFAAlreadyEnabled
+	}
+	if secret, err = totp.GenerateSecret(); err != nil {
+		return "", "", err
+	}
+	enc, err := s.encrypt(secret)
+	if err != nil {
+		return "", "", err
+	}
+	cur.UserID, cur.Secret, cur.LastStep = userID, enc, 0
+	if err := s.repos.MFA.SaveTOTP(&cur); err != nil {
+		return "", "", err
+	}
+	return secret, totp.URI(s.cfg.Issuer, u.Email, secret), nil
+}
+
+// ConfirmTOTP kiểm tra mã đầu tiên từ app để bật 2FA, trả về mã khôi phục (chỉ hiển thị 1 lần).
+func (s *MFAService) ConfirmTOTP(actor Actor, userID int, code string) ([]string, error) {
+	cur, err := s.repos.MFA.GetTOTP(userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrMFANotPending
+		}
+		return nil, err
+	}
+	if cur.ConfirmedAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	secret, err := s.decrypt(cur.Secret)
+	if err != nil {
+		return nil, err
+	}
+	step, ok := totp.Validate(secret, code, time.Now(), 1)
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		if codes[i], err = newRecoveryCode(); err != nil {
+			return nil, err
+		}
+		hashes[i] = hashToken(normalizeRecoveryCode(codes[i]))
+	}
+	err = s.repos.InTx(func(tx repository.Repos) error {
+		if err := tx.MFA.ConfirmTOTP(userID, step, time.Now(), hashes); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return ErrMFAAlreadyEnabled // request khác vừa xác nhận trước
+			}
+			return err
+		}
+		return writeAudit(tx.Audit, actor, models.AuditMFAEnable, userID, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// verify kiểm tra mã 2FA khi đăng nhập: 6 số từ app (mỗi mã chỉ dùng 1 lần) hoặc mã khôi phục.
+// Mã đúng bị tiêu ngay qua r => gọi trong transaction để rollback được.
+func (s *MFAService) verify(r repository.Repos, userID int, code string) error {
+	code = strings.TrimSpace(code)
+	if len(code) == totp.Digits && strings.Trim(code, "0123456789") == "" {
+		cur, err := r.MFA.GetTOTP(userID)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return ErrInvalidMFACode
+			}
+			return err
+		}
+		if cur.ConfirmedAt == nil {
+			return ErrInvalidMFACode
+		}
+		secret, err := s.decrypt(cur.Secret)
+		if err != nil {
+			return err
+		}
+		step, ok := totp.Validate(secret, code, time.Now(), 1)
+		if !ok {
+			return ErrInvalidMFACode
+		}
+		return notFoundAs(r.MFA.UseTOTPStep(userID, step), ErrInvalidMFACode)
+	}
+	return notFoundAs(r.MFA.UseRecoveryCode(userID, hashToken(normalizeRecoveryCode(code)), time.Now()), ErrInvalidMFACode)
+}
+
+// mfaChallengeMaxAttempts: số lần thử mã tối đa của 1 mfa_token => hết lượt phải đăng nhập lại từ đầu
+const mfaChallengeMaxAttempts = 5
+
+// NewChallenge tạo mfa_token cho bước 2 của đăng nhập (user đã nhập đúng mật khẩu).
+// Chỉ lưu hash của token; gắn với token version => đổi mật khẩu/khoá tài khoản thì token mất hiệu lực.
+func (s *MFAService) NewChallenge(u models.User) (string, time.Time, error) {
+	token, hash, err := newSecretToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	exp := time.Now().Add(s.cfg.ChallengeTTL)
+	if err := s.repos.MFA.SaveChallenge(&models.MFAChallenge{
+		UserID: u.ID, TokenHash: hash, TokenVersion: u.TokenVersion, ExpiresAt: exp,
+	}); err != nil {
+		return "", time.Time{}, err
+	}
+	return token, exp, nil
+}
+
+// AttemptChallenge tính 1 lần thử mã cho mfa_token. ErrInvalidToken nếu token không tồn tại,
+// hết hạn, đã dùng hoặc đã hết lượt thử.
+func (s *MFAService) AttemptChallenge(token string) (models.MFAChallenge, error) {
+	c, err := s.repos.MFA.AttemptChallenge(hashToken(token), mfaChallengeMaxAttempts, time.Now())
+	return c, notFoundAs(err, ErrInvalidToken)
+}
+
+// CompleteChallenge: bước 2 của đăng nhập trong 1 transaction — giành challenge trước (mỗi mfa_token
+// chỉ thành công 1 lần, request song song nhận ErrInvalidToken), rồi kiểm tra mã, rồi issue (lưu phiên).
+// Mã sai hoặc issue lỗi => rollback: challenge chưa bị dùng, mã TOTP / mã khôi phục không bị tiêu.
+func (s *MFAService) CompleteChallenge(c models.MFAChallenge, code string, issue func(tx repository.Repos) error) error {
+	return s.repos.InTx(func(tx repository.Repos) error {
+		if err := tx.MFA.UseChallenge(c.ID, time.Now()); err != nil {
+			return notFoundAs(err, ErrInvalidToken)
+		}
+		if err := s.verify(tx, c.UserID, code); err != nil {
+			return err
+		}
+		return issue(tx)
+	})
+}
+
+func notFoundAs(err, as error) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return as
+	}
+	return err
+}
+
+// encrypt: AES-GCM, kết quả base64(nonce|ciphertext)
+func (s *MFAService) encrypt(plain string) (string, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(s.aead.Seal(nonce, nonce, []byte(plain), nil)), nil
+}
+
+func (s *MFAService) decrypt(enc string) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(enc)
+	if err != nil || len(b) < s.aead.NonceSize() {
+		return "", errors.New("mfa: corrupted secret")
+	}
+	n := s.aead.NonceSize()
+	plain, err := s.aead.Open(nil, b[:n], b[n:], nil)
+	if err != nil {
+		return "", errors.New("mfa: cannot decrypt secret (MFA_ENCRYPTION_KEY changed?)")
+	}
+	return string(plain), nil
+}
+
+// newRecoveryCode: 10 ký tự base32 (50 bit) dạng xxxxx-xxxxx
+func newRecoveryCode() (string, error) {
+	b := make([]byte, 7)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	s := strings.ToLower(base32.StdEncoding.EncodeToString(b))[:10]
+	return s[:5] + "-" + s[5:], nil
+}
+
+// normalizeRecoveryCode: bỏ qua khoảng trắng, dấu gạch, hoa/thường khi so khớp
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"crud_api_us/internal/models"
+	"crud_api_us/internal/repository"
+	"crud_api_us/internal/totp"
+)
+
+// enrollTOTP bật 2FA cho u, trả về secret, mã khôi phục và bước TOTP đã dùng để xác nhận
+func enrollTOTP(t *testing.T, mfa *MFAService, u models.User) (string, []string, int64) {
+	t.Helper()
+	secret, _, err := mfa.SetupTOTP(u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	step := totp.Step(time.Now())
+	code, _ := totp.Code(secret, step)
+	codes, err := mfa.ConfirmTOTP(Actor{UserID: u.ID}, u.ID, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return secret, codes, step
+}
+
+func recoveryLeft(t *testing.T, repos repository.Repos, userID int) int64 {
+	t.Helper()
+	n, err := repos.MFA.CountRecoveryCodes(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// challenge bị request khác giành trước hoặc lưu phiên lỗi => mã khôi phục không bị tiêu
+func TestCompleteChallengeKeepsCodeOnFailure(t *testing.T) {
+	db := newTestDB(t)
+	repos := repository.NewMySQLRepos(db)
+	mfa := NewMFAService(repos, MFAConfig{EncryptionKey: "k", ChallengeTTL: time.Minute})
+	u := createUser(t, db, models.User{Username: "alice", Email: "alice@example.com"})
+	_, recovery, _ := enrollTOTP(t, mfa, u)
+
+	newChallenge := func() models.MFAChallenge {
+		t.Helper()
+		tok, _, err := mfa.NewChallenge(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ch, err := mfa.AttemptChallenge(tok)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ch
+	}
+	saved := 0
+	issue := func(repository.Repos) error { saved++; return nil }
+
+	// request khác đã dùng challenge
+	ch := newChallenge()
+	if err := repos.MFA.UseChallenge(ch.ID, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := mfa.CompleteChallenge(ch, recovery[0], issue); err != ErrInvalidToken {
+		t.Fatalf("used challenge: %v, want ErrInvalidToken", err)
+	}
+	if n := recoveryLeft(t, repos, u.ID); n != recoveryCodeCount {
+		t.Fatalf("recovery codes left = %d, want %d", n, recoveryCodeCount)
+	}
+
+	// lưu phiên lỗi => rollback cả challenge lẫn mã
+	ch = newChallenge()
+	boom := errors.New("boom")
+	if err := mfa.CompleteChallenge(ch, recovery[0], func(repository.Repos) error { return boom }); err != boom {
+		t.Fatalf("issue error: %v, want boom", err)
+	}
+	if n := recoveryLeft(t, repos, u.ID); n != recoveryCodeCount {
+		t.Fatalf("recovery codes left after rollback = %d, want %d", n, recoveryCodeCount)
+	}
+
+	// mã sai => challenge vẫn dùng được với mã đúng
+	if err := mfa.CompleteChallenge(ch, "zzzzz-zzzzz", issue); err != ErrInvalidMFACode {
+		t.Fatalf("wrong code: %v, want ErrInvalidMFACode", err)
+	}
+	if err := mfa.CompleteChallenge(ch, recovery[0], issue); err != nil || saved != 1 {
+		t.Fatalf("recovery code: %v (saved %d)", err, saved)
+	}
+	if n := recoveryLeft(t, repos, u.ID); n != recoveryCodeCount-1 {
+		t.Fatalf("recovery codes left = %d, want %d", n, recoveryCodeCount-1)
+	}
+	// mỗi challenge / mã khôi phục chỉ thành công 1 lần
+	if err := mfa.CompleteChallenge(ch, recovery[1], issue); err != ErrInvalidToken {
+		t.Fatalf("challenge reuse: %v, want ErrInvalidToken", err)
+	}
+	if err := mfa.CompleteChallenge(newChallenge(), recovery[0], issue); err != ErrInvalidMFACode {
+		t.Fatalf("recovery code reuse: %v, want ErrInvalidMFACode", err)
+	}
+}
+
+// mã TOTP đã dùng (kể cả mã lúc bật 2FA) không dùng lại được
+func TestLoginMFARejectsStepReplay(t *testing.T) {
+	db := newTestDB(t)
+	repos := repository.NewMySQLRepos(db)
+	mfa := NewMFAService(repos, MFAConfig{EncryptionKey: "k", ChallengeTTL: time.Minute})
+	s := newTestAuthService(t, repos, mfa)
+	u := createUser(t, db, models.User{Username: "bob", Email: "bob@example.com"})
+	secret, _, step := enrollTOTP(t, mfa, u)
+
+	login := func() string {
+		t.Helper()
+		res, err := s.Login("bob", "secret1", ClientMeta{IP: "10.0.0.1"})
+		if err != nil || res.MFAToken == "" {
+			t.Fatalf("Login = %+v, %v; want mfa token", res, err)
+		}
+		return res.MFAToken
+	}
+	used, _ := totp.Code(secret, step) // đã dùng khi ConfirmTOTP
+	if _, err := s.LoginMFA(login(), used, ClientMeta{}); err != ErrInvalidMFACode {
+		t.Fatalf("code used at enrollment: %v, want ErrInvalidMFACode", err)
+	}
+
+	next, _ := totp.Code(secret, step+1) // bước sau, trong khoảng lệch ±1
+	tok := login()
+	res, err := s.LoginMFA(tok, next, ClientMeta{})
+	if err != nil || res.AccessToken == "" || res.Refresh == "" {
+		t.Fatalf("LoginMFA = %+v, %v", res, err)
+	}
+	if _, err := s.LoginMFA(tok, next, ClientMeta{}); err != ErrInvalidToken {
+		t.Fatalf("token reuse: %v, want ErrInvalidToken", err)
+	}
+	if _, err := s.LoginMFA(login(), next, ClientMeta{}); err != ErrInvalidMFACode {
+		t.Fatalf("step replay: %v, want ErrInvalidMFACode", err)
+	}
+}
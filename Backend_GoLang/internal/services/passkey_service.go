@@ -0,0 +1,322 @@
+package services
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"crud_api_us/internal/models"
+	"crud_api_us/internal/repository"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// WebAuthnConfig: cấu hình Relying Party cho passkey
+type WebAuthnConfig struct {
+	RPID       string        // domain của FE (không có scheme/port), vd: example.com
+	RPName     string        // tên hiển thị trong hộp thoại passkey
+	Origins    []string      // origin FE được phép, vd: https://app.example.com
+	SessionTTL time.Duration // thời gian tối đa giữa bước begin và finish
+}
+
+func LoadWebAuthnConfigFromEnv() WebAuthnConfig {
+	var origins []string
+	for _, o := range strings.Split(getEnv("WEBAUTHN_ORIGINS", "http://localhost:5173"), ",") {
+		if o = strings.TrimSpace(strings.TrimRight(o, "/")); o != "" {
+			origins = append(origins, o)
+		}
+	}
+	return WebAuthnConfig{
+		RPID:       getEnv("WEBAUTHN_RP_ID", "localhost"),
+		RPName:     getEnv("WEBAUTHN_RP_NAME", getEnv("MFA_ISSUER", "CRUD API")),
+		Origins:    origins,
+		SessionTTL: parseDurationEnv("WEBAUTHN_SESSION_TTL", 5*time.Minute),
+	}
+}
+
+var ErrPasskeyFailed = errors.New("passkey_failed") // chữ ký/challenge/origin không hợp lệ
+
+// PasskeyService: đăng ký passkey và đăng nhập không mật khẩu (WebAuthn)
+type PasskeyService struct {
+	repos repository.Repos
+	wa    *webauthn.WebAuthn
+	ttl   time.Duration // thời hạn session (dữ liệu ceremony lưu DB, dùng 1 lần)
+}
+
+type passkeySession struct {
+	data   webauthn.SessionData
+	userID int // 0 = đăng nhập
+}
+
+func NewPasskeyService(r repository.Repos, cfg WebAuthnConfig) (*PasskeyService, error) {
+	wa, err := webauthn.New(&webauthn.Config{
+		RPID:          cfg.RPID,
+		RPDisplayName: cfg.RPName,
+		RPOrigins:     cfg.Origins,
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:      protocol.ResidentKeyRequirementRequired, // passkey = discoverable credential
+			UserVerification: protocol.VerificationRequired,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &PasskeyService{repos: r, wa: wa, ttl: cfg.SessionTTL}, nil
+}
+
+// passkeyUser: models.User + passkey của user theo interface webauthn.User
+type passkeyUser struct {
+	u     models.User
+	creds []models.WebAuthnCredential
+}
+
+// user handle = id dạng 8 byte big-endian (không chứa email/username)
+func userHandle(id int) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(id))
+	return b
+}
+
+func (p passkeyUser) WebAuthnID() []byte   { return userHandle(p.u.ID) }
+func (p passkeyUser) WebAuthnName() string { return p.u.Email }
+func (p passkeyUser) WebAuthnDisplayName() string {
+	if p.u.FullName != "" {
+		return p.u.FullName
+	}
+	return p.u.Username
+}
+
+func (p passkeyUser) WebAuthnCredentials() []webauthn.Credential {
+	out := make([]webauthn.Credential, len(p.creds))
+	for i, c := range p.creds {
+		var transports []protocol.AuthenticatorTransport
+		for _, t := range strings.Split(c.Transports, ",") {
+			if t != "" {
+				transports = append(transports, protocol.AuthenticatorTransport(t))
+			}
+		}
+		out[i] = webauthn.Credential{
+			ID: c.CredentialID, PublicKey: c.PublicKey, AttestationType: c.AttestationType, Transport: transports,
+			Flags:         webauthn.CredentialFlags{BackupEligible: c.BackupEligible, BackupState: c.BackupState},
+			Authenticator: webauthn.Authenticator{AAGUID: c.AAGUID, SignCount: c.SignCount},
+		}
+	}
+	return out
+}
+
+func (s *PasskeyService) loadUser(id int) (passkeyUser, error) {
+	u, err := s.repos.Users.Get(id)
+	if err != nil {
+		return passkeyUser{}, err
+	}
+	creds, err := s.repos.WebAuthn.ListCredentials(id)
+	if err != nil {
+		return passkeyUser{}, err
+	}
+	return passkeyUser{u: u, creds: creds}, nil
+}
+
+// List: passkey của user
+func (s *PasskeyService) List(userID int) ([]models.WebAuthnCredential, error) {
+	return s.repos.WebAuthn.ListCredentials(userID)
+}
+
+// BeginRegistration: tuỳ chọn cho navigator.credentials.create() + session_id dùng ở bước finish
+func (s *PasskeyService) BeginRegistration(userID int) (string, *protocol.CredentialCreation, error) {
+	pu, err := s.loadUser(userID)
+	if err != nil {
+		return "", nil, err
+	}
+	// không cho đăng ký lại passkey đã có trên cùng authenticator
+	creation, data, err := s.wa.BeginRegistration(pu,
+		webauthn.WithExclusions(webauthn.Credentials(pu.WebAuthnCredentials()).CredentialDescriptors()))
+	if err != nil {
+		return "", nil, err
+	}
+	sid, err := s.putSession(*data, userID)
+	if err != nil {
+		return "", nil, err
+	}
+	return sid, creation, nil
+}
+
+// FinishRegistration kiểm tra phản hồi của authenticator và lưu passkey
+func (s *PasskeyService) FinishRegistration(actor Actor, userID int, sessionID, name string, body []byte) (models.WebAuthnCredential, error) {
+	sess, err := s.takeSession(sessionID)
+	if err != nil {
+		return models.WebAuthnCredential{}, err
+	}
+	if sess.userID != userID {
+		return models.WebAuthnCredential{}, ErrInvalidToken
+	}
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(body)
+	if err != nil {
+		return models.WebAuthnCredential{}, passkeyErr("parse registration", err)
+	}
+	pu, err := s.loadUser(userID)
+	if err != nil {
+		return models.WebAuthnCredential{}, err
+	}
+	cred, err := s.wa.CreateCredential(pu, sess.data, parsed)
+	if err != nil {
+		return models.WebAuthnCredential{}, passkeyErr("create credential", err)
+	}
+
+	transports := make([]string, len(cred.Transport))
+	for i, t := range cred.Transport {
+		transports[i] = string(t)
+	}
+	if name = strings.TrimSpace(name); name == "" {
+		name = "Passkey"
+	}
+	row := models.WebAuthnCredential{
+		UserID: userID, CredentialID: cred.ID, PublicKey: cred.PublicKey, AttestationType: cred.AttestationType,
+		AAGUID: cred.Authenticator.AAGUID, SignCount: cred.Authenticator.SignCount,
+		Transports:     truncate(strings.Join(transports, ","), 100),
+		BackupEligible: cred.Flags.BackupEligible, BackupState: cred.Flags.BackupState,
+		Name: truncate(name, 100),
+	}
+	err = s.repos.InTx(func(tx repository.Repos) error {
+		if err := tx.WebAuthn.SaveCredential(&row); err != nil {
+			if isDuplicate(err) {
+				return ErrDuplicate
+			}
+			return err
+		}
+		return writeAudit(tx.Audit, actor, models.AuditPasskeyAdd, userID, map[string]AuditChange{
+			"passkey": {After: row.Name},
+		})
+	})
+	return row, err
+}
+
+// Delete xoá 1 passkey của user
+func (s *PasskeyService) Delete(actor Actor, userID, id int) error {
+	return s.repos.InTx(func(tx repository.Repos) error {
+		if err := tx.WebAuthn.DeleteCredential(userID, id); err != nil {
+			return err
+		}
+		return writeAudit(tx.Audit, actor, models.AuditPasskeyRemove, userID, map[string]AuditChange{
+			"passkey": {Before: id},
+		})
+	})
+}
+
+// BeginLogin: tuỳ chọn cho navigator.credentials.get() (discoverable => không cần nhập username)
+func (s *PasskeyService) BeginLogin() (string, *protocol.CredentialAssertion, error) {
+	assertion, data, err := s.wa.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+	if err != nil {
+		return "", nil, err
+	}
+	sid, err := s.putSession(*data, 0)
+	if err != nil {
+		return "", nil, err
+	}
+	return sid, assertion, nil
+}
+
+// FinishLogin kiểm tra chữ ký của passkey (challenge, origin, user verification, bộ đếm chữ ký)
+// và trả về chủ passkey. Bộ đếm không tăng => nghi passkey bị sao chép: từ chối + ghi sự kiện bảo mật.
+func (s *PasskeyService) FinishLogin(sessionID string, body []byte) (models.User, error) {
+	sess, err := s.takeSession(sessionID)
+	if err != nil {
+		return models.User{}, err
+	}
+	if sess.userID != 0 {
+		return models.User{}, ErrInvalidToken
+	}
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(body)
+	if err != nil {
+		return models.User{}, passkeyErr("parse assertion", err)
+	}
+
+	var owner passkeyUser
+	wu, cred, err := s.wa.ValidatePasskeyLogin(func(rawID, handle []byte) (webauthn.User, error) {
+		if len(handle) != 8 {
+			return nil, errors.New("unknown user handle")
+		}
+		pu, err := s.loadUser(int(binary.BigEndian.Uint64(handle)))
+		if err != nil {
+			return nil, err
+		}
+		owner = pu
+		return pu, nil
+	}, sess.data, parsed)
+	if err != nil {
+		if owner.u.ID != 0 {
+			return owner.u, passkeyErr("validate assertion", err)
+		}
+		return models.User{}, passkeyErr("validate assertion", err)
+	}
+	pu := wu.(passkeyUser)
+
+	var row models.WebAuthnCredential
+	for _, c := range pu.creds {
+		if string(c.CredentialID) == string(cred.ID) {
+			row = c
+		}
+	}
+	if cred.Authenticator.CloneWarning {
+		_ = s.repos.Auth.SaveSecurityEvent(&models.SecurityEvent{
+			UserID: pu.u.ID, Type: models.SecurityEventPasskeyClone,
+			Detail: fmt.Sprintf("credential=%d stored=%d got=%d", row.ID, row.SignCount, parsed.Response.AuthenticatorData.Counter),
+		})
+		return pu.u, ErrPasskeyFailed
+	}
+	if err := s.repos.WebAuthn.UseCredential(row.ID, row.SignCount, cred.Authenticator.SignCount,
+		cred.Flags.BackupState, time.Now()); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return pu.u, ErrPasskeyFailed // request khác vừa dùng cùng chữ ký / passkey vừa bị xoá
+		}
+		return pu.u, err
+	}
+	return pu.u, nil
+}
+
+// passkeyErr: lỗi kiểm tra WebAuthn chỉ ghi log chi tiết, client nhận ErrPasskeyFailed
+func passkeyErr(step string, err error) error {
+	var pe *protocol.Error
+	if errors.As(err, &pe) {
+		log.Printf("[passkey] %s: %s (%s)", step, pe.Details, pe.DevInfo)
+	} else {
+		log.Printf("[passkey] %s: %v", step, err)
+	}
+	return ErrPasskeyFailed
+}
+
+// putSession lưu dữ liệu ceremony vào DB (để bước finish chạy trên instance nào cũng được)
+// và trả về session_id cho client; DB chỉ giữ hash của session_id.
+func (s *PasskeyService) putSession(data webauthn.SessionData, userID int) (string, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	id, hash, err := newSecretToken()
+	if err != nil {
+		return "", err
+	}
+	if err := s.repos.WebAuthn.SaveSession(&models.WebAuthnSession{
+		SessionHash: hash, UserID: userID, Data: string(raw), ExpiresAt: time.Now().Add(s.ttl),
+	}); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// takeSession lấy và xoá session (mỗi challenge chỉ dùng 1 lần); ErrInvalidToken nếu không có / hết hạn
+func (s *PasskeyService) takeSession(id string) (passkeySession, error) {
+	row, err := s.repos.WebAuthn.TakeSession(hashToken(id), time.Now())
+	if err != nil {
+		return passkeySession{}, notFoundAs(err, ErrInvalidToken)
+	}
+	var data webauthn.SessionData
+	if err := json.Unmarshal([]byte(row.Data), &data); err != nil {
+		return passkeySession{}, err
+	}
+	return passkeySession{data: data, userID: row.UserID}, nil
+}
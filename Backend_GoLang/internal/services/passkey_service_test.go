@@ -0,0 +1,363 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"crud_api_us/internal/models"
+	"crud_api_us/internal/repository"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+)
+
+const (
+	testRPID   = "example.com"
+	testOrigin = "https://app.example.com"
+)
+
+type fakeWebAuthn struct {
+	repository.WebAuthnRepository
+	mu       sync.Mutex
+	creds    []models.WebAuthnCredential
+	sessions map[string]models.WebAuthnSession
+	nextID   int
+	// useGate != nil: UseCredential chờ đủ số lời gọi rồi mới ghi (giả lập 2 request chạy song song)
+	useGate *sync.WaitGroup
+}
+
+func (f *fakeWebAuthn) ListCredentials(userID int) ([]models.WebAuthnCredential, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []models.WebAuthnCredential
+	for _, c := range f.creds {
+		if c.UserID == userID {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeWebAuthn) SaveCredential(c *models.WebAuthnCredential) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextID++
+	c.ID = f.nextID
+	f.creds = append(f.creds, *c)
+	return nil
+}
+
+// UseCredential: compare-and-set theo sign_count như bản MySQL
+func (f *fakeWebAuthn) UseCredential(id int, prevCount, signCount uint32, backupState bool, at time.Time) error {
+	if f.useGate != nil {
+		f.useGate.Done()
+		f.useGate.Wait()
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, c := range f.creds {
+		if c.ID == id && c.SignCount == prevCount {
+			f.creds[i].SignCount, f.creds[i].BackupState, f.creds[i].LastUsedAt = signCount, backupState, &at
+			return nil
+		}
+	}
+	return repository.ErrNotFound
+}
+
+func (f *fakeWebAuthn) SaveSession(s *models.WebAuthnSession) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.sessions == nil {
+		f.sessions = map[string]models.WebAuthnSession{}
+	}
+	f.sessions[s.SessionHash] = *s
+	return nil
+}
+
+func (f *fakeWebAuthn) TakeSession(sessionHash string, now time.Time) (models.WebAuthnSession, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, ok := f.sessions[sessionHash]
+	delete(f.sessions, sessionHash)
+	if !ok || !now.Before(s.ExpiresAt) {
+		return models.WebAuthnSession{}, repository.ErrNotFound
+	}
+	return s, nil
+}
+
+type fakeAudit struct {
+	repository.AuditRepository
+	mu     sync.Mutex
+	events []models.AuditEvent
+}
+
+func (f *fakeAudit) Save(e *models.AuditEvent) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events = append(f.events, *e)
+	return nil
+}
+
+func (f *fakeAuth) SaveSecurityEvent(e *models.SecurityEvent) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.security = append(f.security, *e)
+	return nil
+}
+
+// softAuthenticator: authenticator phần mềm (ES256, attestation "none") tạo phản hồi WebAuthn như trình duyệt
+type softAuthenticator struct {
+	key    *ecdsa.PrivateKey
+	credID []byte
+	handle []byte
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := make([]byte, 32)
+	_, _ = rand.Read(id)
+	return &softAuthenticator{key: key, credID: id}
+}
+
+func b64(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+func clientData(typ string, challenge protocol.URLEncodedBase64) []byte {
+	b, _ := json.Marshal(map[string]string{"type": typ, "challenge": b64(challenge), "origin": testOrigin})
+	return b
+}
+
+// authData: rpIdHash | flags (UP, UV [, AT]) | counter [| attested credential data]
+func (a *softAuthenticator) authData(t *testing.T, counter uint32, attested bool) []byte {
+	t.Helper()
+	rp := sha256.Sum256([]byte(testRPID))
+	out := append([]byte{}, rp[:]...)
+	flags := byte(protocol.FlagUserPresent | protocol.FlagUserVerified)
+	if attested {
+		flags |= byte(protocol.FlagAttestedCredentialData)
+	}
+	out = append(out, flags)
+	out = binary.BigEndian.AppendUint32(out, counter)
+	if !attested {
+		return out
+	}
+	cose, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{KeyType: int64(webauthncose.EllipticKey), Algorithm: int64(webauthncose.AlgES256)},
+		Curve:         int64(webauthncose.P256),
+		XCoord:        a.key.X.FillBytes(make([]byte, 32)),
+		YCoord:        a.key.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = append(out, make([]byte, 16)...) // AAGUID
+	out = binary.BigEndian.AppendUint16(out, uint16(len(a.credID)))
+	out = append(out, a.credID...)
+	return append(out, cose...)
+}
+
+func (a *softAuthenticator) register(t *testing.T, creation *protocol.CredentialCreation) []byte {
+	t.Helper()
+	a.handle = creation.Response.User.ID.(protocol.URLEncodedBase64)
+	att, err := webauthncbor.Marshal(map[string]any{"fmt": "none", "attStmt": map[string]any{}, "authData": a.authData(t, 0, true)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := json.Marshal(map[string]any{
+		"id": b64(a.credID), "rawId": b64(a.credID), "type": "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    b64(clientData("webauthn.create", creation.Response.Challenge)),
+			"attestationObject": b64(att),
+		},
+	})
+	return body
+}
+
+func (a *softAuthenticator) assert(t *testing.T, assertion *protocol.CredentialAssertion, counter uint32) []byte {
+	t.Helper()
+	cd := clientData("webauthn.get", assertion.Response.Challenge)
+	ad := a.authData(t, counter, false)
+	h := sha256.Sum256(cd)
+	digest := sha256.Sum256(append(append([]byte{}, ad...), h[:]...))
+	sig, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := json.Marshal(map[string]any{
+		"id": b64(a.credID), "rawId": b64(a.credID), "type": "public-key",
+		"response": map[string]string{
+			"clientDataJSON": b64(cd), "authenticatorData": b64(ad), "signature": b64(sig), "userHandle": b64(a.handle),
+		},
+	})
+	return body
+}
+
+func newTestPasskeyService(t *testing.T) (*PasskeyService, repository.Repos, models.User) {
+	t.Helper()
+	alice := models.User{ID: 42, Username: "alice", Email: "alice@example.com", Status: "active"}
+	repos := newFakeRepos(alice)
+	repos.WebAuthn, repos.Audit = &fakeWebAuthn{}, &fakeAudit{}
+	s, err := NewPasskeyService(repos, WebAuthnConfig{RPID: testRPID, RPName: "Test", Origins: []string{testOrigin}, SessionTTL: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, repos, alice
+}
+
+// registerPasskey: đăng ký passkey cho user bằng authenticator phần mềm
+func registerPasskey(t *testing.T, s *PasskeyService, userID int) *softAuthenticator {
+	t.Helper()
+	a := newSoftAuthenticator(t)
+	sid, creation, err := s.BeginRegistration(userID)
+	if err != nil {
+		t.Fatalf("BeginRegistration: %v", err)
+	}
+	if _, err := s.FinishRegistration(Actor{UserID: userID}, userID, sid, "laptop", a.register(t, creation)); err != nil {
+		t.Fatalf("FinishRegistration: %v", err)
+	}
+	return a
+}
+
+func passkeyLogin(t *testing.T, s *PasskeyService, a *softAuthenticator, counter uint32) (models.User, error) {
+	t.Helper()
+	sid, assertion, err := s.BeginLogin()
+	if err != nil {
+		t.Fatalf("BeginLogin: %v", err)
+	}
+	return s.FinishLogin(sid, a.assert(t, assertion, counter))
+}
+
+func TestPasskeyRegisterAndLogin(t *testing.T) {
+	s, repos, alice := newTestPasskeyService(t)
+	a := registerPasskey(t, s, alice.ID)
+
+	creds, _ := repos.WebAuthn.ListCredentials(alice.ID)
+	if len(creds) != 1 || creds[0].Name != "laptop" || string(creds[0].CredentialID) != string(a.credID) {
+		t.Fatalf("stored credentials = %+v", creds)
+	}
+	if ev := repos.Audit.(*fakeAudit).events; len(ev) != 1 || ev[0].Action != models.AuditPasskeyAdd {
+		t.Errorf("audit = %+v", ev)
+	}
+
+	u, err := passkeyLogin(t, s, a, 1)
+	if err != nil || u.ID != alice.ID {
+		t.Fatalf("FinishLogin = %+v, %v", u, err)
+	}
+	creds, _ = repos.WebAuthn.ListCredentials(alice.ID)
+	if creds[0].SignCount != 1 || creds[0].LastUsedAt == nil {
+		t.Errorf("credential after login = %+v", creds[0])
+	}
+
+	// session lưu DB theo hash của session_id, dùng 1 lần
+	sid, assertion, _ := s.BeginLogin()
+	fw := repos.WebAuthn.(*fakeWebAuthn)
+	if _, ok := fw.sessions[hashToken(sid)]; !ok || len(fw.sessions) != 1 {
+		t.Fatalf("sessions = %v, want 1 keyed by hash", fw.sessions)
+	}
+	body := a.assert(t, assertion, 2)
+	if _, err := s.FinishLogin(sid, body); err != nil {
+		t.Fatalf("FinishLogin: %v", err)
+	}
+	if _, err := s.FinishLogin(sid, body); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("reused session: %v, want ErrInvalidToken", err)
+	}
+
+	// chữ ký bằng khoá khác
+	other := newSoftAuthenticator(t)
+	other.credID, other.handle = a.credID, a.handle
+	if _, err := passkeyLogin(t, s, other, 3); !errors.Is(err, ErrPasskeyFailed) {
+		t.Errorf("wrong key: %v, want ErrPasskeyFailed", err)
+	}
+}
+
+// Bộ đếm chữ ký không tăng => nghi passkey bị sao chép: từ chối + ghi SecurityEventPasskeyClone
+func TestPasskeyCloneDetection(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		counter uint32
+	}{{"replayed counter", 5}, {"lower counter", 3}} {
+		t.Run(tt.name, func(t *testing.T) {
+			s, repos, alice := newTestPasskeyService(t)
+			a := registerPasskey(t, s, alice.ID)
+			if _, err := passkeyLogin(t, s, a, 5); err != nil {
+				t.Fatalf("first login: %v", err)
+			}
+
+			u, err := passkeyLogin(t, s, a, tt.counter)
+			if !errors.Is(err, ErrPasskeyFailed) || u.ID != alice.ID {
+				t.Fatalf("cloned login = %+v, %v; want ErrPasskeyFailed", u, err)
+			}
+			ev := repos.Auth.(*fakeAuth).security
+			if len(ev) != 1 || ev[0].Type != models.SecurityEventPasskeyClone || ev[0].UserID != alice.ID {
+				t.Fatalf("security events = %+v", ev)
+			}
+			creds, _ := repos.WebAuthn.ListCredentials(alice.ID)
+			if creds[0].SignCount != 5 {
+				t.Errorf("sign count = %d, want unchanged 5", creds[0].SignCount)
+			}
+		})
+	}
+}
+
+// 2 request hợp lệ cùng qua bước kiểm tra chữ ký với cùng bộ đếm: chỉ 1 request được ghi (UseCredential CAS)
+func TestPasskeyConcurrentUse(t *testing.T) {
+	s, repos, alice := newTestPasskeyService(t)
+	a := registerPasskey(t, s, alice.ID)
+
+	var bodies [2][]byte
+	var sids [2]string
+	for i := range bodies {
+		sid, assertion, err := s.BeginLogin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sids[i], bodies[i] = sid, a.assert(t, assertion, 1)
+	}
+
+	gate := &sync.WaitGroup{}
+	gate.Add(len(bodies))
+	repos.WebAuthn.(*fakeWebAuthn).useGate = gate
+
+	errs := make([]error, len(bodies))
+	var wg sync.WaitGroup
+	for i := range bodies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = s.FinishLogin(sids[i], bodies[i])
+		}(i)
+	}
+	wg.Wait()
+
+	ok, failed := 0, 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			ok++
+		case errors.Is(err, ErrPasskeyFailed):
+			failed++
+		default:
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if ok != 1 || failed != 1 {
+		t.Fatalf("results = %v, want exactly one success", errs)
+	}
+	creds, _ := repos.WebAuthn.ListCredentials(alice.ID)
+	if creds[0].SignCount != 1 {
+		t.Errorf("sign count = %d, want 1", creds[0].SignCount)
+	}
+}
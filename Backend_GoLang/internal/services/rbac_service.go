@@ -0,0 +1,333 @@
+package services
+
+import (
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"crud_api_us/internal/models"
+	"crud_api_us/internal/repository"
+
+	"github.com/joho/godotenv"
+)
+
+var (
+	ErrUnknownRole       = errors.New("unknown_role")       // role không tồn tại
+	ErrUnknownPermission = errors.New("unknown_permission") // quyền không có trong danh mục
+	ErrSystemRole        = errors.New("system_role")        // role mặc định: không xoá / không đổi quyền admin
+	ErrRoleInUse         = errors.New("role_in_use")        // còn user mang role
+	ErrOwnRole           = errors.New("own_role")           // đổi quyền của chính role mình đang mang
+)
+
+// tên role: chữ thường, số, "_" hoặc "-", bắt đầu bằng chữ
+var roleNameRe = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,19}$`)
+
+type RBACConfig struct {
+	// Quyền của role được cache trong RAM; đổi quyền ở instance khác có hiệu lực sau tối đa CacheTTL
+	// (instance xử lý thay đổi xoá cache ngay). 0 = luôn đọc DB.
+	CacheTTL time.Duration
+}
+
+func LoadRBACConfigFromEnv() RBACConfig {
+	_ = godotenv.Load()
+	ttl, err := time.ParseDuration(getEnv("RBAC_CACHE_TTL", "30s"))
+	if err != nil || ttl < 0 {
+		ttl = 30 * time.Second
+	}
+	return RBACConfig{CacheTTL: ttl}
+}
+
+// RBACService: vai trò & quyền (bảng roles, permissions, role_permissions)
+type RBACService struct {
+	repos repository.Repos
+	ttl   time.Duration
+
+	mu    sync.Mutex
+	cache map[string]rolePerms
+}
+
+type rolePerms struct {
+	perms   map[string]bool // nil = role không tồn tại
+	expires time.Time
+}
+
+func NewRBACService(r repository.Repos, cfg RBACConfig) *RBACService {
+	return &RBACService{repos: r, ttl: cfg.CacheTTL, cache: map[string]rolePerms{}}
+}
+
+// HasPermissions: role có đủ mọi quyền perms (role không tồn tại => false)
+func (s *RBACService) HasPermissions(role string, perms ...string) (bool, error) {
+	granted, err := s.lookup(role)
+	if err != nil {
+		return false, err
+	}
+	for _, p := range perms {
+		if !granted[p] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (s *RBACService) lookup(role string) (map[string]bool, error) {
+	now := time.Now()
+	if s.ttl > 0 {
+		s.mu.Lock()
+		rp, ok := s.cache[role]
+		s.mu.Unlock()
+		if ok && now.Before(rp.expires) {
+			return rp.perms, nil
+		}
+	}
+
+	rp := rolePerms{expires: now.Add(s.ttl)}
+	r, err := s.repos.Roles.Get(role)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+	case err != nil:
+		return nil, err
+	default:
+		rp.perms = make(map[string]bool, len(r.Permissions))
+		for _, p := range r.Permissions {
+			rp.perms[p.Name] = true
+		}
+	}
+
+	if s.ttl > 0 {
+		s.mu.Lock()
+		s.cache[role] = rp
+		s.mu.Unlock()
+	}
+	return rp.perms, nil
+}
+
+func (s *RBACService) invalidate(role string) {
+	s.mu.Lock()
+	delete(s.cache, role)
+	s.mu.Unlock()
+}
+
+func (s *RBACService) Roles() ([]models.Role, error) { return s.repos.Roles.List() }
+
+func (s *RBACService) Permissions() ([]models.Permission, error) {
+	return s.repos.Roles.ListPermissions()
+}
+
+// Role theo tên; ErrUnknownRole nếu không có
+func (s *RBACService) Role(name string) (models.Role, error) {
+	r, err := s.repos.Roles.Get(name)
+	if errors.Is(err, repository.ErrNotFound) {
+		return models.Role{}, ErrUnknownRole
+	}
+	return r, err
+}
+
+// RoleParams: dữ liệu tạo/sửa role. Permissions nil khi sửa = giữ nguyên quyền.
+type RoleParams struct {
+	Name        string
+	Description *string
+	Permissions []string
+}
+
+func (s *RBACService) CreateRole(actor Actor, p RoleParams) (models.Role, error) {
+	if !roleNameRe.MatchString(p.Name) {
+		return models.Role{}, ErrBadInput
+	}
+	var out models.Role
+	err := s.repos.InTx(func(tx repository.Repos) error {
+		perms, err := findPermissions(tx, p.Permissions)
+		if err != nil {
+			return err
+		}
+		if err := s.checkGrant(tx, actor, "", perms); err != nil {
+			return err
+		}
+		out = models.Role{Name: p.Name}
+		if p.Description != nil {
+			out.Description = *p.Description
+		}
+		if err := tx.Roles.Create(&out); err != nil {
+			return err
+		}
+		if err := tx.Roles.SetPermissions(out.ID, perms); err != nil {
+			return err
+		}
+		out.Permissions = perms
+		return writeRoleAudit(tx.Audit, actor, models.AuditRoleCreate, out.ID, roleDiff(nil, &out))
+	})
+	if err != nil {
+		if isDuplicate(err) {
+			return models.Role{}, ErrDuplicate
+		}
+		return models.Role{}, err
+	}
+	s.invalidate(out.Name)
+	return out, nil
+}
+
+// UpdateRole đổi mô tả và/hoặc thay toàn bộ quyền của role.
+// Quyền của role admin cố định (luôn đủ mọi quyền) => ErrSystemRole.
+func (s *RBACService) UpdateRole(actor Actor, p RoleParams) (models.Role, error) {
+	var out models.Role
+	err := s.repos.InTx(func(tx repository.Repos) error {
+		before, err := tx.Roles.Get(p.Name)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return ErrUnknownRole
+			}
+			return err
+		}
+		out = before
+		if p.Description != nil && *p.Description != before.Description {
+			if err := tx.Roles.UpdateDescription(before.ID, *p.Description); err != nil {
+				return err
+			}
+			out.Description = *p.Description
+		}
+		if p.Permissions != nil {
+			perms, err := findPermissions(tx, p.Permissions)
+			if err != nil {
+				return err
+			}
+			if !slices.Equal(permNames(perms), permNames(before.Permissions)) {
+				if before.Name == models.RoleAdmin {
+					return ErrSystemRole
+				}
+				if err := s.checkGrant(tx, actor, before.Name, perms); err != nil {
+					return err
+				}
+				if err := tx.Roles.SetPermissions(before.ID, perms); err != nil {
+					return err
+				}
+				out.Permissions = perms
+			}
+		}
+		diff := roleDiff(&before, &out)
+		if len(diff) == 0 {
+			return nil
+		}
+		return writeRoleAudit(tx.Audit, actor, models.AuditRoleUpdate, out.ID, diff)
+	})
+	if err != nil {
+		return models.Role{}, err
+	}
+	s.invalidate(out.Name)
+	return out, nil
+}
+
+// DeleteRole xoá role không phải mặc định và không còn user nào mang
+func (s *RBACService) DeleteRole(actor Actor, name string) error {
+	err := s.repos.InTx(func(tx repository.Repos) error {
+		before, err := tx.Roles.Get(name)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return ErrUnknownRole
+			}
+			return err
+		}
+		if before.System {
+			return ErrSystemRole
+		}
+		n, err := tx.Roles.CountUsers(name)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return ErrRoleInUse
+		}
+		if err := tx.Roles.Delete(before.ID); err != nil {
+			return err
+		}
+		return writeRoleAudit(tx.Audit, actor, models.AuditRoleDelete, before.ID, roleDiff(&before, nil))
+	})
+	if err != nil {
+		return err
+	}
+	s.invalidate(name)
+	return nil
+}
+
+// checkGrant: actor chỉ gán được quyền mà role của mình đang có (ErrPrivilegeEscalation)
+// và không được đổi quyền của chính role mình đang mang (ErrOwnRole). actor = 0 (hệ thống) => bỏ qua.
+func (s *RBACService) checkGrant(tx repository.Repos, actor Actor, target string, perms []models.Permission) error {
+	if actor.UserID == 0 {
+		return nil
+	}
+	u, err := tx.Users.Get(actor.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrPrivilegeEscalation
+		}
+		return err
+	}
+	if target != "" && u.Role == target {
+		return ErrOwnRole
+	}
+	// đọc quyền trong transaction, không qua cache (có thể cũ tới CacheTTL)
+	granted, err := rolePermissions(tx, u.Role)
+	if err != nil {
+		return err
+	}
+	for _, p := range perms {
+		if !granted[p.Name] {
+			return ErrPrivilegeEscalation
+		}
+	}
+	return nil
+}
+
+// findPermissions: quyền theo tên; có tên không nằm trong danh mục => ErrUnknownPermission
+func findPermissions(tx repository.Repos, names []string) ([]models.Permission, error) {
+	names = slices.Compact(slices.Sorted(slices.Values(names)))
+	perms, err := tx.Roles.FindPermissions(names)
+	if err != nil {
+		return nil, err
+	}
+	if len(perms) != len(names) {
+		return nil, ErrUnknownPermission
+	}
+	return perms, nil
+}
+
+func permNames(perms []models.Permission) []string {
+	out := make([]string, len(perms))
+	for i, p := range perms {
+		out[i] = p.Name
+	}
+	slices.Sort(out)
+	return out
+}
+
+// roleDiff: thay đổi của role cho audit, cùng quy ước với userDiff
+// (before/after nil = role chưa tồn tại / đã bị xoá; quyền ghi dạng "a,b,c")
+func roleDiff(before, after *models.Role) map[string]AuditChange {
+	fields := func(r *models.Role) map[string]any {
+		if r == nil {
+			return nil
+		}
+		return map[string]any{"name": r.Name, "description": r.Description,
+			"permissions": strings.Join(permNames(r.Permissions), ",")}
+	}
+	b, a := fields(before), fields(after)
+	out := map[string]AuditChange{}
+	for _, k := range []string{"name", "description", "permissions"} {
+		if b[k] != a[k] && !(isEmpty(b[k]) && isEmpty(a[k])) {
+			out[k] = AuditChange{Before: b[k], After: a[k]}
+		}
+	}
+	return out
+}
+
+// checkRole: role phải tồn tại trong bảng roles (khi tạo/sửa user)
+func checkRole(tx repository.Repos, role string) error {
+	if _, err := tx.Roles.Get(role); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrUnknownRole
+		}
+		return err
+	}
+	return nil
+}
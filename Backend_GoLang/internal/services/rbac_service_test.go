@@ -0,0 +1,125 @@
+package services
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"crud_api_us/internal/middleware"
+	"crud_api_us/internal/models"
+	"crud_api_us/internal/repository"
+)
+
+func TestRBACGrantChecks(t *testing.T) {
+	db := newTestDB(t)
+	repos := repository.NewMySQLRepos(db)
+	rbac := NewRBACService(repos, RBACConfig{CacheTTL: time.Hour})
+	lead, err := rbac.CreateRole(Actor{}, RoleParams{Name: "lead", Permissions: []string{
+		models.PermUsersRead, models.PermUsersWrite, models.PermRolesRead, models.PermRolesWrite}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin := createUser(t, db, models.User{Username: "admin", Email: "admin@x.com", Role: models.RoleAdmin})
+	leader := createUser(t, db, models.User{Username: "leader", Email: "leader@x.com", Role: lead.Name})
+	as := func(u models.User) Actor { return Actor{UserID: u.ID} }
+
+	tests := []struct {
+		name string
+		run  func() error
+		want error
+	}{
+		{"grant subset of own permissions", func() error {
+			_, err := rbac.CreateRole(as(leader), RoleParams{Name: "viewer", Permissions: []string{models.PermUsersRead}})
+			return err
+		}, nil},
+		{"grant permission actor lacks", func() error {
+			_, err := rbac.CreateRole(as(leader), RoleParams{Name: "auditor", Permissions: []string{models.PermAuditRead}})
+			return err
+		}, ErrPrivilegeEscalation},
+		{"raise other role beyond own", func() error {
+			_, err := rbac.UpdateRole(as(leader), RoleParams{Name: "viewer", Permissions: []string{models.PermUsersDelete}})
+			return err
+		}, ErrPrivilegeEscalation},
+		{"change own role", func() error {
+			_, err := rbac.UpdateRole(as(leader), RoleParams{Name: "lead", Permissions: []string{models.PermUsersRead}})
+			return err
+		}, ErrOwnRole},
+		{"change admin permissions", func() error {
+			_, err := rbac.UpdateRole(as(admin), RoleParams{Name: models.RoleAdmin, Permissions: []string{models.PermUsersRead}})
+			return err
+		}, ErrSystemRole},
+		{"delete system role", func() error { return rbac.DeleteRole(as(admin), models.RoleUser) }, ErrSystemRole},
+		{"delete role in use", func() error { return rbac.DeleteRole(as(admin), "lead") }, ErrRoleInUse},
+		{"unknown permission", func() error {
+			_, err := rbac.CreateRole(as(admin), RoleParams{Name: "x1", Permissions: []string{"users:fly"}})
+			return err
+		}, ErrUnknownPermission},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.run(); err != tt.want {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	// audit của role: target_type = role, không lẫn với user cùng id
+	events, _, err := repos.Audit.List(repository.AuditQuery{TargetType: models.AuditTargetRole, TargetID: &lead.ID, Limit: 10})
+	if err != nil || len(events) != 1 || events[0].Action != models.AuditRoleCreate {
+		t.Fatalf("role audit = %+v, %v; want 1 role.create", events, err)
+	}
+	events, _, _ = repos.Audit.List(repository.AuditQuery{TargetType: models.AuditTargetUser, TargetID: &lead.ID, Limit: 10})
+	for _, e := range events {
+		if e.Action == models.AuditRoleCreate {
+			t.Errorf("role event %+v listed as user target", e)
+		}
+	}
+}
+
+// đổi quyền của role có hiệu lực ngay với RequirePermission dù cache còn hạn
+func TestRequirePermissionCacheInvalidation(t *testing.T) {
+	db := newTestDB(t)
+	rbac := NewRBACService(repository.NewMySQLRepos(db), RBACConfig{CacheTTL: time.Hour})
+	if _, err := rbac.CreateRole(Actor{}, RoleParams{Name: "viewer", Permissions: []string{models.PermUsersRead}}); err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/audit", func(c *gin.Context) { c.Set("role", "viewer") },
+		middleware.RequirePermission(rbac, models.PermAuditRead), func(c *gin.Context) { c.Status(200) })
+	status := func() int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/audit", nil))
+		return w.Code
+	}
+
+	if got := status(); got != 403 {
+		t.Fatalf("before grant: %d, want 403", got)
+	}
+	if _, err := rbac.UpdateRole(Actor{}, RoleParams{Name: "viewer", Permissions: []string{models.PermUsersRead, models.PermAuditRead}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := status(); got != 200 {
+		t.Fatalf("after grant: %d, want 200", got)
+	}
+	if _, err := rbac.UpdateRole(Actor{}, RoleParams{Name: "viewer", Permissions: []string{}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := status(); got != 403 {
+		t.Fatalf("after revoke: %d, want 403", got)
+	}
+
+	// sửa thẳng DB (instance khác) => cache giữ giá trị cũ tới khi hết TTL
+	var role models.Role
+	db.Where("name = ?", "viewer").First(&role)
+	perm := models.Permission{}
+	db.Where("name = ?", models.PermAuditRead).First(&perm)
+	if err := db.Model(&role).Association("Permissions").Append(&perm); err != nil {
+		t.Fatal(err)
+	}
+	if got := status(); got != 403 {
+		t.Errorf("cached: %d, want 403 until TTL", got)
+	}
+}
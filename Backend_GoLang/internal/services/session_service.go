@@ -0,0 +1,148 @@
+package services
+
+import (
+	"strings"
+	"time"
+
+	"crud_api_us/internal/models"
+	"crud_api_us/internal/repository"
+)
+
+// Session: 1 phiên đăng nhập = chuỗi refresh token rotate ra từ 1 lần đăng nhập (id = family)
+type Session struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"` // vd "Chrome trên Windows"
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`   // lúc đăng nhập
+	LastUsedAt time.Time `json:"last_used_at"` // lần refresh gần nhất
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"` // phiên của chính request này
+}
+
+// SessionService: xem/thu hồi phiên đăng nhập của user.
+// Thu hồi phiên = thu hồi refresh token; access token đã cấp còn dùng được tới khi hết hạn,
+// riêng RevokeAll tăng token version nên access token cũng mất hiệu lực ngay.
+type SessionService struct{ repos repository.Repos }
+
+func NewSessionService(r repository.Repos) *SessionService { return &SessionService{repos: r} }
+
+func sessionID(t models.RefreshToken) string {
+	if t.FamilyID == "" { // token từ trước khi có family
+		return t.TokenID
+	}
+	return t.FamilyID
+}
+
+// List: các phiên đang hoạt động của user; currentID = phiên của request (để đánh dấu current)
+func (s *SessionService) List(userID int, currentID string) ([]Session, error) {
+	rows, err := s.repos.Auth.ListSessions(userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Session, 0, len(rows))
+	for _, t := range rows {
+		started := t.StartedAt
+		if started.IsZero() {
+			started = t.CreatedAt
+		}
+		name := t.Name
+		if name == "" {
+			name = deviceName(t.UserAgent)
+		}
+		id := sessionID(t)
+		out = append(out, Session{
+			ID: id, Name: name, IP: t.IP, UserAgent: t.UserAgent,
+			CreatedAt: started, LastUsedAt: t.CreatedAt, ExpiresAt: t.ExpiresAt,
+			Current: currentID != "" && id == currentID,
+		})
+	}
+	return out, nil
+}
+
+// Revoke thu hồi 1 phiên của user (repository.ErrNotFound nếu không có)
+func (s *SessionService) Revoke(actor Actor, userID int, id string) error {
+	return s.repos.InTx(func(tx repository.Repos) error {
+		if err := guardUserTarget(tx, actor, userID); err != nil {
+			return err
+		}
+		if err := tx.Auth.RevokeSession(userID, id); err != nil {
+			return err
+		}
+		return writeAudit(tx.Audit, actor, models.AuditSessionRevoke, userID, map[string]AuditChange{
+			"session": {Before: id},
+		})
+	})
+}
+
+// RevokeOthers: "đăng xuất khỏi mọi thiết bị khác", giữ lại phiên keepID
+func (s *SessionService) RevokeOthers(actor Actor, userID int, keepID string) error {
+	return s.repos.InTx(func(tx repository.Repos) error {
+		if err := tx.Auth.RevokeRefreshTokensByUserExcept(userID, keepID); err != nil {
+			return err
+		}
+		return writeAudit(tx.Audit, actor, models.AuditSessionRevoke, userID, map[string]AuditChange{
+			"session": {Before: "others"},
+		})
+	})
+}
+
+// RevokeAll (admin, xử lý sự cố) thu hồi mọi phiên và vô hiệu access token đã cấp của user
+func (s *SessionService) RevokeAll(actor Actor, userID int) error {
+	return s.repos.InTx(func(tx repository.Repos) error {
+		if err := guardUserTarget(tx, actor, userID); err != nil {
+			return err
+		}
+		if err := revokeSessions(tx, userID); err != nil {
+			return err
+		}
+		return writeAudit(tx.Audit, actor, models.AuditSessionRevoke, userID, map[string]AuditChange{
+			"session": {Before: "all"},
+		})
+	})
+}
+
+// guardUserTarget: user userID phải tồn tại (ErrNotFound) và actor không có ít quyền hơn user đó
+func guardUserTarget(tx repository.Repos, actor Actor, userID int) error {
+	if actor.UserID == userID {
+		return nil
+	}
+	u, err := tx.Users.Get(userID)
+	if err != nil {
+		return err
+	}
+	return guardTarget(tx, actor, u)
+}
+
+// deviceName: tên thân thiện từ User-Agent, vd "Chrome trên Windows"
+func deviceName(ua string) string {
+	if ua == "" {
+		return "Không rõ thiết bị"
+	}
+	browser := "Trình duyệt"
+	for _, b := range []struct{ token, name string }{
+		// thứ tự quan trọng: Edge/Opera/Chrome đều chứa "Chrome", Chrome chứa "Safari"
+		{"Edg/", "Edge"}, {"OPR/", "Opera"}, {"Firefox/", "Firefox"}, {"FxiOS/", "Firefox"},
+		{"CriOS/", "Chrome"}, {"Chrome/", "Chrome"}, {"Safari/", "Safari"},
+		{"curl/", "curl"}, {"PostmanRuntime/", "Postman"}, {"okhttp/", "okhttp"},
+	} {
+		if strings.Contains(ua, b.token) {
+			browser = b.name
+			break
+		}
+	}
+	platform := ""
+	for _, o := range []struct{ token, name string }{
+		{"iPhone", "iPhone"}, {"iPad", "iPad"}, {"Android", "Android"},
+		{"Windows", "Windows"}, {"Mac OS X", "macOS"}, {"CrOS", "ChromeOS"}, {"Linux", "Linux"},
+	} {
+		if strings.Contains(ua, o.token) {
+			platform = o.name
+			break
+		}
+	}
+	if platform == "" {
+		return browser
+	}
+	return browser + " trên " + platform
+}
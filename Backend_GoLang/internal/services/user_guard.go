@@ -0,0 +1,127 @@
+package services
+
+import (
+	"errors"
+
+	"crud_api_us/internal/models"
+	"crud_api_us/internal/repository"
+)
+
+// Bất biến khi quản lý user (kiểm tra trong cùng transaction với thay đổi)
+var (
+	ErrLastAdmin           = errors.New("last_admin")            // thay đổi làm mất admin active cuối cùng
+	ErrConfirmRequired     = errors.New("confirmation_required") // tự hạ quyền / tự khoá / tự xoá mà chưa xác nhận
+	ErrRoleChangeDenied    = errors.New("role_change_denied")    // đổi role mà thiếu quyền users:role
+	ErrPrivilegeEscalation = errors.New("privilege_escalation")  // role/user đích có quyền vượt quá quyền của actor
+)
+
+// guardChange kiểm tra bất biến cho thay đổi before -> after của 1 user
+// (before nil = tạo mới, after nil = xoá). confirm: client đã xác nhận thao tác lên chính mình.
+//   - tự đổi role / tự khoá / tự xoá phải có confirm
+//   - đổi role cần quyền users:role và không gán được role có quyền vượt quá quyền của actor
+//   - không sửa/xoá được user có quyền vượt quá quyền của actor
+//   - luôn còn ít nhất 1 admin active
+func guardChange(tx repository.Repos, actor Actor, before, after *models.User, confirm bool) error {
+	roleChanged := after != nil &&
+		((before == nil && after.Role != models.RoleUser) || (before != nil && after.Role != before.Role))
+	self := before != nil && actor.UserID == before.ID
+
+	if self && !confirm &&
+		(after == nil || roleChanged || (after.Status != before.Status && checkStatus(*after) != nil)) {
+		return ErrConfirmRequired
+	}
+
+	// actor = 0: hệ thống / tự đăng ký (role mặc định) => không xét quyền
+	if actor.UserID > 0 && (roleChanged || (before != nil && !self)) {
+		granted, err := actorPermissions(tx, actor, before)
+		if err != nil {
+			return err
+		}
+		if roleChanged {
+			if !granted[models.PermUsersRole] {
+				return ErrRoleChangeDenied
+			}
+			if err := withinPermissions(tx, after.Role, granted); err != nil {
+				return err
+			}
+		}
+		if before != nil && !self {
+			if err := withinPermissions(tx, before.Role, granted); err != nil {
+				return err
+			}
+		}
+	}
+
+	if before != nil && isActiveAdmin(*before) && (after == nil || !isActiveAdmin(*after)) {
+		n, err := tx.Users.CountActiveAdmins(before.ID)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrLastAdmin
+		}
+	}
+	return nil
+}
+
+// guardTarget: actor thao tác quản trị lên user khác (thu hồi phiên, mở khoá, ...) phải có
+// đủ mọi quyền của role user đó, ngược lại ErrPrivilegeEscalation. Thao tác lên chính mình / hệ thống => bỏ qua.
+func guardTarget(tx repository.Repos, actor Actor, target models.User) error {
+	if actor.UserID <= 0 || actor.UserID == target.ID {
+		return nil
+	}
+	granted, err := actorPermissions(tx, actor, &target)
+	if err != nil {
+		return err
+	}
+	return withinPermissions(tx, target.Role, granted)
+}
+
+func isActiveAdmin(u models.User) bool {
+	return u.Role == models.RoleAdmin && checkStatus(u) == nil
+}
+
+// actorPermissions: quyền của actor theo role trong DB (không theo token). Actor tự sửa mình
+// => dùng role trước thay đổi (guardChange chạy sau khi đã ghi trong transaction).
+func actorPermissions(tx repository.Repos, actor Actor, before *models.User) (map[string]bool, error) {
+	if before != nil && before.ID == actor.UserID {
+		return rolePermissions(tx, before.Role)
+	}
+	u, err := tx.Users.Get(actor.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return map[string]bool{}, nil
+		}
+		return nil, err
+	}
+	return rolePermissions(tx, u.Role)
+}
+
+func rolePermissions(tx repository.Repos, role string) (map[string]bool, error) {
+	r, err := tx.Roles.Get(role)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return map[string]bool{}, nil
+		}
+		return nil, err
+	}
+	out := make(map[string]bool, len(r.Permissions))
+	for _, p := range r.Permissions {
+		out[p.Name] = true
+	}
+	return out, nil
+}
+
+// withinPermissions: mọi quyền của role phải nằm trong granted, ngược lại ErrPrivilegeEscalation
+func withinPermissions(tx repository.Repos, role string, granted map[string]bool) error {
+	perms, err := rolePermissions(tx, role)
+	if err != nil {
+		return err
+	}
+	for p := range perms {
+		if !granted[p] {
+			return ErrPrivilegeEscalation
+		}
+	}
+	return nil
+}
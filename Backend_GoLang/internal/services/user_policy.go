@@ -0,0 +1,67 @@
+package services
+
+import (
+	"strconv"
+	"strings"
+
+	"crud_api_us/internal/models"
+	"crud_api_us/internal/policy"
+	"crud_api_us/internal/repository"
+)
+
+// Các action của API quản lý user dùng trong file policy (POLICY_FILE)
+const (
+	ActionUserList     = "user:list" // resource = bộ lọc (role, status, country)
+	ActionUserRead     = "user:read"
+	ActionUserCreate   = "user:create" // resource = user sắp tạo
+	ActionUserUpdate   = "user:update"
+	ActionUserDelete   = "user:delete"
+	ActionUserRestore  = "user:restore"
+	ActionUserPurge    = "user:purge"
+	ActionUserLogins   = "user:logins"   // xem lịch sử đăng nhập
+	ActionUserSessions = "user:sessions" // xem / thu hồi phiên đăng nhập
+	ActionUserUnlock   = "user:unlock"   // mở khoá đăng nhập
+)
+
+// UserPolicy: hook ABAC cho API quản lý user, chạy sau RBAC (quyền theo role).
+// Subject = user đang gọi API (đọc DB để lấy country, email_domain, ...).
+type UserPolicy struct {
+	engine *policy.Engine
+	users  repository.UserRepository
+}
+
+func NewUserPolicy(e *policy.Engine, users repository.UserRepository) *UserPolicy {
+	return &UserPolicy{engine: e, users: users}
+}
+
+// Authorize hỏi engine cho action của uid (role theo access token) trên resource/changes
+func (p *UserPolicy) Authorize(uid int, role, action string, resource, changes policy.Attrs) (policy.Decision, error) {
+	subject := policy.Attrs{}
+	if u, err := p.users.Get(uid); err == nil {
+		subject = UserAttrs(u)
+	} else if err != repository.ErrNotFound {
+		return policy.Decision{}, err
+	}
+	subject["id"] = strconv.Itoa(uid)
+	subject["role"] = role
+	return p.engine.Evaluate(policy.Request{Subject: subject, Action: action, Resource: resource, Changes: changes}), nil
+}
+
+// UserAttrs: thuộc tính của user dùng trong điều kiện policy (subject.x / resource.x)
+func UserAttrs(u models.User) policy.Attrs {
+	a := policy.Attrs{
+		"role": u.Role, "status": u.Status, "country": u.Country,
+		"email_domain": emailDomain(u.Email), // "tổ chức" của user
+	}
+	if u.ID > 0 {
+		a["id"] = strconv.Itoa(u.ID)
+	}
+	return a
+}
+
+func emailDomain(email string) string {
+	if i := strings.LastIndexByte(email, '@'); i >= 0 {
+		return strings.ToLower(email[i+1:])
+	}
+	return ""
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+
+	"crud_api_us/internal/models"
+	"crud_api_us/internal/repository"
+)
+
+// SearchHit: kết quả tìm kiếm user + điểm liên quan + đoạn được đánh dấu <mark>
+type SearchHit struct {
+	User       models.User       `json:"user"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights,omitempty"` // field -> giá trị đã escape HTML + <mark>
+}
+
+// searchTerms tách q thành các từ, bỏ ký tự toán tử của FULLTEXT BOOLEAN MODE
+func searchTerms(q string) []string {
+	f := strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '@' || r == '.' || r == '_')
+	})
+	terms := make([]string, 0, len(f))
+	seen := map[string]bool{}
+	for _, t := range f {
+		t = strings.Trim(t, "@.")
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		terms = append(terms, t)
+	}
+	return terms
+}
+
+// Search: f giới hạn phạm vi tìm (đã được policy user:list kiểm tra như bộ lọc của List)
+func (s *UserService) Search(q string, f repository.UserFilter, limit int) ([]SearchHit, error) {
+	terms := searchTerms(q)
+	if len(terms) == 0 {
+		return nil, ErrBadInput
+	}
+	rows, err := s.repos.Users.Search(terms, f, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	hits := make([]SearchHit, 0, len(rows))
+	for _, r := range rows {
+		hl, n := highlightUser(r.User, terms)
+		score := r.Score
+		if score == 0 { // LIKE fallback: điểm = số lần khớp
+			score = float64(n)
+		}
+		hits = append(hits, SearchHit{User: r.User, Score: score, Highlights: hl})
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+	return hits, nil
+}
+
+// highlightUser đánh dấu các term trong những field được tìm kiếm, trả về số field khớp
+func highlightUser(u models.User, terms []string) (map[string]string, int) {
+	fields := map[string]string{
+		"full_name": u.FullName, "email": u.Email, "username": u.Username,
+		"phone": u.Phone, "city": u.City, "country": u.Country,
+	}
+	out := map[string]string{}
+	for name, v := range fields {
+		if h, ok := highlight(v, terms); ok {
+			out[name] = h
+		}
+	}
+	return out, len(out)
+}
+
+// highlight bọc các đoạn khớp (không phân biệt hoa thường) bằng <mark>…</mark>
+func highlight(v string, terms []string) (string, bool) {
+	if v == "" {
+		return "", false
+	}
+	lower := strings.ToLower(v)
+	if len(lower) != len(v) { // ToLower đổi độ dài byte (hiếm) => không đánh dấu được chính xác
+		return "", false
+	}
+	mark := make([]bool, len(v))
+	found := false
+	for _, t := range terms {
+		for i := 0; ; {
+			j := strings.Index(lower[i:], t)
+			if j < 0 {
+				break
+			}
+			for k := i + j; k < i+j+len(t); k++ {
+				mark[k] = true
+			}
+			found = true
+			i += j + len(t)
+		}
+	}
+	if !found {
+		return "", false
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(v); {
+		j := i
+		for j < len(v) && mark[j] == mark[i] {
+			j++
+		}
+		if mark[i] {
+			b.WriteString("<mark>" + html.EscapeString(v[i:j]) + "</mark>")
+		} else {
+			b.WriteString(html.EscapeString(v[i:j]))
+		}
+		i = j
+	}
+	return b.String(), true
+}
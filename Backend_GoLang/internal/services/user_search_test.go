@@ -0,0 +1,80 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"crud_api_us/internal/models"
+	"crud_api_us/internal/repository"
+)
+
+func TestSearchTerms(t *testing.T) {
+	tests := []struct {
+		q    string
+		want []string
+	}{
+		{"Nguyen  An", []string{"nguyen", "an"}},
+		{`+alice -bob "carol*" (dave)`, []string{"alice", "bob", "carol", "dave"}},
+		{"an@x.com an@x.com", []string{"an@x.com"}},
+		{"@. ..", []string{}},
+	}
+	for _, tt := range tests {
+		if got := searchTerms(tt.q); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("searchTerms(%q) = %q, want %q", tt.q, got, tt.want)
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		v     string
+		terms []string
+		want  string
+		ok    bool
+	}{
+		{"Nguyen Van An", []string{"an"}, "Nguyen V<mark>an</mark> <mark>An</mark>", true},
+		{"Anna", []string{"an", "nn"}, "<mark>Ann</mark>a", true},
+		{"<b>Bob</b>", []string{"bob"}, "&lt;b&gt;<mark>Bob</mark>&lt;/b&gt;", true},
+		{"Carol", []string{"an"}, "", false},
+		{"", []string{"an"}, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := highlight(tt.v, tt.terms)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("highlight(%q, %q) = %q, %v; want %q, %v", tt.v, tt.terms, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// LIKE fallback (SQLite): điểm = số field khớp, xếp giảm dần, kèm đoạn được đánh dấu
+func TestSearchRanksLikeFallback(t *testing.T) {
+	db := newTestDB(t)
+	repos := repository.NewMySQLRepos(db)
+	createUser(t, db, models.User{Username: "bob", Email: "bob@x.com", FullName: "Bob Hanoi"})
+	createUser(t, db, models.User{Username: "hanoi_fan", Email: "fan@hanoi.vn", FullName: "Fan", City: "Hanoi"})
+	createUser(t, db, models.User{Username: "carol", Email: "carol@x.com", City: "Hue"})
+	s := NewUserService(repos)
+
+	if _, err := s.Search(" @ ", repository.UserFilter{}, 20); err != ErrBadInput {
+		t.Errorf("empty query: %v, want ErrBadInput", err)
+	}
+
+	hits, err := s.Search("HANOI", repository.UserFilter{}, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 2 || hits[0].User.Username != "hanoi_fan" || hits[1].User.Username != "bob" {
+		t.Fatalf("hits = %+v", hits)
+	}
+	if hits[0].Score != 3 || hits[1].Score != 1 {
+		t.Errorf("scores = %v, %v; want 3, 1", hits[0].Score, hits[1].Score)
+	}
+	want := map[string]string{
+		"username": "<mark>hanoi</mark>_fan",
+		"email":    "fan@<mark>hanoi</mark>.vn",
+		"city":     "<mark>Hanoi</mark>",
+	}
+	if !reflect.DeepEqual(hits[0].Highlights, want) {
+		t.Errorf("highlights = %v, want %v", hits[0].Highlights, want)
+	}
+}
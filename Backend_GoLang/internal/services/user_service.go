@@ -17,9 +17,10 @@ var (
 	ErrBadInput  = errors.New("bad_input") // dữ liệu không hợp lệ
 )
 
-type UserService struct{ repo repository.UserRepository }
+// UserService: mọi thay đổi user chạy trong 1 transaction cùng với bản ghi audit
+type UserService struct{ repos repository.Repos }
 
-func NewUserService(r repository.UserRepository) *UserService { return &UserService{repo: r} }
+func NewUserService(r repository.Repos) *UserService { return &UserService{repos: r} }
 
 // ====== Helpers ======
 func hashPassword(pw string) (string, error) {
@@ -39,12 +40,16 @@ func parseDOB(s string) (*time.Time, error) {
 	}
 	return &t, nil
 }
+func isDuplicate(err error) bool {
+	return errors.Is(err, gorm.ErrDuplicatedKey) || strings.Contains(err.Error(), "Duplicate entry")
+}
 
 // ====== Service API ======
 type CreateParams struct {
 	Username, Email, Password, FullName, Phone, Gender, DOB,
 	AvatarURL, Street, City, State, Country, PostalCode,
 	Role, Status string
+	EmailVerified bool // admin tạo => coi như đã xác thực; tự đăng ký => phải bấm link
 }
 
 type UpdateParams struct {
@@ -53,11 +58,103 @@ type UpdateParams struct {
 	Role, Status string
 }
 
-func (s *UserService) List() ([]models.User, error)    { return s.repo.List() }
-func (s *UserService) Get(id int) (models.User, error) { return s.repo.Get(id) }
-func (s *UserService) Delete(id int) (bool, error)     { return s.repo.Delete(id) }
+func (s *UserService) Get(id int) (models.User, error) { return s.repos.Users.Get(id) }
+
+func (s *UserService) GetDeleted(id int) (models.User, error) { return s.repos.Users.GetDeleted(id) }
+
+func (s *UserService) List(q repository.UserQuery) (repository.UserPage, error) {
+	page, err := s.repos.Users.List(q)
+	if errors.Is(err, repository.ErrInvalidQuery) {
+		return repository.UserPage{}, ErrBadInput
+	}
+	return page, err
+}
+
+// version > 0: giá trị từ If-Match, sai lệch => repository.ErrVersionMismatch.
+// confirm: xác nhận tự xoá chính mình (xem guardChange).
+func (s *UserService) Delete(actor Actor, id, version int, confirm bool) (bool, error) {
+	var ok bool
+	err := s.repos.InTx(func(tx repository.Repos) error {
+		before, err := tx.Users.Get(id)
+		if err != nil {
+			if err == repository.ErrNotFound {
+				return nil
+			}
+			return err
+		}
+		if err := guardChange(tx, actor, &before, nil, confirm); err != nil {
+			return err
+		}
+		if ok, err = tx.Users.Delete(id, version); err != nil || !ok {
+			return err
+		}
+		// user đã xoá thì access token bị middleware chặn (không tìm thấy), chỉ cần thu hồi refresh
+		if err := tx.Auth.RevokeRefreshTokensByUser(id); err != nil {
+			return err
+		}
+		return writeAudit(tx.Audit, actor, models.AuditUserDelete, id, userDiff(&before, nil))
+	})
+	if err != nil {
+		return false, err
+	}
+	return ok, nil
+}
+
+// DeletedUser: user đã soft delete kèm thời điểm xoá
+type DeletedUser struct {
+	models.User
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+func (s *UserService) ListDeleted(f repository.UserFilter, page, limit int) ([]DeletedUser, int64, error) {
+	users, total, err := s.repos.Users.ListDeleted(f, (page-1)*limit, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	out := make([]DeletedUser, len(users))
+	for i, u := range users {
+		out[i] = DeletedUser{User: u, DeletedAt: u.DeletedAt.Time}
+	}
+	return out, total, nil
+}
+
+// Restore khôi phục user đã xoá; ErrDuplicate nếu username/email đã có user khác dùng
+func (s *UserService) Restore(actor Actor, id int) (models.User, error) {
+	var u models.User
+	err := s.repos.InTx(func(tx repository.Repos) error {
+		var err error
+		if u, err = tx.Users.Restore(id); err != nil {
+			return err
+		}
+		return writeAudit(tx.Audit, actor, models.AuditUserRestore, id, nil)
+	})
+	if err != nil {
+		if isDuplicate(err) {
+			return models.User{}, ErrDuplicate
+		}
+		return models.User{}, err
+	}
+	return u, nil
+}
+
+func (s *UserService) Purge(actor Actor, id int) error {
+	return s.repos.InTx(func(tx repository.Repos) error {
+		if err := tx.Users.Purge(id); err != nil {
+			return err
+		}
+		return writeAudit(tx.Audit, actor, models.AuditUserPurge, id, nil)
+	})
+}
+
+// revokeSessions vô hiệu hoá mọi access token (tăng token version) và refresh token của user
+func revokeSessions(tx repository.Repos, id int) error {
+	if err := tx.Users.IncrementTokenVersion(id); err != nil {
+		return err
+	}
+	return tx.Auth.RevokeRefreshTokensByUser(id)
+}
 
-func (s *UserService) Create(p CreateParams) (models.User, error) {
+func (s *UserService) Create(actor Actor, p CreateParams) (models.User, error) {
 	dob, err := parseDOB(p.DOB)
 	if err != nil {
 		return models.User{}, err
@@ -78,11 +175,28 @@ func (s *UserService) Create(p CreateParams) (models.User, error) {
 		DateOfBirth:  dob,
 		AvatarURL:    p.AvatarURL,
 		Street:       p.Street, City: p.City, State: p.State, Country: p.Country, PostalCode: p.PostalCode,
-		Role:   defaultIfEmpty(p.Role, "user"),
-		Status: defaultIfEmpty(p.Status, "active"),
+		Role:    defaultIfEmpty(p.Role, models.RoleUser),
+		Status:  defaultIfEmpty(p.Status, "active"),
+		Version: 1,
 	}
-	if err := s.repo.Create(&u); err != nil {
-		if errors.Is(err, gorm.ErrDuplicatedKey) || strings.Contains(err.Error(), "Duplicate entry") {
+	if p.EmailVerified {
+		now := time.Now()
+		u.EmailVerifiedAt = &now
+	}
+	err = s.repos.InTx(func(tx repository.Repos) error {
+		if err := checkRole(tx, u.Role); err != nil {
+			return err
+		}
+		if err := guardChange(tx, actor, nil, &u, false); err != nil {
+			return err
+		}
+		if err := tx.Users.Create(&u); err != nil {
+			return err
+		}
+		return writeAudit(tx.Audit, actor, models.AuditUserCreate, u.ID, userDiff(nil, &u))
+	})
+	if err != nil {
+		if isDuplicate(err) {
 			return models.User{}, ErrDuplicate
 		}
 		return models.User{}, err
@@ -90,7 +204,8 @@ func (s *UserService) Create(p CreateParams) (models.User, error) {
 	return u, nil
 }
 
-func (s *UserService) Update(id int, p UpdateParams) (models.User, error) {
+// confirm: xác nhận tự đổi role / tự khoá chính mình (xem guardChange)
+func (s *UserService) Update(actor Actor, id int, p UpdateParams, version int, confirm bool) (models.User, error) {
 	dob, err := parseDOB(p.DOB)
 	if err != nil {
 		return models.User{}, err
@@ -108,9 +223,32 @@ func (s *UserService) Update(id int, p UpdateParams) (models.User, error) {
 			return models.User{}, err
 		}
 	}
-	out, err := s.repo.Update(id, &u)
+	var out models.User
+	err = s.repos.InTx(func(tx repository.Repos) error {
+		before, err := tx.Users.Get(id)
+		if err != nil {
+			return err
+		}
+		if u.Role == "" {
+			u.Role = before.Role // không gửi role => giữ nguyên
+		} else if u.Role != before.Role {
+			if err := checkRole(tx, u.Role); err != nil {
+				return err
+			}
+		}
+		if u.Status == "" {
+			u.Status = before.Status // không gửi status => giữ nguyên
+		}
+		if out, err = tx.Users.Update(id, &u, version); err != nil {
+			return err
+		}
+		if err := guardChange(tx, actor, &before, &out, confirm); err != nil {
+			return err
+		}
+		return afterChange(tx, actor, before, out)
+	})
 	if err != nil {
-		if errors.Is(err, gorm.ErrDuplicatedKey) || strings.Contains(err.Error(), "Duplicate entry") {
+		if isDuplicate(err) {
 			return models.User{}, ErrDuplicate
 		}
 		return models.User{}, err
@@ -118,6 +256,200 @@ func (s *UserService) Update(id int, p UpdateParams) (models.User, error) {
 	return out, nil
 }
 
+// ChangePassword: user tự đổi mật khẩu (phải đúng mật khẩu hiện tại).
+// Refresh token của các phiên khác bị thu hồi; keepFamilyID là phiên hiện tại (rỗng = thu hồi tất cả).
+// Token version tăng => mọi access token đã cấp mất hiệu lực ngay, phiên hiện tại cần
+// AuthService.ReissueSession để nhận token mới.
+func (s *UserService) ChangePassword(actor Actor, id int, current, next, keepFamilyID string) error {
+	hash, err := hashPassword(next)
+	if err != nil {
+		return err
+	}
+	return s.repos.InTx(func(tx repository.Repos) error {
+		before, err := tx.Users.Get(id)
+		if err != nil {
+			return err
+		}
+		if bcrypt.CompareHashAndPassword([]byte(before.PasswordHash), []byte(current)) != nil {
+			return ErrInvalidCredentials
+		}
+		if err := tx.Auth.RevokeRefreshTokensByUserExcept(id, keepFamilyID); err != nil {
+			return err
+		}
+		if err := tx.Users.IncrementTokenVersion(id); err != nil {
+			return err
+		}
+		return updatePassword(tx, actor, before, hash)
+	})
+}
+
+// updatePassword ghi password_hash mới (đã qua hashPassword) và audit, trong transaction tx.
+// Caller tự thu hồi phiên (đổi mật khẩu giữ lại phiên hiện tại, reset thì thu hồi tất cả).
+func updatePassword(tx repository.Repos, actor Actor, before models.User, hash string) error {
+	out, err := tx.Users.UpdateColumns(before.ID, map[string]any{"password_hash": hash}, 0)
+	if err != nil {
+		return err
+	}
+	return auditChange(tx, actor, before, out)
+}
+
+// PatchParams: các field client gửi trong JSON Merge Patch (key = tên field JSON).
+// Giá trị nil = null tường minh => xoá giá trị của field.
+type PatchParams map[string]*string
+
+// Patch cập nhật một phần (RFC 7396): chỉ ghi các cột thực sự thay đổi.
+// confirm: xác nhận tự đổi role / tự khoá chính mình (xem guardChange).
+func (s *UserService) Patch(actor Actor, id int, p PatchParams, version int, confirm bool) (models.User, error) {
+	var out models.User
+	err := s.repos.InTx(func(tx repository.Repos) error {
+		before, err := tx.Users.Get(id)
+		if err != nil {
+			return err
+		}
+		if version > 0 && before.Version != version {
+			return repository.ErrVersionMismatch
+		}
+		cols, err := patchColumns(before, p)
+		if err != nil {
+			return err
+		}
+		if role, ok := cols["role"]; ok {
+			if err := checkRole(tx, role.(string)); err != nil {
+				return err
+			}
+		}
+		if _, ok := cols["email"]; ok && actor.UserID == id {
+			// tự đổi email => phải xác thực lại email mới
+			cols["email_verified_at"] = nil
+		}
+		if len(cols) == 0 {
+			out = before
+			return nil
+		}
+		if out, err = tx.Users.UpdateColumns(id, cols, version); err != nil {
+			return err
+		}
+		if err := guardChange(tx, actor, &before, &out, confirm); err != nil {
+			return err
+		}
+		return afterChange(tx, actor, before, out)
+	})
+	if err != nil {
+		if isDuplicate(err) {
+			return models.User{}, ErrDuplicate
+		}
+		return models.User{}, err
+	}
+	return out, nil
+}
+
+// patchColumns: các cột thực sự thay đổi so với before (key = tên cột)
+func patchColumns(before models.User, p PatchParams) (map[string]any, error) {
+	var err error
+	cols := map[string]any{}
+	for k, v := range p {
+		switch k {
+		case "password":
+			if v == nil {
+				return nil, ErrBadInput
+			}
+			hash, err := hashPassword(*v)
+			if err != nil {
+				return nil, err
+			}
+			cols["password_hash"] = hash
+		case "date_of_birth":
+			var dob *time.Time
+			if v != nil {
+				if dob, err = parseDOB(*v); err != nil {
+					return nil, err
+				}
+			}
+			if !sameDate(before.DateOfBirth, dob) {
+				cols["date_of_birth"] = dob
+			}
+		default:
+			cur, ok := userField(before, k)
+			if !ok {
+				return nil, ErrBadInput
+			}
+			val := ""
+			if v != nil {
+				val = *v
+			}
+			if k == "username" || k == "email" {
+				val = strings.TrimSpace(val)
+			}
+			if val != cur {
+				cols[k] = val
+			}
+		}
+	}
+	return cols, nil
+}
+
+// afterChange: ghi audit; vừa bị khoá (inactive/banned), đổi role hoặc bị admin đặt mật khẩu mới
+// => đá khỏi mọi phiên đang mở (access token mang role cũ không được dùng tiếp với quyền cũ,
+// người đang giữ phiên với mật khẩu cũ bị đăng xuất)
+func afterChange(tx repository.Repos, actor Actor, before, after models.User) error {
+	if (before.Status != after.Status && checkStatus(after) != nil) || before.Role != after.Role ||
+		before.PasswordHash != after.PasswordHash {
+		if err := revokeSessions(tx, after.ID); err != nil {
+			return err
+		}
+	}
+	return auditChange(tx, actor, before, after)
+}
+
+// auditChange ghi audit user.update cho thay đổi before -> after (không có thay đổi => bỏ qua)
+func auditChange(tx repository.Repos, actor Actor, before, after models.User) error {
+	diff := userDiff(&before, &after)
+	if len(diff) == 0 {
+		return nil
+	}
+	return writeAudit(tx.Audit, actor, models.AuditUserUpdate, after.ID, diff)
+}
+
+// userField: giá trị hiện tại của các cột chuỗi được phép patch (key = tên JSON = tên cột)
+func userField(u models.User, key string) (string, bool) {
+	switch key {
+	case "username":
+		return u.Username, true
+	case "email":
+		return u.Email, true
+	case "full_name":
+		return u.FullName, true
+	case "phone":
+		return u.Phone, true
+	case "gender":
+		return u.Gender, true
+	case "avatar_url":
+		return u.AvatarURL, true
+	case "street":
+		return u.Street, true
+	case "city":
+		return u.City, true
+	case "state":
+		return u.State, true
+	case "country":
+		return u.Country, true
+	case "postal_code":
+		return u.PostalCode, true
+	case "role":
+		return u.Role, true
+	case "status":
+		return u.Status, true
+	}
+	return "", false
+}
+
+func sameDate(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Format("2006-01-02") == b.Format("2006-01-02")
+}
+
 func defaultIfEmpty(s, def string) string {
 	if strings.TrimSpace(s) == "" {
 		return def
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"crud_api_us/internal/models"
+	"crud_api_us/internal/repository"
+)
+
+// UserStateChecker xác nhận user của access token vẫn được phép truy cập:
+// chưa bị xoá, đang active và token version khớp với DB.
+// Kết quả được cache theo ttl (ttl = 0 => luôn đọc DB).
+type UserStateChecker struct {
+	repo repository.UserRepository
+	ttl  time.Duration
+
+	mu    sync.Mutex
+	cache map[int]userState
+}
+
+type userState struct {
+	user    models.User
+	err     error // lỗi "nghiệp vụ" (không tồn tại); lỗi DB thì không cache
+	expires time.Time
+}
+
+func NewUserStateChecker(repo repository.UserRepository, ttl time.Duration) *UserStateChecker {
+	return &UserStateChecker{repo: repo, ttl: ttl, cache: map[int]userState{}}
+}
+
+// CheckUserState: reason = mã lý do token bị từ chối (token_revoked, account_banned, ...), "" nếu hợp lệ;
+// err = lỗi đọc DB
+func (c *UserStateChecker) CheckUserState(uid, ver int) (string, error) {
+	st, err := c.load(uid, false)
+	if err != nil {
+		return "", err
+	}
+	// token mới hơn cache (vừa đổi mật khẩu rồi được cấp lại token) => cache đã cũ, đọc lại DB
+	if st.err == nil && ver > st.user.TokenVersion {
+		if st, err = c.load(uid, true); err != nil {
+			return "", err
+		}
+	}
+	if st.err != nil {
+		return st.err.Error(), nil
+	}
+	if err := checkStatus(st.user); err != nil {
+		return err.Error(), nil
+	}
+	if st.user.TokenVersion != ver {
+		return ErrTokenRevoked.Error(), nil
+	}
+	return "", nil
+}
+
+// load đọc trạng thái user từ cache (còn hạn) hoặc DB; fresh = bỏ qua cache
+func (c *UserStateChecker) load(uid int, fresh bool) (userState, error) {
+	now := time.Now()
+	if c.ttl > 0 && !fresh {
+		c.mu.Lock()
+		st, ok := c.cache[uid]
+		c.mu.Unlock()
+		if ok && now.Before(st.expires) {
+			return st, nil
+		}
+	}
+
+	st := userState{expires: now.Add(c.ttl)}
+	u, err := c.repo.Get(uid)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		st.err = ErrTokenRevoked // user đã bị xoá
+	case err != nil:
+		return userState{}, err
+	default:
+		st.user = u
+	}
+
+	if c.ttl > 0 {
+		c.mu.Lock()
+		// dọn entry hết hạn để cache không phình theo số user từng đăng nhập
+		for k, v := range c.cache {
+			if now.After(v.expires) {
+				delete(c.cache, k)
+			}
+		}
+		c.cache[uid] = st
+		c.mu.Unlock()
+	}
+	return st, nil
+}
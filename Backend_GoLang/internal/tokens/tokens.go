@@ -0,0 +1,105 @@
+// Package tokens: cấp và xác thực access/refresh token (JWT).
+// Token phân biệt bằng claim typ; iss/aud được cấp theo cấu hình và bắt buộc khi xác thực
+// => refresh token (hay token của hệ thống khác dùng chung khoá) không dùng được làm bearer token.
+package tokens
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"time"
+
+	"crud_api_us/internal/keyset"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/joho/godotenv"
+)
+
+const (
+	TypeAccess  = "access"
+	TypeRefresh = "refresh"
+)
+
+var ErrInvalid = errors.New("invalid token")
+
+// Claims: nội dung access/refresh token
+type Claims struct {
+	UserID   int    `json:"uid"`
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	Version  int    `json:"ver"`           // = User.TokenVersion lúc cấp token
+	MFA      bool   `json:"mfa,omitempty"` // phiên đăng nhập đã qua bước 2FA
+	Type     string `json:"typ"`           // access | refresh
+	jwt.RegisteredClaims
+}
+
+type Config struct {
+	Issuer   string
+	Audience string
+	Leeway   time.Duration // độ lệch đồng hồ cho phép khi kiểm tra exp/nbf/iat
+}
+
+// LoadConfigFromEnv đọc JWT_ISSUER, JWT_AUDIENCE, JWT_LEEWAY
+func LoadConfigFromEnv() Config {
+	_ = godotenv.Load()
+	cfg := Config{Issuer: getEnv("JWT_ISSUER", "crud_api_us"), Audience: getEnv("JWT_AUDIENCE", "crud_api_us"), Leeway: 30 * time.Second}
+	if d, err := time.ParseDuration(os.Getenv("JWT_LEEWAY")); err == nil && d >= 0 {
+		cfg.Leeway = d
+	}
+	return cfg
+}
+
+func getEnv(k, def string) string {
+	if v := os.Getenv(k); v != "" {
+		return v
+	}
+	return def
+}
+
+type Manager struct {
+	keys *keyset.KeySet
+	cfg  Config
+}
+
+func NewManager(keys *keyset.KeySet, cfg Config) *Manager { return &Manager{keys: keys, cfg: cfg} }
+
+func (m *Manager) Keys() *keyset.KeySet { return m.keys }
+
+// Issue ký token loại typ cho claims (điền typ, iss, aud, sub, iat, exp)
+func (m *Manager) Issue(c Claims, typ, jti string, ttl time.Duration) (string, time.Time, error) {
+	now := time.Now()
+	exp := now.Add(ttl)
+	c.Type = typ
+	c.RegisteredClaims = jwt.RegisteredClaims{
+		Issuer:    m.cfg.Issuer,
+		Subject:   strconv.Itoa(c.UserID),
+		Audience:  jwt.ClaimStrings{m.cfg.Audience},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(exp),
+		ID:        jti,
+	}
+	signed, err := m.keys.Sign(&c)
+	return signed, exp, err
+}
+
+// Parse xác thực chữ ký, iss, aud, exp/iat (có leeway) và loại token
+func (m *Manager) Parse(tokenStr, typ string) (*Claims, error) {
+	var c Claims
+	tok, err := m.keys.Parse(tokenStr, &c,
+		jwt.WithIssuer(m.cfg.Issuer),
+		jwt.WithAudience(m.cfg.Audience),
+		jwt.WithLeeway(m.cfg.Leeway),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil || !tok.Valid {
+		return nil, ErrInvalid
+	}
+	if c.Type != typ || c.ID == "" || c.UserID <= 0 || c.Subject != strconv.Itoa(c.UserID) {
+		return nil, ErrInvalid
+	}
+	if typ == TypeAccess && c.Role == "" {
+		return nil, ErrInvalid
+	}
+	return &c, nil
+}
@@ -0,0 +1,107 @@
+package tokens
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"crud_api_us/internal/keyset"
+)
+
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+	keys, err := keyset.New(keyset.Config{Alg: "HS256", Secret: "test-secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewManager(keys, Config{Issuer: "api", Audience: "web", Leeway: 30 * time.Second})
+}
+
+func validClaims(now time.Time) Claims {
+	return Claims{
+		UserID: 7, Username: "alice", Role: "user", Version: 1, Type: TypeAccess,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer: "api", Subject: "7", Audience: jwt.ClaimStrings{"web"}, ID: "jti-1",
+			IssuedAt: jwt.NewNumericDate(now), ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+		},
+	}
+}
+
+func TestParse(t *testing.T) {
+	m := newTestManager(t)
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		typ    string
+		mutate func(c *Claims)
+		ok     bool
+	}{
+		{"valid access", TypeAccess, func(c *Claims) {}, true},
+		{"valid refresh", TypeRefresh, func(c *Claims) { c.Type = TypeRefresh }, true},
+		{"refresh token used as access", TypeAccess, func(c *Claims) { c.Type = TypeRefresh }, false},
+		{"access token used as refresh", TypeRefresh, func(c *Claims) {}, false},
+		{"missing typ", TypeAccess, func(c *Claims) { c.Type = "" }, false},
+		{"wrong iss", TypeAccess, func(c *Claims) { c.Issuer = "other" }, false},
+		{"wrong aud", TypeAccess, func(c *Claims) { c.Audience = jwt.ClaimStrings{"other"} }, false},
+		{"missing exp", TypeAccess, func(c *Claims) { c.ExpiresAt = nil }, false},
+		{"expired beyond leeway", TypeAccess, func(c *Claims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Minute)) }, false},
+		{"expired within leeway", TypeAccess, func(c *Claims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-10 * time.Second)) }, true},
+		{"issued in the future", TypeAccess, func(c *Claims) { c.IssuedAt = jwt.NewNumericDate(now.Add(time.Hour)) }, false},
+		{"missing jti", TypeAccess, func(c *Claims) { c.ID = "" }, false},
+		{"uid/sub mismatch", TypeAccess, func(c *Claims) { c.Subject = "8" }, false},
+		{"uid zero", TypeAccess, func(c *Claims) { c.UserID, c.Subject = 0, "0" }, false},
+		{"uid negative", TypeAccess, func(c *Claims) { c.UserID, c.Subject = -1, "-1" }, false},
+		{"access token without role", TypeAccess, func(c *Claims) { c.Role = "" }, false},
+		{"refresh token without role", TypeRefresh, func(c *Claims) { c.Type, c.Role = TypeRefresh, "" }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := validClaims(now)
+			tt.mutate(&c)
+			tok, err := m.keys.Sign(&c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := m.Parse(tok, tt.typ)
+			if tt.ok {
+				if err != nil || got.UserID != c.UserID {
+					t.Fatalf("Parse = %+v, %v; want ok", got, err)
+				}
+				return
+			}
+			if err != ErrInvalid {
+				t.Fatalf("Parse err = %v, want ErrInvalid", err)
+			}
+		})
+	}
+
+	// chữ ký của khoá khác
+	other, _ := keyset.New(keyset.Config{Alg: "HS256", Secret: "other-secret"})
+	c := validClaims(now)
+	tok, _ := other.Sign(&c)
+	if _, err := m.Parse(tok, TypeAccess); err != ErrInvalid {
+		t.Errorf("foreign signature: %v, want ErrInvalid", err)
+	}
+}
+
+func TestIssueRoundTrip(t *testing.T) {
+	m := newTestManager(t)
+	tok, exp, err := m.Issue(Claims{UserID: 42, Username: "bob", Role: "admin", Version: 3, MFA: true}, TypeAccess, "jti-9", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := m.Parse(tok, TypeAccess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Subject != strconv.Itoa(42) || c.Issuer != "api" || c.Audience[0] != "web" || c.ID != "jti-9" ||
+		c.Version != 3 || !c.MFA || !c.ExpiresAt.Time.Equal(exp.Truncate(time.Second)) {
+		t.Errorf("claims = %+v (exp %v)", c, exp)
+	}
+	if _, err := m.Parse(tok, TypeRefresh); err != ErrInvalid {
+		t.Errorf("access token accepted as refresh: %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// secret ASCII "12345678901234567890" của RFC 6238 (phụ lục B, SHA1)
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// RFC 6238 in mã 8 số; mã 6 số là 6 chữ số cuối
+var rfcVectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestCodeRFC6238(t *testing.T) {
+	for _, v := range rfcVectors {
+		got, err := Code(rfcSecret, Step(time.Unix(v.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != v.code {
+			t.Errorf("Code(t=%d) = %s, want %s", v.unix, got, v.code)
+		}
+	}
+	// secret thường / có khoảng trắng (người dùng gõ tay) vẫn nhận
+	if got, _ := Code(" gezdgnbvgy3tqojqgezdgnbvgy3tqojq ", 1); got != "287082" {
+		t.Errorf("lowercase secret: %s", got)
+	}
+	if _, err := Code("not base32!", 1); err == nil {
+		t.Error("invalid secret accepted")
+	}
+}
+
+func TestValidateRFC6238(t *testing.T) {
+	for _, v := range rfcVectors {
+		at := time.Unix(v.unix, 0)
+		step, ok := Validate(rfcSecret, v.code, at, 0)
+		if !ok || step != Step(at) {
+			t.Errorf("Validate(t=%d) = %d, %v; want %d, true", v.unix, step, ok, Step(at))
+		}
+	}
+	if _, ok := Validate(rfcSecret, "287 082", time.Unix(59, 0), 0); !ok {
+		t.Error("code with space rejected")
+	}
+	for _, bad := range []string{"", "28708", "2870820", "287083", "abcdef"} {
+		if _, ok := Validate(rfcSecret, bad, time.Unix(59, 0), 1); ok {
+			t.Errorf("Validate(%q) accepted", bad)
+		}
+	}
+}
+
+// lệch ±1 bước được chấp nhận (trả về đúng bước của mã), ±2 thì không
+func TestValidateSkewBoundary(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	base := Step(now)
+	for d := int64(-2); d <= 2; d++ {
+		code, _ := Code(rfcSecret, base+d)
+		step, ok := Validate(rfcSecret, code, now, 1)
+		want := d >= -1 && d <= 1
+		if ok != want {
+			t.Errorf("offset %+d: ok = %v, want %v", d, ok, want)
+			continue
+		}
+		// caller lưu bước này để chặn replay => phải là bước của mã, không phải bước hiện tại
+		if ok && step != base+d {
+			t.Errorf("offset %+d: step = %d, want %d", d, step, base+d)
+		}
+	}
+	// skew 0: chỉ đúng bước hiện tại
+	prev, _ := Code(rfcSecret, base-1)
+	if _, ok := Validate(rfcSecret, prev, now, 0); ok {
+		t.Error("skew 0 accepted previous step")
+	}
+}
@@ -2,6 +2,8 @@ import { BrowserRouter, Routes, Route } from "react-router-dom";
 import { Protected } from "./components/Protected";
 import Login from "./pages/auth/Login";
 import Register from "./pages/auth/Register";
+import ForgotPassword from "./pages/auth/ForgotPassword";
+import ResetPassword from "./pages/auth/ResetPassword";
 import UsersPage from "./pages/admin/UsersPage";
 import Home from "./pages/user/Home";
 
@@ -11,6 +13,8 @@ export default function App(){
       <Routes>
         <Route path="/login" element={<Login/>}/>
         <Route path="/register" element={<Register/>}/>
+        <Route path="/forgot-password" element={<ForgotPassword/>}/>
+        <Route path="/reset-password" element={<ResetPassword/>}/>
         <Route path="/admin" element={<Protected allow={["admin"]}><UsersPage/></Protected>}/>
         <Route path="/app" element={<Protected><Home/></Protected>}/>
         <Route path="*" element={<Login/>}/>
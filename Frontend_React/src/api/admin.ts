@@ -1,8 +1,8 @@
 import { api, authHeader } from "./http";
-import type { User } from "../types";
+import type { Page, User } from "../types";
 
 export type Gender = "male" | "female" | "other";
-export type Role = "user" | "admin";
+export type Role = "user" | "admin" | string; // tên role trong bảng roles (GET /admin/roles)
 export type Status = "active" | "inactive" | "banned" | string;
 
 export interface BaseUserInput {
@@ -30,16 +30,74 @@ export interface UpdateUserInput extends BaseUserInput {
   password?: string;
 }
 
-export async function getUsers(): Promise<User[]> {
-  const r = await api("/admin/users", { headers: authHeader() });
+export interface ListUsersParams {
+  page?: number;
+  limit?: number;
+  cursor?: string;
+  sort?: "id" | "username" | "email" | "full_name" | "created_at" | "updated_at";
+  order?: "asc" | "desc";
+  role?: Role;
+  status?: Status;
+  gender?: Gender;
+  country?: string;
+  created_from?: string;        // yyyy-mm-dd
+  created_to?: string;          // yyyy-mm-dd
+  q?: string;
+}
+
+export async function getUsers(params: ListUsersParams = {}): Promise<Page<User>> {
+  const qs = new URLSearchParams();
+  Object.entries(params).forEach(([k, v]) => {
+    if (v !== undefined && v !== "") qs.set(k, String(v));
+  });
+  const r = await api(`/admin/users?${qs}`, { headers: authHeader() });
+  if (!r.ok) throw new Error(await r.text());
+  return (await r.json()) as Page<User>;
+}
+
+export interface RoleInfo {
+  id: number;
+  name: string;
+  description: string;
+  system: boolean;
+  permissions: { name: string; description: string }[] | null;
+}
+
+export async function getRoles(): Promise<RoleInfo[]> {
+  const r = await api("/admin/roles", { headers: authHeader() });
   if (!r.ok) throw new Error(await r.text());
-  return (await r.json()) as User[];
+  return (await r.json()) as RoleInfo[];
 }
 
 export async function getUser(id: number): Promise<User> {
+  return (await getUserWithETag(id)).user;
+}
+
+/** Lấy user kèm ETag để gửi lại qua If-Match khi sửa/xoá (tránh ghi đè lẫn nhau) */
+export async function getUserWithETag(id: number): Promise<{ user: User; etag: string | null }> {
   const r = await api(`/admin/users/${id}`, { headers: authHeader() });
   if (!r.ok) throw new Error(await r.text());
-  return (await r.json()) as User;
+  return { user: (await r.json()) as User, etag: r.headers.get("ETag") };
+}
+
+/** Lỗi 412: user đã bị người khác sửa kể từ lúc tải */
+export class PreconditionFailedError extends Error {}
+/** 422 confirmation_required: tự đổi role/status hoặc tự xoá => gọi lại với confirm = true */
+export class ConfirmationRequiredError extends Error {}
+
+async function throwWriteError(r: Response): Promise<never> {
+  const text = await r.text();
+  if (r.status === 412) throw new PreconditionFailedError(text);
+  if (r.status === 422 && text.includes("confirmation_required")) throw new ConfirmationRequiredError(text);
+  throw new Error(text);
+}
+
+function confirmQuery(confirm?: boolean): string {
+  return confirm ? "?confirm=true" : "";
+}
+
+function ifMatch(etag?: string | null): Record<string, string> {
+  return etag ? { "If-Match": etag } : {};
 }
 
 export async function createUser(input: CreateUserInput): Promise<User> {
@@ -52,17 +110,41 @@ export async function createUser(input: CreateUserInput): Promise<User> {
   return (await r.json()) as User;
 }
 
-export async function updateUser(id: number, input: UpdateUserInput): Promise<User> {
-  const r = await api(`/admin/users/${id}`, {
+export async function updateUser(
+  id: number,
+  input: UpdateUserInput,
+  etag?: string | null,
+  confirm?: boolean
+): Promise<User> {
+  const r = await api(`/admin/users/${id}${confirmQuery(confirm)}`, {
     method: "PUT",
-    headers: authHeader(),
+    headers: { ...authHeader(), ...ifMatch(etag) },
     body: JSON.stringify(input),
   });
-  if (!r.ok) throw new Error(await r.text());
+  if (!r.ok) await throwWriteError(r);
   return (await r.json()) as User;
 }
 
-export async function deleteUser(id: number): Promise<void> {
-  const r = await api(`/admin/users/${id}`, { method: "DELETE", headers: authHeader() });
-  if (!r.ok) throw new Error(await r.text());
+/** PATCH (JSON Merge Patch): chỉ gửi field cần đổi, null = xoá giá trị */
+export async function patchUser(
+  id: number,
+  patch: Partial<Record<keyof UpdateUserInput, string | null>>,
+  etag?: string | null,
+  confirm?: boolean
+): Promise<User> {
+  const r = await api(`/admin/users/${id}${confirmQuery(confirm)}`, {
+    method: "PATCH",
+    headers: { ...authHeader(), ...ifMatch(etag), "Content-Type": "application/merge-patch+json" },
+    body: JSON.stringify(patch),
+  });
+  if (!r.ok) await throwWriteError(r);
+  return (await r.json()) as User;
+}
+
+export async function deleteUser(id: number, etag?: string | null, confirm?: boolean): Promise<void> {
+  const r = await api(`/admin/users/${id}${confirmQuery(confirm)}`, {
+    method: "DELETE",
+    headers: { ...authHeader(), ...ifMatch(etag) },
+  });
+  if (!r.ok) await throwWriteError(r);
 }
@@ -18,8 +18,25 @@ export async function login(identifier: string, password: string) {
   });
   if (!r.ok) throw new Error(await r.text());
   const data = await r.json();
+  // tài khoản bật 2FA: chưa có token, cần gửi mã qua loginMFA
+  if (data.mfa_required) return { mfaToken: data.mfa_token as string };
   localStorage.setItem("token", data.access_token);
-  return data.user as { id: number; username: string; email: string; role: "user" | "admin" };
+  return { user: data.user as LoginUser };
+}
+
+export type LoginUser = { id: number; username: string; email: string; role: "user" | "admin" };
+
+// bước 2: mã 6 số trong app authenticator hoặc mã khôi phục
+export async function loginMFA(mfaToken: string, code: string) {
+  const r = await api("/auth/login/mfa", {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ mfa_token: mfaToken, code }),
+  });
+  if (!r.ok) throw new Error(await r.text());
+  const data = await r.json();
+  localStorage.setItem("token", data.access_token);
+  return data.user as LoginUser;
 }
 
 export async function me() {
@@ -27,3 +44,22 @@ export async function me() {
   if (!r.ok) throw new Error(await r.text());
   return r.json();
 }
+
+// luôn thành công (202) dù email có tồn tại hay không
+export async function forgotPassword(email: string) {
+  const r = await api("/auth/password/forgot", {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ email }),
+  });
+  if (!r.ok) throw new Error(await r.text());
+}
+
+export async function resetPassword(token: string, newPassword: string) {
+  const r = await api("/auth/password/reset", {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ token, new_password: newPassword }),
+  });
+  if (!r.ok) throw new Error(await r.text());
+}
@@ -20,6 +20,32 @@ export function authHeader(): Record<string, string> {
   return h;
 }
 
+// Gộp các lần refresh song song thành 1 request (refresh token bị rotate mỗi lần gọi)
+let refreshing: Promise<boolean> | null = null;
+
+/** Gọi /auth/refresh (cookie HttpOnly) để lấy access token mới */
+export function refreshAccessToken(): Promise<boolean> {
+  if (!refreshing) {
+    refreshing = (async () => {
+      try {
+        const r = await fetch(`${API_BASE}/auth/refresh`, {
+          method: "POST",
+          credentials: "include",
+        });
+        if (!r.ok) return false;
+        const data = await r.json();
+        localStorage.setItem("token", data.access_token);
+        return true;
+      } catch {
+        return false;
+      } finally {
+        refreshing = null;
+      }
+    })();
+  }
+  return refreshing;
+}
+
 /** Wrapper fetch gọn với headers dạng Record<string,string> */
 export async function api(
   path: string,
@@ -33,12 +59,21 @@ export async function api(
   // Ghép URL an toàn (nếu path không có / đầu thì tự thêm)
   const url = `${API_BASE}${path.startsWith("/") ? "" : "/"}${path}`;
 
-  const res = await fetch(url, {
+  let res = await fetch(url, {
     ...init,
     headers,
     credentials: "include",
   });
 
+  // access token hết hạn -> thử refresh 1 lần rồi gọi lại với token mới
+  if (res.status === 401 && headers.Authorization && (await refreshAccessToken())) {
+    res = await fetch(url, {
+      ...init,
+      headers: { ...headers, ...authHeader() },
+      credentials: "include",
+    });
+  }
+
   if (res.status === 401) {
     localStorage.removeItem("token");
   }
@@ -0,0 +1,82 @@
+import { api, authHeader } from "./http";
+import type { LoginUser } from "./auth";
+
+// WebAuthn dùng ArrayBuffer, BE trao đổi dạng base64url
+function toBuf(s: string): ArrayBuffer {
+  const b64 = s.replace(/-/g, "+").replace(/_/g, "/").padEnd(Math.ceil(s.length / 4) * 4, "=");
+  return Uint8Array.from(atob(b64), (c) => c.charCodeAt(0)).buffer;
+}
+
+function toB64url(buf: ArrayBuffer | null): string | undefined {
+  if (!buf) return undefined;
+  let s = "";
+  new Uint8Array(buf).forEach((b) => (s += String.fromCharCode(b)));
+  return btoa(s).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+}
+
+/* eslint-disable @typescript-eslint/no-explicit-any */
+export function passkeySupported() {
+  return typeof window !== "undefined" && !!window.PublicKeyCredential;
+}
+
+// đăng ký passkey cho tài khoản đang đăng nhập
+export async function registerPasskey(name: string) {
+  const r = await api("/auth/webauthn/register/begin", { method: "POST", headers: authHeader() });
+  if (!r.ok) throw new Error(await r.text());
+  const { session_id, options } = await r.json();
+  const pk = options.publicKey;
+  pk.challenge = toBuf(pk.challenge);
+  pk.user.id = toBuf(pk.user.id);
+  pk.excludeCredentials = (pk.excludeCredentials || []).map((c: any) => ({ ...c, id: toBuf(c.id) }));
+
+  const cred = (await navigator.credentials.create({ publicKey: pk })) as PublicKeyCredential;
+  const res = cred.response as AuthenticatorAttestationResponse;
+  const q = new URLSearchParams({ session_id, name });
+  const f = await api(`/auth/webauthn/register/finish?${q}`, {
+    method: "POST",
+    headers: authHeader(),
+    body: JSON.stringify({
+      id: cred.id,
+      rawId: toB64url(cred.rawId),
+      type: cred.type,
+      response: {
+        clientDataJSON: toB64url(res.clientDataJSON),
+        attestationObject: toB64url(res.attestationObject),
+        transports: res.getTransports?.() ?? [],
+      },
+    }),
+  });
+  if (!f.ok) throw new Error(await f.text());
+  return f.json();
+}
+
+// đăng nhập không mật khẩu (trình duyệt tự hiện danh sách passkey)
+export async function loginWithPasskey() {
+  const r = await api("/auth/webauthn/login/begin", { method: "POST" });
+  if (!r.ok) throw new Error(await r.text());
+  const { session_id, options } = await r.json();
+  const pk = options.publicKey;
+  pk.challenge = toBuf(pk.challenge);
+  pk.allowCredentials = (pk.allowCredentials || []).map((c: any) => ({ ...c, id: toBuf(c.id) }));
+
+  const cred = (await navigator.credentials.get({ publicKey: pk })) as PublicKeyCredential;
+  const res = cred.response as AuthenticatorAssertionResponse;
+  const f = await api(`/auth/webauthn/login/finish?session_id=${encodeURIComponent(session_id)}`, {
+    method: "POST",
+    body: JSON.stringify({
+      id: cred.id,
+      rawId: toB64url(cred.rawId),
+      type: cred.type,
+      response: {
+        clientDataJSON: toB64url(res.clientDataJSON),
+        authenticatorData: toB64url(res.authenticatorData),
+        signature: toB64url(res.signature),
+        userHandle: toB64url(res.userHandle),
+      },
+    }),
+  });
+  if (!f.ok) throw new Error(await f.text());
+  const data = await f.json();
+  localStorage.setItem("token", data.access_token);
+  return data.user as LoginUser;
+}
@@ -3,6 +3,7 @@ import Input from "../../components/ui/Input";
 import Select from "../../components/ui/Select";
 import Button from "../../components/ui/Button";
 import type { User } from "../../types";
+import { getRoles } from "../../api/admin";
 import type { CreateUserInput, UpdateUserInput } from "../../api/admin";
 
 type Mode = "create" | "edit";
@@ -25,7 +26,7 @@ export default function UserForm({
     username: string; email: string; password: string; confirm: string;
     full_name?: string; phone?: string; gender?: string; date_of_birth?: string;
     avatar_url?: string; street?: string; city?: string; state?: string;
-    country?: string; postal_code?: string; role?: "user"|"admin"; status?: string;
+    country?: string; postal_code?: string; role?: string; status?: string;
   }>({
     username: "", email: "", password: "", confirm: "",
     full_name: "", phone: "", gender: "", date_of_birth: "",
@@ -37,6 +38,12 @@ export default function UserForm({
   const [preview, setPreview] = useState<string | null>(null);
   const [err, setErr] = useState("");
   const [busy, setBusy] = useState(false);
+  // danh sách role từ BE (không có quyền roles:read => chỉ hiện 2 role mặc định)
+  const [roles, setRoles] = useState<string[]>(["user", "admin"]);
+
+  useEffect(() => {
+    getRoles().then(rs => setRoles(rs.map(r => r.name))).catch(() => {});
+  }, []);
 
   useEffect(() => {
     if (mode === "edit" && initial) {
@@ -187,9 +194,9 @@ export default function UserForm({
           </div>
           <div>
             <label className="label">Role</label>
-            <Select value={v.role} onChange={e=>setV(s=>({...s, role: e.target.value as "user"|"admin"}))}>
-              <option value="user">user</option>
-              <option value="admin">admin</option>
+            <Select value={v.role} onChange={e=>setV(s=>({...s, role: e.target.value}))}>
+              {roles.includes(v.role || "") ? null : <option value={v.role}>{v.role}</option>}
+              {roles.map(r => <option key={r} value={r}>{r}</option>)}
             </Select>
           </div>
         </div>
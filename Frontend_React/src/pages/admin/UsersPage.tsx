@@ -1,5 +1,13 @@
-import { useEffect, useMemo, useState } from "react";
-import { getUsers, createUser, updateUser, deleteUser } from "../../api/admin";
+import { useEffect, useState } from "react";
+import {
+  getUsers,
+  getUserWithETag,
+  createUser,
+  updateUser,
+  deleteUser,
+  PreconditionFailedError,
+  ConfirmationRequiredError,
+} from "../../api/admin";
 import type { Role, Status, User } from "../../types";
 // Nếu file ../../api/admin export các kiểu input, bỏ comment 2 dòng dưới để type chặt chẽ hơn:
 // import type { CreateUserInput, UpdateUserInput } from "../../api/admin";
@@ -44,9 +52,14 @@ function IconTrash(props: React.SVGProps<SVGSVGElement>) {
 /* ===== Tabs ===== */
 const STATUS_TABS: (Status | "all")[] = ["all", "active", "inactive", "banned"];
 
+const PAGE_SIZE = 20;
+
 export default function UsersPage() {
   const [rows, setRows] = useState<User[]>([]);
+  const [total, setTotal] = useState(0);
+  const [page, setPage] = useState(1);
   const [q, setQ] = useState("");
+  const [debouncedQ, setDebouncedQ] = useState("");
   const [role, setRole] = useState<Role | "all">("all");
   const [status, setStatus] = useState<Status | "all">("all");
   const [loading, setLoading] = useState(true);
@@ -54,60 +67,82 @@ export default function UsersPage() {
   // modal
   const [openCreate, setOpenCreate] = useState(false);
   const [editUser, setEditUser] = useState<User | null>(null);
+  const [editETag, setEditETag] = useState<string | null>(null);
   const [delUser, setDelUser] = useState<User | null>(null);
 
+  // chờ người dùng gõ xong mới gọi API
   useEffect(() => {
-    setLoading(true);
-    getUsers().then(setRows).finally(() => setLoading(false));
-  }, []);
-
-  const statusCount = useMemo(() => {
-  // Khởi tạo đủ key để đảm bảo luôn có số
-  const m: Record<Status | "all", number> = {
-    all: rows.length,
-    active: 0,
-    inactive: 0,
-    banned: 0,
-  };
+    const t = setTimeout(() => setDebouncedQ(q.trim()), 300);
+    return () => clearTimeout(t);
+  }, [q]);
 
-  rows.forEach((r) => {
-    // ✅ fallback nếu r.status có thể undefined
-    const key = (r.status ?? "active") as Status;
-    m[key] = (m[key] ?? 0) + 1;
-  });
-
-  return m;
-}, [rows]);
+  // đổi bộ lọc -> quay về trang 1
+  useEffect(() => setPage(1), [debouncedQ, role, status]);
 
+  // Lọc / phân trang phía server
+  useEffect(() => {
+    setLoading(true);
+    getUsers({
+      page,
+      limit: PAGE_SIZE,
+      q: debouncedQ,
+      role: role === "all" ? undefined : role,
+      status: status === "all" ? undefined : status,
+    })
+      .then((p) => {
+        setRows(p.items);
+        setTotal(p.total);
+      })
+      .finally(() => setLoading(false));
+  }, [page, debouncedQ, role, status]);
 
-  const data = useMemo(() => {
-    const qLower = q.toLowerCase();
-    return rows.filter((u) => {
-      const matchQ = [u.username, u.full_name, u.email, u.phone]
-        .filter(Boolean)
-        .some((v) => (v || "").toLowerCase().includes(qLower));
-      const matchRole = role === "all" || u.role === role;
-      const matchStatus = status === "all" || u.status === status;
-      return matchQ && matchRole && matchStatus;
-    });
-  }, [rows, q, role, status]);
+  const totalPages = Math.max(1, Math.ceil(total / PAGE_SIZE));
 
   // Nếu đã import CreateUserInput/UpdateUserInput ở trên, thay `any` bằng các kiểu đó
   async function handleCreate(payload: any /* CreateUserInput */) {
     const u = await createUser(payload);
     setRows((s) => [u, ...s]);
+    setTotal((t) => t + 1);
     setOpenCreate(false);
   }
-  async function handleUpdate(payload: any /* UpdateUserInput */) {
+  // Mở form sửa: tải bản mới nhất + ETag để PUT kèm If-Match
+  async function openEdit(u: User) {
+    const { user, etag } = await getUserWithETag(u.id);
+    setEditETag(etag);
+    setEditUser(user);
+  }
+  async function handleUpdate(payload: any /* UpdateUserInput */, confirm = false) {
     if (!editUser) return;
-    const u = await updateUser(editUser.id, payload);
-    setRows((s) => s.map((x) => (x.id === u.id ? u : x)));
-    setEditUser(null);
+    try {
+      const u = await updateUser(editUser.id, payload, editETag, confirm);
+      setRows((s) => s.map((x) => (x.id === u.id ? u : x)));
+      setEditUser(null);
+    } catch (e) {
+      if (e instanceof PreconditionFailedError) {
+        alert("Người dùng vừa được người khác cập nhật. Dữ liệu mới nhất sẽ được tải lại.");
+        await openEdit(editUser);
+        return;
+      }
+      if (e instanceof ConfirmationRequiredError) {
+        if (window.confirm("Bạn đang đổi vai trò/trạng thái của chính mình và có thể mất quyền truy cập. Tiếp tục?")) {
+          await handleUpdate(payload, true);
+        }
+        return;
+      }
+      throw e;
+    }
   }
   async function handleDelete() {
     if (!delUser) return;
-    await deleteUser(delUser.id);
+    try {
+      await deleteUser(delUser.id);
+    } catch (e) {
+      if (!(e instanceof ConfirmationRequiredError)) throw e;
+      if (!window.confirm("Bạn đang xoá tài khoản của chính mình. Tiếp tục?")) return;
+      await deleteUser(delUser.id, null, true);
+    }
     setRows((s) => s.filter((x) => x.id !== delUser.id));
+    setTotal((t) => t - 1);
     setDelUser(null);
   }
 
@@ -148,12 +183,11 @@ export default function UsersPage() {
                 }`}
             >
               <span className="capitalize">{s === "all" ? "Tất cả" : s}</span>
-              <span
-                className={`ml-2 inline-block px-2 py-0.5 rounded-full text-xs
-                ${active ? "bg-indigo-100 text-indigo-700" : "bg-gray-100 text-gray-600"}`}
-              >
-                {statusCount[s]}
-              </span>
+              {active && (
+                <span className="ml-2 inline-block px-2 py-0.5 rounded-full text-xs bg-indigo-100 text-indigo-700">
+                  {total}
+                </span>
+              )}
             </button>
           );
         })}
@@ -221,7 +255,7 @@ export default function UsersPage() {
             )}
 
             {!loading &&
-              data.map((u) => (
+              rows.map((u) => (
                 <tr key={u.id} className="hover:bg-indigo-50/40 transition">
                   <td className="px-4 py-3">{u.id}</td>
                   <td className="px-4 py-3">
@@ -259,7 +293,7 @@ export default function UsersPage() {
                         className={iconBtn}
                         aria-label="Sửa"
                         title="Sửa"
-                        onClick={() => setEditUser(u)}
+                        onClick={() => openEdit(u)}
                       >
                         <IconPencil className="h-5 w-5" />
                       </button>
@@ -276,7 +310,7 @@ export default function UsersPage() {
                 </tr>
               ))}
 
-            {!loading && data.length === 0 && (
+            {!loading && rows.length === 0 && (
               <tr>
                 <td colSpan={8} className="px-4 py-10 text-center text-gray-500">
                   Không có dữ liệu
@@ -287,6 +321,27 @@ export default function UsersPage() {
         </table>
       </div>
 
+      {/* Phân trang */}
+      <div className="mt-3 flex items-center justify-end gap-2 text-sm text-gray-600">
+        <span>
+          Trang {page} / {totalPages} · {total} người dùng
+        </span>
+        <button
+          className="h-9 px-3 rounded-lg bg-white border border-gray-200 hover:bg-gray-50 disabled:opacity-50"
+          disabled={page <= 1 || loading}
+          onClick={() => setPage((p) => p - 1)}
+        >
+          Trước
+        </button>
+        <button
+          className="h-9 px-3 rounded-lg bg-white border border-gray-200 hover:bg-gray-50 disabled:opacity-50"
+          disabled={page >= totalPages || loading}
+          onClick={() => setPage((p) => p + 1)}
+        >
+          Sau
+        </button>
+      </div>
+
       {/* Create */}
       <Modal open={openCreate} onClose={() => setOpenCreate(false)} title="Thêm người dùng" size="xl">
         <UserForm mode="create" onSubmit={handleCreate} onCancel={() => setOpenCreate(false)} />
@@ -295,7 +350,7 @@ export default function UsersPage() {
       {/* Edit */}
       <Modal open={!!editUser} onClose={() => setEditUser(null)} title="Chỉnh sửa người dùng" size="xl">
         {editUser && (
-          <UserForm mode="edit" initial={editUser} onSubmit={handleUpdate} onCancel={() => setEditUser(null)} />
+          <UserForm key={editETag ?? editUser.id} mode="edit" initial={editUser} onSubmit={handleUpdate} onCancel={() => setEditUser(null)} />
         )}
       </Modal>
 
@@ -0,0 +1,66 @@
+import { useState } from "react";
+import { Link } from "react-router-dom";
+import { forgotPassword } from "../../api/auth";
+import Button from "../../components/ui/Button";
+import Input from "../../components/ui/Input";
+
+export default function ForgotPassword() {
+  const [email, setEmail] = useState<string>("");
+  const [sent, setSent] = useState(false);
+  const [err, setErr] = useState<string>("");
+
+  async function onSubmit(e: React.FormEvent<HTMLFormElement>) {
+    e.preventDefault();
+    setErr("");
+    if (!/^\S+@\S+\.\S+$/.test(email)) return setErr("Email không hợp lệ.");
+    try {
+      await forgotPassword(email);
+      setSent(true);
+    } catch {
+      setErr("Không gửi được yêu cầu. Vui lòng thử lại.");
+    }
+  }
+
+  return (
+    <div className="relative auth-bg">
+      <span className="auth-overlay" />
+      <div className="relative z-10 min-h-screen flex items-center justify-center p-4">
+        <div className="auth-card fade-in-up">
+          <div className="mb-6 text-center">
+            <h1 className="text-2xl font-semibold">Quên mật khẩu</h1>
+            <p className="text-sm text-gray-500">Nhập email để nhận link đặt lại mật khẩu</p>
+          </div>
+
+          {sent ? (
+            <p className="text-sm text-gray-700 text-center">
+              Nếu email tồn tại trong hệ thống, chúng tôi đã gửi link đặt lại mật khẩu. Hãy kiểm tra hộp thư.
+            </p>
+          ) : (
+            <form onSubmit={onSubmit} className="space-y-4">
+              <div>
+                <label className="label">Email</label>
+                <Input
+                  autoComplete="email"
+                  value={email}
+                  onChange={(e) => setEmail(e.target.value)}
+                  placeholder="you@example.com"
+                  required
+                />
+              </div>
+
+              {err && <p className="text-red-600 text-sm">{err}</p>}
+
+              <Button type="submit" className="w-full">Gửi link</Button>
+            </form>
+          )}
+
+          <p className="mt-4 text-sm text-gray-500 text-center">
+            <Link className="text-indigo-600 hover:underline font-medium" to="/login">
+              Quay lại đăng nhập
+            </Link>
+          </p>
+        </div>
+      </div>
+    </div>
+  );
+}
@@ -1,5 +1,6 @@
 import { useState } from "react";
-import { login } from "../../api/auth";
+import { login, loginMFA } from "../../api/auth";
+import { loginWithPasskey, passkeySupported } from "../../api/passkey";
 import { useNavigate, Link } from "react-router-dom";
 import Button from "../../components/ui/Button";
 import Input from "../../components/ui/Input";
@@ -9,16 +10,52 @@ export default function Login() {
   const [password, setPassword] = useState("");
   const [showPw, setShowPw] = useState(false);
   const [err, setErr] = useState("");
+  const [mfaToken, setMfaToken] = useState(""); // != "" => đang ở bước nhập mã 2FA
+  const [code, setCode] = useState("");
   const nav = useNavigate();
 
   async function onSubmit(e: React.FormEvent<HTMLFormElement>) {
     e.preventDefault();
     setErr("");
     try {
-      const u = await login(identifier, password);
+      if (mfaToken) {
+        const u = await loginMFA(mfaToken, code);
+        nav(u.role === "admin" ? "/admin" : "/app", { replace: true });
+        return;
+      }
+      const res = await login(identifier, password);
+      if ("mfaToken" in res) {
+        setMfaToken(res.mfaToken);
+        return;
+      }
+      nav(res.user.role === "admin" ? "/admin" : "/app", { replace: true });
+    } catch (e) {
+      // BE trả {"error","code"} cho tài khoản bị khoá / chưa kích hoạt
+      const msg = e instanceof Error ? e.message : "";
+      if (msg.includes("too_many_attempts")) setErr("Đăng nhập sai quá nhiều lần, vui lòng thử lại sau");
+      else if (msg.includes("rate_limited")) setErr("Bạn thao tác quá nhanh, vui lòng thử lại sau ít phút");
+      else if (msg.includes("invalid_mfa_code")) setErr("Mã xác thực không đúng");
+      else if (msg.includes("invalid_token")) {
+        setMfaToken("");
+        setCode("");
+        setErr("Phiên xác thực đã hết hạn, vui lòng đăng nhập lại");
+      } else if (msg.includes("account_banned")) setErr("Tài khoản đã bị khoá");
+      else if (msg.includes("account_inactive")) setErr("Tài khoản chưa được kích hoạt");
+      else if (msg.includes("email_not_verified")) setErr("Email chưa được xác thực, hãy kiểm tra hộp thư");
+      else setErr("Sai tài khoản hoặc mật khẩu");
+    }
+  }
+
+  async function onPasskey() {
+    setErr("");
+    try {
+      const u = await loginWithPasskey();
       nav(u.role === "admin" ? "/admin" : "/app", { replace: true });
-    } catch {
-      setErr("Sai tài khoản hoặc mật khẩu");
+    } catch (e) {
+      const msg = e instanceof Error ? e.message : "";
+      if (msg.includes("account_banned")) setErr("Tài khoản đã bị khoá");
+      else if (msg.includes("account_inactive")) setErr("Tài khoản chưa được kích hoạt");
+      else setErr("Đăng nhập bằng passkey không thành công");
     }
   }
 
@@ -40,6 +77,28 @@ export default function Login() {
           </div>
 
           {/* Form */}
+          {mfaToken ? (
+            <form onSubmit={onSubmit} className="space-y-4">
+              <div>
+                <label className="label">Mã xác thực 2 lớp</label>
+                <Input
+                  value={code}
+                  onChange={(e) => setCode(e.target.value)}
+                  placeholder="123456"
+                  autoComplete="one-time-code"
+                  autoFocus
+                  required
+                />
+                <p className="mt-1 text-xs text-gray-500">
+                  Nhập mã 6 số trong app authenticator hoặc một mã khôi phục.
+                </p>
+              </div>
+
+              {err && <p className="text-red-600 text-sm">{err}</p>}
+
+              <Button type="submit" className="w-full">Xác nhận</Button>
+            </form>
+          ) : (
           <form onSubmit={onSubmit} className="space-y-4">
             <div>
               <label className="label">Email / Username</label>
@@ -89,6 +148,22 @@ export default function Login() {
 
             <Button type="submit" className="w-full">Đăng nhập</Button>
 
+            {passkeySupported() && (
+              <button
+                type="button"
+                onClick={onPasskey}
+                className="w-full rounded-xl border border-gray-300 py-2 text-sm font-medium hover:bg-gray-50"
+              >
+                Đăng nhập bằng passkey
+              </button>
+            )}
+
+            <p className="text-sm text-center">
+              <Link className="text-indigo-600 hover:underline" to="/forgot-password">
+                Quên mật khẩu?
+              </Link>
+            </p>
+
             <p className="text-sm text-gray-500 text-center">
               Chưa có tài khoản?{" "}
               <Link className="text-indigo-600 hover:underline font-medium" to="/register">
@@ -96,6 +171,7 @@ export default function Login() {
               </Link>
             </p>
           </form>
+          )}
         </div>
       </div>
     </div>
@@ -28,6 +28,7 @@ export default function Register() {
 
     try {
       await apiRegister(email, pw, cf);
+      alert("Đăng ký thành công! Hãy mở link trong email để xác thực tài khoản.");
       nav("/login", { replace: true });
     } catch {
       setErr("Đăng ký thất bại. Vui lòng kiểm tra lại thông tin.");
@@ -0,0 +1,81 @@
+import { useState } from "react";
+import { Link, useNavigate, useSearchParams } from "react-router-dom";
+import { resetPassword } from "../../api/auth";
+import Button from "../../components/ui/Button";
+import Input from "../../components/ui/Input";
+
+export default function ResetPassword() {
+  const [params] = useSearchParams();
+  const token = params.get("token") ?? "";
+  const [pw, setPw] = useState<string>("");
+  const [cf, setCf] = useState<string>("");
+  const [err, setErr] = useState<string>("");
+  const nav = useNavigate();
+
+  async function onSubmit(e: React.FormEvent<HTMLFormElement>) {
+    e.preventDefault();
+    setErr("");
+    if (pw.length < 6) return setErr("Mật khẩu phải có ít nhất 6 ký tự.");
+    if (pw !== cf) return setErr("Xác nhận mật khẩu không khớp.");
+    try {
+      await resetPassword(token, pw);
+      nav("/login", { replace: true });
+    } catch {
+      setErr("Link đặt lại mật khẩu không hợp lệ hoặc đã hết hạn.");
+    }
+  }
+
+  return (
+    <div className="relative auth-bg">
+      <span className="auth-overlay" />
+      <div className="relative z-10 min-h-screen flex items-center justify-center p-4">
+        <div className="auth-card fade-in-up">
+          <div className="mb-6 text-center">
+            <h1 className="text-2xl font-semibold">Đặt lại mật khẩu</h1>
+          </div>
+
+          {!token ? (
+            <p className="text-red-600 text-sm text-center">Thiếu token trong link.</p>
+          ) : (
+            <form onSubmit={onSubmit} className="space-y-4">
+              <div>
+                <label className="label">Mật khẩu mới</label>
+                <Input
+                  type="password"
+                  autoComplete="new-password"
+                  value={pw}
+                  onChange={(e) => setPw(e.target.value)}
+                  placeholder="Tối thiểu 6 ký tự"
+                  minLength={6}
+                  required
+                />
+              </div>
+              <div>
+                <label className="label">Xác nhận mật khẩu</label>
+                <Input
+                  type="password"
+                  autoComplete="new-password"
+                  value={cf}
+                  onChange={(e) => setCf(e.target.value)}
+                  placeholder="Nhập lại mật khẩu"
+                  minLength={6}
+                  required
+                />
+              </div>
+
+              {err && <p className="text-red-600 text-sm">{err}</p>}
+
+              <Button type="submit" className="w-full">Đặt lại mật khẩu</Button>
+            </form>
+          )}
+
+          <p className="mt-4 text-sm text-gray-500 text-center">
+            <Link className="text-indigo-600 hover:underline font-medium" to="/login">
+              Quay lại đăng nhập
+            </Link>
+          </p>
+        </div>
+      </div>
+    </div>
+  );
+}
@@ -1,9 +1,34 @@
+import { useState } from "react";
+import { passkeySupported, registerPasskey } from "../../api/passkey";
+
 export default function Home(){
+  const [msg, setMsg] = useState("");
+
+  async function addPasskey() {
+    setMsg("");
+    try {
+      await registerPasskey(navigator.platform || "Passkey");
+      setMsg("Đã thêm passkey, lần sau có thể đăng nhập không cần mật khẩu");
+    } catch {
+      setMsg("Không thêm được passkey");
+    }
+  }
+
   return (
     <div className="min-h-[60vh] grid place-items-center">
       <div className="card p-8 text-center">
         <h2 className="text-xl font-semibold">Xin chào 👋</h2>
         <p className="text-gray-600 mt-1">Đây là giao diện người dùng.</p>
+        {passkeySupported() && (
+          <button
+            type="button"
+            onClick={addPasskey}
+            className="mt-4 rounded-xl border border-gray-300 px-4 py-2 text-sm font-medium hover:bg-gray-50"
+          >
+            Thêm passkey
+          </button>
+        )}
+        {msg && <p className="mt-2 text-sm text-gray-600">{msg}</p>}
       </div>
     </div>
   );
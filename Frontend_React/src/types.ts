@@ -1,4 +1,4 @@
-export type Role = "user" | "admin";
+export type Role = "user" | "admin" | string; // role tuỳ chỉnh tạo qua /admin/roles
 export type Status = "active" | "inactive" | "banned" | string;
 
 export interface User {
@@ -21,6 +21,17 @@ export interface User {
   role: Role;
   status?: Status;
 
+  last_login_at?: string;       // ISO string
+  email_verified_at?: string | null; // null = chưa xác thực email
   created_at?: string;          // ISO string
   updated_at?: string;
 }
+
+/** Envelope phân trang từ BE */
+export interface Page<T> {
+  items: T[];
+  total: number;
+  page: number;
+  limit: number;
+  next_cursor?: string;
+}